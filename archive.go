@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/luan/f1-telemetry/sink"
+)
+
+// archiveFilter selects sessions of the archive by the flags shared by the
+// sessions and laps commands.
+type archiveFilter struct {
+	track       *string
+	sessionType *string
+}
+
+func newArchiveFilter(flags *flag.FlagSet) archiveFilter {
+	return archiveFilter{
+		track:       flags.String("track", "", "only sessions on `track`, e.g. Monza"),
+		sessionType: flags.String("type", "", "only sessions of `type`: practice, qualifying or race"),
+	}
+}
+
+// where returns the conditions on the sessions table s, and their arguments.
+func (f archiveFilter) where() ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if *f.track != "" {
+		conds = append(conds, "s.track = ? COLLATE NOCASE")
+		args = append(args, *f.track)
+	}
+	if *f.sessionType != "" {
+		conds = append(conds, "s.session_type = ? COLLATE NOCASE")
+		args = append(args, *f.sessionType)
+	}
+	return conds, args
+}
+
+// sessionsMain runs the sessions command, which lists the sessions stored in
+// an archive written by the sqlite sink.
+func sessionsMain(args []string) {
+	flags := flag.NewFlagSet("sessions", flag.ExitOnError)
+	path := flags.String("db", sink.DefaultArchivePath, "archive `file` to read")
+	filter := newArchiveFilter(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s sessions [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	db := openArchive(*path)
+	defer db.Close()
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	conds, condArgs := filter.where()
+	if err := listSessions(w, db, whereClause(conds), condArgs); err != nil {
+		log.Fatal(err)
+	}
+	w.Flush()
+}
+
+func listSessions(w *tabwriter.Writer, db *sql.DB, where string, args []interface{}) error {
+	rows, err := db.Query(`
+		SELECT s.id, s.start_time, s.track, s.session_type, COUNT(l.lap), MIN(CASE WHEN l.valid THEN l.time END)
+		FROM sessions s LEFT JOIN laps l ON l.session_id = s.id`+
+		where+`
+		GROUP BY s.id ORDER BY s.start_time`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Fprintln(w, "SESSION\tSTART\tTRACK\tTYPE\tLAPS\tBEST")
+	for rows.Next() {
+		var id, start, track, sessionType string
+		var laps int
+		var best sql.NullFloat64
+		if err := rows.Scan(&id, &start, &track, &sessionType, &laps, &best); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", id, localTime(start), track, sessionType, laps, lapTime(best))
+	}
+	return rows.Err()
+}
+
+// lapsFlags are the flags of the laps command selecting and ordering laps.
+type lapsFlags struct {
+	filter  archiveFilter
+	session *string
+	under   *string
+	valid   *bool
+	cars    *bool
+	fastest *bool
+}
+
+func newLapsFlags(flags *flag.FlagSet) lapsFlags {
+	return lapsFlags{
+		filter:  newArchiveFilter(flags),
+		session: flags.String("session", "", "only the session with `ID`"),
+		under:   flags.String("under", "", "only laps faster than `time`, e.g. 1:21 or 80.5"),
+		valid:   flags.Bool("valid", false, "only valid laps of the player"),
+		cars:    flags.Bool("cars", false, "list the laps of every car in the race instead of the player's"),
+		fastest: flags.Bool("fastest", false, "list the fastest laps first"),
+	}
+}
+
+// query returns the WHERE and ORDER BY clauses selecting the laps, on the
+// sessions table s and the laps or car_laps table l, and their arguments.
+func (f lapsFlags) query() (string, []interface{}, error) {
+	conds, args := f.filter.where()
+	if *f.session != "" {
+		conds = append(conds, "s.id = ?")
+		args = append(args, *f.session)
+	}
+	if *f.under != "" {
+		t, err := parseLapTime(*f.under)
+		if err != nil {
+			return "", nil, err
+		}
+		conds = append(conds, "l.time < ?")
+		args = append(args, t)
+	}
+	if *f.valid {
+		if *f.cars {
+			return "", nil, errors.New("-valid only applies to the laps of the player")
+		}
+		conds = append(conds, "l.valid")
+	}
+	order := " ORDER BY s.start_time, l.lap"
+	if *f.cars {
+		order += ", l.position"
+	}
+	if *f.fastest {
+		order = " ORDER BY l.time"
+	}
+	return whereClause(conds) + order, args, nil
+}
+
+// lapsMain runs the laps command, which lists the laps stored in an archive
+// written by the sqlite sink: the player's, or every car's.
+func lapsMain(args []string) {
+	flags := flag.NewFlagSet("laps", flag.ExitOnError)
+	path := flags.String("db", sink.DefaultArchivePath, "archive `file` to read")
+	laps := newLapsFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s laps [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	query, queryArgs, err := laps.query()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := openArchive(*path)
+	defer db.Close()
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if *laps.cars {
+		err = listCarLaps(w, db, query, queryArgs)
+	} else {
+		err = listLaps(w, db, query, queryArgs)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.Flush()
+}
+
+func listLaps(w *tabwriter.Writer, db *sql.DB, query string, args []interface{}) error {
+	rows, err := db.Query(`
+		SELECT s.id, s.track, l.lap, l.time, l.sector1, l.sector2, l.sector3, l.valid, l.compound,
+			l.fuel_start, l.fuel_end,
+			MAX(l.wear_start_rl, l.wear_start_rr, l.wear_start_fl, l.wear_start_fr),
+			MAX(l.wear_end_rl, l.wear_end_rr, l.wear_end_fl, l.wear_end_fr)
+		FROM laps l JOIN sessions s ON s.id = l.session_id`+query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Fprintln(w, "SESSION\tTRACK\tLAP\tTIME\tS1\tS2\tS3\tVALID\tCOMPOUND\tFUEL\tWEAR")
+	for rows.Next() {
+		var id, track, compound string
+		var lap, wearStart, wearEnd int
+		var t, fuelStart, fuelEnd float64
+		var s1, s2, s3 sql.NullFloat64
+		var valid bool
+		if err := rows.Scan(&id, &track, &lap, &t, &s1, &s2, &s3, &valid, &compound, &fuelStart, &fuelEnd, &wearStart, &wearEnd); err != nil {
+			return err
+		}
+		validity := "yes"
+		if !valid {
+			validity = "no"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%.1f-%.1f kg\t%d-%d%%\n",
+			id, track, lap, formatLapTime(t), sectorTime(s1), sectorTime(s2), sectorTime(s3),
+			validity, compound, fuelStart, fuelEnd, wearStart, wearEnd)
+	}
+	return rows.Err()
+}
+
+func listCarLaps(w *tabwriter.Writer, db *sql.DB, query string, args []interface{}) error {
+	rows, err := db.Query(`
+		SELECT s.id, l.car, l.driver, l.team, l.lap, l.time, l.sector1, l.sector2, l.sector3, l.position, l.compound
+		FROM car_laps l JOIN sessions s ON s.id = l.session_id`+query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Fprintln(w, "SESSION\tCAR\tDRIVER\tTEAM\tLAP\tTIME\tS1\tS2\tS3\tPOS\tCOMPOUND")
+	for rows.Next() {
+		var id, driver, team, compound string
+		var car, lap, position int
+		var t float64
+		var s1, s2, s3 sql.NullFloat64
+		if err := rows.Scan(&id, &car, &driver, &team, &lap, &t, &s1, &s2, &s3, &position, &compound); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			id, car, driver, team, lap, formatLapTime(t), sectorTime(s1), sectorTime(s2), sectorTime(s3),
+			position, compound)
+	}
+	return rows.Err()
+}
+
+// openArchive opens the archive at path, which must exist.
+func openArchive(path string) *sql.DB {
+	if _, err := os.Stat(path); err != nil {
+		log.Fatal(err)
+	}
+	db, err := sink.OpenArchive(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// parseLapTime parses a lap time in seconds, or in minutes and seconds, such
+// as "1:21" or "1:20.5".
+func parseLapTime(s string) (float64, error) {
+	minutes := 0
+	seconds := s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		var err error
+		if minutes, err = strconv.Atoi(s[:i]); err != nil {
+			return 0, fmt.Errorf("invalid lap time %q", s)
+		}
+		seconds = s[i+1:]
+	}
+	t, err := strconv.ParseFloat(seconds, 64)
+	if err != nil || minutes < 0 || t < 0 {
+		return 0, fmt.Errorf("invalid lap time %q", s)
+	}
+	return float64(minutes)*60 + t, nil
+}
+
+func lapTime(t sql.NullFloat64) string {
+	if !t.Valid {
+		return "-"
+	}
+	return formatLapTime(t.Float64)
+}
+
+// formatLapTime formats a lap time in seconds as minutes, seconds and
+// milliseconds, e.g. "1:20.123".
+func formatLapTime(t float64) string {
+	ms := int64(math.Round(t * 1000))
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
+
+func sectorTime(t sql.NullFloat64) string {
+	if !t.Valid {
+		return "-"
+	}
+	return strconv.FormatFloat(t.Float64, 'f', 3, 64)
+}
+
+// localTime formats a time stored in the archive in the local time zone.
+func localTime(s string) string {
+	t, err := time.Parse(sink.ArchiveTimeFormat, s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
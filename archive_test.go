@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/luan/f1-telemetry/sink"
+)
+
+// testArchive returns an archive of a race at Monza, of laps 1:20.5 and an
+// invalid 1:20.9, and a qualifying at Spa, of a lap of 1:45.
+func testArchive(t *testing.T) (*sql.DB, func()) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sink.OpenArchive(filepath.Join(dir, "archive.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`INSERT INTO sessions (id, source, format, session_uid, start_time, track_number, track, session_type, era, total_laps)
+			VALUES ('race', 'test', 'F1 2018', 1, '2018-09-02T13:10:00.000Z', 11, 'Monza', 'Race', 2017, 53),
+			('quali', 'test', 'F1 2018', 2, '2018-08-25T14:00:00.000Z', 10, 'Spa', 'Q', 2017, 0)`,
+		`INSERT INTO laps VALUES
+			('race', 2, 80.5, 25, 28, 27.5, 1, 'soft', 10, 9, 1, 2, 3, 4, 5, 6, 7, 8),
+			('race', 3, 80.9, 26, 27, 27.9, 0, 'soft', 9, 8, 5, 6, 7, 8, 9, 10, 11, 12),
+			('quali', 2, 105, NULL, NULL, NULL, 1, 'medium', 5, 4, 0, 0, 0, 0, 1, 1, 2, 2)`,
+		`INSERT INTO car_laps VALUES
+			('race', 1, 2, 9, 'Lewis Hamilton', 4, 'Mercedes', 81.25, 25.5, 28.5, 27.25, 2, 'medium'),
+			('race', 5, 2, 7, 'Kimi Räikkönen', 1, 'Ferrari', 80.75, 25, 28.5, 27.25, 1, 'soft')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// listed runs list with a tabwriter, returning its lines with the columns
+// separated by single spaces, the header left out.
+func listed(t *testing.T, list func(w *tabwriter.Writer) error) []string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	if err := list(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")[1:]
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return lines
+}
+
+func TestListSessions(t *testing.T) {
+	db, done := testArchive(t)
+	defer done()
+
+	for _, test := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"quali Spa Q 1 1:45.000", "race Monza Race 2 1:20.500"}},
+		{[]string{"-track", "monza"}, []string{"race Monza Race 2 1:20.500"}},
+		{[]string{"-type", "Q"}, []string{"quali Spa Q 1 1:45.000"}},
+		{[]string{"-track", "Monza", "-type", "Q"}, nil},
+	} {
+		flags := flag.NewFlagSet("sessions", flag.ContinueOnError)
+		filter := newArchiveFilter(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		conds, args := filter.where()
+		got := listed(t, func(w *tabwriter.Writer) error {
+			return listSessions(w, db, whereClause(conds), args)
+		})
+		// Leave the start times out, shown in the local time zone.
+		for i, line := range got {
+			f := strings.Fields(line)
+			got[i] = strings.Join(append(f[:1], f[3:]...), " ")
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("sessions %q = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestListLaps(t *testing.T) {
+	db, done := testArchive(t)
+	defer done()
+
+	const (
+		race2 = "race Monza 2 1:20.500 25.000 28.000 27.500 yes soft 10.0-9.0 kg 4-8%"
+		race3 = "race Monza 3 1:20.900 26.000 27.000 27.900 no soft 9.0-8.0 kg 8-12%"
+		quali = "quali Spa 2 1:45.000 - - - yes medium 5.0-4.0 kg 0-2%"
+		car1  = "race 1 Lewis Hamilton Mercedes 2 1:21.250 25.500 28.500 27.250 2 medium"
+		car5  = "race 5 Kimi Räikkönen Ferrari 2 1:20.750 25.000 28.500 27.250 1 soft"
+	)
+	for _, test := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{quali, race2, race3}},
+		{[]string{"-fastest"}, []string{race2, race3, quali}},
+		{[]string{"-under", "1:21"}, []string{race2, race3}},
+		{[]string{"-under", "80.7"}, []string{race2}},
+		{[]string{"-valid"}, []string{quali, race2}},
+		{[]string{"-track", "spa"}, []string{quali}},
+		{[]string{"-session", "race", "-valid", "-under", "1:21"}, []string{race2}},
+		{[]string{"-cars"}, []string{car5, car1}},
+		{[]string{"-cars", "-under", "1:21"}, []string{car5}},
+		{[]string{"-cars", "-track", "spa"}, nil},
+	} {
+		flags := flag.NewFlagSet("laps", flag.ContinueOnError)
+		laps := newLapsFlags(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		query, args, err := laps.query()
+		if err != nil {
+			t.Fatalf("laps %q: %v", test.args, err)
+		}
+		list := listLaps
+		if *laps.cars {
+			list = listCarLaps
+		}
+		got := listed(t, func(w *tabwriter.Writer) error {
+			return list(w, db, query, args)
+		})
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("laps %q = %q, want %q", test.args, got, test.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-cars", "-valid"},
+		{"-under", "1:2x"},
+	} {
+		flags := flag.NewFlagSet("laps", flag.ContinueOnError)
+		laps := newLapsFlags(flags)
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := laps.query(); err == nil {
+			t.Errorf("laps %q: no error", args)
+		}
+	}
+}
+
+func TestParseLapTime(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want float64
+		ok   bool
+	}{
+		{"1:21", 81, true},
+		{"1:20.5", 80.5, true},
+		{"80.5", 80.5, true},
+		{"0:59.999", 59.999, true},
+		{"", 0, false},
+		{"1:", 0, false},
+		{":21", 0, false},
+		{"a:21", 0, false},
+		{"-1:21", 0, false},
+		{"1:-21", 0, false},
+		{"1:2:3", 0, false},
+	} {
+		got, err := parseLapTime(test.s)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseLapTime(%q) = %g, %v, want %g", test.s, got, err, test.want)
+		}
+	}
+	if s := formatLapTime(80.5); s != "1:20.500" {
+		t.Errorf("formatLapTime(80.5) = %q, want 1:20.500", s)
+	}
+}
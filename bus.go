@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// An OverflowPolicy tells a subscriber of the bus what to do with a frame
+// published while its buffer is full. Session events are never dropped.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the publisher wait for room, holding up the
+	// listeners and so every other subscriber too.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest frame buffered, so the subscriber
+	// keeps up with the latest frames.
+	OverflowDropOldest
+	// OverflowDropNewest drops the frame published, so the subscriber gets
+	// every frame up to the point it fell behind.
+	OverflowDropNewest
+	// OverflowSample drops every other frame buffered, so the subscriber
+	// gets a sample of the whole stretch it is behind on, at a lower rate.
+	OverflowSample
+)
+
+var overflowPolicies = map[OverflowPolicy]string{
+	OverflowBlock:      "block",
+	OverflowDropOldest: "drop-oldest",
+	OverflowDropNewest: "drop-newest",
+	OverflowSample:     "sample",
+}
+
+func (p OverflowPolicy) String() string {
+	return overflowPolicies[p]
+}
+
+// Set parses an overflow policy by name, for use as a flag.
+func (p *OverflowPolicy) Set(s string) error {
+	for policy, name := range overflowPolicies {
+		if name == s {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown overflow policy %q, want block, drop-oldest, drop-newest or sample", s)
+}
+
+// UnmarshalText parses an overflow policy by name, as in a configuration file.
+func (p *OverflowPolicy) UnmarshalText(b []byte) error {
+	return p.Set(string(b))
+}
+
+// A bus passes the frames and session events of the listeners on to its
+// subscribers, each with a buffer of its own, so a slow subscriber, such as
+// Influx stalling, doesn't hold up the others.
+type bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+}
+
+// subscribe adds a subscriber buffering up to size frames, at least 1, and
+// handling overflows with policy.
+func (b *bus) subscribe(name string, size int, policy OverflowPolicy) *subscriber {
+	if size < 1 {
+		size = 1
+	}
+	c := make(chan message)
+	s := &subscriber{
+		C:      c,
+		name:   name,
+		size:   size,
+		policy: policy,
+		c:      c,
+	}
+	s.changed = sync.NewCond(&s.mu)
+	go s.run()
+
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, s)
+	b.mu.Unlock()
+	return s
+}
+
+// publish sends m to every subscriber.
+func (b *bus) publish(m message) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subscribers {
+		s.send(m)
+	}
+}
+
+// close closes the channels of the subscribers once they have received the
+// messages buffered so far.
+func (b *bus) close() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subscribers {
+		s.close()
+	}
+}
+
+// list returns the subscribers, in the order they subscribed.
+func (b *bus) list() []*subscriber {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]*subscriber(nil), b.subscribers...)
+}
+
+// A subscriber receives the messages published on a bus from C.
+type subscriber struct {
+	// Accessed atomically, first in the struct to be 64-bit aligned.
+	delivered uint64
+	dropped   uint64
+
+	C <-chan message
+
+	name   string
+	size   int
+	policy OverflowPolicy
+	c      chan message
+	status func() string // of what reads C, if set before the first message
+
+	mu      sync.Mutex
+	changed *sync.Cond // signaled when the queue changes or is closed
+	queue   []message
+	frames  int // in queue
+	maxLag  int
+	closed  bool
+}
+
+func (s *subscriber) run() {
+	defer close(s.c)
+
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.changed.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		m := s.queue[0]
+		s.queue[0] = message{}
+		s.queue = s.queue[1:]
+		if m.event == nil {
+			s.frames--
+		}
+		s.changed.Broadcast()
+		s.mu.Unlock()
+
+		s.c <- m
+		atomic.AddUint64(&s.delivered, 1)
+	}
+}
+
+// send queues m, applying the overflow policy if it is a frame and the
+// buffer is full. Session events always go in.
+func (s *subscriber) send(m message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	if m.event == nil {
+		if s.frames >= s.size {
+			switch s.policy {
+			case OverflowBlock:
+				for s.frames >= s.size && !s.closed {
+					s.changed.Wait()
+				}
+				if s.closed {
+					return
+				}
+			case OverflowDropOldest:
+				s.dropOldest()
+			case OverflowDropNewest:
+				atomic.AddUint64(&s.dropped, 1)
+				return
+			case OverflowSample:
+				s.thin()
+			}
+		}
+		s.frames++
+	}
+
+	s.queue = append(s.queue, m)
+	if len(s.queue) > s.maxLag {
+		s.maxLag = len(s.queue)
+	}
+	s.changed.Broadcast()
+}
+
+// dropOldest drops the oldest frame queued.
+func (s *subscriber) dropOldest() {
+	for i, m := range s.queue {
+		if m.event == nil {
+			s.remove(func(j int, _ message) bool { return j == i })
+			return
+		}
+	}
+}
+
+// thin drops every other frame queued, starting with the second one.
+func (s *subscriber) thin() {
+	frame := 0
+	s.remove(func(_ int, m message) bool {
+		if m.event != nil {
+			return false
+		}
+		frame++
+		return frame%2 == 0
+	})
+}
+
+// remove removes the queued frames for which drop, given their index in the
+// queue, returns true, counting them as dropped.
+func (s *subscriber) remove(drop func(i int, m message) bool) {
+	n := 0
+	for i, m := range s.queue {
+		if drop(i, m) {
+			atomic.AddUint64(&s.dropped, 1)
+			s.frames--
+			continue
+		}
+		s.queue[n] = m
+		n++
+	}
+	for i := n; i < len(s.queue); i++ {
+		s.queue[i] = message{}
+	}
+	s.queue = s.queue[:n]
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.changed.Broadcast()
+}
+
+func (s *subscriber) String() string {
+	s.mu.Lock()
+	lag, maxLag := len(s.queue), s.maxLag
+	s.mu.Unlock()
+	return fmt.Sprintf("%s: %d delivered, %d dropped, %d behind (max %d)", s.name,
+		atomic.LoadUint64(&s.delivered), atomic.LoadUint64(&s.dropped), lag, maxLag)
+}
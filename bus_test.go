@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// testMessage returns a frame told apart by its time n.
+func testMessage(n int) message {
+	return message{frame: f1.Frame{Time: float32(n)}}
+}
+
+// received reads what s is sent until its channel is closed, frames by their
+// time and session events as -1.
+func received(s *subscriber) []int {
+	var got []int
+	for m := range s.C {
+		if m.event != nil {
+			got = append(got, -1)
+			continue
+		}
+		got = append(got, int(m.frame.Time))
+	}
+	return got
+}
+
+// stall waits until s has taken the first message off its queue, to send it
+// to a reader that isn't there yet.
+func stall(t *testing.T, s *subscriber) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.queue)
+		s.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("message never taken off the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestBusOverflow publishes frames 1 to 6, with an event after 3, to a
+// subscriber with room for 3 stalled on frame 0.
+func TestBusOverflow(t *testing.T) {
+	for _, test := range []struct {
+		policy  OverflowPolicy
+		want    []int
+		dropped int
+	}{
+		{OverflowDropOldest, []int{0, -1, 4, 5, 6}, 3},
+		{OverflowDropNewest, []int{0, 1, 2, 3, -1}, 3},
+		{OverflowSample, []int{0, 1, -1, 5, 6}, 3},
+		{OverflowBlock, []int{0, 1, 2, 3, -1, 4, 5, 6}, 0},
+	} {
+		var b bus
+		s := b.subscribe("test", 3, test.policy)
+		b.publish(testMessage(0))
+		stall(t, s)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for n := 1; n <= 6; n++ {
+				b.publish(testMessage(n))
+				if n == 3 {
+					b.publish(message{event: &f1.SessionEvent{Type: f1.SessionStart}})
+				}
+			}
+		}()
+		if test.policy == OverflowBlock {
+			select {
+			case <-done:
+				t.Fatal("block: publishing to a full subscriber didn't wait")
+			case <-time.After(50 * time.Millisecond):
+			}
+			// Reading makes room.
+			go func() {
+				<-done
+				b.close()
+			}()
+		} else {
+			<-done
+			b.close()
+		}
+		got := received(s)
+
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: received %v, want %v", test.policy, got, test.want)
+		}
+		want := fmt.Sprintf("test: %d delivered, %d dropped, 0 behind", len(test.want), test.dropped)
+		if status := s.String(); status[:len(want)] != want {
+			t.Errorf("%s: %q, want %q", test.policy, status, want)
+		}
+	}
+}
+
+// TestBusSlowSubscriber checks a subscriber falling behind doesn't hold up
+// the others.
+func TestBusSlowSubscriber(t *testing.T) {
+	var b bus
+	slow := b.subscribe("slow", 4, OverflowDropOldest)
+	fast := b.subscribe("fast", 1, OverflowBlock)
+	fastDone := make(chan []int)
+	go func() { fastDone <- received(fast) }()
+
+	const frames = 1000
+	for n := 0; n < frames; n++ {
+		b.publish(testMessage(n))
+	}
+	b.close()
+	got := <-fastDone
+	if len(got) != frames || got[0] != 0 || got[frames-1] != frames-1 {
+		t.Fatalf("fast subscriber received %d frames, want all %d", len(got), frames)
+	}
+
+	// Only read now, the slow subscriber gets the first frame and the last
+	// ones, in order.
+	got = received(slow)
+	if len(got) > 5 || got[len(got)-1] != frames-1 {
+		t.Fatalf("slow subscriber received %v, want the last 4 frames", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Fatalf("slow subscriber received %v, out of order", got)
+		}
+	}
+	if slow.dropped == 0 || fast.dropped != 0 {
+		t.Fatalf("%v, %v", slow, fast)
+	}
+}
+
+// TestBusCloseBlocked checks closing the bus releases a publisher waiting
+// for room.
+func TestBusCloseBlocked(t *testing.T) {
+	var b bus
+	s := b.subscribe("test", 1, OverflowBlock)
+	b.publish(testMessage(0))
+	stall(t, s)
+	b.publish(testMessage(1))
+
+	done := make(chan struct{})
+	go func() {
+		b.publish(testMessage(2))
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	b.close()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("publish still waiting once closed")
+	}
+	if got := received(s); fmt.Sprint(got) != "[0 1]" {
+		t.Fatalf("received %v, want the frames published before closing", got)
+	}
+}
+
+func TestOverflowPolicySet(t *testing.T) {
+	for policy, name := range overflowPolicies {
+		var p OverflowPolicy
+		if err := p.Set(name); err != nil || p != policy {
+			t.Errorf("Set(%q) = %v, %v", name, p, err)
+		}
+	}
+	var p OverflowPolicy
+	if err := p.UnmarshalText([]byte("drop")); err == nil {
+		t.Error("UnmarshalText(drop) succeeded")
+	}
+}
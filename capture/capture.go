@@ -0,0 +1,268 @@
+// Package capture reads the UDP datagrams sent to a port out of packet
+// captures in the pcap and pcapng formats, as written by tcpdump and
+// Wireshark, along with the time they were captured.
+//
+// Ethernet, Linux cooked, loopback and raw IP captures are supported. Only
+// datagrams captured whole can be read: those cut short by the snapshot
+// length of the capture or fragmented by IP are skipped.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/luan/f1-telemetry/recording"
+)
+
+// ErrNotCapture is returned when reading a file that is not a packet capture.
+var ErrNotCapture = errors.New("capture: not a pcap or pcapng file")
+
+// errIncomplete is returned by datagram for datagrams that weren't captured
+// whole.
+var errIncomplete = errors.New("capture: datagram not captured whole")
+
+// maxPacket caps the size of a captured packet, so a corrupt length doesn't
+// allocate gigabytes.
+const maxPacket = 1 << 20
+
+// Link types, see https://www.tcpdump.org/linktypes.html.
+const (
+	linkNull      = 0
+	linkEthernet  = 1
+	linkRawBSD    = 12 // DLT_RAW on some BSDs
+	linkRawOpen   = 14 // DLT_RAW on OpenBSD
+	linkRaw       = 101
+	linkLoop      = 108
+	linkLinuxSLL  = 113
+	linkIPv4      = 228
+	linkIPv6      = 229
+	linkLinuxSLL2 = 276
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+
+	protocolUDP = 17
+)
+
+// A packet is one packet of a capture, starting with its link layer header.
+type packet struct {
+	time time.Time
+	link uint32
+	data []byte
+}
+
+// A packetReader reads the packets of one capture file format. The data of a
+// packet is only valid until the next call to next.
+type packetReader interface {
+	next() (packet, error)
+}
+
+// A Reader reads the datagrams sent to a port out of a packet capture.
+type Reader struct {
+	// Skipped counts the datagrams sent to the port that were not captured
+	// whole, so could not be read.
+	Skipped int
+
+	port uint16
+	r    packetReader
+}
+
+// NewReader reads the file header of the capture in r, which may be a pcap
+// or pcapng file. The Reader returns the datagrams sent to port, or to any
+// port if it is 0.
+func NewReader(r io.Reader, port uint16) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, notCapture(err)
+	}
+
+	var pr packetReader
+	if binary.BigEndian.Uint32(magic) == pcapngSectionHeader {
+		pr, err = newPcapng(br)
+	} else {
+		pr, err = newPcap(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{port: port, r: pr}, nil
+}
+
+// Next returns the next datagram sent to the port, as a record of the time it
+// was captured. Its Data is only valid until the next call to Next. At the
+// end of the capture, including after a packet cut short, Next returns
+// io.EOF.
+func (r *Reader) Next() (recording.Record, error) {
+	for {
+		p, err := r.r.next()
+		if err != nil {
+			return recording.Record{}, err
+		}
+		data, err := r.datagram(p.link, p.data)
+		if err == errIncomplete {
+			r.Skipped++
+			continue
+		}
+		if data != nil {
+			return recording.Record{Time: p.time, Data: data}, nil
+		}
+	}
+}
+
+// datagram returns the payload of the UDP datagram to the port in packet b of
+// the link type link. It returns nil if b holds anything else.
+func (r *Reader) datagram(link uint32, b []byte) ([]byte, error) {
+	var udp []byte
+	var whole bool
+	switch etherType, b := network(link, b); etherType {
+	case etherTypeIPv4:
+		udp, whole = ipv4(b)
+	case etherTypeIPv6:
+		udp, whole = ipv6(b)
+	}
+	if len(udp) < 8 {
+		return nil, nil
+	}
+
+	if port := binary.BigEndian.Uint16(udp[2:]); r.port != 0 && port != r.port {
+		return nil, nil
+	}
+	n := int(binary.BigEndian.Uint16(udp[4:]))
+	if !whole || n < 8 || n > len(udp) {
+		return nil, errIncomplete
+	}
+	return udp[8:n], nil
+}
+
+// network strips the link layer header off packet b, returning the ether type
+// of the network layer packet it holds.
+func network(link uint32, b []byte) (uint16, []byte) {
+	switch link {
+	case linkEthernet:
+		if len(b) < 14 {
+			return 0, nil
+		}
+		etherType, b := binary.BigEndian.Uint16(b[12:]), b[14:]
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(b) >= 4 {
+			etherType, b = binary.BigEndian.Uint16(b[2:]), b[4:]
+		}
+		return etherType, b
+	case linkLinuxSLL:
+		if len(b) < 16 {
+			return 0, nil
+		}
+		return binary.BigEndian.Uint16(b[14:]), b[16:]
+	case linkLinuxSLL2:
+		if len(b) < 20 {
+			return 0, nil
+		}
+		return binary.BigEndian.Uint16(b[0:]), b[20:]
+	case linkNull, linkLoop:
+		// The address family, in the byte order of the capturing host for
+		// linkNull and big endian for linkLoop.
+		if len(b) < 4 {
+			return 0, nil
+		}
+		family := binary.LittleEndian.Uint32(b)
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(b)
+		}
+		switch family {
+		case 2:
+			return etherTypeIPv4, b[4:]
+		case 24, 28, 30: // AF_INET6 on the BSDs, macOS and Linux
+			return etherTypeIPv6, b[4:]
+		}
+	case linkRaw, linkRawBSD, linkRawOpen, linkIPv4, linkIPv6:
+		if len(b) == 0 {
+			return 0, nil
+		}
+		switch b[0] >> 4 {
+		case 4:
+			return etherTypeIPv4, b
+		case 6:
+			return etherTypeIPv6, b
+		}
+	}
+	return 0, nil
+}
+
+// ipv4 returns the UDP datagram in IPv4 packet b, or nil if it holds none.
+// whole reports whether the datagram is not fragmented.
+func ipv4(b []byte) (udp []byte, whole bool) {
+	if len(b) < 20 || b[9] != protocolUDP {
+		return nil, false
+	}
+	n := int(b[0]&0x0f) * 4
+	if n < 20 || n > len(b) {
+		return nil, false
+	}
+	fragment := binary.BigEndian.Uint16(b[6:])
+	if fragment&0x1fff != 0 {
+		// Only the first fragment starts with the UDP header.
+		return nil, false
+	}
+	// Trim the padding of short Ethernet frames. Captures of offloaded
+	// packets may have no total length.
+	if total := int(binary.BigEndian.Uint16(b[2:])); total >= n && total <= len(b) {
+		b = b[:total]
+	}
+	return b[n:], fragment&0x2000 == 0
+}
+
+// ipv6 returns the UDP datagram in IPv6 packet b, or nil if it holds none.
+// whole reports whether the datagram is not fragmented.
+func ipv6(b []byte) (udp []byte, whole bool) {
+	if len(b) < 40 {
+		return nil, false
+	}
+	next := b[6]
+	if n := int(binary.BigEndian.Uint16(b[4:])); n != 0 && 40+n <= len(b) {
+		b = b[:40+n]
+	}
+	b, whole = b[40:], true
+	for {
+		switch next {
+		case protocolUDP:
+			return b, whole
+		case 0, 43, 60: // hop-by-hop, routing and destination options
+			if len(b) < 8 || len(b) < (int(b[1])+1)*8 {
+				return nil, false
+			}
+			next, b = b[0], b[(int(b[1])+1)*8:]
+		case 44: // fragment
+			if len(b) < 8 || binary.BigEndian.Uint16(b[2:])&0xfff8 != 0 {
+				return nil, false
+			}
+			whole = whole && b[3]&1 == 0
+			next, b = b[0], b[8:]
+		default:
+			return nil, false
+		}
+	}
+}
+
+// notCapture turns the error of reading the header of a file too short to be
+// a capture into ErrNotCapture.
+func notCapture(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrNotCapture
+	}
+	return err
+}
+
+// eof turns the error of reading a packet cut short into io.EOF.
+func eof(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
@@ -0,0 +1,405 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the captures in testdata")
+
+const testPort = 20777
+
+// udp returns a UDP datagram from port 50000 to port dst.
+func udp(dst uint16, payload string) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(b, 50000)
+	binary.BigEndian.PutUint16(b[2:], dst)
+	binary.BigEndian.PutUint16(b[4:], uint16(8+len(payload)))
+	return append(b, payload...)
+}
+
+// ipv4Packet returns an IPv4 packet of protocol from 10.0.0.2 to 10.0.0.1,
+// with fragment as its flags and fragment offset.
+func ipv4Packet(protocol byte, fragment uint16, payload []byte) []byte {
+	b := make([]byte, 20, 20+len(payload))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:], uint16(20+len(payload)))
+	binary.BigEndian.PutUint16(b[6:], fragment)
+	b[8], b[9] = 64, protocol
+	copy(b[12:], []byte{10, 0, 0, 2, 10, 0, 0, 1})
+	return append(b, payload...)
+}
+
+// ipv6Packet returns an IPv6 packet from fe80::2 to fe80::1, its payload
+// starting with the header next.
+func ipv6Packet(next byte, payload []byte) []byte {
+	b := make([]byte, 40, 40+len(payload))
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:], uint16(len(payload)))
+	b[6], b[7] = next, 64
+	b[8], b[9], b[23] = 0xfe, 0x80, 2
+	b[24], b[25], b[39] = 0xfe, 0x80, 1
+	return append(b, payload...)
+}
+
+// ipv6Fragment returns an IPv6 fragment header followed by payload, of the
+// fragment at offset, with more fragments following it if more.
+func ipv6Fragment(offset uint16, more bool, payload []byte) []byte {
+	b := []byte{protocolUDP, 0, 0, 0, 0, 0, 0, 1}
+	binary.BigEndian.PutUint16(b[2:], offset<<3)
+	if more {
+		b[3] |= 1
+	}
+	return append(b, payload...)
+}
+
+// ethernet returns an Ethernet frame of etherType, with a VLAN tag of each
+// of the tag protocols given.
+func ethernet(etherType uint16, payload []byte, tags ...uint16) []byte {
+	b := []byte{2, 0, 0, 0, 0, 1, 2, 0, 0, 0, 0, 2}
+	for i, tag := range tags {
+		b = append(b, byte(tag>>8), byte(tag), 0, byte(10+i))
+	}
+	b = append(b, byte(etherType>>8), byte(etherType))
+	return append(b, payload...)
+}
+
+// A testPacket is a packet of a capture, of orig bytes if more than were
+// captured.
+type testPacket struct {
+	time time.Time
+	data []byte
+	orig int
+}
+
+func (p testPacket) origLen() uint32 {
+	if p.orig > len(p.data) {
+		return uint32(p.orig)
+	}
+	return uint32(len(p.data))
+}
+
+// pcapFile returns a pcap capture of link type link, with microsecond
+// timestamps, or nanosecond ones if nano.
+func pcapFile(order binary.ByteOrder, nano bool, link uint32, packets ...testPacket) []byte {
+	b := make([]byte, 24)
+	magic := uint32(0xa1b2c3d4)
+	if nano {
+		magic = 0xa1b23c4d
+	}
+	order.PutUint32(b, magic)
+	order.PutUint16(b[4:], 2)
+	order.PutUint16(b[6:], 4)
+	order.PutUint32(b[16:], 65535)
+	order.PutUint32(b[20:], link)
+	for _, p := range packets {
+		h := make([]byte, 16)
+		frac := p.time.Nanosecond()
+		if !nano {
+			frac /= 1000
+		}
+		order.PutUint32(h, uint32(p.time.Unix()))
+		order.PutUint32(h[4:], uint32(frac))
+		order.PutUint32(h[8:], uint32(len(p.data)))
+		order.PutUint32(h[12:], p.origLen())
+		b = append(append(b, h...), p.data...)
+	}
+	return b
+}
+
+// pcapngBlock returns a block of type typ, its body padded to 32 bits.
+func pcapngBlock(order binary.ByteOrder, typ uint32, body ...[]byte) []byte {
+	b := make([]byte, 8)
+	for _, part := range body {
+		b = append(b, part...)
+	}
+	b = append(b, make([]byte, -len(b)&3+4)...)
+	order.PutUint32(b, typ)
+	order.PutUint32(b[4:], uint32(len(b)))
+	order.PutUint32(b[len(b)-4:], uint32(len(b)))
+	return b
+}
+
+func u16(order binary.ByteOrder, v uint16) []byte {
+	b := make([]byte, 2)
+	order.PutUint16(b, v)
+	return b
+}
+
+func u32(order binary.ByteOrder, v uint32) []byte {
+	b := make([]byte, 4)
+	order.PutUint32(b, v)
+	return b
+}
+
+func u64(order binary.ByteOrder, v uint64) []byte {
+	b := make([]byte, 8)
+	order.PutUint64(b, v)
+	return b
+}
+
+// pcapngSection returns a section header block of the given byte order,
+// of unknown length.
+func pcapngSection(order binary.ByteOrder) []byte {
+	return pcapngBlock(order, pcapngSectionHeader, u32(order, pcapngByteOrderMagic), u16(order, 1), u16(order, 0), u64(order, ^uint64(0)))
+}
+
+// pcapngOption returns an option of code with value, padded to 32 bits.
+func pcapngOption(order binary.ByteOrder, code uint16, value []byte) []byte {
+	b := append(u16(order, code), u16(order, uint16(len(value)))...)
+	b = append(b, value...)
+	return append(b, make([]byte, -len(b)&3)...)
+}
+
+// pcapngInterfaceBlock returns an interface description block of link type
+// link and snapshot length snaplen, with options.
+func pcapngInterfaceBlock(order binary.ByteOrder, link uint16, snaplen uint32, options ...[]byte) []byte {
+	body := [][]byte{u16(order, link), u16(order, 0), u32(order, snaplen)}
+	if len(options) > 0 {
+		body = append(body, options...)
+		body = append(body, pcapngOption(order, pcapngOptionEnd, nil))
+	}
+	return pcapngBlock(order, pcapngInterface, body...)
+}
+
+// pcapngEnhancedPacketBlock returns an enhanced packet block of p captured
+// on interface iface, with timestamp ts in the units of the interface.
+func pcapngEnhancedPacketBlock(order binary.ByteOrder, iface uint32, ts uint64, p testPacket) []byte {
+	return pcapngBlock(order, pcapngEnhancedPacket, u32(order, iface), u32(order, uint32(ts>>32)), u32(order, uint32(ts)),
+		u32(order, uint32(len(p.data))), u32(order, p.origLen()), p.data)
+}
+
+// pcapngSimplePacketBlock returns a simple packet block of p, which holds as
+// much of it as the snapshot length of the first interface allows.
+func pcapngSimplePacketBlock(order binary.ByteOrder, p testPacket) []byte {
+	return pcapngBlock(order, pcapngSimplePacket, u32(order, p.origLen()), p.data)
+}
+
+// A testRecord is a datagram expected out of a capture.
+type testRecord struct {
+	time time.Time
+	data string
+}
+
+var (
+	testTime = time.Unix(1500000000, 123456000)
+	nanoTime = time.Unix(1500000000, 123456789)
+)
+
+// at returns the time ms milliseconds after testTime.
+func at(ms int) time.Time {
+	return testTime.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// testCaptures are the captures in testdata, built by hand, and the
+// datagrams to testPort read out of them.
+var testCaptures = []struct {
+	name    string
+	build   func() []byte
+	want    []testRecord
+	skipped int
+}{
+	{
+		// Little endian, microsecond timestamps, Ethernet.
+		name: "ethernet.pcap",
+		build: func() []byte {
+			order := binary.LittleEndian
+			return pcapFile(order, false, linkEthernet,
+				testPacket{at(0), ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "ipv4"))), 0},
+				testPacket{at(1), ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "vlan")), etherTypeVLAN), 0},
+				testPacket{at(2), ethernet(etherTypeIPv6, ipv6Packet(protocolUDP, udp(testPort, "qinq ipv6")), etherTypeQinQ, etherTypeVLAN), 0},
+				// TCP, ARP and a datagram to another port.
+				testPacket{at(3), ethernet(etherTypeIPv4, ipv4Packet(6, 0, udp(testPort, "tcp"))), 0},
+				testPacket{at(4), ethernet(0x0806, make([]byte, 28)), 0},
+				testPacket{at(5), ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(53, "dns"))), 0},
+				// The fragments of a datagram, skipped.
+				testPacket{at(6), ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0x2000, udp(testPort, "first fragment"))), 0},
+				testPacket{at(7), ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 3, []byte("last fragment"))), 0},
+				// Padded to the shortest Ethernet frame.
+				testPacket{at(8), append(ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "pad"))), make([]byte, 15)...), 0},
+				// Cut short by the snapshot length, skipped.
+				testPacket{at(9), ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "cut short")))[:50], 51},
+				testPacket{at(10), ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "last"))), 0})
+		},
+		want:    []testRecord{{at(0), "ipv4"}, {at(1), "vlan"}, {at(2), "qinq ipv6"}, {at(8), "pad"}, {at(10), "last"}},
+		skipped: 2,
+	},
+	{
+		// Big endian, nanosecond timestamps, raw IP.
+		name: "raw-nsec-be.pcap",
+		build: func() []byte {
+			order := binary.BigEndian
+			hopByHop := append([]byte{protocolUDP, 0, 1, 4, 0, 0, 0, 0}, udp(testPort, "hop-by-hop")...)
+			return pcapFile(order, true, linkRaw,
+				testPacket{nanoTime, ipv4Packet(protocolUDP, 0, udp(testPort, "ipv4")), 0},
+				testPacket{nanoTime.Add(1), ipv6Packet(0, hopByHop), 0},
+				testPacket{nanoTime.Add(2), ipv6Packet(44, ipv6Fragment(0, false, udp(testPort, "atomic fragment"))), 0},
+				testPacket{nanoTime.Add(3), ipv6Packet(44, ipv6Fragment(0, true, udp(testPort, "first fragment"))), 0},
+				testPacket{nanoTime.Add(4), ipv6Packet(44, ipv6Fragment(2, false, []byte("last fragment"))), 0},
+				testPacket{nanoTime.Add(5), ipv6Packet(6, udp(testPort, "tcp")), 0})
+		},
+		want:    []testRecord{{nanoTime, "ipv4"}, {nanoTime.Add(1), "hop-by-hop"}, {nanoTime.Add(2), "atomic fragment"}},
+		skipped: 1,
+	},
+	{
+		// Little endian, an Ethernet interface with microsecond timestamps
+		// and a raw IP one with nanosecond ones.
+		name: "le.pcapng",
+		build: func() []byte {
+			order := binary.LittleEndian
+			usec := uint64(at(0).UnixNano() / 1000)
+			nsec := uint64(nanoTime.Add(time.Second).UnixNano())
+			var b []byte
+			for _, block := range [][]byte{
+				pcapngSection(order),
+				pcapngInterfaceBlock(order, linkEthernet, 0),
+				pcapngInterfaceBlock(order, linkRaw, 65535, pcapngOption(order, 2, []byte("eth0")), pcapngOption(order, pcapngOptionTSResol, []byte{9})),
+				pcapngEnhancedPacketBlock(order, 0, usec, testPacket{data: ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "enhanced")))}),
+				// Blocks of other types, skipped.
+				pcapngBlock(order, 4, u16(order, 0), u16(order, 0)),
+				pcapngBlock(order, 0x0bad, []byte("custom")),
+				pcapngEnhancedPacketBlock(order, 1, nsec, testPacket{data: ipv6Packet(protocolUDP, udp(testPort, "ipv6 nsec"))}),
+				// No timestamp, so at the time of the packet before.
+				pcapngSimplePacketBlock(order, testPacket{data: ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "simple")), etherTypeVLAN)}),
+				pcapngSimplePacketBlock(order, testPacket{data: ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(53, "dns")))}),
+				pcapngEnhancedPacketBlock(order, 0, usec+1000, testPacket{data: ethernet(etherTypeIPv4, ipv4Packet(6, 0, udp(testPort, "tcp")))}),
+			} {
+				b = append(b, block...)
+			}
+			return b
+		},
+		want: []testRecord{{at(0), "enhanced"}, {nanoTime.Add(time.Second), "ipv6 nsec"}, {nanoTime.Add(time.Second), "simple"}},
+	},
+	{
+		// A big endian section, with binary timestamps from an offset and a
+		// snapshot length of 64, then a little endian one.
+		name: "sections.pcapng",
+		build: func() []byte {
+			be, le := binary.BigEndian, binary.LittleEndian
+			long := ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "longer than the snapshot length")))
+			var b []byte
+			for _, block := range [][]byte{
+				pcapngSection(be),
+				pcapngInterfaceBlock(be, linkEthernet, 64,
+					pcapngOption(be, pcapngOptionTSResol, []byte{0x80 | 20}),
+					pcapngOption(be, pcapngOptionTSOffset, u64(be, 1500000000))),
+				pcapngEnhancedPacketBlock(be, 0, 3<<20|1<<19, testPacket{data: ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "big endian")))}),
+				pcapngSimplePacketBlock(be, testPacket{data: long[:64], orig: len(long)}),
+				pcapngSection(le),
+				pcapngInterfaceBlock(le, linkEthernet, 0),
+				pcapngEnhancedPacketBlock(le, 0, uint64(at(0).UnixNano()/1000), testPacket{data: ethernet(etherTypeIPv4, ipv4Packet(protocolUDP, 0, udp(testPort, "little endian")))}),
+			} {
+				b = append(b, block...)
+			}
+			return b
+		},
+		want:    []testRecord{{time.Unix(1500000003, 500000000), "big endian"}, {at(0), "little endian"}},
+		skipped: 1,
+	},
+}
+
+// readAll returns the records read out of capture b, and how many datagrams
+// were skipped.
+func readAll(t *testing.T, b []byte, port uint16) ([]testRecord, int) {
+	r, err := NewReader(bytes.NewReader(b), port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []testRecord
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, r.Skipped
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, testRecord{record.Time, string(record.Data)})
+	}
+}
+
+func checkRecords(t *testing.T, name string, got, want []testRecord) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: read %v, want %v", name, got, want)
+	}
+	for i := range got {
+		if got[i].data != want[i].data || !got[i].time.Equal(want[i].time) {
+			t.Errorf("%s: read %q at %v, want %q at %v", name, got[i].data, got[i].time, want[i].data, want[i].time)
+		}
+	}
+}
+
+func TestCaptures(t *testing.T) {
+	for _, c := range testCaptures {
+		path := filepath.Join("testdata", c.name)
+		if *update {
+			if err := ioutil.WriteFile(path, c.build(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, c.build()) {
+			t.Errorf("%s differs from the capture built, run go test -update", c.name)
+		}
+
+		got, skipped := readAll(t, b, testPort)
+		checkRecords(t, c.name, got, c.want)
+		if skipped != c.skipped {
+			t.Errorf("%s: %d skipped, want %d", c.name, skipped, c.skipped)
+		}
+	}
+}
+
+func TestCaptureAnyPort(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "ethernet.pcap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := readAll(t, b, 0)
+	checkRecords(t, "ethernet.pcap", got,
+		[]testRecord{{at(0), "ipv4"}, {at(1), "vlan"}, {at(2), "qinq ipv6"}, {at(5), "dns"}, {at(8), "pad"}, {at(10), "last"}})
+}
+
+// TestCaptureCutShort checks a capture ending halfway through a packet, as
+// one still being written, ends before it.
+func TestCaptureCutShort(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		lost int // datagrams in the last packet
+	}{
+		{"ethernet.pcap", 1},
+		{"le.pcapng", 0},
+	} {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", test.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, _ := readAll(t, b, 0)
+		got, _ := readAll(t, b[:len(b)-10], 0)
+		checkRecords(t, test.name+" cut short", got, all[:len(all)-test.lost])
+	}
+}
+
+func TestNotCapture(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		[]byte("ab"),
+		[]byte("not a capture, but long enough to be one"),
+		pcapFile(binary.LittleEndian, false, linkEthernet)[:20],
+		pcapngBlock(binary.LittleEndian, 1, make([]byte, 16)),
+	} {
+		if _, err := NewReader(bytes.NewReader(b), testPort); err != ErrNotCapture {
+			t.Errorf("NewReader(%q) = %v, want ErrNotCapture", b, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// pcapReader reads the classic pcap format, which has a single link type and
+// microsecond or nanosecond timestamps.
+type pcapReader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	nano  bool
+	link  uint32
+	buf   []byte
+}
+
+func newPcap(r *bufio.Reader) (*pcapReader, error) {
+	var h [24]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, notCapture(err)
+	}
+
+	p := &pcapReader{r: r}
+	switch binary.LittleEndian.Uint32(h[:]) {
+	case 0xa1b2c3d4:
+		p.order = binary.LittleEndian
+	case 0xa1b23c4d:
+		p.order, p.nano = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		p.order = binary.BigEndian
+	case 0x4d3cb2a1:
+		p.order, p.nano = binary.BigEndian, true
+	default:
+		return nil, ErrNotCapture
+	}
+	// The upper bits hold whether frames end in a check sequence.
+	p.link = p.order.Uint32(h[20:]) & 0xffff
+	return p, nil
+}
+
+func (p *pcapReader) next() (packet, error) {
+	var h [16]byte
+	if _, err := io.ReadFull(p.r, h[:]); err != nil {
+		return packet{}, eof(err)
+	}
+	n := p.order.Uint32(h[8:])
+	if n > maxPacket {
+		return packet{}, errors.New("capture: packet too long")
+	}
+	if int(n) > cap(p.buf) {
+		p.buf = make([]byte, n)
+	}
+	data := p.buf[:n]
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return packet{}, eof(err)
+	}
+
+	frac := int64(p.order.Uint32(h[4:]))
+	if !p.nano {
+		frac *= int64(time.Microsecond)
+	}
+	return packet{
+		time: time.Unix(int64(p.order.Uint32(h[0:])), frac),
+		link: p.link,
+		data: data,
+	}, nil
+}
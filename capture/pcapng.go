@@ -0,0 +1,222 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// pcapng block types, see https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng.
+const (
+	pcapngSectionHeader  = 0x0a0d0d0a
+	pcapngInterface      = 1
+	pcapngPacket         = 2 // obsolete, but still written by old tools
+	pcapngSimplePacket   = 3
+	pcapngEnhancedPacket = 6
+)
+
+const (
+	pcapngByteOrderMagic = 0x1a2b3c4d
+	pcapngMaxBlock       = 1 << 24
+
+	// Interface block options.
+	pcapngOptionEnd      = 0
+	pcapngOptionTSResol  = 9  // timestamp resolution, microseconds if absent
+	pcapngOptionTSOffset = 14 // seconds to add to timestamps
+)
+
+// pcapngReader reads the pcapng format, which is made of sections of blocks,
+// each section in its own byte order and with its own interfaces. Simple
+// packet blocks have no timestamp, so they are given the time of the packet
+// before them.
+type pcapngReader struct {
+	r          *bufio.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngIface
+	last       time.Time // of the last packet with a timestamp
+	buf        []byte
+}
+
+// A pcapngIface describes an interface packets were captured on.
+type pcapngIface struct {
+	link    uint32
+	snaplen uint32 // 0 for no limit
+	units   uint64 // timestamp units per second
+	offset  int64  // seconds to add to timestamps
+}
+
+func newPcapng(r *bufio.Reader) (*pcapngReader, error) {
+	p := &pcapngReader{r: r}
+	typ, _, err := p.block()
+	if err != nil || typ != pcapngSectionHeader {
+		return nil, ErrNotCapture
+	}
+	return p, nil
+}
+
+func (p *pcapngReader) next() (packet, error) {
+	for {
+		typ, body, err := p.block()
+		if err != nil {
+			return packet{}, eof(err)
+		}
+
+		var iface uint32
+		switch typ {
+		case pcapngInterface:
+			if err := p.addInterface(body); err != nil {
+				return packet{}, err
+			}
+			continue
+		case pcapngSimplePacket:
+			return p.simplePacket(body)
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				return packet{}, errors.New("capture: short packet block")
+			}
+			iface = p.order.Uint32(body)
+		case pcapngPacket:
+			if len(body) < 20 {
+				return packet{}, errors.New("capture: short packet block")
+			}
+			iface = uint32(p.order.Uint16(body))
+		default:
+			continue
+		}
+
+		if int(iface) >= len(p.interfaces) {
+			return packet{}, errors.New("capture: packet of unknown interface")
+		}
+		n := p.order.Uint32(body[12:])
+		if int(n) > len(body)-20 {
+			return packet{}, errors.New("capture: short packet block")
+		}
+		ts := uint64(p.order.Uint32(body[4:]))<<32 | uint64(p.order.Uint32(body[8:]))
+		p.last = p.interfaces[iface].time(ts)
+		return packet{
+			time: p.last,
+			link: p.interfaces[iface].link,
+			data: body[20 : 20+n],
+		}, nil
+	}
+}
+
+// simplePacket returns the packet of a simple packet block body, captured on
+// the first interface. Its length is the original length of the packet, cut
+// to the snapshot length of the interface.
+func (p *pcapngReader) simplePacket(body []byte) (packet, error) {
+	if len(body) < 4 {
+		return packet{}, errors.New("capture: short packet block")
+	}
+	if len(p.interfaces) == 0 {
+		return packet{}, errors.New("capture: packet of unknown interface")
+	}
+	iface := p.interfaces[0]
+	n := p.order.Uint32(body)
+	if iface.snaplen != 0 && n > iface.snaplen {
+		n = iface.snaplen
+	}
+	if int(n) > len(body)-4 {
+		return packet{}, errors.New("capture: short packet block")
+	}
+	return packet{time: p.last, link: iface.link, data: body[4 : 4+n]}, nil
+}
+
+// block reads the next block, returning its type and body. Section header
+// blocks set the byte order of the blocks following them.
+func (p *pcapngReader) block() (uint32, []byte, error) {
+	var h [8]byte
+	if _, err := io.ReadFull(p.r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	// The section header block type reads the same in either byte order.
+	typ := binary.BigEndian.Uint32(h[:])
+	if typ == pcapngSectionHeader {
+		magic, err := p.r.Peek(4)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic) == pcapngByteOrderMagic:
+			p.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == pcapngByteOrderMagic:
+			p.order = binary.BigEndian
+		default:
+			return 0, nil, errors.New("capture: bad pcapng byte order")
+		}
+		p.interfaces = p.interfaces[:0]
+	} else {
+		typ = p.order.Uint32(h[:])
+	}
+
+	n := p.order.Uint32(h[4:])
+	if n < 12 || n%4 != 0 || n > pcapngMaxBlock {
+		return 0, nil, errors.New("capture: bad pcapng block length")
+	}
+	// The body is followed by the length again.
+	if int(n-8) > cap(p.buf) {
+		p.buf = make([]byte, n-8)
+	}
+	body := p.buf[:n-8]
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return 0, nil, err
+	}
+	body = body[:len(body)-4]
+
+	if typ == pcapngSectionHeader && (len(body) < 16 || p.order.Uint16(body[4:]) != 1) {
+		return 0, nil, errors.New("capture: unsupported pcapng version")
+	}
+	return typ, body, nil
+}
+
+// addInterface adds the interface described by an interface block body.
+func (p *pcapngReader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return errors.New("capture: short interface block")
+	}
+	iface := pcapngIface{link: uint32(p.order.Uint16(body)), snaplen: p.order.Uint32(body[4:])}
+	resol := uint8(6)
+
+	options := body[8:]
+	for len(options) >= 4 {
+		code, n := p.order.Uint16(options), int(p.order.Uint16(options[2:]))
+		if code == pcapngOptionEnd || 4+n > len(options) {
+			break
+		}
+		value := options[4 : 4+n]
+		switch {
+		case code == pcapngOptionTSResol && n == 1:
+			resol = value[0]
+		case code == pcapngOptionTSOffset && n == 8:
+			iface.offset = int64(p.order.Uint64(value))
+		}
+		options = options[(4+n+3)&^3:]
+	}
+
+	// The top bit tells powers of two from powers of ten.
+	exp := uint(resol & 0x7f)
+	switch {
+	case resol&0x80 != 0 && exp < 64:
+		iface.units = 1 << exp
+	case resol&0x80 == 0 && exp < 20:
+		iface.units = 1
+		for i := uint(0); i < exp; i++ {
+			iface.units *= 10
+		}
+	default:
+		return errors.New("capture: unsupported timestamp resolution")
+	}
+
+	p.interfaces = append(p.interfaces, iface)
+	return nil
+}
+
+// time converts timestamp ts of a packet captured on the interface.
+func (i pcapngIface) time(ts uint64) time.Time {
+	hi, lo := bits.Mul64(ts%i.units, uint64(time.Second))
+	ns, _ := bits.Div64(hi, lo, i.units)
+	return time.Unix(i.offset+int64(ts/i.units), int64(ns))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/luan/f1-telemetry/recording"
+)
+
+// convertMain runs the convert command, which converts session files between
+// the raw and compact formats.
+func convertMain(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	raw := flags.Bool("raw", false, "convert to raw session files instead of compact ones")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s convert [flags] in out\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "The converted file is read back and checked to hold the same records.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	in, out := flags.Arg(0), flags.Arg(1)
+	if err := convert(in, out, !*raw); err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+}
+
+// convert writes the records of session file in to a new session file out,
+// compact or not, checks it holds the same records, and indexes it.
+func convert(in, out string, compact bool) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	r, err := recording.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("%s: %v", in, err)
+	}
+
+	dst, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	h := r.Header
+	h.Compact = compact
+	w, err := recording.NewWriter(dst, h)
+	if err == nil {
+		err = copyRecords(w, r)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = sameRecords(in, out)
+	}
+	if err != nil {
+		os.Remove(out)
+		return err
+	}
+
+	x, err := recording.BuildIndex(out)
+	if err == nil {
+		err = recording.SaveIndex(out, x)
+	}
+	if err != nil {
+		return err
+	}
+
+	before, _ := src.Stat()
+	after, _ := os.Stat(out)
+	fmt.Printf("%s: %d bytes, %s: %d bytes (%.1f%%)\n", in, before.Size(), out, after.Size(),
+		100*float64(after.Size())/float64(before.Size()))
+	return nil
+}
+
+func copyRecords(w *recording.Writer, r *recording.Reader) error {
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+}
+
+// sameRecords checks that session files a and b hold the same session and
+// the same records, received at the same times.
+func sameRecords(a, b string) error {
+	fa, err := os.Open(a)
+	if err != nil {
+		return err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return err
+	}
+	defer fb.Close()
+
+	ra, err := recording.NewReader(fa)
+	if err != nil {
+		return err
+	}
+	rb, err := recording.NewReader(fb)
+	if err != nil {
+		return err
+	}
+	ha, hb := ra.Header, rb.Header
+	if !ha.Start.Equal(hb.Start) || ha.Format != hb.Format || ha.Source != hb.Source {
+		return errors.New("converted session header differs")
+	}
+
+	for n := 0; ; n++ {
+		recordA, errA := ra.Next()
+		recordB, errB := rb.Next()
+		if errA == io.EOF && errB == io.EOF {
+			return nil
+		}
+		if errA == io.EOF || errB == io.EOF {
+			return errors.New("converted session has a different number of records")
+		}
+		if errA != nil {
+			return errA
+		}
+		if errB != nil {
+			return fmt.Errorf("converted record %d: %v", n, errB)
+		}
+		if !recordA.Time.Equal(recordB.Time) || !bytes.Equal(recordA.Data, recordB.Data) {
+			return fmt.Errorf("converted record %d differs", n)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// A message is sent from the listeners to the dashboard and Influx: a frame,
+// or if event is set, a session starting or ending.
+type message struct {
+	frame f1.Frame
+	event *f1.SessionEvent
+}
+
+// A decoder decodes the datagrams received from one source and splits them
+// into sessions. It accepts a new format once the one it locked onto has
+// stayed silent for StreamTimeout.
+type decoder struct {
+	stream  f1.Stream
+	last    time.Time
+	tracker f1.SessionTracker
+}
+
+func newDecoder() *decoder {
+	return &decoder{tracker: f1.SessionTracker{Gap: StreamTimeout}}
+}
+
+// decode decodes datagram b received at t. It returns the frame it completes,
+// tagged with source and t, if any, preceded by the session events it causes.
+// It returns f1.ErrFormatMismatch for datagrams from another game sending to
+// the same source.
+func (d *decoder) decode(source string, b []byte, t time.Time) ([]message, error) {
+	if t.Sub(d.last) > StreamTimeout {
+		d.stream.Reset()
+	}
+	frame, ok, err := d.stream.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	d.last = t
+	if !ok {
+		return nil, nil
+	}
+
+	frame.Source, frame.Received = source, t
+	var messages []message
+	for _, event := range d.tracker.Track(&frame) {
+		event := event
+		messages = append(messages, message{event: &event})
+	}
+	return append(messages, message{frame: frame}), nil
+}
+
+// session returns the session in progress, if any.
+func (d *decoder) session() (f1.Session, bool) {
+	return d.tracker.Current()
+}
+
+// seek prepares the decoder for datagrams from elsewhere in the same session,
+// as when seeking in a session file.
+func (d *decoder) seek() {
+	d.stream.Reset()
+	d.tracker.Seek()
+}
+
+// end ends the session in progress, returning its end event, if any.
+func (d *decoder) end() (message, bool) {
+	event, ok := d.tracker.End(d.last)
+	if !ok {
+		return message{}, false
+	}
+	return message{event: &event}, true
+}
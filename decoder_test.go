@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// TestDecoderRelock checks a decoder only accepts another format once the
+// one it locked onto stayed silent for StreamTimeout, ending its session.
+func TestDecoderRelock(t *testing.T) {
+	var telemetry f1.TelemetryData
+	legacy, _ := telemetry.MarshalBinary()
+	extradata := make([]byte, 38*4) // level 1
+
+	start := time.Unix(1500000000, 0)
+	d := newDecoder()
+	kinds := func(messages []message) string {
+		s := ""
+		for _, m := range messages {
+			if m.event != nil {
+				s += m.event.Type.String() + " "
+			} else {
+				s += "frame "
+			}
+		}
+		return s
+	}
+	for _, step := range []struct {
+		b    []byte
+		at   time.Duration
+		want string
+		err  error
+	}{
+		{legacy, 0, "start frame ", nil},
+		{extradata, time.Second, "", f1.ErrFormatMismatch},
+		{legacy, 2 * time.Second, "frame ", nil},
+		// Silent for StreamTimeout, not longer.
+		{extradata, 2*time.Second + StreamTimeout, "", f1.ErrFormatMismatch},
+		{extradata, 2*time.Second + StreamTimeout + time.Millisecond, "end start frame ", nil},
+		{legacy, 3*time.Second + StreamTimeout, "", f1.ErrFormatMismatch},
+	} {
+		messages, err := d.decode(":20777", step.b, start.Add(step.at))
+		if got := kinds(messages); got != step.want || err != step.err {
+			t.Fatalf("%d bytes at %v: %q, %v, want %q, %v", len(step.b), step.at, got, err, step.want, step.err)
+		}
+		if len(messages) > 0 && messages[0].event != nil && messages[0].event.Type == f1.SessionEnd {
+			if end := messages[0].event.Session.End; !end.Equal(start.Add(2 * time.Second)) {
+				t.Errorf("session ended at %v, want with its last frame", end)
+			}
+		}
+	}
+	if session, ok := d.session(); !ok || session.Format.Extradata != 1 || session.Format.PacketFormat != 0 {
+		t.Errorf("session %+v, %v, want one of extradata level 1", session, ok)
+	}
+}
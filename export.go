@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/luan/f1-telemetry/sink"
+)
+
+// exportMain runs the export command, which writes the telemetry of session
+// files and captures as CSV, JSON Lines or MoTeC i2 logs, like the csv, jsonl
+// and motec sinks do with live telemetry.
+func exportMain(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "csv", "`format` to export to: csv, jsonl or motec")
+	dir := flags.String("o", ".", "`directory` to write the files to, one per session")
+	channels := flags.String("channels", "", "comma separated `channels` of the player's car to export, e.g. speed,tyres-wear (default all)")
+	carChannels := flags.String("car-channels", "", "comma separated `channels` of the cars to export (default all)")
+	cars := flags.Bool("cars", true, "export the cars in the race")
+	laps := flags.String("laps", "", "`laps` of the player to export, e.g. 3, 3-5 or 3- (default all)")
+	rate := flags.Float64("rate", 0, "`frames` per second of game time to export at most, 0 for every frame, or samples per second of MoTeC logs (default 60)")
+	driver := flags.String("driver", "", "`name` of the driver in MoTeC logs (default the player's driver)")
+	port := flags.Uint("port", DefaultPort, "UDP `port` to read the telemetry of pcap and pcapng captures from, 0 for any")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s export [flags] file...\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Files are session files or pcap and pcapng captures.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *port > math.MaxUint16 {
+		log.Fatalf("invalid port %d", *port)
+	}
+
+	var from, to int
+	if *laps != "" {
+		var err error
+		if from, to, err = parseLaps(*laps); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var options interface{}
+	switch *format {
+	case "csv", "jsonl":
+		o := sink.ExportOptions{Dir: *dir, Cars: *cars, FromLap: from, ToLap: to, Rate: *rate}
+		if *channels != "" {
+			o.Channels = strings.Split(*channels, ",")
+		}
+		if *carChannels != "" {
+			o.CarChannels = strings.Split(*carChannels, ",")
+		}
+		options = o
+	case "motec":
+		// The channels of MoTeC logs are set.
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "channels" || f.Name == "car-channels" || f.Name == "cars" {
+				log.Fatalf("-%s doesn't apply to motec", f.Name)
+			}
+		})
+		o := sink.MotecOptions{Dir: *dir, FromLap: from, ToLap: to, Rate: sink.DefaultMotecRate, Driver: *driver}
+		if *rate != 0 {
+			if *rate != math.Trunc(*rate) {
+				log.Fatalf("invalid rate %g, want whole samples per second", *rate)
+			}
+			o.Rate = int(*rate)
+		}
+		options = o
+	default:
+		log.Fatalf("unknown format %q, want csv, jsonl or motec", *format)
+	}
+	b, err := json.Marshal(options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := sink.New(sink.Config{Type: *format, Options: b})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.Open(); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range flags.Args() {
+		if err := exportFile(name, uint16(*port), from, to, s); err != nil {
+			fmt.Println("Error: ", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(s.(sink.StatusReporter).Status())
+}
+
+// parseLaps parses a lap range: a lap, "from-to", or "from-" for every lap
+// from from on, in which case to is 0.
+func parseLaps(s string) (from, to int, err error) {
+	i := strings.IndexByte(s, '-')
+	if i < 0 {
+		from, err = strconv.Atoi(s)
+		to = from
+	} else if from, err = strconv.Atoi(s[:i]); err == nil && i < len(s)-1 {
+		to, err = strconv.Atoi(s[i+1:])
+	}
+	if err != nil || from < 1 || to != 0 && to < from {
+		return 0, 0, fmt.Errorf("invalid lap range %q", s)
+	}
+	return from, to, nil
+}
+
+// exportFile decodes the session file or capture called name and writes its
+// frames and session events to s. Once the session started, indexed session
+// files skip to lap from, and stop after lap to.
+func exportFile(name string, port uint16, from, to int, s sink.Sink) error {
+	f, err := openSessionFile(name, port)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := newDecoder()
+	seeked := from <= 1
+	end := int64(-1)
+	frames := 0
+	for {
+		if end >= 0 && f.reader.Offset() >= end {
+			break
+		}
+		record, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		messages, err := dec.decode(f.source, record.Data, record.Time)
+		if err != nil {
+			continue
+		}
+		for _, m := range messages {
+			if m.event != nil {
+				err = s.Event(*m.event)
+			} else {
+				frames++
+				err = s.Frame(m.frame)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+
+		// Seeking once the session started keeps its ID, and so the names
+		// of the files, the same as exporting every lap.
+		if _, ok := dec.session(); ok && !seeked {
+			seeked = true
+			if end, ok = f.seekLaps(from, to); ok {
+				dec.seek()
+			} else {
+				end = -1
+			}
+		}
+	}
+	if m, ok := dec.end(); ok {
+		if err := s.Event(*m.event); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	fmt.Printf("%s: %d frames read\n", name, frames)
+	return nil
+}
@@ -0,0 +1,317 @@
+package f1
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// UnmarshalBinary decodes a PacketSize datagram into t without reflection
+// or allocations. On error t is left untouched.
+func (t *TelemetryData) UnmarshalBinary(b []byte) error {
+	if err := checkSize(b); err != nil {
+		return err
+	}
+	t.decode(&wireReader{b: b})
+	return nil
+}
+
+// MarshalBinary encodes t into a PacketSize datagram.
+func (t *TelemetryData) MarshalBinary() ([]byte, error) {
+	return Encode(t), nil
+}
+
+// Encode returns the datagram for t, as sent by the game.
+func Encode(t *TelemetryData) []byte {
+	b := make([]byte, PacketSize)
+	t.encode(&wireWriter{b: b})
+	return b
+}
+
+func (t *TelemetryData) decode(r *wireReader) {
+	t.Time = r.float32()
+	t.Laptime = r.float32()
+	t.Lapdistance = r.float32()
+	t.Totaldistance = r.float32()
+	t.X = r.float32()
+	t.Y = r.float32()
+	t.Z = r.float32()
+	t.Speed = r.float32()
+	t.Xv = r.float32()
+	t.Yv = r.float32()
+	t.Zv = r.float32()
+	t.Xr = r.float32()
+	t.Yr = r.float32()
+	t.Zr = r.float32()
+	t.Xd = r.float32()
+	t.Yd = r.float32()
+	t.Zd = r.float32()
+	r.float32s(t.SuspPos[:])
+	r.float32s(t.SuspVel[:])
+	r.float32s(t.WheelSpeed[:])
+	t.Throttle = r.float32()
+	t.Steer = r.float32()
+	t.Brake = r.float32()
+	t.Clutch = r.float32()
+	t.Gear = r.float32()
+	t.GforceLat = r.float32()
+	t.GforceLon = r.float32()
+	t.Lap = r.float32()
+	t.Enginerate = r.float32()
+	t.SliProNativeSupport = r.float32()
+	t.CarPosition = r.float32()
+	t.KersLevel = r.float32()
+	t.KersMaxLevel = r.float32()
+	t.DRS = r.float32()
+	t.TractionControl = r.float32()
+	t.AntiLockBrakes = r.float32()
+	t.FuelInTank = r.float32()
+	t.FuelCapacity = r.float32()
+	t.InPits = r.float32()
+	t.Sector = r.float32()
+	t.Sector1Time = r.float32()
+	t.Sector2Time = r.float32()
+	r.float32s(t.BrakesTemp[:])
+	r.float32s(t.TyresPressure[:])
+	t.TeamInfo = r.float32()
+	t.TotalLaps = r.float32()
+	t.TrackSize = r.float32()
+	t.LastLapTime = r.float32()
+	t.MaxRpm = r.float32()
+	t.IdleRpm = r.float32()
+	t.MaxGears = r.float32()
+	t.SessionType = r.float32()
+	t.Drsallowed = r.float32()
+	t.TrackNumber = r.float32()
+	t.Vehiclefiaflags = r.float32()
+	t.Era = r.float32()
+	t.EngineTemperature = r.float32()
+	t.GforceVert = r.float32()
+	t.AngVelX = r.float32()
+	t.AngVelY = r.float32()
+	t.AngVelZ = r.float32()
+	r.bytes(t.TyresTemperature[:])
+	r.bytes(t.TyresWear[:])
+	t.TyreCompound = r.uint8()
+	t.FrontBrakeBias = r.uint8()
+	t.FuelMix = r.uint8()
+	t.Currentlapinvalid = r.uint8()
+	r.bytes(t.TyresDamage[:])
+	t.FrontLeftWingDamage = r.uint8()
+	t.FrontRightWingDamage = r.uint8()
+	t.RearWingDamage = r.uint8()
+	t.EngineDamage = r.uint8()
+	t.GearBoxDamage = r.uint8()
+	t.ExhaustDamage = r.uint8()
+	t.PitLimiterStatus = r.uint8()
+	t.PitSpeedLimit = r.uint8()
+	t.SessionTimeLeft = r.float32()
+	t.RevLightsPercent = r.uint8()
+	t.IsSpectating = r.uint8()
+	t.SpectatorCarIndex = r.uint8()
+	t.NumCars = r.uint8()
+	t.PlayerCarIndex = r.uint8()
+	for i := range t.Cars {
+		t.Cars[i].decode(r)
+	}
+	t.Yaw = r.float32()
+	t.Pitch = r.float32()
+	t.Roll = r.float32()
+	t.XLocalVelocity = r.float32()
+	t.YLocalVelocity = r.float32()
+	t.ZLocalVelocity = r.float32()
+	r.float32s(t.SuspAcceleration[:])
+	t.AngAccX = r.float32()
+	t.AngAccY = r.float32()
+	t.AngAccZ = r.float32()
+}
+
+func (t *TelemetryData) encode(w *wireWriter) {
+	w.float32(t.Time)
+	w.float32(t.Laptime)
+	w.float32(t.Lapdistance)
+	w.float32(t.Totaldistance)
+	w.float32(t.X)
+	w.float32(t.Y)
+	w.float32(t.Z)
+	w.float32(t.Speed)
+	w.float32(t.Xv)
+	w.float32(t.Yv)
+	w.float32(t.Zv)
+	w.float32(t.Xr)
+	w.float32(t.Yr)
+	w.float32(t.Zr)
+	w.float32(t.Xd)
+	w.float32(t.Yd)
+	w.float32(t.Zd)
+	w.float32s(t.SuspPos[:])
+	w.float32s(t.SuspVel[:])
+	w.float32s(t.WheelSpeed[:])
+	w.float32(t.Throttle)
+	w.float32(t.Steer)
+	w.float32(t.Brake)
+	w.float32(t.Clutch)
+	w.float32(t.Gear)
+	w.float32(t.GforceLat)
+	w.float32(t.GforceLon)
+	w.float32(t.Lap)
+	w.float32(t.Enginerate)
+	w.float32(t.SliProNativeSupport)
+	w.float32(t.CarPosition)
+	w.float32(t.KersLevel)
+	w.float32(t.KersMaxLevel)
+	w.float32(t.DRS)
+	w.float32(t.TractionControl)
+	w.float32(t.AntiLockBrakes)
+	w.float32(t.FuelInTank)
+	w.float32(t.FuelCapacity)
+	w.float32(t.InPits)
+	w.float32(t.Sector)
+	w.float32(t.Sector1Time)
+	w.float32(t.Sector2Time)
+	w.float32s(t.BrakesTemp[:])
+	w.float32s(t.TyresPressure[:])
+	w.float32(t.TeamInfo)
+	w.float32(t.TotalLaps)
+	w.float32(t.TrackSize)
+	w.float32(t.LastLapTime)
+	w.float32(t.MaxRpm)
+	w.float32(t.IdleRpm)
+	w.float32(t.MaxGears)
+	w.float32(t.SessionType)
+	w.float32(t.Drsallowed)
+	w.float32(t.TrackNumber)
+	w.float32(t.Vehiclefiaflags)
+	w.float32(t.Era)
+	w.float32(t.EngineTemperature)
+	w.float32(t.GforceVert)
+	w.float32(t.AngVelX)
+	w.float32(t.AngVelY)
+	w.float32(t.AngVelZ)
+	w.bytes(t.TyresTemperature[:])
+	w.bytes(t.TyresWear[:])
+	w.uint8(t.TyreCompound)
+	w.uint8(t.FrontBrakeBias)
+	w.uint8(t.FuelMix)
+	w.uint8(t.Currentlapinvalid)
+	w.bytes(t.TyresDamage[:])
+	w.uint8(t.FrontLeftWingDamage)
+	w.uint8(t.FrontRightWingDamage)
+	w.uint8(t.RearWingDamage)
+	w.uint8(t.EngineDamage)
+	w.uint8(t.GearBoxDamage)
+	w.uint8(t.ExhaustDamage)
+	w.uint8(t.PitLimiterStatus)
+	w.uint8(t.PitSpeedLimit)
+	w.float32(t.SessionTimeLeft)
+	w.uint8(t.RevLightsPercent)
+	w.uint8(t.IsSpectating)
+	w.uint8(t.SpectatorCarIndex)
+	w.uint8(t.NumCars)
+	w.uint8(t.PlayerCarIndex)
+	for i := range t.Cars {
+		t.Cars[i].encode(w)
+	}
+	w.float32(t.Yaw)
+	w.float32(t.Pitch)
+	w.float32(t.Roll)
+	w.float32(t.XLocalVelocity)
+	w.float32(t.YLocalVelocity)
+	w.float32(t.ZLocalVelocity)
+	w.float32s(t.SuspAcceleration[:])
+	w.float32(t.AngAccX)
+	w.float32(t.AngAccY)
+	w.float32(t.AngAccZ)
+}
+
+func (c *CarData) decode(r *wireReader) {
+	r.float32s(c.WorldPosition[:])
+	c.LastlapTime = r.float32()
+	c.CurrentlapTime = r.float32()
+	c.BestlapTime = r.float32()
+	c.Sector1Time = r.float32()
+	c.Sector2Time = r.float32()
+	c.LapDistance = r.float32()
+	c.DriverID = r.uint8()
+	c.TeamID = r.uint8()
+	c.CarPosition = r.uint8()
+	c.CurrentLapNum = r.uint8()
+	c.TyreCompound = r.uint8()
+	c.InPits = r.uint8()
+	c.Sector = r.uint8()
+	c.Currentlapinvalid = r.uint8()
+	c.Penalties = r.uint8()
+}
+
+func (c *CarData) encode(w *wireWriter) {
+	w.float32s(c.WorldPosition[:])
+	w.float32(c.LastlapTime)
+	w.float32(c.CurrentlapTime)
+	w.float32(c.BestlapTime)
+	w.float32(c.Sector1Time)
+	w.float32(c.Sector2Time)
+	w.float32(c.LapDistance)
+	w.uint8(c.DriverID)
+	w.uint8(c.TeamID)
+	w.uint8(c.CarPosition)
+	w.uint8(c.CurrentLapNum)
+	w.uint8(c.TyreCompound)
+	w.uint8(c.InPits)
+	w.uint8(c.Sector)
+	w.uint8(c.Currentlapinvalid)
+	w.uint8(c.Penalties)
+}
+
+// wireReader reads little-endian values from consecutive offsets of b.
+// Callers check the length of b up front.
+type wireReader struct {
+	b   []byte
+	off int
+}
+
+func (r *wireReader) uint8() uint8 {
+	v := r.b[r.off]
+	r.off++
+	return v
+}
+
+func (r *wireReader) float32() float32 {
+	v := math.Float32frombits(binary.LittleEndian.Uint32(r.b[r.off:]))
+	r.off += 4
+	return v
+}
+
+func (r *wireReader) bytes(v []byte) {
+	r.off += copy(v, r.b[r.off:])
+}
+
+func (r *wireReader) float32s(v []float32) {
+	for i := range v {
+		v[i] = r.float32()
+	}
+}
+
+// wireWriter is the encoding counterpart of wireReader.
+type wireWriter struct {
+	b   []byte
+	off int
+}
+
+func (w *wireWriter) uint8(v uint8) {
+	w.b[w.off] = v
+	w.off++
+}
+
+func (w *wireWriter) float32(v float32) {
+	binary.LittleEndian.PutUint32(w.b[w.off:], math.Float32bits(v))
+	w.off += 4
+}
+
+func (w *wireWriter) bytes(v []byte) {
+	w.off += copy(w.b[w.off:], v)
+}
+
+func (w *wireWriter) float32s(v []float32) {
+	for _, f := range v {
+		w.float32(f)
+	}
+}
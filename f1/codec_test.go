@@ -0,0 +1,228 @@
+package f1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// sampleTelemetry returns a TelemetryData with every field set to a value of
+// its own, so a field decoded from the wrong offset shows.
+func sampleTelemetry() TelemetryData {
+	var t TelemetryData
+	n := 0
+	var fill func(v reflect.Value)
+	fill = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				fill(v.Field(i))
+			}
+		case reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				fill(v.Index(i))
+			}
+		case reflect.Float32:
+			n++
+			v.SetFloat(float64(n) + 0.25)
+		case reflect.Uint8:
+			n++
+			v.SetUint(uint64(n % 256))
+		default:
+			panic("unexpected field kind " + v.Kind().String())
+		}
+	}
+	fill(reflect.ValueOf(&t).Elem())
+	return t
+}
+
+// binaryEncode encodes t the way the game lays it out, through reflection.
+func binaryEncode(t *TelemetryData) []byte {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, t); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPacketSize(t *testing.T) {
+	if n := binary.Size(TelemetryData{}); n != PacketSize {
+		t.Fatalf("binary.Size(TelemetryData{}) = %d, want %d", n, PacketSize)
+	}
+}
+
+func TestEncodeMatchesBinaryWrite(t *testing.T) {
+	telemetry := sampleTelemetry()
+	if got, want := Encode(&telemetry), binaryEncode(&telemetry); !bytes.Equal(got, want) {
+		t.Fatalf("Encode differs from binary.Write at byte %d", firstDifference(got, want))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := sampleTelemetry()
+	b, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TelemetryData
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UnmarshalBinary(MarshalBinary(t)) = %+v, want %+v", got, want)
+	}
+
+	decoded, err := Decode(Encode(&want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("Decode(Encode(t)) = %+v, want %+v", decoded, want)
+	}
+}
+
+func TestUnmarshalBinaryMatchesBinaryRead(t *testing.T) {
+	telemetry := sampleTelemetry()
+	b := binaryEncode(&telemetry)
+	var want TelemetryData
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &want); err != nil {
+		t.Fatal(err)
+	}
+	var got TelemetryData
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UnmarshalBinary = %+v, binary.Read = %+v", got, want)
+	}
+}
+
+func TestUnmarshalBinarySize(t *testing.T) {
+	sample := sampleTelemetry()
+	b := Encode(&sample)
+	tests := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"empty", nil, ErrShortPacket},
+		{"short", b[:PacketSize-1], ErrShortPacket},
+		{"long", append(append([]byte{}, b...), 0), ErrUnknownFormat},
+	}
+	for _, test := range tests {
+		telemetry := TelemetryData{Speed: 42}
+		if err := telemetry.UnmarshalBinary(test.b); err != test.err {
+			t.Errorf("%s: UnmarshalBinary = %v, want %v", test.name, err, test.err)
+		}
+		if !reflect.DeepEqual(telemetry, TelemetryData{Speed: 42}) {
+			t.Errorf("%s: UnmarshalBinary changed the telemetry on error", test.name)
+		}
+		if _, err := Decode(test.b); err != test.err {
+			t.Errorf("%s: Decode = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestUnmarshalBinaryAllocs(t *testing.T) {
+	sample := sampleTelemetry()
+	b := Encode(&sample)
+	var telemetry TelemetryData
+	allocs := testing.AllocsPerRun(100, func() {
+		telemetry.UnmarshalBinary(b)
+	})
+	if allocs != 0 {
+		t.Fatalf("UnmarshalBinary allocates %v times, want 0", allocs)
+	}
+}
+
+// datagrams is a reader returning one datagram per Read, like a UDP socket.
+type datagrams [][]byte
+
+func (d *datagrams) Read(b []byte) (int, error) {
+	if len(*d) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, (*d)[0])
+	*d = (*d)[1:]
+	return n, nil
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	first, second := sampleTelemetry(), sampleTelemetry()
+	second.Speed = 99
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	var sent datagrams
+	for _, telemetry := range []*TelemetryData{&first, &second} {
+		if err := enc.Encode(telemetry); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, append([]byte{}, buf.Bytes()...))
+		buf.Reset()
+	}
+	// A short and a long datagram in between are refused, and skipped.
+	sent = []([]byte){sent[0], sent[1][:10], append(append([]byte{}, sent[1]...), 0), sent[1]}
+
+	dec := NewDecoder(&sent)
+	var telemetry TelemetryData
+	for i, want := range []error{nil, ErrShortPacket, ErrUnknownFormat, nil, io.EOF} {
+		if err := dec.Decode(&telemetry); err != want {
+			t.Fatalf("Decode %d = %v, want %v", i, err, want)
+		}
+		if i == 0 && !reflect.DeepEqual(telemetry, first) {
+			t.Fatalf("Decode 0 = %+v, want %+v", telemetry, first)
+		}
+	}
+	if !reflect.DeepEqual(telemetry, second) {
+		t.Fatalf("Decode 3 = %+v, want %+v", telemetry, second)
+	}
+}
+
+func firstDifference(a, b []byte) int {
+	for i := range a {
+		if i >= len(b) || a[i] != b[i] {
+			return i
+		}
+	}
+	return len(a)
+}
+
+func BenchmarkUnmarshalBinary(b *testing.B) {
+	sample := sampleTelemetry()
+	datagram := Encode(&sample)
+	var telemetry TelemetryData
+	b.SetBytes(PacketSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := telemetry.UnmarshalBinary(datagram); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBinaryRead is the reflection based decoding UnmarshalBinary
+// replaced, for comparison.
+func BenchmarkBinaryRead(b *testing.B) {
+	sample := sampleTelemetry()
+	datagram := Encode(&sample)
+	var telemetry TelemetryData
+	b.SetBytes(PacketSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := binary.Read(bytes.NewReader(datagram), binary.LittleEndian, &telemetry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	sample := sampleTelemetry()
+	b.SetBytes(PacketSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Encode(&sample)
+	}
+}
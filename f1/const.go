@@ -1,5 +1,7 @@
 package f1
 
+import "strconv"
+
 var Drivers = map[byte]string{
 	9:  "HAM",
 	15: "BOT",
@@ -35,3 +37,117 @@ var Teams = map[byte]string{
 	3:  "Renault",
 	5:  "Sauber",
 }
+
+// SessionTypes names the values of TelemetryData.SessionType.
+var SessionTypes = map[float32]string{
+	0: "unknown",
+	1: "practice",
+	2: "qualifying",
+	3: "race",
+}
+
+// TyreCompounds names the values of TelemetryData.TyreCompound.
+var TyreCompounds = map[byte]string{
+	0: "ultra soft",
+	1: "super soft",
+	2: "soft",
+	3: "medium",
+	4: "hard",
+	5: "inter",
+	6: "wet",
+}
+
+// Weathers names the values of Conditions.Weather.
+var Weathers = map[byte]string{
+	0: "clear",
+	1: "light cloud",
+	2: "overcast",
+	3: "light rain",
+	4: "heavy rain",
+	5: "storm",
+}
+
+// SafetyCars names the values of Conditions.SafetyCarStatus.
+var SafetyCars = map[byte]string{
+	0: "none",
+	1: "full",
+	2: "virtual",
+	3: "formation lap",
+}
+
+// Tracks names the values of TelemetryData.TrackNumber.
+var Tracks = map[float32]string{
+	0:  "Melbourne",
+	1:  "Sepang",
+	2:  "Shanghai",
+	3:  "Sakhir",
+	4:  "Catalunya",
+	5:  "Monaco",
+	6:  "Montreal",
+	7:  "Silverstone",
+	8:  "Hockenheim",
+	9:  "Hungaroring",
+	10: "Spa",
+	11: "Monza",
+	12: "Singapore",
+	13: "Suzuka",
+	14: "Abu Dhabi",
+	15: "Texas",
+	16: "Brazil",
+	17: "Austria",
+	18: "Sochi",
+	19: "Mexico",
+	20: "Baku",
+	21: "Sakhir Short",
+	22: "Silverstone Short",
+	23: "Texas Short",
+	24: "Suzuka Short",
+	25: "Hanoi",
+	26: "Zandvoort",
+	27: "Imola",
+	28: "Portimao",
+	29: "Jeddah",
+	30: "Miami",
+	31: "Las Vegas",
+	32: "Losail",
+}
+
+// SessionTypeName names a session type, or returns its number if unknown.
+func SessionTypeName(t float32) string {
+	if name, ok := SessionTypes[t]; ok {
+		return name
+	}
+	return strconv.FormatFloat(float64(t), 'g', -1, 32)
+}
+
+// TrackName names a track, or returns its number if unknown.
+func TrackName(t float32) string {
+	if name, ok := Tracks[t]; ok {
+		return name
+	}
+	return strconv.FormatFloat(float64(t), 'g', -1, 32)
+}
+
+// TyreCompoundName names a tyre compound, or returns its number if unknown.
+func TyreCompoundName(c byte) string {
+	if name, ok := TyreCompounds[c]; ok {
+		return name
+	}
+	return strconv.Itoa(int(c))
+}
+
+// WeatherName names a weather, or returns its number if unknown.
+func WeatherName(w byte) string {
+	if name, ok := Weathers[w]; ok {
+		return name
+	}
+	return strconv.Itoa(int(w))
+}
+
+// SafetyCarName names a safety car status, or returns its number if unknown.
+func SafetyCarName(s byte) string {
+	if name, ok := SafetyCars[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
@@ -0,0 +1,86 @@
+package f1
+
+import (
+	"errors"
+	"io"
+)
+
+// PacketSize is the size in bytes of a single F1 2017 telemetry datagram.
+const PacketSize = 1289
+
+var (
+	// ErrShortPacket is returned for datagrams smaller than a full packet.
+	ErrShortPacket = errors.New("f1: short packet")
+	// ErrUnknownFormat is returned for datagrams that are not laid out as
+	// a known telemetry packet.
+	ErrUnknownFormat = errors.New("f1: unknown packet format")
+)
+
+// Decode decodes a single datagram into a TelemetryData. The datagram must
+// be exactly PacketSize bytes long.
+func Decode(b []byte) (TelemetryData, error) {
+	var telemetry TelemetryData
+	err := telemetry.UnmarshalBinary(b)
+	return telemetry, err
+}
+
+func checkSize(b []byte) error {
+	switch {
+	case len(b) < PacketSize:
+		return ErrShortPacket
+	case len(b) > PacketSize:
+		return ErrUnknownFormat
+	}
+	return nil
+}
+
+// A Decoder reads and decodes telemetry packets from a datagram oriented
+// input stream, such as a *net.UDPConn. Every call to Read on the underlying
+// reader is expected to return exactly one datagram.
+type Decoder struct {
+	r   io.Reader
+	buf []byte
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:   r,
+		buf: make([]byte, PacketSize+1),
+	}
+}
+
+// Decode reads the next datagram and stores the decoded packet in the value
+// pointed to by telemetry. Datagrams that fail validation return
+// ErrShortPacket or ErrUnknownFormat and leave telemetry untouched; the
+// caller may keep decoding. Any other error comes from the underlying reader.
+func (d *Decoder) Decode(telemetry *TelemetryData) error {
+	n, err := d.r.Read(d.buf)
+	if err != nil {
+		return err
+	}
+
+	return telemetry.UnmarshalBinary(d.buf[:n])
+}
+
+// An Encoder writes telemetry packets to an output stream, one datagram per
+// Write call.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:   w,
+		buf: make([]byte, PacketSize),
+	}
+}
+
+// Encode writes the datagram for telemetry to the stream.
+func (e *Encoder) Encode(telemetry *TelemetryData) error {
+	telemetry.encode(&wireWriter{b: e.buf})
+	_, err := e.w.Write(e.buf)
+	return err
+}
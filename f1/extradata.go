@@ -0,0 +1,32 @@
+package f1
+
+// extradataSizes are the datagram sizes of the extradata levels shared by
+// the Codemasters games, such as DiRT Rally and GRID Autosport. Every level
+// sends a prefix of the floats at the start of TelemetryData: 17 floats up to
+// Zd, 38 up to Enginerate, 64 up to MaxRpm and 66 up to MaxGears.
+var extradataSizes = [...]int{17 * 4, 38 * 4, 64 * 4, 66 * 4}
+
+// DecodeExtradata decodes a datagram of one of the extradata levels into a
+// TelemetryData. Fields the level does not send are left zero; Format.Fields
+// tells which ones were sent.
+func DecodeExtradata(b []byte) (TelemetryData, error) {
+	var telemetry TelemetryData
+	if _, ok := extradataLevel(len(b)); !ok {
+		return telemetry, ErrUnknownFormat
+	}
+
+	var buf [PacketSize]byte
+	copy(buf[:], b)
+	telemetry.decode(&wireReader{b: buf[:]})
+	return telemetry, nil
+}
+
+// extradataLevel returns the extradata level sending datagrams of n bytes.
+func extradataLevel(n int) (int, bool) {
+	for level, size := range extradataSizes {
+		if n == size {
+			return level, true
+		}
+	}
+	return 0, false
+}
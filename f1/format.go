@@ -0,0 +1,127 @@
+package f1
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// LegacyPacketFormat is the packet format reported for F1 2017 datagrams,
+// which predate the packet format header field.
+const LegacyPacketFormat = 2017
+
+// A Format identifies the layout a datagram was sent in.
+type Format struct {
+	// PacketFormat is the packet format header field of the header based
+	// formats, or LegacyPacketFormat for F1 2017. It is zero for the
+	// extradata layouts of other Codemasters games.
+	PacketFormat uint16
+	// Extradata is the extradata level of the single packet layouts, 0 to
+	// 3. F1 2017 always sends level 3.
+	Extradata int
+	// GameMajorVersion and GameMinorVersion are the game version sent by
+	// the header based formats from 2019 onwards.
+	GameMajorVersion uint8
+	GameMinorVersion uint8
+}
+
+// Legacy reports whether f is one of the single packet layouts, where every
+// datagram carries a whole frame.
+func (f Format) Legacy() bool {
+	return f.PacketFormat == 0 || f.PacketFormat == LegacyPacketFormat
+}
+
+// Matches reports whether datagrams of f and g can belong to the same
+// stream. The game version is ignored.
+func (f Format) Matches(g Format) bool {
+	return f.PacketFormat == g.PacketFormat && f.Extradata == g.Extradata
+}
+
+func (f Format) String() string {
+	switch {
+	case f.PacketFormat == 0:
+		return fmt.Sprintf("extradata=%d", f.Extradata)
+	case f.PacketFormat == LegacyPacketFormat:
+		return "F1 2017"
+	case f.GameMajorVersion != 0 || f.GameMinorVersion != 0:
+		return fmt.Sprintf("F1 %d v%d.%02d", f.PacketFormat, f.GameMajorVersion, f.GameMinorVersion)
+	}
+	return fmt.Sprintf("F1 %d", f.PacketFormat)
+}
+
+// Fields returns the fields of TelemetryData and Conditions sent in f. The
+// others are left zero by the decoders.
+func (f Format) Fields() Fields {
+	switch {
+	case f.PacketFormat == LegacyPacketFormat:
+		return AllFields &^ FieldConditions
+	case f.PacketFormat != 0:
+		return AllFields
+	}
+	fields := FieldMotion
+	if f.Extradata >= 1 {
+		fields |= FieldInputs
+	}
+	if f.Extradata >= 2 {
+		fields |= FieldCarStatus
+	}
+	if f.Extradata >= 3 {
+		fields |= FieldGears
+	}
+	return fields
+}
+
+// Fields is a set of groups of TelemetryData and Conditions fields.
+type Fields uint
+
+const (
+	FieldMotion     Fields = 1 << iota // Time to Zd
+	FieldInputs                        // SuspPos to Enginerate
+	FieldCarStatus                     // SliProNativeSupport to MaxRpm
+	FieldGears                         // IdleRpm and MaxGears
+	FieldSession                       // SessionType to SpectatorCarIndex
+	FieldCars                          // NumCars, PlayerCarIndex and Cars
+	FieldMotionEx                      // Yaw to AngAccZ
+	FieldConditions                    // Conditions
+
+	AllFields = FieldMotion | FieldInputs | FieldCarStatus | FieldGears | FieldSession | FieldCars | FieldMotionEx | FieldConditions
+)
+
+// Has reports whether all fields of g are in f.
+func (f Fields) Has(g Fields) bool {
+	return f&g == g
+}
+
+// Sniff identifies the format of a datagram. Header based datagrams are
+// recognised by their packet format, packet id and length; the single packet
+// layouts only by their length.
+func Sniff(b []byte) (Format, error) {
+	f, _, err := lookupPacket(b)
+	if err == nil {
+		found := Format{PacketFormat: f.packetFormat}
+		if f.versionOffset > 0 {
+			found.GameMajorVersion = b[f.versionOffset]
+			found.GameMinorVersion = b[f.versionOffset+1]
+		}
+		return found, nil
+	}
+
+	if len(b) == PacketSize {
+		return Format{PacketFormat: LegacyPacketFormat, Extradata: 3}, nil
+	}
+	if level, ok := extradataLevel(len(b)); ok {
+		return Format{Extradata: level}, nil
+	}
+	if _, ok := formats[packetFormat(b)]; ok {
+		return Format{}, err
+	}
+	return Format{}, ErrUnknownFormat
+}
+
+// packetFormat returns the packet format header field of b, or 0 if b is too
+// short to have one.
+func packetFormat(b []byte) uint16 {
+	if len(b) < 2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
@@ -0,0 +1,160 @@
+package f1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// Packet ids of the header based formats. Later games only ever added new
+// ids, so the meaning of an id is the same for every format that has it.
+const (
+	PacketMotion              = 0  // physics data for all cars
+	PacketSession             = 1  // data about the session, e.g. track, time left
+	PacketLapData             = 2  // lap data for all cars
+	PacketEvent               = 3  // various notable events during a session
+	PacketParticipants        = 4  // list of participants in the session
+	PacketCarSetups           = 5  // car setups for all cars
+	PacketCarTelemetry        = 6  // telemetry data for all cars
+	PacketCarStatus           = 7  // status data for all cars
+	PacketFinalClassification = 8  // final classification confirmation at the end of a race (2020+)
+	PacketLobbyInfo           = 9  // information about players in a multiplayer lobby (2020+)
+	PacketCarDamage           = 10 // damage status for all cars (2021+)
+	PacketSessionHistory      = 11 // lap and tyre data for one car in the session (2021+)
+	PacketTyreSets            = 12 // extended tyre set data for one car (2023+)
+	PacketMotionEx            = 13 // extended motion data for the player car (2023+)
+	PacketTimeTrial           = 14 // time trial specific data (2024+)
+)
+
+// A Packet is one decoded datagram of the header based formats, which split
+// the telemetry of a frame across several packet types.
+type Packet interface {
+	PacketHeader() PacketHeader
+	apply(s *State)
+}
+
+// PacketHeader holds the header fields of a packet, independent of the layout
+// used by a particular game. Fields a game does not send are left zero.
+type PacketHeader struct {
+	PacketFormat            uint16
+	GameYear                uint8
+	GameMajorVersion        uint8
+	GameMinorVersion        uint8
+	PacketVersion           uint8
+	PacketID                uint8
+	SessionUID              uint64
+	SessionTime             float32
+	FrameIdentifier         uint32
+	OverallFrameIdentifier  uint32
+	PlayerCarIndex          uint8
+	SecondaryPlayerCarIndex uint8
+}
+
+// A format holds the packet layouts of one game, keyed by packet id.
+type format struct {
+	packetFormat   uint16 // value of the packet format header field
+	versionOffset  int    // offset of the game major and minor version, 0 if not sent
+	packetIDOffset int    // offset of the packet id within the header
+	header         func() packetHeader
+	packets        map[uint8]func() Packet
+
+	// Filled in by registerFormat.
+	headerSize int
+	sizes      map[uint8]int
+}
+
+// packetHeader is implemented by the header layout of every game.
+type packetHeader interface {
+	PacketHeader() PacketHeader
+}
+
+var formats = map[uint16]format{}
+
+// registerFormat makes the packet layouts of a game available to
+// DecodePacket. Every game year registers its table from its own file.
+func registerFormat(f format) {
+	f.headerSize = binary.Size(f.header())
+	f.sizes = make(map[uint8]int, len(f.packets))
+	for id, newPacket := range f.packets {
+		f.sizes[id] = binary.Size(newPacket())
+	}
+	formats[f.packetFormat] = f
+}
+
+// PacketFormats returns the packet formats understood by DecodePacket.
+func PacketFormats() []uint16 {
+	var ids []uint16
+	for id := range formats {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// DecodePacket decodes a single datagram of one of the header based formats.
+// The datagram must be exactly as long as the packet type named in its
+// header.
+func DecodePacket(b []byte) (Packet, error) {
+	f, id, err := lookupPacket(b)
+	if err != nil {
+		return nil, err
+	}
+	p := f.packets[id]()
+	err = binary.Read(bytes.NewReader(b), binary.LittleEndian, p)
+	return p, err
+}
+
+// DecodeHeader decodes only the header of a datagram of one of the header
+// based formats. The datagram is validated as by DecodePacket.
+func DecodeHeader(b []byte) (PacketHeader, error) {
+	f, _, err := lookupPacket(b)
+	if err != nil {
+		return PacketHeader{}, err
+	}
+	h := f.header()
+	err = binary.Read(bytes.NewReader(b[:f.headerSize]), binary.LittleEndian, h)
+	return h.PacketHeader(), err
+}
+
+// PacketID returns the packet id of a datagram of one of the header based
+// formats. ok is false for any other datagram.
+func PacketID(b []byte) (id uint8, ok bool) {
+	_, id, err := lookupPacket(b)
+	return id, err == nil
+}
+
+// lookupPacket finds the format and packet id of a datagram of one of the
+// header based formats, and checks the datagram is as long as that packet.
+func lookupPacket(b []byte) (format, uint8, error) {
+	if len(b) < 2 {
+		return format{}, 0, ErrShortPacket
+	}
+	f, ok := formats[binary.LittleEndian.Uint16(b)]
+	if !ok {
+		return format{}, 0, ErrUnknownFormat
+	}
+	if len(b) < f.headerSize {
+		return format{}, 0, ErrShortPacket
+	}
+
+	id := b[f.packetIDOffset]
+	size, ok := f.sizes[id]
+	switch {
+	case !ok:
+		return format{}, 0, ErrUnknownFormat
+	case len(b) < size:
+		return format{}, 0, ErrShortPacket
+	case len(b) > size:
+		return format{}, 0, ErrUnknownFormat
+	}
+	return f, id, nil
+}
+
+// cString returns the string in b up to the first null byte.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
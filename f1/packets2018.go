@@ -0,0 +1,387 @@
+package f1
+
+// Packet layouts of the F1 2018 UDP specification. Every packet starts with a
+// PacketHeader2018 and all wheel arrays have the order RL, RR, FL, FR.
+
+func init() {
+	registerFormat(format{
+		packetFormat:   2018,
+		packetIDOffset: 3,
+		header:         func() packetHeader { return new(PacketHeader2018) },
+		packets: map[uint8]func() Packet{
+			PacketMotion:       func() Packet { return new(PacketMotionData2018) },
+			PacketSession:      func() Packet { return new(PacketSessionData2018) },
+			PacketLapData:      func() Packet { return new(PacketLapData2018) },
+			PacketEvent:        func() Packet { return new(PacketEventData2018) },
+			PacketParticipants: func() Packet { return new(PacketParticipantsData2018) },
+			PacketCarSetups:    func() Packet { return new(PacketCarSetupData2018) },
+			PacketCarTelemetry: func() Packet { return new(PacketCarTelemetryData2018) },
+			PacketCarStatus:    func() Packet { return new(PacketCarStatusData2018) },
+		},
+	})
+}
+
+type PacketHeader2018 struct {
+	PacketFormat    uint16  // 2018
+	PacketVersion   uint8   // version of this packet type, starts from 1
+	PacketID        uint8   // identifier for the packet type
+	SessionUID      uint64  // unique identifier for the session
+	SessionTime     float32 // session timestamp
+	FrameIdentifier uint32  // identifier for the frame the data was retrieved on
+	PlayerCarIndex  uint8   // index of player's car in the array
+}
+
+type CarMotionData2018 struct {
+	WorldPositionX     float32 // world space X position
+	WorldPositionY     float32 // world space Y position
+	WorldPositionZ     float32 // world space Z position
+	WorldVelocityX     float32 // velocity in world space X
+	WorldVelocityY     float32 // velocity in world space Y
+	WorldVelocityZ     float32 // velocity in world space Z
+	WorldForwardDirX   int16   // world space forward X direction (normalised)
+	WorldForwardDirY   int16   // world space forward Y direction (normalised)
+	WorldForwardDirZ   int16   // world space forward Z direction (normalised)
+	WorldRightDirX     int16   // world space right X direction (normalised)
+	WorldRightDirY     int16   // world space right Y direction (normalised)
+	WorldRightDirZ     int16   // world space right Z direction (normalised)
+	GForceLateral      float32 // lateral G-force component
+	GForceLongitudinal float32 // longitudinal G-force component
+	GForceVertical     float32 // vertical G-force component
+	Yaw                float32 // yaw angle in radians
+	Pitch              float32 // pitch angle in radians
+	Roll               float32 // roll angle in radians
+}
+
+type PacketMotionData2018 struct {
+	PacketHeader2018
+	CarMotionData [20]CarMotionData2018 // data for all cars on track
+
+	// Extra player car only data
+	SuspensionPosition     [4]float32
+	SuspensionVelocity     [4]float32
+	SuspensionAcceleration [4]float32
+	WheelSpeed             [4]float32 // speed of each wheel
+	WheelSlip              [4]float32 // slip ratio for each wheel
+	LocalVelocityX         float32    // velocity in local space
+	LocalVelocityY         float32    // velocity in local space
+	LocalVelocityZ         float32    // velocity in local space
+	AngularVelocityX       float32    // angular velocity x-component
+	AngularVelocityY       float32    // angular velocity y-component
+	AngularVelocityZ       float32    // angular velocity z-component
+	AngularAccelerationX   float32    // angular acceleration x-component
+	AngularAccelerationY   float32    // angular acceleration y-component
+	AngularAccelerationZ   float32    // angular acceleration z-component
+	FrontWheelsAngle       float32    // current front wheels angle in radians
+}
+
+type MarshalZone2018 struct {
+	ZoneStart float32 // fraction (0..1) of way through the lap the marshal zone starts
+	ZoneFlag  int8    // -1 = invalid/unknown, 0 = none, 1 = green, 2 = blue, 3 = yellow, 4 = red
+}
+
+type PacketSessionData2018 struct {
+	PacketHeader2018
+	Weather             uint8  // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature    int8   // track temp. in degrees celsius
+	AirTemperature      int8   // air temp. in degrees celsius
+	TotalLaps           uint8  // total number of laps in this race
+	TrackLength         uint16 // track length in metres
+	SessionType         uint8  // 0 = unknown, 1 = P1, 2 = P2, 3 = P3, 4 = Short P, 5 = Q1, 6 = Q2, 7 = Q3, 8 = Short Q, 9 = OSQ, 10 = R, 11 = R2, 12 = Time Trial
+	TrackID             int8   // -1 for unknown, 0-21 for tracks
+	Era                 uint8  // era, 0 = modern, 1 = classic
+	SessionTimeLeft     uint16 // time left in session in seconds
+	SessionDuration     uint16 // session duration in seconds
+	PitSpeedLimit       uint8  // pit speed limit in kilometres per hour
+	GamePaused          uint8  // whether the game is paused
+	IsSpectating        uint8  // whether the player is spectating
+	SpectatorCarIndex   uint8  // index of the car being spectated
+	SliProNativeSupport uint8  // SLI Pro support, 0 = inactive, 1 = active
+	NumMarshalZones     uint8  // number of marshal zones to follow
+	MarshalZones        [21]MarshalZone2018
+	SafetyCarStatus     uint8 // 0 = no safety car, 1 = full safety car, 2 = virtual safety car
+	NetworkGame         uint8 // 0 = offline, 1 = online
+}
+
+type LapData2018 struct {
+	LastLapTime       float32 // last lap time in seconds
+	CurrentLapTime    float32 // current time around the lap in seconds
+	BestLapTime       float32 // best lap time of the session in seconds
+	Sector1Time       float32 // sector 1 time in seconds
+	Sector2Time       float32 // sector 2 time in seconds
+	LapDistance       float32 // distance vehicle is around current lap in metres
+	TotalDistance     float32 // total distance travelled in session in metres
+	SafetyCarDelta    float32 // delta in seconds for safety car
+	CarPosition       uint8   // car race position
+	CurrentLapNum     uint8   // current lap number
+	PitStatus         uint8   // 0 = none, 1 = pitting, 2 = in pit area
+	Sector            uint8   // 0 = sector1, 1 = sector2, 2 = sector3
+	CurrentLapInvalid uint8   // current lap invalid - 0 = valid, 1 = invalid
+	Penalties         uint8   // accumulated time penalties in seconds to be added
+	GridPosition      uint8   // grid position the vehicle started the race in
+	DriverStatus      uint8   // 0 = in garage, 1 = flying lap, 2 = in lap, 3 = out lap, 4 = on track
+	ResultStatus      uint8   // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = disqualified, 5 = not classified, 6 = retired
+}
+
+type PacketLapData2018 struct {
+	PacketHeader2018
+	LapData [20]LapData2018 // lap data for all cars on track
+}
+
+type PacketEventData2018 struct {
+	PacketHeader2018
+	EventStringCode [4]uint8 // "SSTA" = session started, "SEND" = session ended
+}
+
+type ParticipantData2018 struct {
+	AIControlled uint8     // whether the vehicle is AI (1) or human (0) controlled
+	DriverID     uint8     // driver id
+	TeamID       uint8     // team id
+	RaceNumber   uint8     // race number of the car
+	Nationality  uint8     // nationality of the driver
+	Name         [48]uint8 // name of participant in UTF-8 format, null terminated
+}
+
+type PacketParticipantsData2018 struct {
+	PacketHeader2018
+	NumCars      uint8 // number of cars in the data
+	Participants [20]ParticipantData2018
+}
+
+type CarSetupData2018 struct {
+	FrontWing             uint8   // front wing aero
+	RearWing              uint8   // rear wing aero
+	OnThrottle            uint8   // differential adjustment on throttle (percentage)
+	OffThrottle           uint8   // differential adjustment off throttle (percentage)
+	FrontCamber           float32 // front camber angle (suspension geometry)
+	RearCamber            float32 // rear camber angle (suspension geometry)
+	FrontToe              float32 // front toe angle (suspension geometry)
+	RearToe               float32 // rear toe angle (suspension geometry)
+	FrontSuspension       uint8   // front suspension
+	RearSuspension        uint8   // rear suspension
+	FrontAntiRollBar      uint8   // front anti-roll bar
+	RearAntiRollBar       uint8   // rear anti-roll bar
+	FrontSuspensionHeight uint8   // front ride height
+	RearSuspensionHeight  uint8   // rear ride height
+	BrakePressure         uint8   // brake pressure (percentage)
+	BrakeBias             uint8   // brake bias (percentage)
+	FrontTyrePressure     float32 // front tyre pressure (PSI)
+	RearTyrePressure      float32 // rear tyre pressure (PSI)
+	Ballast               uint8   // ballast
+	FuelLoad              float32 // fuel load
+}
+
+type PacketCarSetupData2018 struct {
+	PacketHeader2018
+	CarSetups [20]CarSetupData2018
+}
+
+type CarTelemetryData2018 struct {
+	Speed                   uint16     // speed of car in kilometres per hour
+	Throttle                uint8      // amount of throttle applied (0 to 100)
+	Steer                   int8       // steering (-100 (full lock left) to 100 (full lock right))
+	Brake                   uint8      // amount of brake applied (0 to 100)
+	Clutch                  uint8      // amount of clutch applied (0 to 100)
+	Gear                    int8       // gear selected (1-8, N=0, R=-1)
+	EngineRPM               uint16     // engine RPM
+	DRS                     uint8      // 0 = off, 1 = on
+	RevLightsPercent        uint8      // rev lights indicator (percentage)
+	BrakesTemperature       [4]uint16  // brakes temperature (celsius)
+	TyresSurfaceTemperature [4]uint16  // tyres surface temperature (celsius)
+	TyresInnerTemperature   [4]uint16  // tyres inner temperature (celsius)
+	EngineTemperature       uint16     // engine temperature (celsius)
+	TyresPressure           [4]float32 // tyres pressure (PSI)
+}
+
+type PacketCarTelemetryData2018 struct {
+	PacketHeader2018
+	CarTelemetryData [20]CarTelemetryData2018
+	ButtonStatus     uint32 // bit flags specifying which buttons are being pressed currently
+}
+
+type CarStatusData2018 struct {
+	TractionControl         uint8    // 0 (off) - 2 (high)
+	AntiLockBrakes          uint8    // 0 (off) - 1 (on)
+	FuelMix                 uint8    // fuel mix - 0 = lean, 1 = standard, 2 = rich, 3 = max
+	FrontBrakeBias          uint8    // front brake bias (percentage)
+	PitLimiterStatus        uint8    // pit limiter status - 0 = off, 1 = on
+	FuelInTank              float32  // current fuel mass
+	FuelCapacity            float32  // fuel capacity
+	MaxRPM                  uint16   // cars max RPM, point of rev limiter
+	IdleRPM                 uint16   // cars idle RPM
+	MaxGears                uint8    // maximum number of gears
+	DRSAllowed              int8     // 0 = not allowed, 1 = allowed, -1 = unknown
+	TyresWear               [4]uint8 // tyre wear percentage
+	TyreCompound            uint8    // 0 = hyper soft, 1 = ultra soft, 2 = super soft, 3 = soft, 4 = medium, 5 = hard, 6 = super hard, 7 = inter, 8 = wet
+	TyresDamage             [4]uint8 // tyre damage (percentage)
+	FrontLeftWingDamage     uint8    // front left wing damage (percentage)
+	FrontRightWingDamage    uint8    // front right wing damage (percentage)
+	RearWingDamage          uint8    // rear wing damage (percentage)
+	EngineDamage            uint8    // engine damage (percentage)
+	GearBoxDamage           uint8    // gear box damage (percentage)
+	ExhaustDamage           uint8    // exhaust damage (percentage)
+	VehicleFIAFlags         int8     // -1 = invalid/unknown, 0 = none, 1 = green, 2 = blue, 3 = yellow, 4 = red
+	ERSStoreEnergy          float32  // ERS energy store in Joules
+	ERSDeployMode           uint8    // 0 = none, 1 = low, 2 = medium, 3 = high, 4 = overtake, 5 = hotlap
+	ERSHarvestedThisLapMGUK float32  // ERS energy harvested this lap by MGU-K
+	ERSHarvestedThisLapMGUH float32  // ERS energy harvested this lap by MGU-H
+	ERSDeployedThisLap      float32  // ERS energy deployed this lap
+}
+
+type PacketCarStatusData2018 struct {
+	PacketHeader2018
+	CarStatusData [20]CarStatusData2018
+}
+
+func (h PacketHeader2018) PacketHeader() PacketHeader {
+	return PacketHeader{
+		PacketFormat:    h.PacketFormat,
+		PacketVersion:   h.PacketVersion,
+		PacketID:        h.PacketID,
+		SessionUID:      h.SessionUID,
+		SessionTime:     h.SessionTime,
+		FrameIdentifier: h.FrameIdentifier,
+		PlayerCarIndex:  h.PlayerCarIndex,
+	}
+}
+
+func (p *PacketMotionData2018) apply(s *State) {
+	s.applyCarMotion(p.CarMotionData[:])
+	s.applyPlayerMotion(playerMotion{
+		suspensionPosition:     p.SuspensionPosition,
+		suspensionVelocity:     p.SuspensionVelocity,
+		suspensionAcceleration: p.SuspensionAcceleration,
+		wheelSpeed:             p.WheelSpeed,
+		localVelocity:          [3]float32{p.LocalVelocityX, p.LocalVelocityY, p.LocalVelocityZ},
+		angularVelocity:        [3]float32{p.AngularVelocityX, p.AngularVelocityY, p.AngularVelocityZ},
+		angularAcceleration:    [3]float32{p.AngularAccelerationX, p.AngularAccelerationY, p.AngularAccelerationZ},
+	})
+}
+
+func (p *PacketSessionData2018) apply(s *State) {
+	era := float32(2017)
+	if p.Era == 1 {
+		era = 1980
+	}
+	s.applySession(session{
+		weather:             p.Weather,
+		trackTemperature:    p.TrackTemperature,
+		airTemperature:      p.AirTemperature,
+		totalLaps:           p.TotalLaps,
+		trackLength:         p.TrackLength,
+		sessionType:         legacySessionType(p.SessionType, 9, 11),
+		trackID:             p.TrackID,
+		era:                 era,
+		sessionTimeLeft:     p.SessionTimeLeft,
+		pitSpeedLimit:       p.PitSpeedLimit,
+		gamePaused:          p.GamePaused,
+		isSpectating:        p.IsSpectating,
+		spectatorCarIndex:   p.SpectatorCarIndex,
+		sliProNativeSupport: p.SliProNativeSupport,
+		safetyCarStatus:     p.SafetyCarStatus,
+		networkGame:         p.NetworkGame,
+	})
+}
+
+func (l LapData2018) lap() lap {
+	return lap{
+		lastLapTime:       l.LastLapTime,
+		currentLapTime:    l.CurrentLapTime,
+		sector1Time:       l.Sector1Time,
+		sector2Time:       l.Sector2Time,
+		lapDistance:       l.LapDistance,
+		totalDistance:     l.TotalDistance,
+		carPosition:       l.CarPosition,
+		currentLapNum:     l.CurrentLapNum,
+		pitStatus:         l.PitStatus,
+		sector:            l.Sector,
+		currentLapInvalid: l.CurrentLapInvalid,
+		penalties:         l.Penalties,
+	}
+}
+
+func (p *PacketLapData2018) apply(s *State) {
+	for i, l := range p.LapData {
+		s.applyLap(i, l.lap())
+		if c := s.car(i); c != nil {
+			c.BestlapTime = l.BestLapTime
+		}
+	}
+}
+
+func (p *PacketEventData2018) apply(s *State) {
+	s.Event = string(p.EventStringCode[:])
+}
+
+func (p *PacketParticipantsData2018) apply(s *State) {
+	s.NumCars = p.NumCars
+	for i, d := range p.Participants {
+		s.applyParticipant(i, d.DriverID, d.TeamID, d.Name[:])
+	}
+}
+
+// Car setups have no 2017 counterpart.
+func (p *PacketCarSetupData2018) apply(s *State) {}
+
+func (p *PacketCarTelemetryData2018) apply(s *State) {
+	i, ok := s.player(len(p.CarTelemetryData))
+	if !ok {
+		return
+	}
+	t := p.CarTelemetryData[i]
+	s.applyCarTelemetry(carTelemetry{
+		speed:                   t.Speed,
+		throttle:                float32(t.Throttle) / 100,
+		steer:                   float32(t.Steer) / 100,
+		brake:                   float32(t.Brake) / 100,
+		clutch:                  t.Clutch,
+		gear:                    t.Gear,
+		engineRPM:               t.EngineRPM,
+		drs:                     t.DRS,
+		revLightsPercent:        t.RevLightsPercent,
+		brakesTemperature:       t.BrakesTemperature,
+		tyresSurfaceTemperature: t.TyresSurfaceTemperature,
+		engineTemperature:       t.EngineTemperature,
+		tyresPressure:           t.TyresPressure,
+	})
+}
+
+func (p *PacketCarStatusData2018) apply(s *State) {
+	for i, c := range p.CarStatusData {
+		if car := s.car(i); car != nil {
+			car.TyreCompound = legacyCompound2018(c.TyreCompound)
+		}
+	}
+
+	i, ok := s.player(len(p.CarStatusData))
+	if !ok {
+		return
+	}
+	c := p.CarStatusData[i]
+	s.applyCarStatus(carStatus{
+		tractionControl:  c.TractionControl,
+		antiLockBrakes:   c.AntiLockBrakes,
+		fuelMix:          c.FuelMix,
+		frontBrakeBias:   c.FrontBrakeBias,
+		pitLimiterStatus: c.PitLimiterStatus,
+		fuelInTank:       c.FuelInTank,
+		fuelCapacity:     c.FuelCapacity,
+		maxRPM:           c.MaxRPM,
+		idleRPM:          c.IdleRPM,
+		maxGears:         c.MaxGears,
+		drsAllowed:       c.DRSAllowed,
+		tyreCompound:     legacyCompound2018(c.TyreCompound),
+		vehicleFIAFlags:  c.VehicleFIAFlags,
+		ersStoreEnergy:   c.ERSStoreEnergy,
+		ersDeployMode:    c.ERSDeployMode,
+	})
+	s.applyCarDamage(carDamage{
+		tyresWear:            c.TyresWear,
+		tyresDamage:          c.TyresDamage,
+		frontLeftWingDamage:  c.FrontLeftWingDamage,
+		frontRightWingDamage: c.FrontRightWingDamage,
+		rearWingDamage:       c.RearWingDamage,
+		engineDamage:         c.EngineDamage,
+		gearBoxDamage:        c.GearBoxDamage,
+		exhaustDamage:        c.ExhaustDamage,
+	})
+}
@@ -0,0 +1,306 @@
+package f1
+
+// Packet layouts of the F1 2019 UDP specification. The header gained the game
+// version, so every packet type changed; layouts of per-car data that stayed
+// the same since 2018 are reused.
+
+func init() {
+	registerFormat(format{
+		packetFormat:   2019,
+		versionOffset:  2,
+		packetIDOffset: 5,
+		header:         func() packetHeader { return new(PacketHeader2019) },
+		packets: map[uint8]func() Packet{
+			PacketMotion:       func() Packet { return new(PacketMotionData2019) },
+			PacketSession:      func() Packet { return new(PacketSessionData2019) },
+			PacketLapData:      func() Packet { return new(PacketLapData2019) },
+			PacketEvent:        func() Packet { return new(PacketEventData2019) },
+			PacketParticipants: func() Packet { return new(PacketParticipantsData2019) },
+			PacketCarSetups:    func() Packet { return new(PacketCarSetupData2019) },
+			PacketCarTelemetry: func() Packet { return new(PacketCarTelemetryData2019) },
+			PacketCarStatus:    func() Packet { return new(PacketCarStatusData2019) },
+		},
+	})
+}
+
+type PacketHeader2019 struct {
+	PacketFormat     uint16  // 2019
+	GameMajorVersion uint8   // game major version - "X.00"
+	GameMinorVersion uint8   // game minor version - "1.XX"
+	PacketVersion    uint8   // version of this packet type, starts from 1
+	PacketID         uint8   // identifier for the packet type
+	SessionUID       uint64  // unique identifier for the session
+	SessionTime      float32 // session timestamp
+	FrameIdentifier  uint32  // identifier for the frame the data was retrieved on
+	PlayerCarIndex   uint8   // index of player's car in the array
+}
+
+type PacketMotionData2019 struct {
+	PacketHeader2019
+	CarMotionData [20]CarMotionData2018 // data for all cars on track
+
+	// Extra player car only data
+	SuspensionPosition     [4]float32
+	SuspensionVelocity     [4]float32
+	SuspensionAcceleration [4]float32
+	WheelSpeed             [4]float32 // speed of each wheel
+	WheelSlip              [4]float32 // slip ratio for each wheel
+	LocalVelocityX         float32    // velocity in local space
+	LocalVelocityY         float32    // velocity in local space
+	LocalVelocityZ         float32    // velocity in local space
+	AngularVelocityX       float32    // angular velocity x-component
+	AngularVelocityY       float32    // angular velocity y-component
+	AngularVelocityZ       float32    // angular velocity z-component
+	AngularAccelerationX   float32    // angular acceleration x-component
+	AngularAccelerationY   float32    // angular acceleration y-component
+	AngularAccelerationZ   float32    // angular acceleration z-component
+	FrontWheelsAngle       float32    // current front wheels angle in radians
+}
+
+type PacketSessionData2019 struct {
+	PacketHeader2019
+	Weather             uint8  // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature    int8   // track temp. in degrees celsius
+	AirTemperature      int8   // air temp. in degrees celsius
+	TotalLaps           uint8  // total number of laps in this race
+	TrackLength         uint16 // track length in metres
+	SessionType         uint8  // 0 = unknown, 1 = P1, 2 = P2, 3 = P3, 4 = Short P, 5 = Q1, 6 = Q2, 7 = Q3, 8 = Short Q, 9 = OSQ, 10 = R, 11 = R2, 12 = Time Trial
+	TrackID             int8   // -1 for unknown, 0-21 for tracks
+	Formula             uint8  // 0 = F1 Modern, 1 = F1 Classic, 2 = F2, 3 = F1 Generic
+	SessionTimeLeft     uint16 // time left in session in seconds
+	SessionDuration     uint16 // session duration in seconds
+	PitSpeedLimit       uint8  // pit speed limit in kilometres per hour
+	GamePaused          uint8  // whether the game is paused
+	IsSpectating        uint8  // whether the player is spectating
+	SpectatorCarIndex   uint8  // index of the car being spectated
+	SliProNativeSupport uint8  // SLI Pro support, 0 = inactive, 1 = active
+	NumMarshalZones     uint8  // number of marshal zones to follow
+	MarshalZones        [21]MarshalZone2018
+	SafetyCarStatus     uint8 // 0 = no safety car, 1 = full safety car, 2 = virtual safety car
+	NetworkGame         uint8 // 0 = offline, 1 = online
+}
+
+type PacketLapData2019 struct {
+	PacketHeader2019
+	LapData [20]LapData2018 // lap data for all cars on track
+}
+
+type PacketEventData2019 struct {
+	PacketHeader2019
+	EventStringCode [4]uint8 // "SSTA", "SEND", "FTLP", "RTMT", "DRSE", "DRSD", "TMPT", "CHQF" or "RCWN"
+	EventDetails    [5]uint8 // event specific details, e.g. vehicle index and lap time of a fastest lap
+}
+
+type ParticipantData2019 struct {
+	AIControlled  uint8     // whether the vehicle is AI (1) or human (0) controlled
+	DriverID      uint8     // driver id
+	TeamID        uint8     // team id
+	RaceNumber    uint8     // race number of the car
+	Nationality   uint8     // nationality of the driver
+	Name          [48]uint8 // name of participant in UTF-8 format, null terminated
+	YourTelemetry uint8     // the player's UDP setting, 0 = restricted, 1 = public
+}
+
+type PacketParticipantsData2019 struct {
+	PacketHeader2019
+	NumActiveCars uint8 // number of active cars in the data
+	Participants  [20]ParticipantData2019
+}
+
+type PacketCarSetupData2019 struct {
+	PacketHeader2019
+	CarSetups [20]CarSetupData2018
+}
+
+type CarTelemetryData2019 struct {
+	Speed                   uint16     // speed of car in kilometres per hour
+	Throttle                float32    // amount of throttle applied (0.0 to 1.0)
+	Steer                   float32    // steering (-1.0 (full lock left) to 1.0 (full lock right))
+	Brake                   float32    // amount of brake applied (0.0 to 1.0)
+	Clutch                  uint8      // amount of clutch applied (0 to 100)
+	Gear                    int8       // gear selected (1-8, N=0, R=-1)
+	EngineRPM               uint16     // engine RPM
+	DRS                     uint8      // 0 = off, 1 = on
+	RevLightsPercent        uint8      // rev lights indicator (percentage)
+	BrakesTemperature       [4]uint16  // brakes temperature (celsius)
+	TyresSurfaceTemperature [4]uint16  // tyres surface temperature (celsius)
+	TyresInnerTemperature   [4]uint16  // tyres inner temperature (celsius)
+	EngineTemperature       uint16     // engine temperature (celsius)
+	TyresPressure           [4]float32 // tyres pressure (PSI)
+	SurfaceType             [4]uint8   // driving surface
+}
+
+type PacketCarTelemetryData2019 struct {
+	PacketHeader2019
+	CarTelemetryData [20]CarTelemetryData2019
+	ButtonStatus     uint32 // bit flags specifying which buttons are being pressed currently
+}
+
+type CarStatusData2019 struct {
+	TractionControl         uint8    // 0 (off) - 2 (high)
+	AntiLockBrakes          uint8    // 0 (off) - 1 (on)
+	FuelMix                 uint8    // fuel mix - 0 = lean, 1 = standard, 2 = rich, 3 = max
+	FrontBrakeBias          uint8    // front brake bias (percentage)
+	PitLimiterStatus        uint8    // pit limiter status - 0 = off, 1 = on
+	FuelInTank              float32  // current fuel mass
+	FuelCapacity            float32  // fuel capacity
+	FuelRemainingLaps       float32  // fuel remaining in terms of laps
+	MaxRPM                  uint16   // cars max RPM, point of rev limiter
+	IdleRPM                 uint16   // cars idle RPM
+	MaxGears                uint8    // maximum number of gears
+	DRSAllowed              int8     // 0 = not allowed, 1 = allowed, -1 = unknown
+	TyresWear               [4]uint8 // tyre wear percentage
+	ActualTyreCompound      uint8    // 16 = C5, 17 = C4, 18 = C3, 19 = C2, 20 = C1, 7 = inter, 8 = wet
+	VisualTyreCompound      uint8    // 16 = soft, 17 = medium, 18 = hard, 7 = inter, 8 = wet
+	TyresDamage             [4]uint8 // tyre damage (percentage)
+	FrontLeftWingDamage     uint8    // front left wing damage (percentage)
+	FrontRightWingDamage    uint8    // front right wing damage (percentage)
+	RearWingDamage          uint8    // rear wing damage (percentage)
+	EngineDamage            uint8    // engine damage (percentage)
+	GearBoxDamage           uint8    // gear box damage (percentage)
+	VehicleFIAFlags         int8     // -1 = invalid/unknown, 0 = none, 1 = green, 2 = blue, 3 = yellow, 4 = red
+	ERSStoreEnergy          float32  // ERS energy store in Joules
+	ERSDeployMode           uint8    // 0 = none, 1 = low, 2 = medium, 3 = high, 4 = overtake, 5 = hotlap
+	ERSHarvestedThisLapMGUK float32  // ERS energy harvested this lap by MGU-K
+	ERSHarvestedThisLapMGUH float32  // ERS energy harvested this lap by MGU-H
+	ERSDeployedThisLap      float32  // ERS energy deployed this lap
+}
+
+type PacketCarStatusData2019 struct {
+	PacketHeader2019
+	CarStatusData [20]CarStatusData2019
+}
+
+func (h PacketHeader2019) PacketHeader() PacketHeader {
+	return PacketHeader{
+		PacketFormat:     h.PacketFormat,
+		GameMajorVersion: h.GameMajorVersion,
+		GameMinorVersion: h.GameMinorVersion,
+		PacketVersion:    h.PacketVersion,
+		PacketID:         h.PacketID,
+		SessionUID:       h.SessionUID,
+		SessionTime:      h.SessionTime,
+		FrameIdentifier:  h.FrameIdentifier,
+		PlayerCarIndex:   h.PlayerCarIndex,
+	}
+}
+
+func (p *PacketMotionData2019) apply(s *State) {
+	s.applyCarMotion(p.CarMotionData[:])
+	s.applyPlayerMotion(playerMotion{
+		suspensionPosition:     p.SuspensionPosition,
+		suspensionVelocity:     p.SuspensionVelocity,
+		suspensionAcceleration: p.SuspensionAcceleration,
+		wheelSpeed:             p.WheelSpeed,
+		localVelocity:          [3]float32{p.LocalVelocityX, p.LocalVelocityY, p.LocalVelocityZ},
+		angularVelocity:        [3]float32{p.AngularVelocityX, p.AngularVelocityY, p.AngularVelocityZ},
+		angularAcceleration:    [3]float32{p.AngularAccelerationX, p.AngularAccelerationY, p.AngularAccelerationZ},
+	})
+}
+
+func (p *PacketSessionData2019) apply(s *State) {
+	s.applySession(session{
+		weather:             p.Weather,
+		trackTemperature:    p.TrackTemperature,
+		airTemperature:      p.AirTemperature,
+		totalLaps:           p.TotalLaps,
+		trackLength:         p.TrackLength,
+		sessionType:         legacySessionType(p.SessionType, 9, 11),
+		trackID:             p.TrackID,
+		era:                 legacyEra(p.Formula),
+		sessionTimeLeft:     p.SessionTimeLeft,
+		pitSpeedLimit:       p.PitSpeedLimit,
+		gamePaused:          p.GamePaused,
+		isSpectating:        p.IsSpectating,
+		spectatorCarIndex:   p.SpectatorCarIndex,
+		sliProNativeSupport: p.SliProNativeSupport,
+		safetyCarStatus:     p.SafetyCarStatus,
+		networkGame:         p.NetworkGame,
+	})
+}
+
+func (p *PacketLapData2019) apply(s *State) {
+	for i, l := range p.LapData {
+		s.applyLap(i, l.lap())
+		if c := s.car(i); c != nil {
+			c.BestlapTime = l.BestLapTime
+		}
+	}
+}
+
+func (p *PacketEventData2019) apply(s *State) {
+	s.Event = string(p.EventStringCode[:])
+}
+
+func (p *PacketParticipantsData2019) apply(s *State) {
+	s.NumCars = p.NumActiveCars
+	for i, d := range p.Participants {
+		s.applyParticipant(i, d.DriverID, d.TeamID, d.Name[:])
+	}
+}
+
+// Car setups have no 2017 counterpart.
+func (p *PacketCarSetupData2019) apply(s *State) {}
+
+func (p *PacketCarTelemetryData2019) apply(s *State) {
+	i, ok := s.player(len(p.CarTelemetryData))
+	if !ok {
+		return
+	}
+	t := p.CarTelemetryData[i]
+	s.applyCarTelemetry(carTelemetry{
+		speed:                   t.Speed,
+		throttle:                t.Throttle,
+		steer:                   t.Steer,
+		brake:                   t.Brake,
+		clutch:                  t.Clutch,
+		gear:                    t.Gear,
+		engineRPM:               t.EngineRPM,
+		drs:                     t.DRS,
+		revLightsPercent:        t.RevLightsPercent,
+		brakesTemperature:       t.BrakesTemperature,
+		tyresSurfaceTemperature: t.TyresSurfaceTemperature,
+		engineTemperature:       t.EngineTemperature,
+		tyresPressure:           t.TyresPressure,
+	})
+}
+
+func (p *PacketCarStatusData2019) apply(s *State) {
+	for i, c := range p.CarStatusData {
+		if car := s.car(i); car != nil {
+			car.TyreCompound = legacyVisualCompound(c.VisualTyreCompound)
+		}
+	}
+
+	i, ok := s.player(len(p.CarStatusData))
+	if !ok {
+		return
+	}
+	c := p.CarStatusData[i]
+	s.applyCarStatus(carStatus{
+		tractionControl:  c.TractionControl,
+		antiLockBrakes:   c.AntiLockBrakes,
+		fuelMix:          c.FuelMix,
+		frontBrakeBias:   c.FrontBrakeBias,
+		pitLimiterStatus: c.PitLimiterStatus,
+		fuelInTank:       c.FuelInTank,
+		fuelCapacity:     c.FuelCapacity,
+		maxRPM:           c.MaxRPM,
+		idleRPM:          c.IdleRPM,
+		maxGears:         c.MaxGears,
+		drsAllowed:       c.DRSAllowed,
+		tyreCompound:     legacyVisualCompound(c.VisualTyreCompound),
+		vehicleFIAFlags:  c.VehicleFIAFlags,
+		ersStoreEnergy:   c.ERSStoreEnergy,
+		ersDeployMode:    c.ERSDeployMode,
+	})
+	s.applyCarDamage(carDamage{
+		tyresWear:            c.TyresWear,
+		tyresDamage:          c.TyresDamage,
+		frontLeftWingDamage:  c.FrontLeftWingDamage,
+		frontRightWingDamage: c.FrontRightWingDamage,
+		rearWingDamage:       c.RearWingDamage,
+		engineDamage:         c.EngineDamage,
+		gearBoxDamage:        c.GearBoxDamage,
+	})
+}
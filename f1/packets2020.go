@@ -0,0 +1,430 @@
+package f1
+
+// Packet layouts of the F1 2020 UDP specification. The car arrays grew to 22
+// entries to make room for My Team, and lap data switched sector times to
+// milliseconds.
+
+func init() {
+	registerFormat(format{
+		packetFormat:   2020,
+		versionOffset:  2,
+		packetIDOffset: 5,
+		header:         func() packetHeader { return new(PacketHeader2020) },
+		packets: map[uint8]func() Packet{
+			PacketMotion:              func() Packet { return new(PacketMotionData2020) },
+			PacketSession:             func() Packet { return new(PacketSessionData2020) },
+			PacketLapData:             func() Packet { return new(PacketLapData2020) },
+			PacketEvent:               func() Packet { return new(PacketEventData2020) },
+			PacketParticipants:        func() Packet { return new(PacketParticipantsData2020) },
+			PacketCarSetups:           func() Packet { return new(PacketCarSetupData2020) },
+			PacketCarTelemetry:        func() Packet { return new(PacketCarTelemetryData2020) },
+			PacketCarStatus:           func() Packet { return new(PacketCarStatusData2020) },
+			PacketFinalClassification: func() Packet { return new(PacketFinalClassificationData2020) },
+			PacketLobbyInfo:           func() Packet { return new(PacketLobbyInfoData2020) },
+		},
+	})
+}
+
+type PacketHeader2020 struct {
+	PacketFormat            uint16  // 2020, 2021 or 2022
+	GameMajorVersion        uint8   // game major version - "X.00"
+	GameMinorVersion        uint8   // game minor version - "1.XX"
+	PacketVersion           uint8   // version of this packet type, starts from 1
+	PacketID                uint8   // identifier for the packet type
+	SessionUID              uint64  // unique identifier for the session
+	SessionTime             float32 // session timestamp
+	FrameIdentifier         uint32  // identifier for the frame the data was retrieved on
+	PlayerCarIndex          uint8   // index of player's car in the array
+	SecondaryPlayerCarIndex uint8   // index of secondary player's car in the array (splitscreen), 255 if none
+}
+
+type PacketMotionData2020 struct {
+	PacketHeader2020
+	CarMotionData [22]CarMotionData2018 // data for all cars on track
+
+	// Extra player car only data
+	SuspensionPosition     [4]float32
+	SuspensionVelocity     [4]float32
+	SuspensionAcceleration [4]float32
+	WheelSpeed             [4]float32 // speed of each wheel
+	WheelSlip              [4]float32 // slip ratio for each wheel
+	LocalVelocityX         float32    // velocity in local space
+	LocalVelocityY         float32    // velocity in local space
+	LocalVelocityZ         float32    // velocity in local space
+	AngularVelocityX       float32    // angular velocity x-component
+	AngularVelocityY       float32    // angular velocity y-component
+	AngularVelocityZ       float32    // angular velocity z-component
+	AngularAccelerationX   float32    // angular acceleration x-component
+	AngularAccelerationY   float32    // angular acceleration y-component
+	AngularAccelerationZ   float32    // angular acceleration z-component
+	FrontWheelsAngle       float32    // current front wheels angle in radians
+}
+
+type WeatherForecastSample2020 struct {
+	SessionType      uint8 // session type, see PacketSessionData2020
+	TimeOffset       uint8 // time in minutes the forecast is for
+	Weather          uint8 // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature int8  // track temp. in degrees celsius
+	AirTemperature   int8  // air temp. in degrees celsius
+}
+
+type PacketSessionData2020 struct {
+	PacketHeader2020
+	Weather                   uint8  // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature          int8   // track temp. in degrees celsius
+	AirTemperature            int8   // air temp. in degrees celsius
+	TotalLaps                 uint8  // total number of laps in this race
+	TrackLength               uint16 // track length in metres
+	SessionType               uint8  // 0 = unknown, 1 = P1, 2 = P2, 3 = P3, 4 = Short P, 5 = Q1, 6 = Q2, 7 = Q3, 8 = Short Q, 9 = OSQ, 10 = R, 11 = R2, 12 = Time Trial
+	TrackID                   int8   // -1 for unknown, 0-21 for tracks
+	Formula                   uint8  // 0 = F1 Modern, 1 = F1 Classic, 2 = F2, 3 = F1 Generic
+	SessionTimeLeft           uint16 // time left in session in seconds
+	SessionDuration           uint16 // session duration in seconds
+	PitSpeedLimit             uint8  // pit speed limit in kilometres per hour
+	GamePaused                uint8  // whether the game is paused
+	IsSpectating              uint8  // whether the player is spectating
+	SpectatorCarIndex         uint8  // index of the car being spectated
+	SliProNativeSupport       uint8  // SLI Pro support, 0 = inactive, 1 = active
+	NumMarshalZones           uint8  // number of marshal zones to follow
+	MarshalZones              [21]MarshalZone2018
+	SafetyCarStatus           uint8 // 0 = no safety car, 1 = full safety car, 2 = virtual safety car
+	NetworkGame               uint8 // 0 = offline, 1 = online
+	NumWeatherForecastSamples uint8 // number of weather samples to follow
+	WeatherForecastSamples    [20]WeatherForecastSample2020
+}
+
+type LapData2020 struct {
+	LastLapTime                float32 // last lap time in seconds
+	CurrentLapTime             float32 // current time around the lap in seconds
+	Sector1TimeInMS            uint16  // sector 1 time in milliseconds
+	Sector2TimeInMS            uint16  // sector 2 time in milliseconds
+	BestLapTime                float32 // best lap time of the session in seconds
+	BestLapNum                 uint8   // lap number best time achieved on
+	BestLapSector1TimeInMS     uint16  // sector 1 time of best lap in the session in milliseconds
+	BestLapSector2TimeInMS     uint16  // sector 2 time of best lap in the session in milliseconds
+	BestLapSector3TimeInMS     uint16  // sector 3 time of best lap in the session in milliseconds
+	BestOverallSector1TimeInMS uint16  // best overall sector 1 time of the session in milliseconds
+	BestOverallSector1LapNum   uint8   // lap number best overall sector 1 time achieved on
+	BestOverallSector2TimeInMS uint16  // best overall sector 2 time of the session in milliseconds
+	BestOverallSector2LapNum   uint8   // lap number best overall sector 2 time achieved on
+	BestOverallSector3TimeInMS uint16  // best overall sector 3 time of the session in milliseconds
+	BestOverallSector3LapNum   uint8   // lap number best overall sector 3 time achieved on
+	LapDistance                float32 // distance vehicle is around current lap in metres
+	TotalDistance              float32 // total distance travelled in session in metres
+	SafetyCarDelta             float32 // delta in seconds for safety car
+	CarPosition                uint8   // car race position
+	CurrentLapNum              uint8   // current lap number
+	PitStatus                  uint8   // 0 = none, 1 = pitting, 2 = in pit area
+	Sector                     uint8   // 0 = sector1, 1 = sector2, 2 = sector3
+	CurrentLapInvalid          uint8   // current lap invalid - 0 = valid, 1 = invalid
+	Penalties                  uint8   // accumulated time penalties in seconds to be added
+	GridPosition               uint8   // grid position the vehicle started the race in
+	DriverStatus               uint8   // 0 = in garage, 1 = flying lap, 2 = in lap, 3 = out lap, 4 = on track
+	ResultStatus               uint8   // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = disqualified, 5 = not classified, 6 = retired
+}
+
+type PacketLapData2020 struct {
+	PacketHeader2020
+	LapData [22]LapData2020 // lap data for all cars on track
+}
+
+type PacketEventData2020 struct {
+	PacketHeader2020
+	EventStringCode [4]uint8 // event string code, e.g. "SSTA", "FTLP", "PENA" or "SPTP"
+	EventDetails    [7]uint8 // event specific details, e.g. vehicle index and lap time of a fastest lap
+}
+
+type PacketParticipantsData2020 struct {
+	PacketHeader2020
+	NumActiveCars uint8 // number of active cars in the data
+	Participants  [22]ParticipantData2019
+}
+
+type CarSetupData2020 struct {
+	FrontWing              uint8   // front wing aero
+	RearWing               uint8   // rear wing aero
+	OnThrottle             uint8   // differential adjustment on throttle (percentage)
+	OffThrottle            uint8   // differential adjustment off throttle (percentage)
+	FrontCamber            float32 // front camber angle (suspension geometry)
+	RearCamber             float32 // rear camber angle (suspension geometry)
+	FrontToe               float32 // front toe angle (suspension geometry)
+	RearToe                float32 // rear toe angle (suspension geometry)
+	FrontSuspension        uint8   // front suspension
+	RearSuspension         uint8   // rear suspension
+	FrontAntiRollBar       uint8   // front anti-roll bar
+	RearAntiRollBar        uint8   // rear anti-roll bar
+	FrontSuspensionHeight  uint8   // front ride height
+	RearSuspensionHeight   uint8   // rear ride height
+	BrakePressure          uint8   // brake pressure (percentage)
+	BrakeBias              uint8   // brake bias (percentage)
+	RearLeftTyrePressure   float32 // rear left tyre pressure (PSI)
+	RearRightTyrePressure  float32 // rear right tyre pressure (PSI)
+	FrontLeftTyrePressure  float32 // front left tyre pressure (PSI)
+	FrontRightTyrePressure float32 // front right tyre pressure (PSI)
+	Ballast                uint8   // ballast
+	FuelLoad               float32 // fuel load
+}
+
+type PacketCarSetupData2020 struct {
+	PacketHeader2020
+	CarSetups [22]CarSetupData2020
+}
+
+type CarTelemetryData2020 struct {
+	Speed                   uint16     // speed of car in kilometres per hour
+	Throttle                float32    // amount of throttle applied (0.0 to 1.0)
+	Steer                   float32    // steering (-1.0 (full lock left) to 1.0 (full lock right))
+	Brake                   float32    // amount of brake applied (0.0 to 1.0)
+	Clutch                  uint8      // amount of clutch applied (0 to 100)
+	Gear                    int8       // gear selected (1-8, N=0, R=-1)
+	EngineRPM               uint16     // engine RPM
+	DRS                     uint8      // 0 = off, 1 = on
+	RevLightsPercent        uint8      // rev lights indicator (percentage)
+	BrakesTemperature       [4]uint16  // brakes temperature (celsius)
+	TyresSurfaceTemperature [4]uint8   // tyres surface temperature (celsius)
+	TyresInnerTemperature   [4]uint8   // tyres inner temperature (celsius)
+	EngineTemperature       uint16     // engine temperature (celsius)
+	TyresPressure           [4]float32 // tyres pressure (PSI)
+	SurfaceType             [4]uint8   // driving surface
+}
+
+type PacketCarTelemetryData2020 struct {
+	PacketHeader2020
+	CarTelemetryData             [22]CarTelemetryData2020
+	ButtonStatus                 uint32 // bit flags specifying which buttons are being pressed currently
+	MFDPanelIndex                uint8  // index of MFD panel open, 255 = MFD closed
+	MFDPanelIndexSecondaryPlayer uint8  // see above
+	SuggestedGear                int8   // suggested gear for the player (1-8), 0 if no gear suggested
+}
+
+type CarStatusData2020 struct {
+	TractionControl         uint8    // 0 (off) - 2 (high)
+	AntiLockBrakes          uint8    // 0 (off) - 1 (on)
+	FuelMix                 uint8    // fuel mix - 0 = lean, 1 = standard, 2 = rich, 3 = max
+	FrontBrakeBias          uint8    // front brake bias (percentage)
+	PitLimiterStatus        uint8    // pit limiter status - 0 = off, 1 = on
+	FuelInTank              float32  // current fuel mass
+	FuelCapacity            float32  // fuel capacity
+	FuelRemainingLaps       float32  // fuel remaining in terms of laps
+	MaxRPM                  uint16   // cars max RPM, point of rev limiter
+	IdleRPM                 uint16   // cars idle RPM
+	MaxGears                uint8    // maximum number of gears
+	DRSAllowed              uint8    // 0 = not allowed, 1 = allowed
+	DRSActivationDistance   uint16   // 0 = DRS not available, non-zero = DRS will be available in [X] metres
+	TyresWear               [4]uint8 // tyre wear percentage
+	ActualTyreCompound      uint8    // 16 = C5, 17 = C4, 18 = C3, 19 = C2, 20 = C1, 7 = inter, 8 = wet
+	VisualTyreCompound      uint8    // 16 = soft, 17 = medium, 18 = hard, 7 = inter, 8 = wet
+	TyresAgeLaps            uint8    // age in laps of the current set of tyres
+	TyresDamage             [4]uint8 // tyre damage (percentage)
+	FrontLeftWingDamage     uint8    // front left wing damage (percentage)
+	FrontRightWingDamage    uint8    // front right wing damage (percentage)
+	RearWingDamage          uint8    // rear wing damage (percentage)
+	DRSFault                uint8    // indicator for DRS fault, 0 = OK, 1 = fault
+	EngineDamage            uint8    // engine damage (percentage)
+	GearBoxDamage           uint8    // gear box damage (percentage)
+	VehicleFIAFlags         int8     // -1 = invalid/unknown, 0 = none, 1 = green, 2 = blue, 3 = yellow, 4 = red
+	ERSStoreEnergy          float32  // ERS energy store in Joules
+	ERSDeployMode           uint8    // 0 = none, 1 = medium, 2 = overtake, 3 = hotlap
+	ERSHarvestedThisLapMGUK float32  // ERS energy harvested this lap by MGU-K
+	ERSHarvestedThisLapMGUH float32  // ERS energy harvested this lap by MGU-H
+	ERSDeployedThisLap      float32  // ERS energy deployed this lap
+}
+
+type PacketCarStatusData2020 struct {
+	PacketHeader2020
+	CarStatusData [22]CarStatusData2020
+}
+
+type FinalClassificationData2020 struct {
+	Position         uint8    // finishing position
+	NumLaps          uint8    // number of laps completed
+	GridPosition     uint8    // grid position of the car
+	Points           uint8    // number of points scored
+	NumPitStops      uint8    // number of pit stops made
+	ResultStatus     uint8    // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = disqualified, 5 = not classified, 6 = retired
+	BestLapTime      float32  // best lap time of the session in seconds
+	TotalRaceTime    float64  // total race time in seconds without penalties
+	PenaltiesTime    uint8    // total penalties accumulated in seconds
+	NumPenalties     uint8    // number of penalties applied to this driver
+	NumTyreStints    uint8    // number of tyres stints up to maximum
+	TyreStintsActual [8]uint8 // actual tyres used by this driver
+	TyreStintsVisual [8]uint8 // visual tyres used by this driver
+}
+
+type PacketFinalClassificationData2020 struct {
+	PacketHeader2020
+	NumCars            uint8 // number of cars in the final classification
+	ClassificationData [22]FinalClassificationData2020
+}
+
+type LobbyInfoData2020 struct {
+	AIControlled uint8     // whether the vehicle is AI (1) or human (0) controlled
+	TeamID       uint8     // team id, 255 if no team currently selected
+	Nationality  uint8     // nationality of the driver
+	Name         [48]uint8 // name of participant in UTF-8 format, null terminated
+	ReadyStatus  uint8     // 0 = not ready, 1 = ready, 2 = spectating
+}
+
+type PacketLobbyInfoData2020 struct {
+	PacketHeader2020
+	NumPlayers   uint8 // number of players in the lobby data
+	LobbyPlayers [22]LobbyInfoData2020
+}
+
+func (h PacketHeader2020) PacketHeader() PacketHeader {
+	return PacketHeader{
+		PacketFormat:            h.PacketFormat,
+		GameMajorVersion:        h.GameMajorVersion,
+		GameMinorVersion:        h.GameMinorVersion,
+		PacketVersion:           h.PacketVersion,
+		PacketID:                h.PacketID,
+		SessionUID:              h.SessionUID,
+		SessionTime:             h.SessionTime,
+		FrameIdentifier:         h.FrameIdentifier,
+		PlayerCarIndex:          h.PlayerCarIndex,
+		SecondaryPlayerCarIndex: h.SecondaryPlayerCarIndex,
+	}
+}
+
+func (p *PacketMotionData2020) apply(s *State) {
+	s.applyCarMotion(p.CarMotionData[:])
+	s.applyPlayerMotion(playerMotion{
+		suspensionPosition:     p.SuspensionPosition,
+		suspensionVelocity:     p.SuspensionVelocity,
+		suspensionAcceleration: p.SuspensionAcceleration,
+		wheelSpeed:             p.WheelSpeed,
+		localVelocity:          [3]float32{p.LocalVelocityX, p.LocalVelocityY, p.LocalVelocityZ},
+		angularVelocity:        [3]float32{p.AngularVelocityX, p.AngularVelocityY, p.AngularVelocityZ},
+		angularAcceleration:    [3]float32{p.AngularAccelerationX, p.AngularAccelerationY, p.AngularAccelerationZ},
+	})
+}
+
+func (p *PacketSessionData2020) apply(s *State) {
+	s.applySession(session{
+		weather:             p.Weather,
+		trackTemperature:    p.TrackTemperature,
+		airTemperature:      p.AirTemperature,
+		totalLaps:           p.TotalLaps,
+		trackLength:         p.TrackLength,
+		sessionType:         legacySessionType(p.SessionType, 9, 11),
+		trackID:             p.TrackID,
+		era:                 legacyEra(p.Formula),
+		sessionTimeLeft:     p.SessionTimeLeft,
+		pitSpeedLimit:       p.PitSpeedLimit,
+		gamePaused:          p.GamePaused,
+		isSpectating:        p.IsSpectating,
+		spectatorCarIndex:   p.SpectatorCarIndex,
+		sliProNativeSupport: p.SliProNativeSupport,
+		safetyCarStatus:     p.SafetyCarStatus,
+		networkGame:         p.NetworkGame,
+	})
+}
+
+func (p *PacketLapData2020) apply(s *State) {
+	for i, l := range p.LapData {
+		s.applyLap(i, lap{
+			lastLapTime:       l.LastLapTime,
+			currentLapTime:    l.CurrentLapTime,
+			sector1Time:       seconds(uint32(l.Sector1TimeInMS)),
+			sector2Time:       seconds(uint32(l.Sector2TimeInMS)),
+			lapDistance:       l.LapDistance,
+			totalDistance:     l.TotalDistance,
+			carPosition:       l.CarPosition,
+			currentLapNum:     l.CurrentLapNum,
+			pitStatus:         l.PitStatus,
+			sector:            l.Sector,
+			currentLapInvalid: l.CurrentLapInvalid,
+			penalties:         l.Penalties,
+		})
+		if c := s.car(i); c != nil {
+			c.BestlapTime = l.BestLapTime
+		}
+	}
+}
+
+func (p *PacketEventData2020) apply(s *State) {
+	s.Event = string(p.EventStringCode[:])
+}
+
+func (p *PacketParticipantsData2020) apply(s *State) {
+	s.NumCars = p.NumActiveCars
+	for i, d := range p.Participants {
+		s.applyParticipant(i, d.DriverID, d.TeamID, d.Name[:])
+	}
+}
+
+// Car setups have no 2017 counterpart.
+func (p *PacketCarSetupData2020) apply(s *State) {}
+
+func (t CarTelemetryData2020) carTelemetry() carTelemetry {
+	c := carTelemetry{
+		speed:             t.Speed,
+		throttle:          t.Throttle,
+		steer:             t.Steer,
+		brake:             t.Brake,
+		clutch:            t.Clutch,
+		gear:              t.Gear,
+		engineRPM:         t.EngineRPM,
+		drs:               t.DRS,
+		revLightsPercent:  t.RevLightsPercent,
+		brakesTemperature: t.BrakesTemperature,
+		engineTemperature: t.EngineTemperature,
+		tyresPressure:     t.TyresPressure,
+	}
+	for i, v := range t.TyresSurfaceTemperature {
+		c.tyresSurfaceTemperature[i] = uint16(v)
+	}
+	return c
+}
+
+func (p *PacketCarTelemetryData2020) apply(s *State) {
+	if i, ok := s.player(len(p.CarTelemetryData)); ok {
+		s.applyCarTelemetry(p.CarTelemetryData[i].carTelemetry())
+	}
+}
+
+func (p *PacketCarStatusData2020) apply(s *State) {
+	for i, c := range p.CarStatusData {
+		if car := s.car(i); car != nil {
+			car.TyreCompound = legacyVisualCompound(c.VisualTyreCompound)
+		}
+	}
+
+	i, ok := s.player(len(p.CarStatusData))
+	if !ok {
+		return
+	}
+	c := p.CarStatusData[i]
+	s.applyCarStatus(carStatus{
+		tractionControl:  c.TractionControl,
+		antiLockBrakes:   c.AntiLockBrakes,
+		fuelMix:          c.FuelMix,
+		frontBrakeBias:   c.FrontBrakeBias,
+		pitLimiterStatus: c.PitLimiterStatus,
+		fuelInTank:       c.FuelInTank,
+		fuelCapacity:     c.FuelCapacity,
+		maxRPM:           c.MaxRPM,
+		idleRPM:          c.IdleRPM,
+		maxGears:         c.MaxGears,
+		drsAllowed:       int8(c.DRSAllowed),
+		tyreCompound:     legacyVisualCompound(c.VisualTyreCompound),
+		vehicleFIAFlags:  c.VehicleFIAFlags,
+		ersStoreEnergy:   c.ERSStoreEnergy,
+		ersDeployMode:    c.ERSDeployMode,
+	})
+	s.applyCarDamage(carDamage{
+		tyresWear:            c.TyresWear,
+		tyresDamage:          c.TyresDamage,
+		frontLeftWingDamage:  c.FrontLeftWingDamage,
+		frontRightWingDamage: c.FrontRightWingDamage,
+		rearWingDamage:       c.RearWingDamage,
+		engineDamage:         c.EngineDamage,
+		gearBoxDamage:        c.GearBoxDamage,
+	})
+}
+
+// The final classification repeats what lap data already reported.
+func (p *PacketFinalClassificationData2020) apply(s *State) {}
+
+// Lobby info is only sent before a session starts.
+func (p *PacketLobbyInfoData2020) apply(s *State) {}
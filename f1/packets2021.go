@@ -0,0 +1,432 @@
+package f1
+
+// Packet layouts of the F1 2021 UDP specification. Lap times moved to
+// milliseconds, damage moved out of car status into its own packet and best
+// laps are only reported through the session history.
+
+func init() {
+	registerFormat(format{
+		packetFormat:   2021,
+		versionOffset:  2,
+		packetIDOffset: 5,
+		header:         func() packetHeader { return new(PacketHeader2020) },
+		packets: map[uint8]func() Packet{
+			PacketMotion:              func() Packet { return new(PacketMotionData2020) },
+			PacketSession:             func() Packet { return new(PacketSessionData2021) },
+			PacketLapData:             func() Packet { return new(PacketLapData2021) },
+			PacketEvent:               func() Packet { return new(PacketEventData2021) },
+			PacketParticipants:        func() Packet { return new(PacketParticipantsData2021) },
+			PacketCarSetups:           func() Packet { return new(PacketCarSetupData2020) },
+			PacketCarTelemetry:        func() Packet { return new(PacketCarTelemetryData2021) },
+			PacketCarStatus:           func() Packet { return new(PacketCarStatusData2021) },
+			PacketFinalClassification: func() Packet { return new(PacketFinalClassificationData2021) },
+			PacketLobbyInfo:           func() Packet { return new(PacketLobbyInfoData2021) },
+			PacketCarDamage:           func() Packet { return new(PacketCarDamageData2021) },
+			PacketSessionHistory:      func() Packet { return new(PacketSessionHistoryData2021) },
+		},
+	})
+}
+
+type WeatherForecastSample2021 struct {
+	SessionType            uint8 // session type, see PacketSessionData2021
+	TimeOffset             uint8 // time in minutes the forecast is for
+	Weather                uint8 // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature       int8  // track temp. in degrees celsius
+	TrackTemperatureChange int8  // track temp. change, 0 = up, 1 = down, 2 = no change
+	AirTemperature         int8  // air temp. in degrees celsius
+	AirTemperatureChange   int8  // air temp. change, 0 = up, 1 = down, 2 = no change
+	RainPercentage         uint8 // rain percentage (0-100)
+}
+
+type PacketSessionData2021 struct {
+	PacketHeader2020
+	Weather                   uint8  // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature          int8   // track temp. in degrees celsius
+	AirTemperature            int8   // air temp. in degrees celsius
+	TotalLaps                 uint8  // total number of laps in this race
+	TrackLength               uint16 // track length in metres
+	SessionType               uint8  // 0 = unknown, 1 = P1, 2 = P2, 3 = P3, 4 = Short P, 5 = Q1, 6 = Q2, 7 = Q3, 8 = Short Q, 9 = OSQ, 10 = R, 11 = R2, 12 = R3, 13 = Time Trial
+	TrackID                   int8   // -1 for unknown
+	Formula                   uint8  // 0 = F1 Modern, 1 = F1 Classic, 2 = F2, 3 = F1 Generic
+	SessionTimeLeft           uint16 // time left in session in seconds
+	SessionDuration           uint16 // session duration in seconds
+	PitSpeedLimit             uint8  // pit speed limit in kilometres per hour
+	GamePaused                uint8  // whether the game is paused
+	IsSpectating              uint8  // whether the player is spectating
+	SpectatorCarIndex         uint8  // index of the car being spectated
+	SliProNativeSupport       uint8  // SLI Pro support, 0 = inactive, 1 = active
+	NumMarshalZones           uint8  // number of marshal zones to follow
+	MarshalZones              [21]MarshalZone2018
+	SafetyCarStatus           uint8 // 0 = no safety car, 1 = full safety car, 2 = virtual safety car, 3 = formation lap
+	NetworkGame               uint8 // 0 = offline, 1 = online
+	NumWeatherForecastSamples uint8 // number of weather samples to follow
+	WeatherForecastSamples    [56]WeatherForecastSample2021
+	ForecastAccuracy          uint8  // 0 = perfect, 1 = approximate
+	AIDifficulty              uint8  // AI difficulty rating (0-110)
+	SeasonLinkIdentifier      uint32 // identifier for season, persists across saves
+	WeekendLinkIdentifier     uint32 // identifier for weekend, persists across saves
+	SessionLinkIdentifier     uint32 // identifier for session, persists across saves
+	PitStopWindowIdealLap     uint8  // ideal lap to pit on for current strategy (player)
+	PitStopWindowLatestLap    uint8  // latest lap to pit on for current strategy (player)
+	PitStopRejoinPosition     uint8  // predicted position to rejoin at (player)
+	SteeringAssist            uint8  // 0 = off, 1 = on
+	BrakingAssist             uint8  // 0 = off, 1 = low, 2 = medium, 3 = high
+	GearboxAssist             uint8  // 1 = manual, 2 = manual & suggested gear, 3 = auto
+	PitAssist                 uint8  // 0 = off, 1 = on
+	PitReleaseAssist          uint8  // 0 = off, 1 = on
+	ERSAssist                 uint8  // 0 = off, 1 = on
+	DRSAssist                 uint8  // 0 = off, 1 = on
+	DynamicRacingLine         uint8  // 0 = off, 1 = corners only, 2 = full
+	DynamicRacingLineType     uint8  // 0 = 2D, 1 = 3D
+}
+
+type LapData2021 struct {
+	LastLapTimeInMS             uint32  // last lap time in milliseconds
+	CurrentLapTimeInMS          uint32  // current time around the lap in milliseconds
+	Sector1TimeInMS             uint16  // sector 1 time in milliseconds
+	Sector2TimeInMS             uint16  // sector 2 time in milliseconds
+	LapDistance                 float32 // distance vehicle is around current lap in metres
+	TotalDistance               float32 // total distance travelled in session in metres
+	SafetyCarDelta              float32 // delta in seconds for safety car
+	CarPosition                 uint8   // car race position
+	CurrentLapNum               uint8   // current lap number
+	PitStatus                   uint8   // 0 = none, 1 = pitting, 2 = in pit area
+	NumPitStops                 uint8   // number of pit stops taken in this race
+	Sector                      uint8   // 0 = sector1, 1 = sector2, 2 = sector3
+	CurrentLapInvalid           uint8   // current lap invalid - 0 = valid, 1 = invalid
+	Penalties                   uint8   // accumulated time penalties in seconds to be added
+	Warnings                    uint8   // accumulated number of warnings issued
+	NumUnservedDriveThroughPens uint8   // num drive through pens left to serve
+	NumUnservedStopGoPens       uint8   // num stop go pens left to serve
+	GridPosition                uint8   // grid position the vehicle started the race in
+	DriverStatus                uint8   // 0 = in garage, 1 = flying lap, 2 = in lap, 3 = out lap, 4 = on track
+	ResultStatus                uint8   // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = didnotfinish, 5 = disqualified, 6 = not classified, 7 = retired
+	PitLaneTimerActive          uint8   // pit lane timing, 0 = inactive, 1 = active
+	PitLaneTimeInLaneInMS       uint16  // if active, the current time spent in the pit lane in ms
+	PitStopTimerInMS            uint16  // time of the actual pit stop in ms
+	PitStopShouldServePen       uint8   // whether the car should serve a penalty at this stop
+}
+
+type PacketLapData2021 struct {
+	PacketHeader2020
+	LapData [22]LapData2021 // lap data for all cars on track
+}
+
+type PacketEventData2021 struct {
+	PacketHeader2020
+	EventStringCode [4]uint8 // event string code, e.g. "SSTA", "FLBK" or "BUTN"
+	EventDetails    [8]uint8 // event specific details, e.g. vehicle index and lap time of a fastest lap
+}
+
+type ParticipantData2021 struct {
+	AIControlled  uint8     // whether the vehicle is AI (1) or human (0) controlled
+	DriverID      uint8     // driver id, 255 if network human
+	NetworkID     uint8     // network id, unique identifier for network players
+	TeamID        uint8     // team id
+	MyTeam        uint8     // my team flag, 1 = My Team, 0 = otherwise
+	RaceNumber    uint8     // race number of the car
+	Nationality   uint8     // nationality of the driver
+	Name          [48]uint8 // name of participant in UTF-8 format, null terminated
+	YourTelemetry uint8     // the player's UDP setting, 0 = restricted, 1 = public
+}
+
+type PacketParticipantsData2021 struct {
+	PacketHeader2020
+	NumActiveCars uint8 // number of active cars in the data
+	Participants  [22]ParticipantData2021
+}
+
+type CarTelemetryData2021 struct {
+	Speed                   uint16     // speed of car in kilometres per hour
+	Throttle                float32    // amount of throttle applied (0.0 to 1.0)
+	Steer                   float32    // steering (-1.0 (full lock left) to 1.0 (full lock right))
+	Brake                   float32    // amount of brake applied (0.0 to 1.0)
+	Clutch                  uint8      // amount of clutch applied (0 to 100)
+	Gear                    int8       // gear selected (1-8, N=0, R=-1)
+	EngineRPM               uint16     // engine RPM
+	DRS                     uint8      // 0 = off, 1 = on
+	RevLightsPercent        uint8      // rev lights indicator (percentage)
+	RevLightsBitValue       uint16     // rev lights (bit 0 = leftmost LED, bit 14 = rightmost LED)
+	BrakesTemperature       [4]uint16  // brakes temperature (celsius)
+	TyresSurfaceTemperature [4]uint8   // tyres surface temperature (celsius)
+	TyresInnerTemperature   [4]uint8   // tyres inner temperature (celsius)
+	EngineTemperature       uint16     // engine temperature (celsius)
+	TyresPressure           [4]float32 // tyres pressure (PSI)
+	SurfaceType             [4]uint8   // driving surface
+}
+
+type PacketCarTelemetryData2021 struct {
+	PacketHeader2020
+	CarTelemetryData             [22]CarTelemetryData2021
+	MFDPanelIndex                uint8 // index of MFD panel open, 255 = MFD closed
+	MFDPanelIndexSecondaryPlayer uint8 // see above
+	SuggestedGear                int8  // suggested gear for the player (1-8), 0 if no gear suggested
+}
+
+type CarStatusData2021 struct {
+	TractionControl         uint8   // 0 = off, 1 = medium, 2 = full
+	AntiLockBrakes          uint8   // 0 (off) - 1 (on)
+	FuelMix                 uint8   // fuel mix - 0 = lean, 1 = standard, 2 = rich, 3 = max
+	FrontBrakeBias          uint8   // front brake bias (percentage)
+	PitLimiterStatus        uint8   // pit limiter status - 0 = off, 1 = on
+	FuelInTank              float32 // current fuel mass
+	FuelCapacity            float32 // fuel capacity
+	FuelRemainingLaps       float32 // fuel remaining in terms of laps
+	MaxRPM                  uint16  // cars max RPM, point of rev limiter
+	IdleRPM                 uint16  // cars idle RPM
+	MaxGears                uint8   // maximum number of gears
+	DRSAllowed              uint8   // 0 = not allowed, 1 = allowed
+	DRSActivationDistance   uint16  // 0 = DRS not available, non-zero = DRS will be available in [X] metres
+	ActualTyreCompound      uint8   // 16 = C5, 17 = C4, 18 = C3, 19 = C2, 20 = C1, 7 = inter, 8 = wet
+	VisualTyreCompound      uint8   // 16 = soft, 17 = medium, 18 = hard, 7 = inter, 8 = wet
+	TyresAgeLaps            uint8   // age in laps of the current set of tyres
+	VehicleFIAFlags         int8    // -1 = invalid/unknown, 0 = none, 1 = green, 2 = blue, 3 = yellow, 4 = red
+	ERSStoreEnergy          float32 // ERS energy store in Joules
+	ERSDeployMode           uint8   // 0 = none, 1 = medium, 2 = hotlap, 3 = overtake
+	ERSHarvestedThisLapMGUK float32 // ERS energy harvested this lap by MGU-K
+	ERSHarvestedThisLapMGUH float32 // ERS energy harvested this lap by MGU-H
+	ERSDeployedThisLap      float32 // ERS energy deployed this lap
+	NetworkPaused           uint8   // whether the car is paused in a network game
+}
+
+type PacketCarStatusData2021 struct {
+	PacketHeader2020
+	CarStatusData [22]CarStatusData2021
+}
+
+type FinalClassificationData2021 struct {
+	Position         uint8    // finishing position
+	NumLaps          uint8    // number of laps completed
+	GridPosition     uint8    // grid position of the car
+	Points           uint8    // number of points scored
+	NumPitStops      uint8    // number of pit stops made
+	ResultStatus     uint8    // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = didnotfinish, 5 = disqualified, 6 = not classified, 7 = retired
+	BestLapTimeInMS  uint32   // best lap time of the session in milliseconds
+	TotalRaceTime    float64  // total race time in seconds without penalties
+	PenaltiesTime    uint8    // total penalties accumulated in seconds
+	NumPenalties     uint8    // number of penalties applied to this driver
+	NumTyreStints    uint8    // number of tyres stints up to maximum
+	TyreStintsActual [8]uint8 // actual tyres used by this driver
+	TyreStintsVisual [8]uint8 // visual tyres used by this driver
+}
+
+type PacketFinalClassificationData2021 struct {
+	PacketHeader2020
+	NumCars            uint8 // number of cars in the final classification
+	ClassificationData [22]FinalClassificationData2021
+}
+
+type LobbyInfoData2021 struct {
+	AIControlled uint8     // whether the vehicle is AI (1) or human (0) controlled
+	TeamID       uint8     // team id, 255 if no team currently selected
+	Nationality  uint8     // nationality of the driver
+	Name         [48]uint8 // name of participant in UTF-8 format, null terminated
+	CarNumber    uint8     // car number of the player
+	ReadyStatus  uint8     // 0 = not ready, 1 = ready, 2 = spectating
+}
+
+type PacketLobbyInfoData2021 struct {
+	PacketHeader2020
+	NumPlayers   uint8 // number of players in the lobby data
+	LobbyPlayers [22]LobbyInfoData2021
+}
+
+type CarDamageData2021 struct {
+	TyresWear            [4]float32 // tyre wear (percentage)
+	TyresDamage          [4]uint8   // tyre damage (percentage)
+	BrakesDamage         [4]uint8   // brakes damage (percentage)
+	FrontLeftWingDamage  uint8      // front left wing damage (percentage)
+	FrontRightWingDamage uint8      // front right wing damage (percentage)
+	RearWingDamage       uint8      // rear wing damage (percentage)
+	FloorDamage          uint8      // floor damage (percentage)
+	DiffuserDamage       uint8      // diffuser damage (percentage)
+	SidepodDamage        uint8      // sidepod damage (percentage)
+	DRSFault             uint8      // indicator for DRS fault, 0 = OK, 1 = fault
+	GearBoxDamage        uint8      // gear box damage (percentage)
+	EngineDamage         uint8      // engine damage (percentage)
+	EngineMGUHWear       uint8      // engine wear MGU-H (percentage)
+	EngineESWear         uint8      // engine wear ES (percentage)
+	EngineCEWear         uint8      // engine wear CE (percentage)
+	EngineICEWear        uint8      // engine wear ICE (percentage)
+	EngineMGUKWear       uint8      // engine wear MGU-K (percentage)
+	EngineTCWear         uint8      // engine wear TC (percentage)
+}
+
+type PacketCarDamageData2021 struct {
+	PacketHeader2020
+	CarDamageData [22]CarDamageData2021
+}
+
+type LapHistoryData2021 struct {
+	LapTimeInMS      uint32 // lap time in milliseconds
+	Sector1TimeInMS  uint16 // sector 1 time in milliseconds
+	Sector2TimeInMS  uint16 // sector 2 time in milliseconds
+	Sector3TimeInMS  uint16 // sector 3 time in milliseconds
+	LapValidBitFlags uint8  // 0x01 bit set = lap valid, 0x02 = sector 1 valid, 0x04 = sector 2 valid, 0x08 = sector 3 valid
+}
+
+type TyreStintHistoryData2021 struct {
+	EndLap             uint8 // lap the tyre usage ends on (255 of current tyre)
+	TyreActualCompound uint8 // actual tyres used by this driver
+	TyreVisualCompound uint8 // visual tyres used by this driver
+}
+
+type PacketSessionHistoryData2021 struct {
+	PacketHeader2020
+	CarIdx                uint8 // index of the car this lap data relates to
+	NumLaps               uint8 // num laps in the data (including current partial lap)
+	NumTyreStints         uint8 // number of tyre stints in the data
+	BestLapTimeLapNum     uint8 // lap the best lap time was achieved on
+	BestSector1LapNum     uint8 // lap the best sector 1 time was achieved on
+	BestSector2LapNum     uint8 // lap the best sector 2 time was achieved on
+	BestSector3LapNum     uint8 // lap the best sector 3 time was achieved on
+	LapHistoryData        [100]LapHistoryData2021
+	TyreStintsHistoryData [8]TyreStintHistoryData2021
+}
+
+func (l LapData2021) lap() lap {
+	return lap{
+		lastLapTime:       seconds(l.LastLapTimeInMS),
+		currentLapTime:    seconds(l.CurrentLapTimeInMS),
+		sector1Time:       seconds(uint32(l.Sector1TimeInMS)),
+		sector2Time:       seconds(uint32(l.Sector2TimeInMS)),
+		lapDistance:       l.LapDistance,
+		totalDistance:     l.TotalDistance,
+		carPosition:       l.CarPosition,
+		currentLapNum:     l.CurrentLapNum,
+		pitStatus:         l.PitStatus,
+		sector:            l.Sector,
+		currentLapInvalid: l.CurrentLapInvalid,
+		penalties:         l.Penalties,
+	}
+}
+
+func (t CarTelemetryData2021) carTelemetry() carTelemetry {
+	c := carTelemetry{
+		speed:             t.Speed,
+		throttle:          t.Throttle,
+		steer:             t.Steer,
+		brake:             t.Brake,
+		clutch:            t.Clutch,
+		gear:              t.Gear,
+		engineRPM:         t.EngineRPM,
+		drs:               t.DRS,
+		revLightsPercent:  t.RevLightsPercent,
+		brakesTemperature: t.BrakesTemperature,
+		engineTemperature: t.EngineTemperature,
+		tyresPressure:     t.TyresPressure,
+	}
+	for i, v := range t.TyresSurfaceTemperature {
+		c.tyresSurfaceTemperature[i] = uint16(v)
+	}
+	return c
+}
+
+func (c CarStatusData2021) carStatus() carStatus {
+	return carStatus{
+		tractionControl:  c.TractionControl,
+		antiLockBrakes:   c.AntiLockBrakes,
+		fuelMix:          c.FuelMix,
+		frontBrakeBias:   c.FrontBrakeBias,
+		pitLimiterStatus: c.PitLimiterStatus,
+		fuelInTank:       c.FuelInTank,
+		fuelCapacity:     c.FuelCapacity,
+		maxRPM:           c.MaxRPM,
+		idleRPM:          c.IdleRPM,
+		maxGears:         c.MaxGears,
+		drsAllowed:       int8(c.DRSAllowed),
+		tyreCompound:     legacyVisualCompound(c.VisualTyreCompound),
+		vehicleFIAFlags:  c.VehicleFIAFlags,
+		ersStoreEnergy:   c.ERSStoreEnergy,
+		ersDeployMode:    c.ERSDeployMode,
+	}
+}
+
+func (d CarDamageData2021) carDamage() carDamage {
+	c := carDamage{
+		tyresDamage:          d.TyresDamage,
+		frontLeftWingDamage:  d.FrontLeftWingDamage,
+		frontRightWingDamage: d.FrontRightWingDamage,
+		rearWingDamage:       d.RearWingDamage,
+		engineDamage:         d.EngineDamage,
+		gearBoxDamage:        d.GearBoxDamage,
+	}
+	for i, w := range d.TyresWear {
+		c.tyresWear[i] = uint8(w)
+	}
+	return c
+}
+
+func (p *PacketSessionData2021) apply(s *State) {
+	s.applySession(session{
+		weather:             p.Weather,
+		trackTemperature:    p.TrackTemperature,
+		airTemperature:      p.AirTemperature,
+		totalLaps:           p.TotalLaps,
+		trackLength:         p.TrackLength,
+		sessionType:         legacySessionType(p.SessionType, 9, 12),
+		trackID:             p.TrackID,
+		era:                 legacyEra(p.Formula),
+		sessionTimeLeft:     p.SessionTimeLeft,
+		pitSpeedLimit:       p.PitSpeedLimit,
+		gamePaused:          p.GamePaused,
+		isSpectating:        p.IsSpectating,
+		spectatorCarIndex:   p.SpectatorCarIndex,
+		sliProNativeSupport: p.SliProNativeSupport,
+		safetyCarStatus:     p.SafetyCarStatus,
+		networkGame:         p.NetworkGame,
+	})
+}
+
+func (p *PacketLapData2021) apply(s *State) {
+	for i, l := range p.LapData {
+		s.applyLap(i, l.lap())
+	}
+}
+
+func (p *PacketEventData2021) apply(s *State) {
+	s.Event = string(p.EventStringCode[:])
+}
+
+func (p *PacketParticipantsData2021) apply(s *State) {
+	s.NumCars = p.NumActiveCars
+	for i, d := range p.Participants {
+		s.applyParticipant(i, d.DriverID, d.TeamID, d.Name[:])
+	}
+}
+
+func (p *PacketCarTelemetryData2021) apply(s *State) {
+	if i, ok := s.player(len(p.CarTelemetryData)); ok {
+		s.applyCarTelemetry(p.CarTelemetryData[i].carTelemetry())
+	}
+}
+
+func (p *PacketCarStatusData2021) apply(s *State) {
+	for i, c := range p.CarStatusData {
+		if car := s.car(i); car != nil {
+			car.TyreCompound = legacyVisualCompound(c.VisualTyreCompound)
+		}
+	}
+	if i, ok := s.player(len(p.CarStatusData)); ok {
+		s.applyCarStatus(p.CarStatusData[i].carStatus())
+	}
+}
+
+// The final classification repeats what lap data already reported.
+func (p *PacketFinalClassificationData2021) apply(s *State) {}
+
+// Lobby info is only sent before a session starts.
+func (p *PacketLobbyInfoData2021) apply(s *State) {}
+
+func (p *PacketCarDamageData2021) apply(s *State) {
+	if i, ok := s.player(len(p.CarDamageData)); ok {
+		s.applyCarDamage(p.CarDamageData[i].carDamage())
+	}
+}
+
+func (p *PacketSessionHistoryData2021) apply(s *State) {
+	n := int(p.BestLapTimeLapNum)
+	if c := s.car(int(p.CarIdx)); c != nil && n > 0 && n <= len(p.LapHistoryData) {
+		c.BestlapTime = seconds(p.LapHistoryData[n-1].LapTimeInMS)
+	}
+}
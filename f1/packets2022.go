@@ -0,0 +1,192 @@
+package f1
+
+// Packet layouts of the F1 2022 UDP specification.
+
+func init() {
+	registerFormat(format{
+		packetFormat:   2022,
+		versionOffset:  2,
+		packetIDOffset: 5,
+		header:         func() packetHeader { return new(PacketHeader2020) },
+		packets: map[uint8]func() Packet{
+			PacketMotion:              func() Packet { return new(PacketMotionData2020) },
+			PacketSession:             func() Packet { return new(PacketSessionData2022) },
+			PacketLapData:             func() Packet { return new(PacketLapData2022) },
+			PacketEvent:               func() Packet { return new(PacketEventData2022) },
+			PacketParticipants:        func() Packet { return new(PacketParticipantsData2021) },
+			PacketCarSetups:           func() Packet { return new(PacketCarSetupData2020) },
+			PacketCarTelemetry:        func() Packet { return new(PacketCarTelemetryData2021) },
+			PacketCarStatus:           func() Packet { return new(PacketCarStatusData2021) },
+			PacketFinalClassification: func() Packet { return new(PacketFinalClassificationData2022) },
+			PacketLobbyInfo:           func() Packet { return new(PacketLobbyInfoData2021) },
+			PacketCarDamage:           func() Packet { return new(PacketCarDamageData2022) },
+			PacketSessionHistory:      func() Packet { return new(PacketSessionHistoryData2021) },
+		},
+	})
+}
+
+type PacketSessionData2022 struct {
+	PacketHeader2020
+	Weather                   uint8  // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature          int8   // track temp. in degrees celsius
+	AirTemperature            int8   // air temp. in degrees celsius
+	TotalLaps                 uint8  // total number of laps in this race
+	TrackLength               uint16 // track length in metres
+	SessionType               uint8  // 0 = unknown, 1 = P1, 2 = P2, 3 = P3, 4 = Short P, 5 = Q1, 6 = Q2, 7 = Q3, 8 = Short Q, 9 = OSQ, 10 = R, 11 = R2, 12 = R3, 13 = Time Trial
+	TrackID                   int8   // -1 for unknown
+	Formula                   uint8  // 0 = F1 Modern, 1 = F1 Classic, 2 = F2, 3 = F1 Generic, 4 = Beta, 5 = Supercars, 6 = Esports, 7 = F2 2021
+	SessionTimeLeft           uint16 // time left in session in seconds
+	SessionDuration           uint16 // session duration in seconds
+	PitSpeedLimit             uint8  // pit speed limit in kilometres per hour
+	GamePaused                uint8  // whether the game is paused
+	IsSpectating              uint8  // whether the player is spectating
+	SpectatorCarIndex         uint8  // index of the car being spectated
+	SliProNativeSupport       uint8  // SLI Pro support, 0 = inactive, 1 = active
+	NumMarshalZones           uint8  // number of marshal zones to follow
+	MarshalZones              [21]MarshalZone2018
+	SafetyCarStatus           uint8 // 0 = no safety car, 1 = full safety car, 2 = virtual safety car, 3 = formation lap
+	NetworkGame               uint8 // 0 = offline, 1 = online
+	NumWeatherForecastSamples uint8 // number of weather samples to follow
+	WeatherForecastSamples    [56]WeatherForecastSample2021
+	ForecastAccuracy          uint8  // 0 = perfect, 1 = approximate
+	AIDifficulty              uint8  // AI difficulty rating (0-110)
+	SeasonLinkIdentifier      uint32 // identifier for season, persists across saves
+	WeekendLinkIdentifier     uint32 // identifier for weekend, persists across saves
+	SessionLinkIdentifier     uint32 // identifier for session, persists across saves
+	PitStopWindowIdealLap     uint8  // ideal lap to pit on for current strategy (player)
+	PitStopWindowLatestLap    uint8  // latest lap to pit on for current strategy (player)
+	PitStopRejoinPosition     uint8  // predicted position to rejoin at (player)
+	SteeringAssist            uint8  // 0 = off, 1 = on
+	BrakingAssist             uint8  // 0 = off, 1 = low, 2 = medium, 3 = high
+	GearboxAssist             uint8  // 1 = manual, 2 = manual & suggested gear, 3 = auto
+	PitAssist                 uint8  // 0 = off, 1 = on
+	PitReleaseAssist          uint8  // 0 = off, 1 = on
+	ERSAssist                 uint8  // 0 = off, 1 = on
+	DRSAssist                 uint8  // 0 = off, 1 = on
+	DynamicRacingLine         uint8  // 0 = off, 1 = corners only, 2 = full
+	DynamicRacingLineType     uint8  // 0 = 2D, 1 = 3D
+	GameMode                  uint8  // game mode id
+	RuleSet                   uint8  // ruleset
+	TimeOfDay                 uint32 // local time of day - minutes since midnight
+	SessionLength             uint8  // 0 = none, 2 = very short, 3 = short, 4 = medium, 5 = medium long, 6 = long, 7 = full
+}
+
+type PacketLapData2022 struct {
+	PacketHeader2020
+	LapData              [22]LapData2021 // lap data for all cars on track
+	TimeTrialPBCarIdx    uint8           // index of personal best car in time trial (255 if invalid)
+	TimeTrialRivalCarIdx uint8           // index of rival car in time trial (255 if invalid)
+}
+
+type PacketEventData2022 struct {
+	PacketHeader2020
+	EventStringCode [4]uint8  // event string code, e.g. "SSTA", "STLG" or "SPTP"
+	EventDetails    [12]uint8 // event specific details, e.g. vehicle index and lap time of a fastest lap
+}
+
+type FinalClassificationData2022 struct {
+	Position          uint8    // finishing position
+	NumLaps           uint8    // number of laps completed
+	GridPosition      uint8    // grid position of the car
+	Points            uint8    // number of points scored
+	NumPitStops       uint8    // number of pit stops made
+	ResultStatus      uint8    // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = didnotfinish, 5 = disqualified, 6 = not classified, 7 = retired
+	BestLapTimeInMS   uint32   // best lap time of the session in milliseconds
+	TotalRaceTime     float64  // total race time in seconds without penalties
+	PenaltiesTime     uint8    // total penalties accumulated in seconds
+	NumPenalties      uint8    // number of penalties applied to this driver
+	NumTyreStints     uint8    // number of tyres stints up to maximum
+	TyreStintsActual  [8]uint8 // actual tyres used by this driver
+	TyreStintsVisual  [8]uint8 // visual tyres used by this driver
+	TyreStintsEndLaps [8]uint8 // the lap number stints end on
+}
+
+type PacketFinalClassificationData2022 struct {
+	PacketHeader2020
+	NumCars            uint8 // number of cars in the final classification
+	ClassificationData [22]FinalClassificationData2022
+}
+
+type CarDamageData2022 struct {
+	TyresWear            [4]float32 // tyre wear (percentage)
+	TyresDamage          [4]uint8   // tyre damage (percentage)
+	BrakesDamage         [4]uint8   // brakes damage (percentage)
+	FrontLeftWingDamage  uint8      // front left wing damage (percentage)
+	FrontRightWingDamage uint8      // front right wing damage (percentage)
+	RearWingDamage       uint8      // rear wing damage (percentage)
+	FloorDamage          uint8      // floor damage (percentage)
+	DiffuserDamage       uint8      // diffuser damage (percentage)
+	SidepodDamage        uint8      // sidepod damage (percentage)
+	DRSFault             uint8      // indicator for DRS fault, 0 = OK, 1 = fault
+	ERSFault             uint8      // indicator for ERS fault, 0 = OK, 1 = fault
+	GearBoxDamage        uint8      // gear box damage (percentage)
+	EngineDamage         uint8      // engine damage (percentage)
+	EngineMGUHWear       uint8      // engine wear MGU-H (percentage)
+	EngineESWear         uint8      // engine wear ES (percentage)
+	EngineCEWear         uint8      // engine wear CE (percentage)
+	EngineICEWear        uint8      // engine wear ICE (percentage)
+	EngineMGUKWear       uint8      // engine wear MGU-K (percentage)
+	EngineTCWear         uint8      // engine wear TC (percentage)
+	EngineBlown          uint8      // engine blown, 0 = OK, 1 = fault
+	EngineSeized         uint8      // engine seized, 0 = OK, 1 = fault
+}
+
+type PacketCarDamageData2022 struct {
+	PacketHeader2020
+	CarDamageData [22]CarDamageData2022
+}
+
+func (d CarDamageData2022) carDamage() carDamage {
+	c := carDamage{
+		tyresDamage:          d.TyresDamage,
+		frontLeftWingDamage:  d.FrontLeftWingDamage,
+		frontRightWingDamage: d.FrontRightWingDamage,
+		rearWingDamage:       d.RearWingDamage,
+		engineDamage:         d.EngineDamage,
+		gearBoxDamage:        d.GearBoxDamage,
+	}
+	for i, w := range d.TyresWear {
+		c.tyresWear[i] = uint8(w)
+	}
+	return c
+}
+
+func (p *PacketSessionData2022) apply(s *State) {
+	s.applySession(session{
+		weather:             p.Weather,
+		trackTemperature:    p.TrackTemperature,
+		airTemperature:      p.AirTemperature,
+		totalLaps:           p.TotalLaps,
+		trackLength:         p.TrackLength,
+		sessionType:         legacySessionType(p.SessionType, 9, 12),
+		trackID:             p.TrackID,
+		era:                 legacyEra(p.Formula),
+		sessionTimeLeft:     p.SessionTimeLeft,
+		pitSpeedLimit:       p.PitSpeedLimit,
+		gamePaused:          p.GamePaused,
+		isSpectating:        p.IsSpectating,
+		spectatorCarIndex:   p.SpectatorCarIndex,
+		sliProNativeSupport: p.SliProNativeSupport,
+		safetyCarStatus:     p.SafetyCarStatus,
+		networkGame:         p.NetworkGame,
+	})
+}
+
+func (p *PacketLapData2022) apply(s *State) {
+	for i, l := range p.LapData {
+		s.applyLap(i, l.lap())
+	}
+}
+
+func (p *PacketEventData2022) apply(s *State) {
+	s.Event = string(p.EventStringCode[:])
+}
+
+// The final classification repeats what lap data already reported.
+func (p *PacketFinalClassificationData2022) apply(s *State) {}
+
+func (p *PacketCarDamageData2022) apply(s *State) {
+	if i, ok := s.player(len(p.CarDamageData)); ok {
+		s.applyCarDamage(p.CarDamageData[i].carDamage())
+	}
+}
@@ -0,0 +1,464 @@
+package f1
+
+// Packet layouts of the F1 23 UDP specification. The header gained the game
+// year and an overall frame identifier, so every packet type changed. The
+// player only motion data moved to its own packet.
+
+func init() {
+	registerFormat(format{
+		packetFormat:   2023,
+		versionOffset:  3,
+		packetIDOffset: 6,
+		header:         func() packetHeader { return new(PacketHeader2023) },
+		packets: map[uint8]func() Packet{
+			PacketMotion:              func() Packet { return new(PacketMotionData2023) },
+			PacketSession:             func() Packet { return new(PacketSessionData2023) },
+			PacketLapData:             func() Packet { return new(PacketLapData2023) },
+			PacketEvent:               func() Packet { return new(PacketEventData2023) },
+			PacketParticipants:        func() Packet { return new(PacketParticipantsData2023) },
+			PacketCarSetups:           func() Packet { return new(PacketCarSetupData2023) },
+			PacketCarTelemetry:        func() Packet { return new(PacketCarTelemetryData2023) },
+			PacketCarStatus:           func() Packet { return new(PacketCarStatusData2023) },
+			PacketFinalClassification: func() Packet { return new(PacketFinalClassificationData2023) },
+			PacketLobbyInfo:           func() Packet { return new(PacketLobbyInfoData2023) },
+			PacketCarDamage:           func() Packet { return new(PacketCarDamageData2023) },
+			PacketSessionHistory:      func() Packet { return new(PacketSessionHistoryData2023) },
+			PacketTyreSets:            func() Packet { return new(PacketTyreSetsData2023) },
+			PacketMotionEx:            func() Packet { return new(PacketMotionExData2023) },
+		},
+	})
+}
+
+type PacketHeader2023 struct {
+	PacketFormat            uint16  // 2023
+	GameYear                uint8   // game year - last two digits e.g. 23
+	GameMajorVersion        uint8   // game major version - "X.00"
+	GameMinorVersion        uint8   // game minor version - "1.XX"
+	PacketVersion           uint8   // version of this packet type, starts from 1
+	PacketID                uint8   // identifier for the packet type
+	SessionUID              uint64  // unique identifier for the session
+	SessionTime             float32 // session timestamp
+	FrameIdentifier         uint32  // identifier for the frame the data was retrieved on
+	OverallFrameIdentifier  uint32  // overall identifier for the frame, doesn't go back after flashbacks
+	PlayerCarIndex          uint8   // index of player's car in the array
+	SecondaryPlayerCarIndex uint8   // index of secondary player's car in the array (splitscreen), 255 if none
+}
+
+type PacketMotionData2023 struct {
+	PacketHeader2023
+	CarMotionData [22]CarMotionData2018 // data for all cars on track
+}
+
+type PacketSessionData2023 struct {
+	PacketHeader2023
+	Weather                         uint8  // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature                int8   // track temp. in degrees celsius
+	AirTemperature                  int8   // air temp. in degrees celsius
+	TotalLaps                       uint8  // total number of laps in this race
+	TrackLength                     uint16 // track length in metres
+	SessionType                     uint8  // 0 = unknown, 1 = P1, 2 = P2, 3 = P3, 4 = Short P, 5 = Q1, 6 = Q2, 7 = Q3, 8 = Short Q, 9 = OSQ, 10 = SSO1, 11 = SSO2, 12 = SSO3, 13 = Short SSO, 14 = OSSO, 15 = R, 16 = R2, 17 = R3, 18 = Time Trial
+	TrackID                         int8   // -1 for unknown
+	Formula                         uint8  // 0 = F1 Modern, 1 = F1 Classic, 2 = F2, 3 = F1 Generic, 4 = Beta, 5 = Supercars, 6 = Esports, 7 = F2 2021
+	SessionTimeLeft                 uint16 // time left in session in seconds
+	SessionDuration                 uint16 // session duration in seconds
+	PitSpeedLimit                   uint8  // pit speed limit in kilometres per hour
+	GamePaused                      uint8  // whether the game is paused
+	IsSpectating                    uint8  // whether the player is spectating
+	SpectatorCarIndex               uint8  // index of the car being spectated
+	SliProNativeSupport             uint8  // SLI Pro support, 0 = inactive, 1 = active
+	NumMarshalZones                 uint8  // number of marshal zones to follow
+	MarshalZones                    [21]MarshalZone2018
+	SafetyCarStatus                 uint8 // 0 = no safety car, 1 = full safety car, 2 = virtual safety car, 3 = formation lap
+	NetworkGame                     uint8 // 0 = offline, 1 = online
+	NumWeatherForecastSamples       uint8 // number of weather samples to follow
+	WeatherForecastSamples          [56]WeatherForecastSample2021
+	ForecastAccuracy                uint8  // 0 = perfect, 1 = approximate
+	AIDifficulty                    uint8  // AI difficulty rating (0-110)
+	SeasonLinkIdentifier            uint32 // identifier for season, persists across saves
+	WeekendLinkIdentifier           uint32 // identifier for weekend, persists across saves
+	SessionLinkIdentifier           uint32 // identifier for session, persists across saves
+	PitStopWindowIdealLap           uint8  // ideal lap to pit on for current strategy (player)
+	PitStopWindowLatestLap          uint8  // latest lap to pit on for current strategy (player)
+	PitStopRejoinPosition           uint8  // predicted position to rejoin at (player)
+	SteeringAssist                  uint8  // 0 = off, 1 = on
+	BrakingAssist                   uint8  // 0 = off, 1 = low, 2 = medium, 3 = high
+	GearboxAssist                   uint8  // 1 = manual, 2 = manual & suggested gear, 3 = auto
+	PitAssist                       uint8  // 0 = off, 1 = on
+	PitReleaseAssist                uint8  // 0 = off, 1 = on
+	ERSAssist                       uint8  // 0 = off, 1 = on
+	DRSAssist                       uint8  // 0 = off, 1 = on
+	DynamicRacingLine               uint8  // 0 = off, 1 = corners only, 2 = full
+	DynamicRacingLineType           uint8  // 0 = 2D, 1 = 3D
+	GameMode                        uint8  // game mode id
+	RuleSet                         uint8  // ruleset
+	TimeOfDay                       uint32 // local time of day - minutes since midnight
+	SessionLength                   uint8  // 0 = none, 2 = very short, 3 = short, 4 = medium, 5 = medium long, 6 = long, 7 = full
+	SpeedUnitsLeadPlayer            uint8  // 0 = MPH, 1 = KPH
+	TemperatureUnitsLeadPlayer      uint8  // 0 = celsius, 1 = fahrenheit
+	SpeedUnitsSecondaryPlayer       uint8  // 0 = MPH, 1 = KPH
+	TemperatureUnitsSecondaryPlayer uint8  // 0 = celsius, 1 = fahrenheit
+	NumSafetyCarPeriods             uint8  // number of safety cars called during session
+	NumVirtualSafetyCarPeriods      uint8  // number of virtual safety cars called
+	NumRedFlagPeriods               uint8  // number of red flags called during session
+}
+
+type LapData2023 struct {
+	LastLapTimeInMS             uint32  // last lap time in milliseconds
+	CurrentLapTimeInMS          uint32  // current time around the lap in milliseconds
+	Sector1TimeInMS             uint16  // sector 1 time in milliseconds
+	Sector1TimeMinutes          uint8   // sector 1 whole minute part
+	Sector2TimeInMS             uint16  // sector 2 time in milliseconds
+	Sector2TimeMinutes          uint8   // sector 2 whole minute part
+	DeltaToCarInFrontInMS       uint16  // time delta to car in front in milliseconds
+	DeltaToRaceLeaderInMS       uint16  // time delta to race leader in milliseconds
+	LapDistance                 float32 // distance vehicle is around current lap in metres
+	TotalDistance               float32 // total distance travelled in session in metres
+	SafetyCarDelta              float32 // delta in seconds for safety car
+	CarPosition                 uint8   // car race position
+	CurrentLapNum               uint8   // current lap number
+	PitStatus                   uint8   // 0 = none, 1 = pitting, 2 = in pit area
+	NumPitStops                 uint8   // number of pit stops taken in this race
+	Sector                      uint8   // 0 = sector1, 1 = sector2, 2 = sector3
+	CurrentLapInvalid           uint8   // current lap invalid - 0 = valid, 1 = invalid
+	Penalties                   uint8   // accumulated time penalties in seconds to be added
+	TotalWarnings               uint8   // accumulated number of warnings issued
+	CornerCuttingWarnings       uint8   // accumulated number of corner cutting warnings issued
+	NumUnservedDriveThroughPens uint8   // num drive through pens left to serve
+	NumUnservedStopGoPens       uint8   // num stop go pens left to serve
+	GridPosition                uint8   // grid position the vehicle started the race in
+	DriverStatus                uint8   // 0 = in garage, 1 = flying lap, 2 = in lap, 3 = out lap, 4 = on track
+	ResultStatus                uint8   // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = didnotfinish, 5 = disqualified, 6 = not classified, 7 = retired
+	PitLaneTimerActive          uint8   // pit lane timing, 0 = inactive, 1 = active
+	PitLaneTimeInLaneInMS       uint16  // if active, the current time spent in the pit lane in ms
+	PitStopTimerInMS            uint16  // time of the actual pit stop in ms
+	PitStopShouldServePen       uint8   // whether the car should serve a penalty at this stop
+}
+
+type PacketLapData2023 struct {
+	PacketHeader2023
+	LapData              [22]LapData2023 // lap data for all cars on track
+	TimeTrialPBCarIdx    uint8           // index of personal best car in time trial (255 if invalid)
+	TimeTrialRivalCarIdx uint8           // index of rival car in time trial (255 if invalid)
+}
+
+type PacketEventData2023 struct {
+	PacketHeader2023
+	EventStringCode [4]uint8  // event string code, e.g. "SSTA", "RDFL" or "OVTK"
+	EventDetails    [12]uint8 // event specific details, e.g. vehicle index and lap time of a fastest lap
+}
+
+type ParticipantData2023 struct {
+	AIControlled    uint8     // whether the vehicle is AI (1) or human (0) controlled
+	DriverID        uint8     // driver id, 255 if network human
+	NetworkID       uint8     // network id, unique identifier for network players
+	TeamID          uint8     // team id
+	MyTeam          uint8     // my team flag, 1 = My Team, 0 = otherwise
+	RaceNumber      uint8     // race number of the car
+	Nationality     uint8     // nationality of the driver
+	Name            [48]uint8 // name of participant in UTF-8 format, null terminated
+	YourTelemetry   uint8     // the player's UDP setting, 0 = restricted, 1 = public
+	ShowOnlineNames uint8     // the player's show online names setting, 0 = off, 1 = on
+	Platform        uint8     // 1 = Steam, 3 = PlayStation, 4 = Xbox, 6 = Origin, 255 = unknown
+}
+
+type PacketParticipantsData2023 struct {
+	PacketHeader2023
+	NumActiveCars uint8 // number of active cars in the data
+	Participants  [22]ParticipantData2023
+}
+
+type PacketCarSetupData2023 struct {
+	PacketHeader2023
+	CarSetups [22]CarSetupData2020
+}
+
+type PacketCarTelemetryData2023 struct {
+	PacketHeader2023
+	CarTelemetryData             [22]CarTelemetryData2021
+	MFDPanelIndex                uint8 // index of MFD panel open, 255 = MFD closed
+	MFDPanelIndexSecondaryPlayer uint8 // see above
+	SuggestedGear                int8  // suggested gear for the player (1-8), 0 if no gear suggested
+}
+
+type CarStatusData2023 struct {
+	TractionControl         uint8   // 0 = off, 1 = medium, 2 = full
+	AntiLockBrakes          uint8   // 0 (off) - 1 (on)
+	FuelMix                 uint8   // fuel mix - 0 = lean, 1 = standard, 2 = rich, 3 = max
+	FrontBrakeBias          uint8   // front brake bias (percentage)
+	PitLimiterStatus        uint8   // pit limiter status - 0 = off, 1 = on
+	FuelInTank              float32 // current fuel mass
+	FuelCapacity            float32 // fuel capacity
+	FuelRemainingLaps       float32 // fuel remaining in terms of laps
+	MaxRPM                  uint16  // cars max RPM, point of rev limiter
+	IdleRPM                 uint16  // cars idle RPM
+	MaxGears                uint8   // maximum number of gears
+	DRSAllowed              uint8   // 0 = not allowed, 1 = allowed
+	DRSActivationDistance   uint16  // 0 = DRS not available, non-zero = DRS will be available in [X] metres
+	ActualTyreCompound      uint8   // 16 = C5, 17 = C4, 18 = C3, 19 = C2, 20 = C1, 21 = C0, 7 = inter, 8 = wet
+	VisualTyreCompound      uint8   // 16 = soft, 17 = medium, 18 = hard, 7 = inter, 8 = wet
+	TyresAgeLaps            uint8   // age in laps of the current set of tyres
+	VehicleFIAFlags         int8    // -1 = invalid/unknown, 0 = none, 1 = green, 2 = blue, 3 = yellow
+	EnginePowerICE          float32 // engine power output of ICE (W)
+	EnginePowerMGUK         float32 // engine power output of MGU-K (W)
+	ERSStoreEnergy          float32 // ERS energy store in Joules
+	ERSDeployMode           uint8   // 0 = none, 1 = medium, 2 = hotlap, 3 = overtake
+	ERSHarvestedThisLapMGUK float32 // ERS energy harvested this lap by MGU-K
+	ERSHarvestedThisLapMGUH float32 // ERS energy harvested this lap by MGU-H
+	ERSDeployedThisLap      float32 // ERS energy deployed this lap
+	NetworkPaused           uint8   // whether the car is paused in a network game
+}
+
+type PacketCarStatusData2023 struct {
+	PacketHeader2023
+	CarStatusData [22]CarStatusData2023
+}
+
+type PacketFinalClassificationData2023 struct {
+	PacketHeader2023
+	NumCars            uint8 // number of cars in the final classification
+	ClassificationData [22]FinalClassificationData2022
+}
+
+type LobbyInfoData2023 struct {
+	AIControlled uint8     // whether the vehicle is AI (1) or human (0) controlled
+	TeamID       uint8     // team id, 255 if no team currently selected
+	Nationality  uint8     // nationality of the driver
+	Platform     uint8     // 1 = Steam, 3 = PlayStation, 4 = Xbox, 6 = Origin, 255 = unknown
+	Name         [48]uint8 // name of participant in UTF-8 format, null terminated
+	CarNumber    uint8     // car number of the player
+	ReadyStatus  uint8     // 0 = not ready, 1 = ready, 2 = spectating
+}
+
+type PacketLobbyInfoData2023 struct {
+	PacketHeader2023
+	NumPlayers   uint8 // number of players in the lobby data
+	LobbyPlayers [22]LobbyInfoData2023
+}
+
+type PacketCarDamageData2023 struct {
+	PacketHeader2023
+	CarDamageData [22]CarDamageData2022
+}
+
+type LapHistoryData2023 struct {
+	LapTimeInMS        uint32 // lap time in milliseconds
+	Sector1TimeInMS    uint16 // sector 1 milliseconds part
+	Sector1TimeMinutes uint8  // sector 1 whole minute part
+	Sector2TimeInMS    uint16 // sector 2 milliseconds part
+	Sector2TimeMinutes uint8  // sector 2 whole minute part
+	Sector3TimeInMS    uint16 // sector 3 milliseconds part
+	Sector3TimeMinutes uint8  // sector 3 whole minute part
+	LapValidBitFlags   uint8  // 0x01 bit set = lap valid, 0x02 = sector 1 valid, 0x04 = sector 2 valid, 0x08 = sector 3 valid
+}
+
+type PacketSessionHistoryData2023 struct {
+	PacketHeader2023
+	CarIdx                uint8 // index of the car this lap data relates to
+	NumLaps               uint8 // num laps in the data (including current partial lap)
+	NumTyreStints         uint8 // number of tyre stints in the data
+	BestLapTimeLapNum     uint8 // lap the best lap time was achieved on
+	BestSector1LapNum     uint8 // lap the best sector 1 time was achieved on
+	BestSector2LapNum     uint8 // lap the best sector 2 time was achieved on
+	BestSector3LapNum     uint8 // lap the best sector 3 time was achieved on
+	LapHistoryData        [100]LapHistoryData2023
+	TyreStintsHistoryData [8]TyreStintHistoryData2021
+}
+
+type TyreSetData2023 struct {
+	ActualTyreCompound uint8 // actual tyre compound used
+	VisualTyreCompound uint8 // visual tyre compound used
+	Wear               uint8 // tyre wear (percentage)
+	Available          uint8 // whether this set is currently available
+	RecommendedSession uint8 // recommended session for tyre set
+	LifeSpan           uint8 // laps left in this tyre set
+	UsableLife         uint8 // max number of laps recommended for this compound
+	LapDeltaTime       int16 // lap delta time in milliseconds compared to fitted set
+	Fitted             uint8 // whether the set is fitted or not
+}
+
+type PacketTyreSetsData2023 struct {
+	PacketHeader2023
+	CarIdx      uint8               // index of the car this data relates to
+	TyreSetData [20]TyreSetData2023 // 13 (dry) + 7 (wet)
+	FittedIdx   uint8               // index into array of fitted tyre
+}
+
+type PacketMotionExData2023 struct {
+	PacketHeader2023
+	SuspensionPosition     [4]float32 // note: all wheel arrays have the following order: RL, RR, FL, FR
+	SuspensionVelocity     [4]float32
+	SuspensionAcceleration [4]float32
+	WheelSpeed             [4]float32 // speed of each wheel
+	WheelSlipRatio         [4]float32 // slip ratio for each wheel
+	WheelSlipAngle         [4]float32 // slip angles for each wheel
+	WheelLatForce          [4]float32 // lateral forces for each wheel
+	WheelLongForce         [4]float32 // longitudinal forces for each wheel
+	HeightOfCOGAboveGround float32    // height of centre of gravity above ground
+	LocalVelocityX         float32    // velocity in local space - metres/s
+	LocalVelocityY         float32    // velocity in local space
+	LocalVelocityZ         float32    // velocity in local space
+	AngularVelocityX       float32    // angular velocity x-component - radians/s
+	AngularVelocityY       float32    // angular velocity y-component
+	AngularVelocityZ       float32    // angular velocity z-component
+	AngularAccelerationX   float32    // angular acceleration x-component - radians/s/s
+	AngularAccelerationY   float32    // angular acceleration y-component
+	AngularAccelerationZ   float32    // angular acceleration z-component
+	FrontWheelsAngle       float32    // current front wheels angle in radians
+	WheelVertForce         [4]float32 // vertical forces for each wheel
+}
+
+func (h PacketHeader2023) PacketHeader() PacketHeader {
+	return PacketHeader{
+		PacketFormat:            h.PacketFormat,
+		GameYear:                h.GameYear,
+		GameMajorVersion:        h.GameMajorVersion,
+		GameMinorVersion:        h.GameMinorVersion,
+		PacketVersion:           h.PacketVersion,
+		PacketID:                h.PacketID,
+		SessionUID:              h.SessionUID,
+		SessionTime:             h.SessionTime,
+		FrameIdentifier:         h.FrameIdentifier,
+		OverallFrameIdentifier:  h.OverallFrameIdentifier,
+		PlayerCarIndex:          h.PlayerCarIndex,
+		SecondaryPlayerCarIndex: h.SecondaryPlayerCarIndex,
+	}
+}
+
+// minutesSeconds converts a time split in a milliseconds and a whole minute
+// part to seconds.
+func minutesSeconds(ms uint16, minutes uint8) float32 {
+	return float32(minutes)*60 + seconds(uint32(ms))
+}
+
+func (l LapData2023) lap() lap {
+	return lap{
+		lastLapTime:       seconds(l.LastLapTimeInMS),
+		currentLapTime:    seconds(l.CurrentLapTimeInMS),
+		sector1Time:       minutesSeconds(l.Sector1TimeInMS, l.Sector1TimeMinutes),
+		sector2Time:       minutesSeconds(l.Sector2TimeInMS, l.Sector2TimeMinutes),
+		lapDistance:       l.LapDistance,
+		totalDistance:     l.TotalDistance,
+		carPosition:       l.CarPosition,
+		currentLapNum:     l.CurrentLapNum,
+		pitStatus:         l.PitStatus,
+		sector:            l.Sector,
+		currentLapInvalid: l.CurrentLapInvalid,
+		penalties:         l.Penalties,
+	}
+}
+
+func (c CarStatusData2023) carStatus() carStatus {
+	return carStatus{
+		tractionControl:  c.TractionControl,
+		antiLockBrakes:   c.AntiLockBrakes,
+		fuelMix:          c.FuelMix,
+		frontBrakeBias:   c.FrontBrakeBias,
+		pitLimiterStatus: c.PitLimiterStatus,
+		fuelInTank:       c.FuelInTank,
+		fuelCapacity:     c.FuelCapacity,
+		maxRPM:           c.MaxRPM,
+		idleRPM:          c.IdleRPM,
+		maxGears:         c.MaxGears,
+		drsAllowed:       int8(c.DRSAllowed),
+		tyreCompound:     legacyVisualCompound(c.VisualTyreCompound),
+		vehicleFIAFlags:  c.VehicleFIAFlags,
+		ersStoreEnergy:   c.ERSStoreEnergy,
+		ersDeployMode:    c.ERSDeployMode,
+	}
+}
+
+func (p *PacketMotionData2023) apply(s *State) {
+	s.applyCarMotion(p.CarMotionData[:])
+}
+
+func (p *PacketSessionData2023) apply(s *State) {
+	s.applySession(session{
+		weather:             p.Weather,
+		trackTemperature:    p.TrackTemperature,
+		airTemperature:      p.AirTemperature,
+		totalLaps:           p.TotalLaps,
+		trackLength:         p.TrackLength,
+		sessionType:         legacySessionType(p.SessionType, 14, 17),
+		trackID:             p.TrackID,
+		era:                 legacyEra(p.Formula),
+		sessionTimeLeft:     p.SessionTimeLeft,
+		pitSpeedLimit:       p.PitSpeedLimit,
+		gamePaused:          p.GamePaused,
+		isSpectating:        p.IsSpectating,
+		spectatorCarIndex:   p.SpectatorCarIndex,
+		sliProNativeSupport: p.SliProNativeSupport,
+		safetyCarStatus:     p.SafetyCarStatus,
+		networkGame:         p.NetworkGame,
+	})
+}
+
+func (p *PacketLapData2023) apply(s *State) {
+	for i, l := range p.LapData {
+		s.applyLap(i, l.lap())
+	}
+}
+
+func (p *PacketEventData2023) apply(s *State) {
+	s.Event = string(p.EventStringCode[:])
+}
+
+func (p *PacketParticipantsData2023) apply(s *State) {
+	s.NumCars = p.NumActiveCars
+	for i, d := range p.Participants {
+		s.applyParticipant(i, d.DriverID, d.TeamID, d.Name[:])
+	}
+}
+
+// Car setups have no 2017 counterpart.
+func (p *PacketCarSetupData2023) apply(s *State) {}
+
+func (p *PacketCarTelemetryData2023) apply(s *State) {
+	if i, ok := s.player(len(p.CarTelemetryData)); ok {
+		s.applyCarTelemetry(p.CarTelemetryData[i].carTelemetry())
+	}
+}
+
+func (p *PacketCarStatusData2023) apply(s *State) {
+	for i, c := range p.CarStatusData {
+		if car := s.car(i); car != nil {
+			car.TyreCompound = legacyVisualCompound(c.VisualTyreCompound)
+		}
+	}
+	if i, ok := s.player(len(p.CarStatusData)); ok {
+		s.applyCarStatus(p.CarStatusData[i].carStatus())
+	}
+}
+
+// The final classification repeats what lap data already reported.
+func (p *PacketFinalClassificationData2023) apply(s *State) {}
+
+// Lobby info is only sent before a session starts.
+func (p *PacketLobbyInfoData2023) apply(s *State) {}
+
+func (p *PacketCarDamageData2023) apply(s *State) {
+	if i, ok := s.player(len(p.CarDamageData)); ok {
+		s.applyCarDamage(p.CarDamageData[i].carDamage())
+	}
+}
+
+func (p *PacketSessionHistoryData2023) apply(s *State) {
+	n := int(p.BestLapTimeLapNum)
+	if c := s.car(int(p.CarIdx)); c != nil && n > 0 && n <= len(p.LapHistoryData) {
+		c.BestlapTime = seconds(p.LapHistoryData[n-1].LapTimeInMS)
+	}
+}
+
+// Tyre sets have no 2017 counterpart.
+func (p *PacketTyreSetsData2023) apply(s *State) {}
+
+func (p *PacketMotionExData2023) apply(s *State) {
+	s.applyPlayerMotion(playerMotion{
+		suspensionPosition:     p.SuspensionPosition,
+		suspensionVelocity:     p.SuspensionVelocity,
+		suspensionAcceleration: p.SuspensionAcceleration,
+		wheelSpeed:             p.WheelSpeed,
+		localVelocity:          [3]float32{p.LocalVelocityX, p.LocalVelocityY, p.LocalVelocityZ},
+		angularVelocity:        [3]float32{p.AngularVelocityX, p.AngularVelocityY, p.AngularVelocityZ},
+		angularAcceleration:    [3]float32{p.AngularAccelerationX, p.AngularAccelerationY, p.AngularAccelerationZ},
+	})
+}
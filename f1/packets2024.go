@@ -0,0 +1,317 @@
+package f1
+
+// Packet layouts of the F1 24 UDP specification. The header is unchanged from
+// F1 23, so packets whose layout did not change reuse the 2023 types.
+
+func init() {
+	registerFormat(format{
+		packetFormat:   2024,
+		versionOffset:  3,
+		packetIDOffset: 6,
+		header:         func() packetHeader { return new(PacketHeader2023) },
+		packets: map[uint8]func() Packet{
+			PacketMotion:              func() Packet { return new(PacketMotionData2023) },
+			PacketSession:             func() Packet { return new(PacketSessionData2024) },
+			PacketLapData:             func() Packet { return new(PacketLapData2024) },
+			PacketEvent:               func() Packet { return new(PacketEventData2023) },
+			PacketParticipants:        func() Packet { return new(PacketParticipantsData2024) },
+			PacketCarSetups:           func() Packet { return new(PacketCarSetupData2024) },
+			PacketCarTelemetry:        func() Packet { return new(PacketCarTelemetryData2023) },
+			PacketCarStatus:           func() Packet { return new(PacketCarStatusData2023) },
+			PacketFinalClassification: func() Packet { return new(PacketFinalClassificationData2023) },
+			PacketLobbyInfo:           func() Packet { return new(PacketLobbyInfoData2024) },
+			PacketCarDamage:           func() Packet { return new(PacketCarDamageData2023) },
+			PacketSessionHistory:      func() Packet { return new(PacketSessionHistoryData2023) },
+			PacketTyreSets:            func() Packet { return new(PacketTyreSetsData2023) },
+			PacketMotionEx:            func() Packet { return new(PacketMotionExData2024) },
+			PacketTimeTrial:           func() Packet { return new(PacketTimeTrialData2024) },
+		},
+	})
+}
+
+type PacketSessionData2024 struct {
+	PacketHeader2023
+	Weather                         uint8  // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature                int8   // track temp. in degrees celsius
+	AirTemperature                  int8   // air temp. in degrees celsius
+	TotalLaps                       uint8  // total number of laps in this race
+	TrackLength                     uint16 // track length in metres
+	SessionType                     uint8  // 0 = unknown, 1 = P1, 2 = P2, 3 = P3, 4 = Short P, 5 = Q1, 6 = Q2, 7 = Q3, 8 = Short Q, 9 = OSQ, 10 = SSO1, 11 = SSO2, 12 = SSO3, 13 = Short SSO, 14 = OSSO, 15 = R, 16 = R2, 17 = R3, 18 = Time Trial
+	TrackID                         int8   // -1 for unknown
+	Formula                         uint8  // 0 = F1 Modern, 1 = F1 Classic, 2 = F2, 3 = F1 Generic, 4 = Beta, 6 = Esports, 8 = F1 World, 9 = F1 Elimination
+	SessionTimeLeft                 uint16 // time left in session in seconds
+	SessionDuration                 uint16 // session duration in seconds
+	PitSpeedLimit                   uint8  // pit speed limit in kilometres per hour
+	GamePaused                      uint8  // whether the game is paused
+	IsSpectating                    uint8  // whether the player is spectating
+	SpectatorCarIndex               uint8  // index of the car being spectated
+	SliProNativeSupport             uint8  // SLI Pro support, 0 = inactive, 1 = active
+	NumMarshalZones                 uint8  // number of marshal zones to follow
+	MarshalZones                    [21]MarshalZone2018
+	SafetyCarStatus                 uint8 // 0 = no safety car, 1 = full safety car, 2 = virtual safety car, 3 = formation lap
+	NetworkGame                     uint8 // 0 = offline, 1 = online
+	NumWeatherForecastSamples       uint8 // number of weather samples to follow
+	WeatherForecastSamples          [64]WeatherForecastSample2021
+	ForecastAccuracy                uint8     // 0 = perfect, 1 = approximate
+	AIDifficulty                    uint8     // AI difficulty rating (0-110)
+	SeasonLinkIdentifier            uint32    // identifier for season, persists across saves
+	WeekendLinkIdentifier           uint32    // identifier for weekend, persists across saves
+	SessionLinkIdentifier           uint32    // identifier for session, persists across saves
+	PitStopWindowIdealLap           uint8     // ideal lap to pit on for current strategy (player)
+	PitStopWindowLatestLap          uint8     // latest lap to pit on for current strategy (player)
+	PitStopRejoinPosition           uint8     // predicted position to rejoin at (player)
+	SteeringAssist                  uint8     // 0 = off, 1 = on
+	BrakingAssist                   uint8     // 0 = off, 1 = low, 2 = medium, 3 = high
+	GearboxAssist                   uint8     // 1 = manual, 2 = manual & suggested gear, 3 = auto
+	PitAssist                       uint8     // 0 = off, 1 = on
+	PitReleaseAssist                uint8     // 0 = off, 1 = on
+	ERSAssist                       uint8     // 0 = off, 1 = on
+	DRSAssist                       uint8     // 0 = off, 1 = on
+	DynamicRacingLine               uint8     // 0 = off, 1 = corners only, 2 = full
+	DynamicRacingLineType           uint8     // 0 = 2D, 1 = 3D
+	GameMode                        uint8     // game mode id
+	RuleSet                         uint8     // ruleset
+	TimeOfDay                       uint32    // local time of day - minutes since midnight
+	SessionLength                   uint8     // 0 = none, 2 = very short, 3 = short, 4 = medium, 5 = medium long, 6 = long, 7 = full
+	SpeedUnitsLeadPlayer            uint8     // 0 = MPH, 1 = KPH
+	TemperatureUnitsLeadPlayer      uint8     // 0 = celsius, 1 = fahrenheit
+	SpeedUnitsSecondaryPlayer       uint8     // 0 = MPH, 1 = KPH
+	TemperatureUnitsSecondaryPlayer uint8     // 0 = celsius, 1 = fahrenheit
+	NumSafetyCarPeriods             uint8     // number of safety cars called during session
+	NumVirtualSafetyCarPeriods      uint8     // number of virtual safety cars called
+	NumRedFlagPeriods               uint8     // number of red flags called during session
+	EqualCarPerformance             uint8     // 0 = off, 1 = on
+	RecoveryMode                    uint8     // 0 = none, 1 = flashbacks, 2 = auto-recovery
+	FlashbackLimit                  uint8     // 0 = low, 1 = medium, 2 = high, 3 = unlimited
+	SurfaceType                     uint8     // 0 = simplified, 1 = realistic
+	LowFuelMode                     uint8     // 0 = easy, 1 = hard
+	RaceStarts                      uint8     // 0 = manual, 1 = assisted
+	TyreTemperature                 uint8     // 0 = surface only, 1 = surface & carcass
+	PitLaneTyreSim                  uint8     // 0 = on, 1 = off
+	CarDamage                       uint8     // 0 = off, 1 = reduced, 2 = standard, 3 = simulation
+	CarDamageRate                   uint8     // 0 = reduced, 1 = standard, 2 = simulation
+	Collisions                      uint8     // 0 = off, 1 = player-to-player off, 2 = on
+	CollisionsOffForFirstLapOnly    uint8     // 0 = disabled, 1 = enabled
+	MPUnsafePitRelease              uint8     // 0 = on, 1 = off (multiplayer)
+	MPOffForGriefing                uint8     // 0 = disabled, 1 = enabled (multiplayer)
+	CornerCuttingStringency         uint8     // 0 = regular, 1 = strict
+	ParcFermeRules                  uint8     // 0 = off, 1 = on
+	PitStopExperience               uint8     // 0 = automatic, 1 = broadcast, 2 = immersive
+	SafetyCar                       uint8     // 0 = off, 1 = reduced, 2 = standard, 3 = increased
+	SafetyCarExperience             uint8     // 0 = broadcast, 1 = immersive
+	FormationLap                    uint8     // 0 = off, 1 = on
+	FormationLapExperience          uint8     // 0 = broadcast, 1 = immersive
+	RedFlags                        uint8     // 0 = off, 1 = reduced, 2 = standard, 3 = increased
+	AffectsLicenceLevelSolo         uint8     // 0 = off, 1 = on
+	AffectsLicenceLevelMP           uint8     // 0 = off, 1 = on
+	NumSessionsInWeekend            uint8     // number of session in following array
+	WeekendStructure                [12]uint8 // list of session types to show weekend structure
+	Sector2LapDistanceStart         float32   // distance in m around track where sector 2 starts
+	Sector3LapDistanceStart         float32   // distance in m around track where sector 3 starts
+}
+
+type LapData2024 struct {
+	LastLapTimeInMS              uint32  // last lap time in milliseconds
+	CurrentLapTimeInMS           uint32  // current time around the lap in milliseconds
+	Sector1TimeInMS              uint16  // sector 1 time in milliseconds
+	Sector1TimeMinutes           uint8   // sector 1 whole minute part
+	Sector2TimeInMS              uint16  // sector 2 time in milliseconds
+	Sector2TimeMinutes           uint8   // sector 2 whole minute part
+	DeltaToCarInFrontMSPart      uint16  // time delta to car in front milliseconds part
+	DeltaToCarInFrontMinutesPart uint8   // time delta to car in front whole minute part
+	DeltaToRaceLeaderMSPart      uint16  // time delta to race leader milliseconds part
+	DeltaToRaceLeaderMinutesPart uint8   // time delta to race leader whole minute part
+	LapDistance                  float32 // distance vehicle is around current lap in metres
+	TotalDistance                float32 // total distance travelled in session in metres
+	SafetyCarDelta               float32 // delta in seconds for safety car
+	CarPosition                  uint8   // car race position
+	CurrentLapNum                uint8   // current lap number
+	PitStatus                    uint8   // 0 = none, 1 = pitting, 2 = in pit area
+	NumPitStops                  uint8   // number of pit stops taken in this race
+	Sector                       uint8   // 0 = sector1, 1 = sector2, 2 = sector3
+	CurrentLapInvalid            uint8   // current lap invalid - 0 = valid, 1 = invalid
+	Penalties                    uint8   // accumulated time penalties in seconds to be added
+	TotalWarnings                uint8   // accumulated number of warnings issued
+	CornerCuttingWarnings        uint8   // accumulated number of corner cutting warnings issued
+	NumUnservedDriveThroughPens  uint8   // num drive through pens left to serve
+	NumUnservedStopGoPens        uint8   // num stop go pens left to serve
+	GridPosition                 uint8   // grid position the vehicle started the race in
+	DriverStatus                 uint8   // 0 = in garage, 1 = flying lap, 2 = in lap, 3 = out lap, 4 = on track
+	ResultStatus                 uint8   // 0 = invalid, 1 = inactive, 2 = active, 3 = finished, 4 = didnotfinish, 5 = disqualified, 6 = not classified, 7 = retired
+	PitLaneTimerActive           uint8   // pit lane timing, 0 = inactive, 1 = active
+	PitLaneTimeInLaneInMS        uint16  // if active, the current time spent in the pit lane in ms
+	PitStopTimerInMS             uint16  // time of the actual pit stop in ms
+	PitStopShouldServePen        uint8   // whether the car should serve a penalty at this stop
+	SpeedTrapFastestSpeed        float32 // fastest speed through speed trap for this car in kmph
+	SpeedTrapFastestLap          uint8   // lap no the fastest speed was achieved, 255 = not set
+}
+
+type PacketLapData2024 struct {
+	PacketHeader2023
+	LapData              [22]LapData2024 // lap data for all cars on track
+	TimeTrialPBCarIdx    uint8           // index of personal best car in time trial (255 if invalid)
+	TimeTrialRivalCarIdx uint8           // index of rival car in time trial (255 if invalid)
+}
+
+type ParticipantData2024 struct {
+	AIControlled    uint8     // whether the vehicle is AI (1) or human (0) controlled
+	DriverID        uint8     // driver id, 255 if network human
+	NetworkID       uint8     // network id, unique identifier for network players
+	TeamID          uint8     // team id
+	MyTeam          uint8     // my team flag, 1 = My Team, 0 = otherwise
+	RaceNumber      uint8     // race number of the car
+	Nationality     uint8     // nationality of the driver
+	Name            [48]uint8 // name of participant in UTF-8 format, null terminated
+	YourTelemetry   uint8     // the player's UDP setting, 0 = restricted, 1 = public
+	ShowOnlineNames uint8     // the player's show online names setting, 0 = off, 1 = on
+	TechLevel       uint16    // F1 World tech level
+	Platform        uint8     // 1 = Steam, 3 = PlayStation, 4 = Xbox, 6 = Origin, 255 = unknown
+}
+
+type PacketParticipantsData2024 struct {
+	PacketHeader2023
+	NumActiveCars uint8 // number of active cars in the data
+	Participants  [22]ParticipantData2024
+}
+
+type CarSetupData2024 struct {
+	FrontWing              uint8   // front wing aero
+	RearWing               uint8   // rear wing aero
+	OnThrottle             uint8   // differential adjustment on throttle (percentage)
+	OffThrottle            uint8   // differential adjustment off throttle (percentage)
+	FrontCamber            float32 // front camber angle (suspension geometry)
+	RearCamber             float32 // rear camber angle (suspension geometry)
+	FrontToe               float32 // front toe angle (suspension geometry)
+	RearToe                float32 // rear toe angle (suspension geometry)
+	FrontSuspension        uint8   // front suspension
+	RearSuspension         uint8   // rear suspension
+	FrontAntiRollBar       uint8   // front anti-roll bar
+	RearAntiRollBar        uint8   // rear anti-roll bar
+	FrontSuspensionHeight  uint8   // front ride height
+	RearSuspensionHeight   uint8   // rear ride height
+	BrakePressure          uint8   // brake pressure (percentage)
+	BrakeBias              uint8   // brake bias (percentage)
+	EngineBraking          uint8   // engine braking (percentage)
+	RearLeftTyrePressure   float32 // rear left tyre pressure (PSI)
+	RearRightTyrePressure  float32 // rear right tyre pressure (PSI)
+	FrontLeftTyrePressure  float32 // front left tyre pressure (PSI)
+	FrontRightTyrePressure float32 // front right tyre pressure (PSI)
+	Ballast                uint8   // ballast
+	FuelLoad               float32 // fuel load
+}
+
+type PacketCarSetupData2024 struct {
+	PacketHeader2023
+	CarSetups          [22]CarSetupData2024
+	NextFrontWingValue float32 // value of front wing after next pit stop - player only
+}
+
+type LobbyInfoData2024 struct {
+	AIControlled    uint8     // whether the vehicle is AI (1) or human (0) controlled
+	TeamID          uint8     // team id, 255 if no team currently selected
+	Nationality     uint8     // nationality of the driver
+	Platform        uint8     // 1 = Steam, 3 = PlayStation, 4 = Xbox, 6 = Origin, 255 = unknown
+	Name            [48]uint8 // name of participant in UTF-8 format, null terminated
+	CarNumber       uint8     // car number of the player
+	YourTelemetry   uint8     // the player's UDP setting, 0 = restricted, 1 = public
+	ShowOnlineNames uint8     // the player's show online names setting, 0 = off, 1 = on
+	TechLevel       uint16    // F1 World tech level
+	ReadyStatus     uint8     // 0 = not ready, 1 = ready, 2 = spectating
+}
+
+type PacketLobbyInfoData2024 struct {
+	PacketHeader2023
+	NumPlayers   uint8 // number of players in the lobby data
+	LobbyPlayers [22]LobbyInfoData2024
+}
+
+type PacketMotionExData2024 struct {
+	PacketMotionExData2023
+	FrontAeroHeight float32 // front plank edge height above road surface
+	RearAeroHeight  float32 // rear plank edge height above road surface
+	FrontRollAngle  float32 // roll angle of the front suspension
+	RearRollAngle   float32 // roll angle of the rear suspension
+	ChassisYaw      float32 // yaw angle of the chassis relative to the direction of motion - radians
+}
+
+type TimeTrialDataSet2024 struct {
+	CarIdx              uint8  // index of the car this data relates to
+	TeamID              uint8  // team id
+	LapTimeInMS         uint32 // lap time in milliseconds
+	Sector1TimeInMS     uint32 // sector 1 time in milliseconds
+	Sector2TimeInMS     uint32 // sector 2 time in milliseconds
+	Sector3TimeInMS     uint32 // sector 3 time in milliseconds
+	TractionControl     uint8  // 0 = off, 1 = medium, 2 = full
+	GearboxAssist       uint8  // 1 = manual, 2 = manual & suggested gear, 3 = auto
+	AntiLockBrakes      uint8  // 0 (off) - 1 (on)
+	EqualCarPerformance uint8  // 0 = realistic, 1 = equal
+	CustomSetup         uint8  // 0 = no, 1 = yes
+	Valid               uint8  // 0 = invalid, 1 = valid
+}
+
+type PacketTimeTrialData2024 struct {
+	PacketHeader2023
+	PlayerSessionBestDataSet TimeTrialDataSet2024 // player session best data set
+	PersonalBestDataSet      TimeTrialDataSet2024 // personal best data set
+	RivalDataSet             TimeTrialDataSet2024 // rival data set
+}
+
+func (l LapData2024) lap() lap {
+	return lap{
+		lastLapTime:       seconds(l.LastLapTimeInMS),
+		currentLapTime:    seconds(l.CurrentLapTimeInMS),
+		sector1Time:       minutesSeconds(l.Sector1TimeInMS, l.Sector1TimeMinutes),
+		sector2Time:       minutesSeconds(l.Sector2TimeInMS, l.Sector2TimeMinutes),
+		lapDistance:       l.LapDistance,
+		totalDistance:     l.TotalDistance,
+		carPosition:       l.CarPosition,
+		currentLapNum:     l.CurrentLapNum,
+		pitStatus:         l.PitStatus,
+		sector:            l.Sector,
+		currentLapInvalid: l.CurrentLapInvalid,
+		penalties:         l.Penalties,
+	}
+}
+
+func (p *PacketSessionData2024) apply(s *State) {
+	s.applySession(session{
+		weather:             p.Weather,
+		trackTemperature:    p.TrackTemperature,
+		airTemperature:      p.AirTemperature,
+		totalLaps:           p.TotalLaps,
+		trackLength:         p.TrackLength,
+		sessionType:         legacySessionType(p.SessionType, 14, 17),
+		trackID:             p.TrackID,
+		era:                 legacyEra(p.Formula),
+		sessionTimeLeft:     p.SessionTimeLeft,
+		pitSpeedLimit:       p.PitSpeedLimit,
+		gamePaused:          p.GamePaused,
+		isSpectating:        p.IsSpectating,
+		spectatorCarIndex:   p.SpectatorCarIndex,
+		sliProNativeSupport: p.SliProNativeSupport,
+		safetyCarStatus:     p.SafetyCarStatus,
+		networkGame:         p.NetworkGame,
+	})
+}
+
+func (p *PacketLapData2024) apply(s *State) {
+	for i, l := range p.LapData {
+		s.applyLap(i, l.lap())
+	}
+}
+
+func (p *PacketParticipantsData2024) apply(s *State) {
+	s.NumCars = p.NumActiveCars
+	for i, d := range p.Participants {
+		s.applyParticipant(i, d.DriverID, d.TeamID, d.Name[:])
+	}
+}
+
+// Car setups have no 2017 counterpart.
+func (p *PacketCarSetupData2024) apply(s *State) {}
+
+// Lobby info is only sent before a session starts.
+func (p *PacketLobbyInfoData2024) apply(s *State) {}
+
+// Time trial data sets have no 2017 counterpart.
+func (p *PacketTimeTrialData2024) apply(s *State) {}
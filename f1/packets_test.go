@@ -0,0 +1,485 @@
+package f1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// packetSizes are the sizes of the packets of each format, as documented by
+// the games.
+var packetSizes = map[uint16]map[uint8]int{
+	2018: {0: 1341, 1: 147, 2: 841, 3: 25, 4: 1082, 5: 841, 6: 1085, 7: 1061},
+	2019: {0: 1343, 1: 149, 2: 843, 3: 32, 4: 1104, 5: 843, 6: 1347, 7: 1143},
+	2020: {0: 1464, 1: 251, 2: 1190, 3: 35, 4: 1213, 5: 1102, 6: 1307, 7: 1344, 8: 839, 9: 1169},
+	2021: {0: 1464, 1: 625, 2: 970, 3: 36, 4: 1257, 5: 1102, 6: 1347, 7: 1058, 8: 839, 9: 1191, 10: 882, 11: 1155},
+	2022: {0: 1464, 1: 632, 2: 972, 3: 40, 4: 1257, 5: 1102, 6: 1347, 7: 1058, 8: 1015, 9: 1191, 10: 948, 11: 1155},
+	2023: {0: 1349, 1: 644, 2: 1131, 3: 45, 4: 1306, 5: 1107, 6: 1352, 7: 1239, 8: 1020, 9: 1218, 10: 953, 11: 1460, 12: 231, 13: 217},
+	2024: {0: 1349, 1: 753, 2: 1285, 3: 45, 4: 1350, 5: 1133, 6: 1352, 7: 1239, 8: 1020, 9: 1306, 10: 953, 11: 1460, 12: 231, 13: 237, 14: 101},
+}
+
+func TestPacketSizes(t *testing.T) {
+	for _, pf := range PacketFormats() {
+		want, ok := packetSizes[pf]
+		if !ok {
+			t.Errorf("no documented sizes for format %d", pf)
+			continue
+		}
+		if !reflect.DeepEqual(formats[pf].sizes, want) {
+			t.Errorf("format %d: sizes %v, want %v", pf, formats[pf].sizes, want)
+		}
+	}
+}
+
+// The golden files testdata/packets<format>.bin hold one frame of each header
+// based format: a packet of every type the format has, ending with the car
+// telemetry, each preceded by its length as a little-endian uint16. The cars
+// are racing at Silverstone, the player in car fixturePlayer. Run the tests
+// with -update to write them again.
+
+const (
+	fixturePlayer     = 3
+	fixtureSessionUID = 0x0123456789abcdef
+)
+
+func TestGoldenPackets(t *testing.T) {
+	for _, pf := range PacketFormats() {
+		name := filepath.Join("testdata", fmt.Sprintf("packets%d.bin", pf))
+		if *update {
+			if err := ioutil.WriteFile(name, fixture(pf), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var s State
+		var stream Stream
+		var frame Frame
+		var complete bool
+		var ids []uint8
+		for len(b) > 0 {
+			n := int(binary.LittleEndian.Uint16(b))
+			datagram := b[2 : 2+n]
+			b = b[2+n:]
+			if f, ok, err := stream.Decode(datagram); err != nil {
+				t.Fatalf("%d: %v", pf, err)
+			} else if ok {
+				frame = f
+			}
+
+			p, err := DecodePacket(datagram)
+			if err != nil {
+				t.Fatalf("%d: %v", pf, err)
+			}
+			h := p.PacketHeader()
+			if n != packetSizes[pf][h.PacketID] {
+				t.Errorf("%d: packet %d is %d bytes, want %d", pf, h.PacketID, n, packetSizes[pf][h.PacketID])
+			}
+			if h.PacketFormat != pf || h.SessionUID != fixtureSessionUID || h.PlayerCarIndex != fixturePlayer {
+				t.Errorf("%d: packet %d has header %+v", pf, h.PacketID, h)
+			}
+			if f, err := Sniff(datagram); err != nil || f.PacketFormat != pf {
+				t.Errorf("%d: Sniff(packet %d) = %v, %v", pf, h.PacketID, f, err)
+			}
+			ids = append(ids, h.PacketID)
+			complete = s.Apply(p)
+		}
+		if len(ids) != len(packetSizes[pf]) {
+			t.Errorf("%d: fixture has packets %v, want one of each of %d types", pf, ids, len(packetSizes[pf]))
+		}
+		if !complete {
+			t.Errorf("%d: frame not complete after the car telemetry", pf)
+		}
+		checkFixtureState(t, pf, &s)
+		if frame.TelemetryData != s.TelemetryData || frame.Conditions != s.Conditions {
+			t.Errorf("%d: frame of the stream differs from the state", pf)
+		}
+	}
+}
+
+func checkFixtureState(t *testing.T, pf uint16, s *State) {
+	player := &s.Cars[fixturePlayer]
+	floats := []struct {
+		name      string
+		got, want float32
+	}{
+		{"Time", s.Time, 123.5},
+		{"Speed", s.Speed, 200 / 3.6},
+		{"Throttle", s.Throttle, 0.8},
+		{"Brake", s.Brake, 0.25},
+		{"Steer", s.Steer, -0.5},
+		{"Gear", s.Gear, 6},
+		{"Enginerate", s.Enginerate, 11000},
+		{"Laptime", s.Laptime, 30.25},
+		{"Lapdistance", s.Lapdistance, 1234.5},
+		{"Lap", s.Lap, 2},
+		{"CarPosition", s.CarPosition, fixturePlayer + 1},
+		{"Sector1Time", s.Sector1Time, 25.5},
+		{"Sector2Time", s.Sector2Time, 28.25},
+		{"LastLapTime", s.LastLapTime, 81.5 + fixturePlayer},
+		{"FuelInTank", s.FuelInTank, 12.5},
+		{"TrackNumber", s.TrackNumber, 7},
+		{"TrackSize", s.TrackSize, 5891},
+		{"TotalLaps", s.TotalLaps, 52},
+		{"SessionType", s.SessionType, 3},
+		{"Era", s.Era, 2017},
+		{"X", s.X, 100 + fixturePlayer},
+		{"WheelSpeed[0]", s.WheelSpeed[0], 50},
+		{"Cars[player].LastlapTime", player.LastlapTime, 81.5 + fixturePlayer},
+		{"Cars[player].BestlapTime", player.BestlapTime, 80.75},
+		{"Cars[0].LastlapTime", s.Cars[0].LastlapTime, 81.5},
+	}
+	for _, f := range floats {
+		if math.Abs(float64(f.got-f.want)) > 1e-3 {
+			t.Errorf("%d: %s = %g, want %g", pf, f.name, f.got, f.want)
+		}
+	}
+
+	ints := []struct {
+		name      string
+		got, want byte
+	}{
+		{"TyreCompound", s.TyreCompound, 3},           // medium
+		{"Cars[player].DriverID", player.DriverID, 9}, // HAM
+		{"Cars[player].TeamID", player.TeamID, 4},     // Mercedes
+		{"Cars[player].TyreCompound", player.TyreCompound, 3},
+		{"Cars[player].CurrentLapNum", player.CurrentLapNum, 3},
+		{"Cars[0].DriverID", s.Cars[0].DriverID, 0}, // VET
+		{"Cars[0].TeamID", s.Cars[0].TeamID, 1},     // Ferrari
+		{"Cars[0].CarPosition", s.Cars[0].CarPosition, 1},
+		{"Cars[1].InPits", s.Cars[1].InPits, 1},
+		{"TyresWear[3]", s.TyresWear[3], 13},
+		{"PitSpeedLimit", s.PitSpeedLimit, 22}, // m/s
+		{"Weather", s.Weather, 1},
+		{"TrackTemperature", byte(s.TrackTemperature), 32},
+		{"AirTemperature", byte(s.AirTemperature), 24},
+		{"SafetyCarStatus", s.SafetyCarStatus, 2},
+	}
+	for _, b := range ints {
+		if b.got != b.want {
+			t.Errorf("%d: %s = %d, want %d", pf, b.name, b.got, b.want)
+		}
+	}
+	if s.Names[fixturePlayer] != "Driver 3" {
+		t.Errorf("%d: Names[player] = %q, want %q", pf, s.Names[fixturePlayer], "Driver 3")
+	}
+	if s.Event != "SSTA" {
+		t.Errorf("%d: Event = %q, want SSTA", pf, s.Event)
+	}
+}
+
+// fixture returns the golden file of format pf.
+func fixture(pf uint16) []byte {
+	var ids []int
+	for id := range formats[pf].packets {
+		if id != PacketCarTelemetry {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+	ids = append(ids, PacketCarTelemetry)
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		p := formats[pf].packets[uint8(id)]()
+		fill(reflect.ValueOf(p).Elem(), pf, uint8(id), 0)
+		var datagram bytes.Buffer
+		binary.Write(&datagram, binary.LittleEndian, p)
+		binary.Write(&buf, binary.LittleEndian, uint16(datagram.Len()))
+		buf.Write(datagram.Bytes())
+	}
+	return buf.Bytes()
+}
+
+// fill sets the fields of packet id of format pf to the values of the
+// fixtures, car being the index of the car v belongs to.
+func fill(v reflect.Value, pf uint16, id uint8, car int) {
+	typ := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f, name := v.Field(i), typ.Field(i).Name
+		switch {
+		case strings.HasPrefix(name, "PacketHeader"):
+			fillHeader(f, pf, id)
+		case f.Kind() == reflect.Struct:
+			fill(f, pf, id, car)
+		case f.Kind() == reflect.Array && f.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < f.Len(); j++ {
+				fill(f.Index(j), pf, id, j)
+			}
+		case name == "Name":
+			copy(f.Slice(0, f.Len()).Bytes(), fmt.Sprintf("Driver %d", car))
+		case name == "EventStringCode":
+			copy(f.Slice(0, f.Len()).Bytes(), "SSTA")
+		case f.Kind() == reflect.Array:
+			if value, ok := fixtureValue(name, pf, car); ok {
+				for j := 0; j < f.Len(); j++ {
+					set(f.Index(j), name, value+float64(j))
+				}
+			}
+		default:
+			if value, ok := fixtureValue(name, pf, car); ok {
+				set(f, name, value)
+			}
+		}
+	}
+}
+
+func fillHeader(h reflect.Value, pf uint16, id uint8) {
+	values := map[string]float64{
+		"PacketFormat":            float64(pf),
+		"GameYear":                float64(pf % 100),
+		"GameMajorVersion":        1,
+		"GameMinorVersion":        2,
+		"PacketVersion":           1,
+		"PacketID":                float64(id),
+		"SessionTime":             123.5,
+		"FrameIdentifier":         4567,
+		"OverallFrameIdentifier":  4567,
+		"PlayerCarIndex":          fixturePlayer,
+		"SecondaryPlayerCarIndex": 255,
+	}
+	for i := 0; i < h.NumField(); i++ {
+		f, name := h.Field(i), h.Type().Field(i).Name
+		if name == "SessionUID" {
+			f.SetUint(fixtureSessionUID)
+		} else if value, ok := values[name]; ok {
+			set(f, name, value)
+		}
+	}
+}
+
+// fixtureValue returns the value of the field called name of car in the
+// fixtures of format pf, in the units of the field, if it has one. Pedals and
+// steering are fractions, scaled to percent for integer fields.
+func fixtureValue(name string, pf uint16, car int) (float64, bool) {
+	lastLap := 81.5 + float64(car)
+	switch name {
+	case "WorldPositionX":
+		return 100 + float64(car), true
+	case "WheelSpeed":
+		return 50, true
+	case "Weather":
+		return 1, true
+	case "TrackTemperature":
+		return 32, true
+	case "AirTemperature":
+		return 24, true
+	case "SafetyCarStatus":
+		return 2, true // virtual
+	case "PitSpeedLimit":
+		return 80, true // km/h
+	case "TotalLaps":
+		return 52, true
+	case "TrackLength":
+		return 5891, true
+	case "TrackID":
+		return 7, true // Silverstone
+	case "SessionType":
+		if pf >= 2023 {
+			return 15, true // race, after the sprint shootouts
+		}
+		return 10, true
+	case "LastLapTime":
+		return lastLap, true
+	case "LastLapTimeInMS":
+		return lastLap * 1000, true
+	case "CurrentLapTime":
+		return 30.25, true
+	case "CurrentLapTimeInMS":
+		return 30250, true
+	case "BestLapTime":
+		return 80.75, true
+	case "LapTimeInMS":
+		return 80750, true
+	case "BestLapTimeLapNum":
+		return 1, true
+	case "CarIdx":
+		return fixturePlayer, true
+	case "Sector1Time":
+		return 25.5, true
+	case "Sector1TimeInMS":
+		return 25500, true
+	case "Sector2Time":
+		return 28.25, true
+	case "Sector2TimeInMS":
+		return 28250, true
+	case "LapDistance":
+		return 1234.5, true
+	case "CarPosition":
+		return float64(car + 1), true
+	case "CurrentLapNum":
+		return 3, true
+	case "PitStatus":
+		if car == 1 {
+			return 1, true // pitting
+		}
+		return 0, true
+	case "NumCars", "NumActiveCars":
+		return 20, true
+	case "DriverID":
+		switch car {
+		case 0:
+			return 13, true // VET
+		case fixturePlayer:
+			return 7, true // HAM
+		}
+		return 100, true // unknown
+	case "TeamID":
+		switch car {
+		case 0:
+			return 1, true // Ferrari
+		case fixturePlayer:
+			return 0, true // Mercedes
+		}
+		return 9, true
+	case "Speed":
+		return 200, true
+	case "Throttle":
+		return 0.8, true
+	case "Brake":
+		return 0.25, true
+	case "Steer":
+		return -0.5, true
+	case "Gear":
+		return 6, true
+	case "EngineRPM":
+		return 11000, true
+	case "FuelInTank":
+		return 12.5, true
+	case "TyresWear":
+		return 10, true
+	case "TyreCompound":
+		return 4, true // medium in 2018
+	case "ActualTyreCompound":
+		return 11, true // C3
+	case "VisualTyreCompound":
+		return 17, true // medium
+	}
+	return 0, false
+}
+
+// set sets f, named name, to value, scaling fractions to percent for the
+// integer pedal and steering fields.
+func set(f reflect.Value, name string, value float64) {
+	switch f.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f.SetFloat(value)
+		return
+	}
+	if name == "Throttle" || name == "Brake" || name == "Steer" {
+		value *= 100
+	}
+	switch f.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.SetUint(uint64(math.Round(value)))
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.SetInt(int64(math.Round(value)))
+	default:
+		panic("cannot set " + name + " of kind " + f.Kind().String())
+	}
+}
+
+// specHeader holds the byte offsets of the header fields of a format, as
+// documented by the game, major being -1 if the format has no game version.
+type specHeader struct {
+	size, id, uid, time, frame, player, major int
+}
+
+var specHeaders = map[uint16]specHeader{
+	2018: {21, 3, 4, 12, 16, 20, -1},
+	2019: {23, 5, 6, 14, 18, 22, 2},
+	2020: {24, 5, 6, 14, 18, 22, 2},
+	2021: {24, 5, 6, 14, 18, 22, 2},
+	2022: {24, 5, 6, 14, 18, 22, 2},
+	2023: {29, 6, 7, 15, 19, 27, 3},
+	2024: {29, 6, 7, 15, 19, 27, 3},
+}
+
+// specCarTelemetry is the size of the telemetry of one car of each format,
+// speed being its first field.
+var specCarTelemetry = map[uint16]int{2018: 53, 2019: 66, 2020: 58, 2021: 60, 2022: 60, 2023: 60, 2024: 60}
+
+// specPacket returns a datagram of packet id of format pf, zero but for the
+// header, laid out by hand from the documentation of the game.
+func specPacket(pf uint16, id uint8) []byte {
+	h := specHeaders[pf]
+	b := make([]byte, packetSizes[pf][id])
+	binary.LittleEndian.PutUint16(b, pf)
+	if h.major >= 0 {
+		b[h.major], b[h.major+1] = 1, 23
+	}
+	if pf >= 2023 {
+		b[2] = byte(pf % 100)
+	}
+	b[h.id] = id
+	binary.LittleEndian.PutUint64(b[h.uid:], fixtureSessionUID)
+	binary.LittleEndian.PutUint32(b[h.time:], math.Float32bits(123.5))
+	binary.LittleEndian.PutUint32(b[h.frame:], 4567)
+	b[h.player] = 19
+	return b
+}
+
+// TestSpecOffsets decodes a session and a car telemetry packet of each
+// format built from the byte offsets the games document, rather than from the
+// packet structs, so the structs are checked against the documentation.
+func TestSpecOffsets(t *testing.T) {
+	for _, pf := range PacketFormats() {
+		h := specHeaders[pf]
+
+		// The session data, from right after the header.
+		session := specPacket(pf, PacketSession)
+		body := session[h.size:]
+		body[0] = 3                                   // weather: light rain
+		body[1] = 32                                  // track temperature
+		body[2] = 0xfc                                // air temperature, -4
+		body[3] = 52                                  // total laps
+		binary.LittleEndian.PutUint16(body[4:], 5891) // track length
+		body[6] = 10                                  // session type: race
+		body[7] = 7                                   // track: Silverstone
+		binary.LittleEndian.PutUint16(body[9:], 3600) // session time left
+		body[13] = 80                                 // pit speed limit, km/h
+		body[124] = 1                                 // safety car: full
+		if pf >= 2023 {
+			body[6] = 15 // race, after the sprint shootouts
+		}
+
+		// The car telemetry of car 19, the player.
+		telemetry := specPacket(pf, PacketCarTelemetry)
+		binary.LittleEndian.PutUint16(telemetry[h.size+19*specCarTelemetry[pf]:], 252)
+
+		var s State
+		for _, b := range [][]byte{session, telemetry} {
+			p, err := DecodePacket(b)
+			if err != nil {
+				t.Fatalf("%d: %v", pf, err)
+			}
+			s.Apply(p)
+		}
+		if f, err := Sniff(session); err != nil || h.major >= 0 && (f.GameMajorVersion != 1 || f.GameMinorVersion != 23) {
+			t.Errorf("%d: Sniff = %v, %v, want version 1.23", pf, f, err)
+		}
+
+		got := []interface{}{s.SessionUID, s.FrameIdentifier, s.Time, s.PlayerCarIndex,
+			s.Weather, s.TrackTemperature, s.AirTemperature, s.TotalLaps, s.TrackSize, s.SessionType, s.TrackNumber,
+			s.SessionTimeLeft, s.PitSpeedLimit, s.SafetyCarStatus, s.Speed}
+		want := []interface{}{uint64(fixtureSessionUID), uint32(4567), float32(123.5), byte(19),
+			uint8(3), int8(32), int8(-4), float32(52), float32(5891), float32(3), float32(7),
+			float32(3600), byte(22), uint8(1), float32(252 / 3.6)}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: decoded %v,\nwant %v", pf, got, want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package f1
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// RestartTime is how close to the start of a session the game time has
+	// to go back to for a session to count as restarted.
+	RestartTime = 3 // seconds
+	// MaxFlashback is the most game time a flashback rewinds; the game time
+	// going back further starts a new session.
+	MaxFlashback = 60 // seconds
+)
+
+// A Session is one session of a telemetry stream: a practice, qualifying or
+// race session, or a time trial, from the first frame to the last.
+type Session struct {
+	ID         string // source and start time, e.g. ":20777-20180504-193012.250"
+	Source     string
+	Format     Format
+	SessionUID uint64    // sent by the header based formats, 0 otherwise
+	Start      time.Time // receive time of the first frame
+	End        time.Time // receive time of the last frame, once ended
+
+	// Taken from the frames. The header based formats send them a few times
+	// a second, so they may be 0 in the session start event.
+	SessionType float32
+	TrackNumber float32
+	Era         float32
+	TotalLaps   float32
+}
+
+// SessionEventType tells session start events from end events.
+type SessionEventType int
+
+const (
+	SessionStart SessionEventType = iota
+	SessionEnd
+)
+
+func (t SessionEventType) String() string {
+	if t == SessionEnd {
+		return "end"
+	}
+	return "start"
+}
+
+// A SessionEvent reports a session starting or ending.
+type SessionEvent struct {
+	Type    SessionEventType
+	Session Session
+}
+
+// A SessionTracker splits the frames of one source into sessions. A new
+// session starts when the game starts another session, when the session
+// data, such as the track, changes, when the game time or laps go back to
+// the start, or when the stream stays silent for longer than Gap.
+type SessionTracker struct {
+	Gap time.Duration // 0 for no limit
+
+	session Session
+	active  bool
+	seeked  bool
+	last    Frame
+}
+
+// Current returns the session in progress, if any.
+func (t *SessionTracker) Current() (Session, bool) {
+	return t.session, t.active
+}
+
+// Track sets the Session of frame, returning the events of the session in
+// progress ending and of a new one starting, if frame starts one.
+func (t *SessionTracker) Track(frame *Frame) []SessionEvent {
+	var events []SessionEvent
+	if t.active && t.boundary(frame) {
+		event, _ := t.End(t.last.Received)
+		events = append(events, event)
+	}
+	started := !t.active
+	if started {
+		t.session = Session{
+			ID:         fmt.Sprintf("%s-%s", frame.Source, frame.Received.UTC().Format("20060102-150405.000")),
+			Source:     frame.Source,
+			Format:     frame.Format,
+			SessionUID: frame.SessionUID,
+			Start:      frame.Received,
+		}
+		t.active = true
+	}
+	t.update(frame)
+	if started {
+		events = append(events, SessionEvent{Type: SessionStart, Session: t.session})
+	}
+
+	t.last, t.seeked = *frame, false
+	frame.Session = t.session.ID
+	return events
+}
+
+// Seek makes the next frame continue the session in progress, wherever in it
+// the frame is, as after seeking in a session file.
+func (t *SessionTracker) Seek() {
+	t.seeked = true
+}
+
+// End ends the session in progress, as of receive time end, for instance when
+// the stream went silent or was closed.
+func (t *SessionTracker) End(end time.Time) (SessionEvent, bool) {
+	if !t.active {
+		return SessionEvent{}, false
+	}
+	t.active = false
+	t.session.End = end
+	return SessionEvent{Type: SessionEnd, Session: t.session}, true
+}
+
+// boundary reports whether frame starts a new session.
+func (t *SessionTracker) boundary(frame *Frame) bool {
+	last := &t.last
+	switch {
+	case frame.Source != last.Source || !frame.Format.Matches(last.Format):
+		return true
+	case t.seeked:
+		return false
+	case t.Gap > 0 && frame.Received.Sub(last.Received) > t.Gap:
+		return true
+	case frame.Time < RestartTime && last.Time >= RestartTime, last.Time-frame.Time > MaxFlashback:
+		return true
+	case frame.Lap < last.Lap-1:
+		// A flashback takes back a lap at most.
+		return true
+	}
+
+	if frame.SessionUID != 0 {
+		// The game tells its sessions apart, and only sends the session
+		// data every so often, so it goes from 0 to known mid session.
+		return frame.SessionUID != last.SessionUID
+	}
+	return frame.SessionType != last.SessionType || frame.TrackNumber != last.TrackNumber ||
+		frame.Era != last.Era || frame.TotalLaps != last.TotalLaps
+}
+
+// update refreshes the session data of the session in progress from frame.
+func (t *SessionTracker) update(frame *Frame) {
+	t.session.SessionType = frame.SessionType
+	t.session.TrackNumber = frame.TrackNumber
+	t.session.Era = frame.Era
+	t.session.TotalLaps = frame.TotalLaps
+}
+
+// FileName replaces the characters of a source name or session ID that are
+// not safe in file names, such as the colon of ":20777".
+func FileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
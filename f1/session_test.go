@@ -0,0 +1,137 @@
+package f1
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var trackerStart = time.Date(2018, 5, 4, 19, 30, 12, 250e6, time.UTC)
+
+// trackerFrame returns the i-th frame of a stream, a second apart in both
+// receive and game time, 2018 format.
+func trackerFrame(i int) Frame {
+	f := Frame{Format: Format{PacketFormat: 2018}, Source: ":20777", Received: trackerStart.Add(time.Duration(i) * time.Second)}
+	f.SessionUID = 1
+	f.Time = float32(100 + i)
+	f.Lap = 2
+	f.TrackNumber = 7
+	return f
+}
+
+// legacyFrame is trackerFrame in the legacy format, which has no session
+// UID.
+func legacyFrame(i int) Frame {
+	f := trackerFrame(i)
+	f.Format, f.SessionUID = Format{}, 0
+	return f
+}
+
+func TestSessionTracker(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		gap   time.Duration
+		first func(i int) Frame
+		// Changes to the second frame, and the events it causes, the
+		// frames being made by first, trackerFrame by default.
+		change func(f *Frame)
+		seek   bool
+		want   string
+	}{
+		{name: "same session", change: func(f *Frame) {}},
+		{name: "session UID", change: func(f *Frame) { f.SessionUID = 2 }, want: "end start"},
+		{name: "session data sent", first: func(i int) Frame {
+			f := trackerFrame(i)
+			f.TrackNumber = 0
+			return f
+		}, change: func(f *Frame) { f.TrackNumber = 7 }},
+		{name: "session data with UID", change: func(f *Frame) { f.TotalLaps = 52 }},
+		{name: "track", first: legacyFrame, change: func(f *Frame) { f.TrackNumber = 8 }, want: "end start"},
+		{name: "session type", first: legacyFrame, change: func(f *Frame) { f.SessionType = 10 }, want: "end start"},
+		{name: "total laps", first: legacyFrame, change: func(f *Frame) { f.TotalLaps = 52 }, want: "end start"},
+		{name: "legacy", first: legacyFrame, change: func(f *Frame) {}},
+
+		{name: "gap", gap: 5 * time.Second, change: func(f *Frame) { f.Received = f.Received.Add(5 * time.Second) }, want: "end start"},
+		{name: "within gap", gap: 5 * time.Second, change: func(f *Frame) { f.Received = f.Received.Add(4 * time.Second) }},
+		{name: "no gap", change: func(f *Frame) { f.Received = f.Received.Add(time.Hour) }},
+
+		{name: "format", change: func(f *Frame) { f.Format.PacketFormat = 2019 }, want: "end start"},
+		{name: "legacy extradata", first: legacyFrame, change: func(f *Frame) { f.Format.Extradata = 3 }, want: "end start"},
+		{name: "game version", change: func(f *Frame) { f.Format.GameMajorVersion = 1 }},
+		{name: "source", change: func(f *Frame) { f.Source = ":20778" }, want: "end start"},
+
+		{name: "restart", change: func(f *Frame) { f.Time = 1 }, want: "end start"},
+		{name: "flashback", change: func(f *Frame) { f.Time = 60; f.Lap = 1 }},
+		{name: "too far back", change: func(f *Frame) { f.Time = 30 }, want: "end start"},
+		{name: "laps back", change: func(f *Frame) { f.Lap = 0 }, want: "end start"},
+		{name: "seek back", change: func(f *Frame) { f.Time = 1; f.Lap = 0 }, seek: true},
+		{name: "seek to another format", change: func(f *Frame) { f.Format.PacketFormat = 2019 }, seek: true, want: "end start"},
+	} {
+		first := trackerFrame
+		if test.first != nil {
+			first = test.first
+		}
+		tracker := SessionTracker{Gap: test.gap}
+		f0, f1 := first(0), first(1)
+		test.change(&f1)
+
+		events := tracker.Track(&f0)
+		if len(events) != 1 || events[0].Type != SessionStart {
+			t.Fatalf("%s: first frame: %v, want a session start", test.name, events)
+		}
+		started := events[0].Session
+		if started.ID != ":20777-20180504-193012.250" || f0.Session != started.ID || !started.Start.Equal(f0.Received) {
+			t.Fatalf("%s: session %+v started by frame of session %q", test.name, started, f0.Session)
+		}
+
+		if test.seek {
+			tracker.Seek()
+		}
+		var types []string
+		for _, e := range tracker.Track(&f1) {
+			types = append(types, e.Type.String())
+			switch e.Type {
+			case SessionEnd:
+				if e.Session.ID != started.ID || !e.Session.End.Equal(f0.Received) {
+					t.Errorf("%s: ended %+v, want %s ending with the last frame", test.name, e.Session, started.ID)
+				}
+			case SessionStart:
+				if e.Session.ID == started.ID || !e.Session.Start.Equal(f1.Received) || e.Session.Format != f1.Format {
+					t.Errorf("%s: started %+v, want a session of the second frame", test.name, e.Session)
+				}
+			}
+		}
+		if got := strings.Join(types, " "); got != test.want {
+			t.Errorf("%s: second frame: %q, want %q", test.name, got, test.want)
+		}
+		current, ok := tracker.Current()
+		if !ok || current.ID != f1.Session || current.TrackNumber != f1.TrackNumber || current.TotalLaps != f1.TotalLaps {
+			t.Errorf("%s: current session %+v, %v, want the session of the second frame, %q", test.name, current, ok, f1.Session)
+		}
+	}
+}
+
+func TestSessionTrackerEnd(t *testing.T) {
+	var tracker SessionTracker
+	if _, ok := tracker.End(trackerStart); ok {
+		t.Fatal("ended a session before the first frame")
+	}
+	f := trackerFrame(0)
+	tracker.Track(&f)
+	end := trackerStart.Add(time.Minute)
+	if e, ok := tracker.End(end); !ok || e.Type != SessionEnd || e.Session.ID != f.Session || !e.Session.End.Equal(end) {
+		t.Fatalf("End = %+v, %v", e, ok)
+	}
+	if _, ok := tracker.Current(); ok {
+		t.Fatal("session in progress once ended")
+	}
+	if _, ok := tracker.End(end); ok {
+		t.Fatal("ended the session twice")
+	}
+
+	// The next frame starts a session, even if it would continue the last.
+	f = trackerFrame(1)
+	if events := tracker.Track(&f); len(events) != 1 || events[0].Type != SessionStart {
+		t.Fatalf("frame after the end: %v, want a session start", events)
+	}
+}
@@ -0,0 +1,452 @@
+package f1
+
+// State merges the packets of a header based telemetry stream into a single
+// per-session model.
+//
+// The embedded TelemetryData mirrors the player car and the car array in
+// F1 2017 terms, so a State can be consumed anywhere a legacy packet is:
+// speeds are in m/s, pedal inputs in 0..1, times in seconds, and driver, team,
+// tyre compound and session type ids are translated to the 2017 numbering.
+// Formats with more than 20 cars only fill in the first 20. Data that has no
+// 2017 counterpart is kept alongside, the Conditions in the frames too.
+type State struct {
+	TelemetryData
+	Conditions
+
+	PacketFormat    uint16
+	SessionUID      uint64
+	FrameIdentifier uint32
+	GamePaused      uint8  // whether the game is paused
+	NetworkGame     uint8  // 0 = offline, 1 = online
+	ERSDeployMode   uint8  // 0 = none, 1 = low, 2 = medium, 3 = high, 4 = overtake, 5 = hotlap
+	Event           string // code of the last event, e.g. "SSTA" or "SEND"
+}
+
+// Conditions are the session data of the header based formats that has no
+// 2017 counterpart, carried by their frames.
+type Conditions struct {
+	Weather          uint8      // 0 = clear, 1 = light cloud, 2 = overcast, 3 = light rain, 4 = heavy rain, 5 = storm
+	TrackTemperature int8       // track temp. in degrees celsius
+	AirTemperature   int8       // air temp. in degrees celsius
+	SafetyCarStatus  uint8      // 0 = no safety car, 1 = full safety car, 2 = virtual safety car, 3 = formation lap
+	Names            [20]string // participant names, by car index
+}
+
+// Apply merges p into the state, starting over whenever the session changes.
+// It reports whether p completed a frame, which happens on every car
+// telemetry packet.
+func (s *State) Apply(p Packet) bool {
+	h := p.PacketHeader()
+	if h.SessionUID != s.SessionUID || h.PacketFormat != s.PacketFormat {
+		*s = State{PacketFormat: h.PacketFormat, SessionUID: h.SessionUID}
+	}
+
+	s.FrameIdentifier = h.FrameIdentifier
+	s.Time = h.SessionTime
+	s.PlayerCarIndex = h.PlayerCarIndex
+	p.apply(s)
+
+	return h.PacketID == PacketCarTelemetry
+}
+
+// car returns the car at index i, or nil for cars beyond the 2017 array.
+func (s *State) car(i int) *CarData {
+	if i < 0 || i >= len(s.Cars) {
+		return nil
+	}
+	return &s.Cars[i]
+}
+
+// player returns the index of the player's car in an array of n cars, and
+// false while spectating.
+func (s *State) player(n int) (int, bool) {
+	i := int(s.PlayerCarIndex)
+	return i, i < n
+}
+
+// playerMotion is the extra motion data sent for the player car only.
+type playerMotion struct {
+	suspensionPosition     [4]float32
+	suspensionVelocity     [4]float32
+	suspensionAcceleration [4]float32
+	wheelSpeed             [4]float32
+	localVelocity          [3]float32
+	angularVelocity        [3]float32
+	angularAcceleration    [3]float32
+}
+
+// lap is the lap data of one car, in seconds and metres.
+type lap struct {
+	lastLapTime       float32
+	currentLapTime    float32
+	sector1Time       float32
+	sector2Time       float32
+	lapDistance       float32
+	totalDistance     float32
+	carPosition       uint8
+	currentLapNum     uint8
+	pitStatus         uint8
+	sector            uint8
+	currentLapInvalid uint8
+	penalties         uint8
+}
+
+// carTelemetry is the telemetry of one car, with pedals and steering scaled
+// to 0..1 and -1..1.
+type carTelemetry struct {
+	speed                   uint16 // kilometres per hour
+	throttle                float32
+	steer                   float32
+	brake                   float32
+	clutch                  uint8 // percentage
+	gear                    int8
+	engineRPM               uint16
+	drs                     uint8
+	revLightsPercent        uint8
+	brakesTemperature       [4]uint16
+	tyresSurfaceTemperature [4]uint16
+	engineTemperature       uint16
+	tyresPressure           [4]float32
+}
+
+// carStatus is the status of one car, with the tyre compound already
+// translated to the 2017 numbering.
+type carStatus struct {
+	tractionControl  uint8
+	antiLockBrakes   uint8
+	fuelMix          uint8
+	frontBrakeBias   uint8
+	pitLimiterStatus uint8
+	fuelInTank       float32
+	fuelCapacity     float32
+	maxRPM           uint16
+	idleRPM          uint16
+	maxGears         uint8
+	drsAllowed       int8
+	tyreCompound     uint8
+	vehicleFIAFlags  int8
+	ersStoreEnergy   float32
+	ersDeployMode    uint8
+}
+
+// carDamage is the damage of one car, in percent.
+type carDamage struct {
+	tyresWear            [4]uint8
+	tyresDamage          [4]uint8
+	frontLeftWingDamage  uint8
+	frontRightWingDamage uint8
+	rearWingDamage       uint8
+	engineDamage         uint8
+	gearBoxDamage        uint8
+	exhaustDamage        uint8
+}
+
+// session is the session data shared by every format, with the session type
+// and era already translated to the 2017 values.
+type session struct {
+	weather             uint8
+	trackTemperature    int8
+	airTemperature      int8
+	totalLaps           uint8
+	trackLength         uint16
+	sessionType         float32
+	trackID             int8
+	era                 float32
+	sessionTimeLeft     uint16
+	pitSpeedLimit       uint8 // kilometres per hour
+	gamePaused          uint8
+	isSpectating        uint8
+	spectatorCarIndex   uint8
+	sliProNativeSupport uint8
+	safetyCarStatus     uint8
+	networkGame         uint8
+}
+
+func (s *State) applyCarMotion(motion []CarMotionData2018) {
+	for i, m := range motion {
+		if c := s.car(i); c != nil {
+			c.WorldPosition = [3]float32{m.WorldPositionX, m.WorldPositionY, m.WorldPositionZ}
+		}
+	}
+
+	i, ok := s.player(len(motion))
+	if !ok {
+		return
+	}
+	m := motion[i]
+	s.X, s.Y, s.Z = m.WorldPositionX, m.WorldPositionY, m.WorldPositionZ
+	s.Xv, s.Yv, s.Zv = m.WorldVelocityX, m.WorldVelocityY, m.WorldVelocityZ
+	s.Xd, s.Yd, s.Zd = normalised(m.WorldForwardDirX), normalised(m.WorldForwardDirY), normalised(m.WorldForwardDirZ)
+	s.Xr, s.Yr, s.Zr = normalised(m.WorldRightDirX), normalised(m.WorldRightDirY), normalised(m.WorldRightDirZ)
+	s.GforceLat = m.GForceLateral
+	s.GforceLon = m.GForceLongitudinal
+	s.GforceVert = m.GForceVertical
+	s.Yaw, s.Pitch, s.Roll = m.Yaw, m.Pitch, m.Roll
+}
+
+func (s *State) applyPlayerMotion(m playerMotion) {
+	s.SuspPos = m.suspensionPosition
+	s.SuspVel = m.suspensionVelocity
+	s.SuspAcceleration = m.suspensionAcceleration
+	s.WheelSpeed = m.wheelSpeed
+	s.XLocalVelocity, s.YLocalVelocity, s.ZLocalVelocity = m.localVelocity[0], m.localVelocity[1], m.localVelocity[2]
+	s.AngVelX, s.AngVelY, s.AngVelZ = m.angularVelocity[0], m.angularVelocity[1], m.angularVelocity[2]
+	s.AngAccX, s.AngAccY, s.AngAccZ = m.angularAcceleration[0], m.angularAcceleration[1], m.angularAcceleration[2]
+}
+
+func (s *State) applySession(p session) {
+	s.Weather = p.weather
+	s.TrackTemperature = p.trackTemperature
+	s.AirTemperature = p.airTemperature
+	s.GamePaused = p.gamePaused
+	s.SafetyCarStatus = p.safetyCarStatus
+	s.NetworkGame = p.networkGame
+
+	s.TotalLaps = float32(p.totalLaps)
+	s.TrackSize = float32(p.trackLength)
+	s.SessionType = p.sessionType
+	s.TrackNumber = float32(p.trackID)
+	s.Era = p.era
+	s.SessionTimeLeft = float32(p.sessionTimeLeft)
+	s.PitSpeedLimit = metresPerSecond(p.pitSpeedLimit)
+	s.IsSpectating = p.isSpectating
+	s.SpectatorCarIndex = p.spectatorCarIndex
+	s.SliProNativeSupport = float32(p.sliProNativeSupport)
+}
+
+// applyLap merges the lap data of car i. The best lap is not part of the lap
+// data in every format, so callers set it separately.
+func (s *State) applyLap(i int, l lap) {
+	if c := s.car(i); c != nil {
+		c.LastlapTime = l.lastLapTime
+		c.CurrentlapTime = l.currentLapTime
+		c.Sector1Time = l.sector1Time
+		c.Sector2Time = l.sector2Time
+		c.LapDistance = l.lapDistance
+		c.CarPosition = l.carPosition
+		c.CurrentLapNum = l.currentLapNum
+		c.InPits = l.pitStatus
+		c.Sector = l.sector
+		c.Currentlapinvalid = l.currentLapInvalid
+		c.Penalties = l.penalties
+	}
+
+	if i != int(s.PlayerCarIndex) {
+		return
+	}
+	s.Laptime = l.currentLapTime
+	s.Lapdistance = l.lapDistance
+	s.Totaldistance = l.totalDistance
+	s.Lap = 0
+	if l.currentLapNum > 0 {
+		s.Lap = float32(l.currentLapNum - 1)
+	}
+	s.CarPosition = float32(l.carPosition)
+	s.InPits = float32(l.pitStatus)
+	s.Sector = float32(l.sector)
+	s.Sector1Time = l.sector1Time
+	s.Sector2Time = l.sector2Time
+	s.LastLapTime = l.lastLapTime
+	s.Currentlapinvalid = l.currentLapInvalid
+}
+
+func (s *State) applyParticipant(i int, driverID, teamID uint8, name []byte) {
+	c := s.car(i)
+	if c == nil {
+		return
+	}
+	s.Names[i] = cString(name)
+	c.DriverID = legacyDriver(driverID)
+	c.TeamID = legacyTeam(teamID)
+	if i == int(s.PlayerCarIndex) {
+		s.TeamInfo = float32(c.TeamID)
+	}
+}
+
+func (s *State) applyCarTelemetry(t carTelemetry) {
+	s.Speed = float32(t.speed) / 3.6
+	s.Throttle = t.throttle
+	s.Steer = t.steer
+	s.Brake = t.brake
+	s.Clutch = float32(t.clutch) / 100
+	s.Gear = float32(t.gear)
+	s.Enginerate = float32(t.engineRPM)
+	s.DRS = float32(t.drs)
+	s.RevLightsPercent = t.revLightsPercent
+	s.EngineTemperature = float32(t.engineTemperature)
+	s.TyresPressure = t.tyresPressure
+	for i := range t.brakesTemperature {
+		s.BrakesTemp[i] = float32(t.brakesTemperature[i])
+		s.TyresTemperature[i] = saturate(t.tyresSurfaceTemperature[i])
+	}
+}
+
+func (s *State) applyCarStatus(c carStatus) {
+	s.TractionControl = float32(c.tractionControl)
+	s.AntiLockBrakes = float32(c.antiLockBrakes)
+	s.FuelMix = c.fuelMix
+	s.FrontBrakeBias = c.frontBrakeBias
+	s.PitLimiterStatus = c.pitLimiterStatus
+	s.FuelInTank = c.fuelInTank
+	s.FuelCapacity = c.fuelCapacity
+	s.MaxRpm = float32(c.maxRPM)
+	s.IdleRpm = float32(c.idleRPM)
+	s.MaxGears = float32(c.maxGears)
+	s.Drsallowed = float32(c.drsAllowed)
+	s.TyreCompound = c.tyreCompound
+	s.Vehiclefiaflags = float32(c.vehicleFIAFlags)
+	s.KersLevel = c.ersStoreEnergy
+	s.ERSDeployMode = c.ersDeployMode
+}
+
+func (s *State) applyCarDamage(d carDamage) {
+	s.TyresWear = d.tyresWear
+	s.TyresDamage = d.tyresDamage
+	s.FrontLeftWingDamage = d.frontLeftWingDamage
+	s.FrontRightWingDamage = d.frontRightWingDamage
+	s.RearWingDamage = d.rearWingDamage
+	s.EngineDamage = d.engineDamage
+	s.GearBoxDamage = d.gearBoxDamage
+	s.ExhaustDamage = d.exhaustDamage
+}
+
+// saturate clamps a temperature to the byte range used by the 2017 format.
+func saturate(v uint16) byte {
+	if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
+
+// normalised converts a normalised direction component to a unit float.
+func normalised(v int16) float32 {
+	return float32(v) / 32767
+}
+
+// metresPerSecond converts a speed in kilometres per hour to whole metres per
+// second.
+func metresPerSecond(kmh uint8) uint8 {
+	return uint8(float32(kmh)/3.6 + 0.5)
+}
+
+// seconds converts a time in milliseconds to seconds.
+func seconds(ms uint32) float32 {
+	return float32(ms) / 1000
+}
+
+// legacyEra maps the formula field sent from 2019 onwards to the 2017 era.
+func legacyEra(formula uint8) float32 {
+	if formula == 1 {
+		return 1980
+	}
+	return 2017
+}
+
+// legacyTeams maps the team ids used from 2018 onwards to the 2017 ones. The
+// grid kept its ten slots, so later liveries of a team map to the same id.
+var legacyTeams = map[uint8]byte{
+	0: 4,  // Mercedes
+	1: 1,  // Ferrari
+	2: 0,  // Red Bull
+	3: 7,  // Williams
+	4: 6,  // Force India, Racing Point, Aston Martin
+	5: 3,  // Renault, Alpine
+	6: 8,  // Toro Rosso, AlphaTauri, RB
+	7: 11, // Haas
+	8: 2,  // McLaren
+	9: 5,  // Sauber, Alfa Romeo
+}
+
+// legacyDrivers maps the driver ids used from 2018 onwards to the 2017 ones,
+// for the drivers that raced in both games.
+var legacyDrivers = map[uint8]byte{
+	0:  23, // SAI
+	1:  1,  // KVY
+	2:  16, // RIC
+	3:  2,  // ALO
+	6:  6,  // RÄI
+	7:  9,  // HAM
+	8:  18, // ERI
+	9:  22, // VER
+	10: 10, // HUL
+	11: 14, // MAG
+	12: 7,  // GRO
+	13: 0,  // VET
+	14: 5,  // PER
+	15: 15, // BOT
+	17: 33, // OCO
+	18: 34, // VAN
+	19: 35, // STR
+}
+
+func legacyTeam(id uint8) byte {
+	if t, ok := legacyTeams[id]; ok {
+		return t
+	}
+	return 255
+}
+
+func legacyDriver(id uint8) byte {
+	if d, ok := legacyDrivers[id]; ok {
+		return d
+	}
+	return 255
+}
+
+// legacyCompounds2018 maps the 2018 tyre compounds to the closest 2017 one.
+var legacyCompounds2018 = [...]byte{
+	0: 0, // hyper soft
+	1: 0, // ultra soft
+	2: 1, // super soft
+	3: 2, // soft
+	4: 3, // medium
+	5: 4, // hard
+	6: 4, // super hard
+	7: 5, // inter
+	8: 6, // wet
+}
+
+func legacyCompound2018(c uint8) byte {
+	if int(c) < len(legacyCompounds2018) {
+		return legacyCompounds2018[c]
+	}
+	return c
+}
+
+// legacyVisualCompound maps the visual tyre compounds sent from 2019 onwards
+// to the closest 2017 one.
+func legacyVisualCompound(c uint8) byte {
+	switch c {
+	case 7: // inter
+		return 5
+	case 8, 15: // wet, F2 wet
+		return 6
+	case 16, 20: // soft, F2 soft
+		return 2
+	case 17, 21: // medium, F2 medium
+		return 3
+	case 18, 22: // hard, F2 hard
+		return 4
+	case 19: // F2 super soft
+		return 1
+	}
+	return c
+}
+
+// legacySessionType maps the detailed session types used from 2018 onwards
+// to 0 = unknown, 1 = practice, 2 = qualifying, 3 = race. The last
+// qualifying and race session types moved as sessions were added over the
+// years, time trial always follows the last race type.
+func legacySessionType(t, lastQualifying, lastRace uint8) float32 {
+	switch {
+	case t == 0:
+		return 0
+	case t <= 4:
+		return 1
+	case t <= lastQualifying:
+		return 2
+	case t <= lastRace:
+		return 3
+	}
+	return 1
+}
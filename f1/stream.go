@@ -0,0 +1,100 @@
+package f1
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFormatMismatch is returned by a Stream for datagrams sent in a different
+// format than the one it locked onto.
+var ErrFormatMismatch = errors.New("f1: packet format does not match the stream")
+
+// A Frame is one frame of telemetry along with the format it was sent in.
+type Frame struct {
+	Format   Format
+	Source   string    // where the frame came from, e.g. the name of a listener
+	Received time.Time // when the datagram completing the frame was received
+
+	// SessionUID is sent by the header based formats, and 0 for the others.
+	SessionUID uint64
+	// Session is the ID of the session the frame belongs to, if tracked by
+	// a SessionTracker.
+	Session string
+
+	TelemetryData
+	// Conditions are sent by the header based formats only, see
+	// FieldConditions.
+	Conditions
+}
+
+// PlayerLap returns the lap the player is on, counting from 1.
+func (f *Frame) PlayerLap() int {
+	if !f.Format.Fields().Has(FieldCars) {
+		// Lap counts the laps completed.
+		return int(f.Lap) + 1
+	}
+	if i := int(f.PlayerCarIndex); i < len(f.Cars) {
+		return int(f.Cars[i].CurrentLapNum)
+	}
+	return 0
+}
+
+// A Stream decodes the datagrams of one telemetry stream, whatever format
+// they are sent in. It locks onto the format of the first datagram it
+// decodes and refuses datagrams of any other format until Reset, so a second
+// game sending to the same port can't mix its data into the session.
+type Stream struct {
+	format Format
+	locked bool
+	state  State
+}
+
+// Format returns the format the stream is locked onto, if any.
+func (s *Stream) Format() (Format, bool) {
+	return s.format, s.locked
+}
+
+// Reset unlocks the stream and drops the session state, so the next datagram
+// may be sent in any format.
+func (s *Stream) Reset() {
+	*s = Stream{}
+}
+
+// Decode decodes a single datagram. Datagrams of the single packet layouts
+// each yield a frame; the header based formats are merged into the state of
+// the stream, which only yields a frame once the packet completing it
+// arrives. ok reports whether frame holds a new frame.
+func (s *Stream) Decode(b []byte) (frame Frame, ok bool, err error) {
+	f, err := Sniff(b)
+	if err != nil {
+		return Frame{}, false, err
+	}
+	if s.locked && !f.Matches(s.format) {
+		return Frame{}, false, ErrFormatMismatch
+	}
+
+	switch {
+	case f.PacketFormat == 0:
+		frame.TelemetryData, err = DecodeExtradata(b)
+		ok = err == nil
+	case f.PacketFormat == LegacyPacketFormat:
+		frame.TelemetryData, err = Decode(b)
+		ok = err == nil
+	default:
+		var p Packet
+		p, err = DecodePacket(b)
+		if err == nil {
+			ok = s.state.Apply(p)
+			frame.TelemetryData = s.state.TelemetryData
+			frame.Conditions = s.state.Conditions
+			frame.SessionUID = s.state.SessionUID
+		}
+	}
+	if err != nil {
+		return Frame{}, false, err
+	}
+
+	s.format, s.locked = f, true
+	frame.Format = f
+	return frame, ok, nil
+}
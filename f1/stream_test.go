@@ -0,0 +1,128 @@
+package f1
+
+import "testing"
+
+// legacyDatagram returns a datagram of the legacy format.
+func legacyDatagram() []byte {
+	telemetry := sampleTelemetry()
+	b, _ := telemetry.MarshalBinary()
+	return b
+}
+
+type sniffTest struct {
+	name string
+	b    []byte
+	want Format
+	err  error
+}
+
+func TestSniff(t *testing.T) {
+	legacy := legacyDatagram()
+	tests := []sniffTest{
+		{"legacy", legacy, Format{PacketFormat: LegacyPacketFormat, Extradata: 3}, nil},
+		{"extradata 0", make([]byte, 17*4), Format{Extradata: 0}, nil},
+		{"extradata 1", make([]byte, 38*4), Format{Extradata: 1}, nil},
+		{"extradata 2", make([]byte, 64*4), Format{Extradata: 2}, nil},
+		{"extradata 3", make([]byte, 66*4), Format{Extradata: 3}, nil},
+		{"empty", nil, Format{}, ErrUnknownFormat},
+		{"unknown size", make([]byte, 100), Format{}, ErrUnknownFormat},
+	}
+	for _, pf := range PacketFormats() {
+		b := specPacket(pf, PacketCarTelemetry)
+		want := Format{PacketFormat: pf}
+		if specHeaders[pf].major >= 0 {
+			want.GameMajorVersion, want.GameMinorVersion = 1, 23
+		}
+		tests = append(tests,
+			sniffTest{"header", b, want, nil},
+			sniffTest{"header, short", b[:len(b)-1], Format{}, ErrShortPacket},
+			sniffTest{"header, long", append(b, 0), Format{}, ErrUnknownFormat})
+	}
+	for _, test := range tests {
+		f, err := Sniff(test.b)
+		if f != test.want || err != test.err {
+			t.Errorf("%s, %d bytes: Sniff = %v, %v, want %v, %v", test.name, len(test.b), f, err, test.want, test.err)
+		}
+	}
+}
+
+// TestStreamMixed checks a stream locked onto a format refuses datagrams of
+// other formats, and of the wrong size, without losing its state.
+func TestStreamMixed(t *testing.T) {
+	var s Stream
+	if _, locked := s.Format(); locked {
+		t.Fatal("new stream locked")
+	}
+	session := specPacket(2019, PacketSession)
+	session[specHeaders[2019].size+7] = 7 // track: Silverstone
+	if _, _, err := s.Decode(session); err != nil {
+		t.Fatal(err)
+	}
+	if f, locked := s.Format(); !locked || f.PacketFormat != 2019 {
+		t.Fatalf("stream locked onto %v, %v, want 2019", f, locked)
+	}
+
+	legacy := legacyDatagram()
+	for _, b := range [][]byte{
+		specPacket(2018, PacketCarTelemetry),
+		specPacket(2020, PacketCarTelemetry),
+		legacy,
+		make([]byte, 38*4),
+	} {
+		if _, _, err := s.Decode(b); err != ErrFormatMismatch {
+			t.Errorf("%d bytes of format %d: %v, want a mismatch", len(b), packetFormat(b), err)
+		}
+	}
+	telemetry := specPacket(2019, PacketCarTelemetry)
+	for _, b := range [][]byte{telemetry[:len(telemetry)-1], append(telemetry, 0)} {
+		if _, _, err := s.Decode(b); err == nil || err == ErrFormatMismatch {
+			t.Errorf("%d bytes of car telemetry: %v, want a size error", len(b), err)
+		}
+	}
+
+	// Another version of the same game is the same format.
+	telemetry[specHeaders[2019].major] = 2
+	frame, _, err := s.Decode(telemetry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.TrackNumber != 7 || frame.Format.GameMajorVersion != 2 {
+		t.Errorf("frame on track %g, of version %d, want the state of the stream kept", frame.TrackNumber, frame.Format.GameMajorVersion)
+	}
+}
+
+// TestStreamRelock checks a reset stream locks onto the format of the next
+// datagram, with the state of the last one dropped.
+func TestStreamRelock(t *testing.T) {
+	var s Stream
+	extradata := make([]byte, 38*4)
+	if _, ok, err := s.Decode(extradata); err != nil || !ok {
+		t.Fatalf("Decode = %v, %v", ok, err)
+	}
+	legacy := legacyDatagram()
+	// The extradata levels are formats of their own.
+	for _, b := range [][]byte{legacy, make([]byte, 66*4)} {
+		if _, _, err := s.Decode(b); err != ErrFormatMismatch {
+			t.Fatalf("%d bytes: %v, want a mismatch", len(b), err)
+		}
+	}
+
+	s.Reset()
+	frame, ok, err := s.Decode(legacy)
+	if err != nil || !ok || frame.Format.PacketFormat != LegacyPacketFormat || frame.Speed != sampleTelemetry().Speed {
+		t.Fatalf("Decode after Reset = %v, %v, %v", frame.Format, ok, err)
+	}
+	if _, _, err := s.Decode(extradata); err != ErrFormatMismatch {
+		t.Fatalf("extradata after relocking: %v, want a mismatch", err)
+	}
+
+	session := specPacket(2018, PacketSession)
+	session[specHeaders[2018].size+7] = 7
+	s.Reset()
+	s.Decode(session)
+	s.Reset()
+	s.Decode(specPacket(2018, PacketCarTelemetry))
+	if s.state.TrackNumber != 0 {
+		t.Fatalf("track %g after Reset, want the session state dropped", s.state.TrackNumber)
+	}
+}
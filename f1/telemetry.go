@@ -1,5 +1,7 @@
 package f1
 
+// TelemetryData is the F1 2017 telemetry packet. It extends the extradata=3
+// layout shared by the Codemasters games with session, car and motion data.
 type TelemetryData struct {
 	Time                 float32
 	Laptime              float32
@@ -86,6 +88,18 @@ type TelemetryData struct {
 	NumCars        byte        // number of cars in data
 	PlayerCarIndex byte        // index of player's car in the array
 	Cars           [20]CarData // data for all cars on track
+
+	// Motion
+	Yaw              float32    // NEW (v1.8)
+	Pitch            float32    // NEW (v1.8)
+	Roll             float32    // NEW (v1.8)
+	XLocalVelocity   float32    // NEW (v1.8) Velocity in local space
+	YLocalVelocity   float32    // NEW (v1.8) Velocity in local space
+	ZLocalVelocity   float32    // NEW (v1.8) Velocity in local space
+	SuspAcceleration [4]float32 // NEW (v1.8) RL, RR, FL, FR
+	AngAccX          float32    // NEW (v1.8) angular acceleration x-component
+	AngAccY          float32    // NEW (v1.8) angular acceleration y-component
+	AngAccZ          float32    // NEW (v1.8) angular acceleration z-component
 }
 
 type CarData struct {
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+)
+
+// importMain runs the import command, which writes the telemetry of session
+// files and captures to the sinks, InfluxDB by default, as if it was received
+// live, at the times it was received.
+func importMain(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	port := flags.Uint("port", DefaultPort, "UDP `port` to read the telemetry of pcap and pcapng captures from, 0 for any")
+	config := flags.String("config", "", "`file` configuring the sinks to write telemetry to (default Influx on localhost)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s import [flags] file...\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Files are session files or pcap and pcapng captures.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *port > math.MaxUint16 {
+		log.Fatalf("invalid port %d", *port)
+	}
+
+	// Importing can take as long as it needs, so every frame is written.
+	sinks, err := loadPipeline(*config, 1000, OverflowBlock)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range sinks.sinks {
+		s.overflow = OverflowBlock
+	}
+	if err := sinks.open(); err != nil {
+		log.Fatal(err)
+	}
+	var telemetry bus
+	sinks.start(&telemetry)
+
+	for _, name := range flags.Args() {
+		if err := importFile(name, uint16(*port), &telemetry); err != nil {
+			fmt.Println("Error: ", err)
+		}
+	}
+	telemetry.close()
+	sinks.wait()
+}
+
+// importFile decodes the session file or capture called name and publishes
+// its frames and session events on telemetry.
+func importFile(name string, port uint16, telemetry *bus) error {
+	s, err := openSessionFile(name, port)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	dec := newDecoder()
+	frames := 0
+	for {
+		record, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		messages, err := dec.decode(s.source, record.Data, record.Time)
+		if err != nil {
+			continue
+		}
+		for _, m := range messages {
+			if m.event == nil {
+				frames++
+			}
+			telemetry.publish(m)
+		}
+	}
+	if m, ok := dec.end(); ok {
+		telemetry.publish(m)
+	}
+
+	fmt.Printf("%s: %d frames imported", name, frames)
+	if skipped := s.Skipped(); skipped > 0 {
+		fmt.Printf(", %d datagrams skipped as not captured whole", skipped)
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/luan/f1-telemetry/recording"
+)
+
+// indexMain runs the index command, which rebuilds the index of session
+// files, such as those recorded before indexes existed or cut short by a
+// crash.
+func indexMain(args []string) {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s index file...\n", os.Args[0])
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, name := range flags.Args() {
+		x, err := recording.BuildIndex(name)
+		if err == recording.ErrNotRecording {
+			err = fmt.Errorf("%s: %v", name, err)
+		}
+		if err == nil {
+			err = recording.SaveIndex(name, x)
+		}
+		if err != nil {
+			fmt.Println("Error: ", err)
+			failed = true
+			continue
+		}
+
+		laps := 0
+		if n := len(x.Entries); n > 0 {
+			laps = x.Entries[n-1].Lap
+		}
+		fmt.Printf("%s: %d entries up to lap %d\n", name, len(x.Entries), laps)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
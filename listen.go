@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// DefaultPort is the UDP port the games send telemetry to by default.
+	DefaultPort = 20777
+	// DefaultListenAddr is the address telemetry is received on by default.
+	DefaultListenAddr = ":20777"
+)
+
+// A listener is a UDP address telemetry is received on. Its name tags every
+// frame received on it, so several rigs can be told apart.
+type listener struct {
+	name string
+	addr string
+}
+
+// listeners collects the -listen flags.
+type listeners []listener
+
+func (l *listeners) String() string {
+	var s []string
+	for _, listener := range *l {
+		s = append(s, listener.name+"="+listener.addr)
+	}
+	return strings.Join(s, ",")
+}
+
+// Set parses a listener as "name=address" or just "address", in which case
+// the address doubles as its name. Addresses are host:port pairs; IPv6 hosts
+// go in brackets, e.g. "[::]:20777" or "[fe80::1%eth0]:20777".
+func (l *listeners) Set(s string) error {
+	name, addr := s, s
+	if i := strings.Index(s, "="); i >= 0 {
+		name, addr = s[:i], s[i+1:]
+	}
+	if name == "" || addr == "" {
+		return errors.New("want [name=]address")
+	}
+	for _, listener := range *l {
+		if listener.name == name {
+			return fmt.Errorf("duplicate listener name %q", name)
+		}
+	}
+
+	*l = append(*l, listener{name: name, addr: addr})
+	return nil
+}
+
+// listen binds the UDP address of l.
+func (l listener) listen() (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", l.addr)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", addr)
+}
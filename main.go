@@ -1,207 +1,172 @@
 package main
 
 import (
-	"encoding/binary"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net"
-	"strconv"
+	"os"
 	"time"
 
-	client "github.com/influxdata/influxdb/client/v2"
 	"github.com/luan/f1-telemetry/f1"
+	"github.com/luan/f1-telemetry/sink"
 )
 
 const (
-	DBName = "f1telemetry"
+	// StreamTimeout is how long the game has to stay silent before the
+	// listener accepts datagrams of a different format.
+	StreamTimeout = 5 * time.Second
+
+	// UIBufferSize is how many frames the dashboard may fall behind by
+	// before it skips the oldest ones, about a second of telemetry.
+	UIBufferSize = 64
 )
 
 func main() {
-	dataChan := make(chan f1.TelemetryData, 1000)
-
-	go serveTelemetry(dataChan)
-
-	uiDataChan := make(chan f1.TelemetryData, 1000)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			replayMain(os.Args[2:])
+			return
+		case "import":
+			importMain(os.Args[2:])
+			return
+		case "index":
+			indexMain(os.Args[2:])
+			return
+		case "convert":
+			convertMain(os.Args[2:])
+			return
+		case "export":
+			exportMain(os.Args[2:])
+			return
+		case "sessions":
+			sessionsMain(os.Args[2:])
+			return
+		case "laps":
+			lapsMain(os.Args[2:])
+			return
+		}
+	}
 
-	influxDataChan := make(chan f1.TelemetryData, 1000)
-	go influx(influxDataChan)
+	var addrs listeners
+	flag.Var(&addrs, "listen", "`[name=]address` to receive telemetry on, may be repeated (default "+DefaultListenAddr+")")
+	var targets relays
+	flag.Var(&targets, "relay", "`[source=]host:port[?rate=N&packets=ID,...]` to forward received datagrams to, may be repeated")
+	recordDir := flag.String("record", "", "`directory` to record the raw datagrams of every session to")
+	compact := flag.Bool("compact", false, "record compact session files, several times smaller but lossless")
+	config := flag.String("config", "", "`file` configuring the sinks to write telemetry to (default Influx on localhost)")
+	sinkBuffer := flag.Int("sink-buffer", 1000, "`frames` a sink may fall behind by before -sink-overflow applies, unless configured otherwise")
+	sinkOverflow := OverflowDropOldest
+	flag.Var(&sinkOverflow, "sink-overflow", "`policy` for frames a sink has no room for, unless configured otherwise: block (holding up the dashboard), drop-oldest, drop-newest or sample")
+	httpAddr := flag.String("http", "", "`address` to serve the live feed on over WebSocket and server-sent events, and a dashboard for browsers, e.g. localhost:8080")
+	flag.Parse()
+	if len(addrs) == 0 {
+		addrs = listeners{{name: DefaultListenAddr, addr: DefaultListenAddr}}
+	}
 
-	go func() {
-		for data := range dataChan {
-			uiDataChan <- data
-			influxDataChan <- data
+	for _, r := range targets {
+		if err := r.start(); err != nil {
+			log.Fatalf("relay %s: %v", r.addr, err)
 		}
-	}()
-
-	ui := NewUI(uiDataChan)
-	ui.Start()
-}
+	}
 
-func serveTelemetry(dataChan chan<- f1.TelemetryData) {
-	serverAddr, err := net.ResolveUDPAddr("udp", ":20777")
+	sinks, err := loadPipeline(*config, *sinkBuffer, sinkOverflow)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	serverConn, err := net.ListenUDP("udp", serverAddr)
-	if err != nil {
+	if *httpAddr != "" {
+		options, _ := json.Marshal(map[string]string{"addr": *httpAddr})
+		if err := sinks.add(sinkConfig{Config: sink.Config{Type: "feed", Options: options}}, *sinkBuffer, sinkOverflow); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := sinks.open(); err != nil {
 		log.Fatal(err)
 	}
-	defer serverConn.Close()
 
-	for {
-		var telemetry f1.TelemetryData
-		err := binary.Read(serverConn, binary.LittleEndian, &telemetry)
+	// Subscribed before the listeners start, so no frame is missed.
+	var telemetry bus
+	uiSubscriber := telemetry.subscribe("dashboard", UIBufferSize, OverflowDropOldest)
+	sinks.start(&telemetry)
+
+	if *recordDir != "" {
+		if err := os.MkdirAll(*recordDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var recorders []*recorder
+	for _, l := range addrs {
+		serverConn, err := l.listen()
 		if err != nil {
-			fmt.Println("Error: ", err)
+			log.Fatalf("listen %s: %v", l.name, err)
+		}
+		var rec *recorder
+		if *recordDir != "" {
+			rec = newRecorder(*recordDir, *compact)
+			recorders = append(recorders, rec)
 		}
+		go serveTelemetry(serverConn, l.name, targets, rec, &telemetry)
+	}
 
-		dataChan <- telemetry
+	ui := NewUI(uiSubscriber.C, targets, telemetry.list())
+	ui.Start()
+
+	// Let the sinks write what was published so far before exiting.
+	telemetry.close()
+	sinks.wait()
+	for _, rec := range recorders {
+		rec.stop()
 	}
 }
 
-func influx(dataChan <-chan f1.TelemetryData) {
-	// Create a new HTTPClient
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr: "http://localhost:8086",
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
+// serveTelemetry forwards the datagrams received on serverConn to targets,
+// records them with rec if not nil, decodes them and publishes the frames,
+// tagged with source, and the session events on telemetry.
+func serveTelemetry(serverConn *net.UDPConn, source string, targets relays, rec *recorder, telemetry *bus) {
+	defer serverConn.Close()
 
-	for data := range dataChan {
-		// Create a new point batch
-		bp, err := client.NewBatchPoints(client.BatchPointsConfig{
-			Database:  DBName,
-			Precision: "us",
-		})
+	dec := newDecoder()
+	buf := make([]byte, 2048)
+	for {
+		serverConn.SetReadDeadline(time.Now().Add(StreamTimeout))
+		n, err := serverConn.Read(buf)
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			// The game went silent, so the session is over.
+			if m, ok := dec.end(); ok {
+				if rec != nil {
+					rec.end()
+				}
+				telemetry.publish(m)
+			}
+			continue
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		// Create a point and add to batch
-		tags := map[string]string{"driver": "self"}
-		fields := map[string]interface{}{
-			"time":                    data.Speed,
-			"laptime":                 data.Laptime,
-			"lapdistance":             data.Lapdistance,
-			"totaldistance":           data.Totaldistance,
-			"x":                       data.X,
-			"y":                       data.Y,
-			"z":                       data.Z,
-			"speed":                   data.Speed,
-			"xv":                      data.Xv,
-			"yv":                      data.Yv,
-			"zv":                      data.Zv,
-			"xr":                      data.Xr,
-			"yr":                      data.Yr,
-			"zr":                      data.Zr,
-			"xd":                      data.Xd,
-			"yd":                      data.Yd,
-			"zd":                      data.Zd,
-			"susp-pos":                data.SuspPos,
-			"susp-vel":                data.SuspVel,
-			"wheel-speed":             data.WheelSpeed,
-			"throttle":                data.Throttle,
-			"steer":                   data.Steer,
-			"brake":                   data.Brake,
-			"clutch":                  data.Clutch,
-			"gear":                    data.Gear,
-			"gforce-lat":              data.GforceLat,
-			"gforce-lon":              data.GforceLon,
-			"lap":                     data.Lap,
-			"enginerate":              data.Enginerate,
-			"sli-pro-native-support":  data.SliProNativeSupport,
-			"car-position":            data.CarPosition,
-			"kers-level":              data.KersLevel,
-			"kers-max-level":          data.KersMaxLevel,
-			"drs":                     data.DRS,
-			"traction-control":        data.TractionControl,
-			"anti-lock-brakes":        data.AntiLockBrakes,
-			"fuel-in-tank":            data.FuelInTank,
-			"fuel-capacity":           data.FuelCapacity,
-			"in-pits":                 data.InPits,
-			"sector":                  data.Sector,
-			"sector1-time":            data.Sector1Time,
-			"sector2-time":            data.Sector2Time,
-			"brakes-temp":             data.BrakesTemp,
-			"tyres-pressure":          data.TyresPressure,
-			"team-info":               data.TeamInfo,
-			"total-laps":              data.TotalLaps,
-			"track-size":              data.TrackSize,
-			"last-lap-time":           data.LastLapTime,
-			"max-rpm":                 data.MaxRpm,
-			"idle-rpm":                data.IdleRpm,
-			"max-gears":               data.MaxGears,
-			"session-type":            data.SessionType,
-			"drsallowed":              data.Drsallowed,
-			"track-number":            data.TrackNumber,
-			"vehiclefiaflags":         data.Vehiclefiaflags,
-			"era":                     data.Era,
-			"engine-temperature":      data.EngineTemperature,
-			"gforce-vert":             data.GforceVert,
-			"ang-vel-x":               data.AngVelX,
-			"ang-vel-y":               data.AngVelY,
-			"ang-vel-z":               data.AngVelZ,
-			"tyres-temperature":       data.TyresTemperature,
-			"tyres-wear":              data.TyresWear,
-			"tyre-compound":           data.TyreCompound,
-			"front-brake-bias":        data.FrontBrakeBias,
-			"fuel-mix":                data.FuelMix,
-			"currentlapinvalid":       data.Currentlapinvalid,
-			"tyres-damage":            data.TyresDamage,
-			"front-left-wing-damage":  data.FrontLeftWingDamage,
-			"front-right-wing-damage": data.FrontRightWingDamage,
-			"rear-wing-damage":        data.RearWingDamage,
-			"engine-damage":           data.EngineDamage,
-			"gear-box-damage":         data.GearBoxDamage,
-			"exhaust-damage":          data.ExhaustDamage,
-			"pit-limiter-status":      data.PitLimiterStatus,
-			"pit-speed-limit":         data.PitSpeedLimit,
-			"session-time-left":       data.SessionTimeLeft,
-			"rev-lights-percent":      data.RevLightsPercent,
-			"is-spectating":           data.IsSpectating,
-			"spectator-car-index":     data.SpectatorCarIndex,
-			"num-cars":                data.NumCars,
-			"player-car-index":        data.PlayerCarIndex,
+		now := time.Now()
+		targets.forward(source, buf[:n])
+
+		messages, err := dec.decode(source, buf[:n], now)
+		if rec != nil {
+			// Recorded even if not decoded, in the session it was sent in.
+			session, _ := dec.session()
+			rec.record(buf[:n], now, session)
 		}
-
-		pt, err := client.NewPoint("telemetry", tags, fields, time.Now())
-		if err != nil {
-			log.Fatal(err)
+		if err == f1.ErrFormatMismatch {
+			// Another game is sending to the same port; keep listening to
+			// the one we locked onto.
+			continue
 		}
-		bp.AddPoint(pt)
-
-		for _, car := range data.Cars {
-			tags := map[string]string{"driver": strconv.Itoa(int(car.DriverID))}
-			fields := map[string]interface{}{
-				"lastlap-time":      car.LastlapTime,
-				"currentlap-time":   car.CurrentlapTime,
-				"bestlap-time":      car.BestlapTime,
-				"sector1-time":      car.Sector1Time,
-				"sector2-time":      car.Sector2Time,
-				"lap-distance":      car.LapDistance,
-				"driver-id":         car.DriverID,
-				"team-id":           car.TeamID,
-				"car-position":      car.CarPosition,
-				"current-lap-num":   car.CurrentLapNum,
-				"tyre-compound":     car.TyreCompound,
-				"in-pits":           car.InPits,
-				"sector":            car.Sector,
-				"currentlapinvalid": car.Currentlapinvalid,
-				"penalties":         car.Penalties,
-			}
-
-			pt, err := client.NewPoint("car", tags, fields, time.Now())
-			if err != nil {
-				log.Fatal(err)
-			}
-			bp.AddPoint(pt)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			continue
 		}
 
-		// Write the batch
-		if err := c.Write(bp); err != nil {
-			log.Fatal(err)
+		for _, m := range messages {
+			telemetry.publish(m)
 		}
 	}
 }
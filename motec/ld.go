@@ -0,0 +1,213 @@
+// Package motec writes logs for MoTeC i2: .ld files, holding channels of
+// samples taken at a fixed rate, and the .ldx files next to them, holding the
+// lap markers.
+//
+// An .ld file starts with a header, pointing to an event, venue and vehicle
+// block, the channel blocks and the samples, laid out in that order:
+//
+//	header    1762 bytes  pointers, date, time, driver, vehicle and venue
+//	event     1154 bytes  event name, session, comment and venue pointer
+//	venue     1100 bytes  venue name and vehicle pointer
+//	vehicle    260 bytes  vehicle ID, weight, type and comment
+//	channels   124 bytes  for each channel, a doubly linked list
+//	samples               of each channel in turn
+//
+// All integers are little endian, and strings are padded with zeros. The
+// format isn't documented by MoTeC; fields not known to matter are written
+// with the values found in logs written by their own loggers. Samples are
+// written as float32, with no scaling, so they are exactly the values given.
+package motec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// A Log is a MoTeC i2 log.
+type Log struct {
+	Start   time.Time // date and time shown for the log
+	Driver  string
+	Vehicle string
+	Venue   string
+	Event   string
+	Session string
+	Comment string
+	// Channels are shown in this order. Names longer than the format
+	// allows are cut short.
+	Channels []Channel
+}
+
+// A Channel is a series of samples taken Freq times a second.
+type Channel struct {
+	Name  string // at most 32 bytes
+	Short string // at most 8 bytes
+	Unit  string // at most 12 bytes, e.g. "km/h" or "C"
+	Freq  int
+	Data  []float32
+}
+
+type header struct {
+	Marker        uint32
+	_             [4]byte
+	ChannelsPtr   uint32
+	DataPtr       uint32
+	_             [20]byte
+	EventPtr      uint32
+	_             [24]byte
+	Unknown       [3]uint16
+	DeviceSerial  uint32
+	DeviceType    [8]byte
+	DeviceVersion uint16
+	Unknown2      uint16
+	NumChannels   uint32
+	_             [4]byte
+	Date          [16]byte
+	_             [16]byte
+	Time          [16]byte
+	_             [16]byte
+	Driver        [64]byte
+	Vehicle       [64]byte
+	_             [64]byte
+	Venue         [64]byte
+	_             [64]byte
+	_             [1024]byte
+	ProLogging    uint32
+	_             [66]byte
+	ShortComment  [64]byte
+	_             [126]byte
+}
+
+type event struct {
+	Name     [64]byte
+	Session  [64]byte
+	Comment  [1024]byte
+	VenuePtr uint16
+}
+
+type venue struct {
+	Name       [64]byte
+	_          [1034]byte
+	VehiclePtr uint16
+}
+
+type vehicle struct {
+	ID      [64]byte
+	_       [128]byte
+	Weight  uint32
+	Type    [32]byte
+	Comment [32]byte
+}
+
+type channel struct {
+	PrevPtr  uint32
+	NextPtr  uint32
+	DataPtr  uint32
+	Samples  uint32
+	Counter  uint16
+	Type     [2]uint16 // kind and size of the samples
+	Freq     uint16
+	Shift    int16 // value = (sample / Scale / 10^Decimals + Shift) * Mul
+	Mul      int16
+	Scale    int16
+	Decimals int16
+	Name     [32]byte
+	Short    [8]byte
+	Unit     [12]byte
+	_        [40]byte
+}
+
+const (
+	channelMarker = 0x2ee1 // plus the index of the channel
+	floatType     = 0x07
+)
+
+// WriteTo writes l to w as an .ld file.
+func (l *Log) WriteTo(w io.Writer) (int64, error) {
+	h := header{
+		Marker:        0x40,
+		EventPtr:      uint32(binary.Size(header{})),
+		Unknown:       [3]uint16{1, 0x4240, 0xf},
+		DeviceSerial:  0x1f44,
+		DeviceVersion: 420,
+		Unknown2:      0xadb0,
+		NumChannels:   uint32(len(l.Channels)),
+		ProLogging:    0xc81a4,
+	}
+	venuePtr := h.EventPtr + uint32(binary.Size(event{}))
+	vehiclePtr := venuePtr + uint32(binary.Size(venue{}))
+	h.ChannelsPtr = vehiclePtr + uint32(binary.Size(vehicle{}))
+	h.DataPtr = h.ChannelsPtr + uint32(len(l.Channels)*binary.Size(channel{}))
+	copy(h.DeviceType[:], "ADL")
+	copy(h.Date[:], l.Start.Format("02/01/2006"))
+	copy(h.Time[:], l.Start.Format("15:04:05"))
+	copy(h.Driver[:], l.Driver)
+	copy(h.Vehicle[:], l.Vehicle)
+	copy(h.Venue[:], l.Venue)
+	copy(h.ShortComment[:], l.Comment)
+
+	e := event{VenuePtr: uint16(venuePtr)}
+	copy(e.Name[:], l.Event)
+	copy(e.Session[:], l.Session)
+	copy(e.Comment[:], l.Comment)
+	v := venue{VehiclePtr: uint16(vehiclePtr)}
+	copy(v.Name[:], l.Venue)
+	car := vehicle{}
+	copy(car.ID[:], l.Vehicle)
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	for _, block := range []interface{}{h, e, v, car} {
+		if err := binary.Write(cw, binary.LittleEndian, block); err != nil {
+			return cw.n, err
+		}
+	}
+
+	ptr := h.ChannelsPtr
+	dataPtr := h.DataPtr
+	size := uint32(binary.Size(channel{}))
+	for i, ch := range l.Channels {
+		c := channel{
+			DataPtr: dataPtr,
+			Samples: uint32(len(ch.Data)),
+			Counter: uint16(channelMarker + i),
+			Type:    [2]uint16{floatType, 4},
+			Freq:    uint16(ch.Freq),
+			Mul:     1,
+			Scale:   1,
+		}
+		if i > 0 {
+			c.PrevPtr = ptr - size
+		}
+		if i < len(l.Channels)-1 {
+			c.NextPtr = ptr + size
+		}
+		copy(c.Name[:], ch.Name)
+		copy(c.Short[:], ch.Short)
+		copy(c.Unit[:], ch.Unit)
+		if err := binary.Write(cw, binary.LittleEndian, c); err != nil {
+			return cw.n, err
+		}
+		ptr += size
+		dataPtr += 4 * uint32(len(ch.Data))
+	}
+
+	for _, ch := range l.Channels {
+		if err := binary.Write(cw, binary.LittleEndian, ch.Data); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, bw.Flush()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
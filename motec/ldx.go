@@ -0,0 +1,66 @@
+package motec
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// A Lap is a lap of a Log.
+type Lap struct {
+	Number int
+	Start  time.Duration // since the start of the log
+	Time   time.Duration // 0 if not completed in the log
+}
+
+// WriteLDX writes the .ldx file of a log with laps, in order: a beacon marker
+// at the start of each lap but one starting with the log, and the number of
+// laps and the fastest one.
+func WriteLDX(w io.Writer, laps []Lap) error {
+	var fastest *Lap
+	for i := range laps {
+		if laps[i].Time > 0 && (fastest == nil || laps[i].Time < fastest.Time) {
+			fastest = &laps[i]
+		}
+	}
+
+	b := []byte(`<?xml version="1.0"?>
+<LDXFile Locale="English_United States.1252" DefaultLocale="C" Version="1.6">
+ <Layers>
+  <Layer>
+   <MarkerBlock>
+    <MarkerGroup Name="Beacons" Index="3">
+`)
+	n := 0
+	for _, lap := range laps {
+		if lap.Start <= 0 {
+			continue
+		}
+		n++
+		// Times are in microseconds.
+		b = append(b, fmt.Sprintf("     <Marker Version=\"100\" ClassName=\"BCN\" Name=\"Manual.%d\" Flags=\"77\" Time=\"%d.000000\"/>\n",
+			n, lap.Start/time.Microsecond)...)
+	}
+	b = append(b, `    </MarkerGroup>
+   </MarkerBlock>
+  </Layer>
+  <Details>
+`...)
+	b = append(b, fmt.Sprintf("   <String Id=\"Total Laps\" Value=\"%d\"/>\n", len(laps))...)
+	if fastest != nil {
+		b = append(b, fmt.Sprintf("   <String Id=\"Fastest Time\" Value=\"%s\"/>\n", lapTime(fastest.Time))...)
+		b = append(b, fmt.Sprintf("   <String Id=\"Fastest Lap\" Value=\"%d\"/>\n", fastest.Number)...)
+	}
+	b = append(b, `  </Details>
+ </Layers>
+</LDXFile>
+`...)
+	_, err := w.Write(b)
+	return err
+}
+
+// lapTime formats d as i2 does, e.g. "1:23.456".
+func lapTime(d time.Duration) string {
+	ms := int64(d / time.Millisecond)
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/luan/f1-telemetry/sink"
+)
+
+// DefaultFlushInterval is how often sinks are flushed by default.
+const DefaultFlushInterval = 500 * time.Millisecond
+
+// A pipelineConfig is read from the file given with -config, and lists the
+// sinks to write telemetry to, for instance:
+//
+//	{
+//		"sinks": [
+//			{"type": "influx"},
+//			{"type": "influx", "name": "nas", "buffer": 10000, "overflow": "block", "flush": "2s",
+//				"options": {"addr": "http://nas:8086", "database": "f1"}}
+//		]
+//	}
+//
+// buffer and overflow set how the sink subscribes to the telemetry, and
+// default to the -sink-buffer and -sink-overflow flags. flush sets how often
+// the sink is flushed, DefaultFlushInterval by default. The options depend on
+// the type of sink.
+type pipelineConfig struct {
+	Sinks []sinkConfig `json:"sinks"`
+}
+
+type sinkConfig struct {
+	sink.Config
+	Buffer   int             `json:"buffer,omitempty"`
+	Overflow *OverflowPolicy `json:"overflow,omitempty"`
+	Flush    sink.Duration   `json:"flush,omitempty"`
+}
+
+// defaultPipeline is used without -config: Influx on localhost.
+var defaultPipeline = pipelineConfig{Sinks: []sinkConfig{{Config: sink.Config{Type: "influx"}}}}
+
+// A pipeline feeds the telemetry published on a bus to sinks, each from a
+// subscriber of its own.
+type pipeline struct {
+	sinks []*pipelineSink
+	done  sync.WaitGroup
+}
+
+type pipelineSink struct {
+	name     string
+	sink     sink.Sink
+	buffer   int
+	overflow OverflowPolicy
+	flush    time.Duration
+}
+
+// loadPipeline creates the sinks configured in the file called name, or the
+// default ones if name is empty, subscribing with buffer and overflow unless
+// configured otherwise. The sinks aren't opened yet.
+func loadPipeline(name string, buffer int, overflow OverflowPolicy) (*pipeline, error) {
+	config := defaultPipeline
+	if name != "" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		config = pipelineConfig{}
+		err = dec.Decode(&config)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	p := &pipeline{}
+	for _, c := range config.Sinks {
+		if err := p.add(c, buffer, overflow); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// add creates the sink configured by c, subscribing with buffer and overflow
+// unless configured otherwise.
+func (p *pipeline) add(c sinkConfig, buffer int, overflow OverflowPolicy) error {
+	if c.Name == "" {
+		c.Name = c.Type
+	}
+	for _, s := range p.sinks {
+		if s.name == c.Name {
+			return fmt.Errorf("duplicate sink name %q, name them apart", c.Name)
+		}
+	}
+
+	s, err := sink.New(c.Config)
+	if err != nil {
+		return err
+	}
+	ps := &pipelineSink{name: c.Name, sink: s, buffer: buffer, overflow: overflow, flush: DefaultFlushInterval}
+	if c.Flush > 0 {
+		ps.flush = time.Duration(c.Flush)
+	}
+	if c.Buffer > 0 {
+		ps.buffer = c.Buffer
+	}
+	if c.Overflow != nil {
+		ps.overflow = *c.Overflow
+	}
+	p.sinks = append(p.sinks, ps)
+	return nil
+}
+
+// open opens the sinks in the order they are configured. If one fails, the
+// ones opened already are closed again.
+func (p *pipeline) open() error {
+	for i, s := range p.sinks {
+		if err := s.sink.Open(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				p.sinks[j].sink.Close()
+			}
+			return fmt.Errorf("sink %s: %v", s.name, err)
+		}
+	}
+	return nil
+}
+
+// start subscribes the sinks to telemetry, and feeds them until it is closed.
+func (p *pipeline) start(telemetry *bus) {
+	for _, s := range p.sinks {
+		sub := telemetry.subscribe(s.name, s.buffer, s.overflow)
+		if r, ok := s.sink.(sink.StatusReporter); ok {
+			sub.status = r.Status
+		}
+		p.done.Add(1)
+		go p.run(s, sub.C)
+	}
+}
+
+// run feeds s the messages from c, flushing it every flush interval.
+func (p *pipeline) run(s *pipelineSink, c <-chan message) {
+	defer p.done.Done()
+
+	flush := time.NewTicker(s.flush)
+	defer flush.Stop()
+	for {
+		select {
+		case m, ok := <-c:
+			if !ok {
+				return
+			}
+			if m.event != nil {
+				s.report(s.sink.Event(*m.event))
+			} else {
+				s.report(s.sink.Frame(m.frame))
+			}
+		case <-flush.C:
+			s.report(s.sink.Flush())
+		}
+	}
+}
+
+// wait waits for the sinks to be fed everything published before the bus was
+// closed, then closes them in the reverse order they were opened in.
+func (p *pipeline) wait() {
+	p.done.Wait()
+	for i := len(p.sinks) - 1; i >= 0; i-- {
+		s := p.sinks[i]
+		s.report(s.sink.Close())
+	}
+}
+
+func (s *pipelineSink) report(err error) {
+	if err != nil {
+		fmt.Printf("Error: sink %s: %v\n", s.name, err)
+	}
+}
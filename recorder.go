@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+	"github.com/luan/f1-telemetry/recording"
+)
+
+// RecordQueueSize is how many datagrams may wait to be written to disk
+// before the listener blocks.
+const RecordQueueSize = 4096
+
+// A recorder writes the raw datagrams received by one listener to session
+// files in a directory, starting a new file for every session.
+type recorder struct {
+	dir     string
+	compact bool // whether to write compact session files
+	items   chan recorderItem
+	quit    chan struct{}
+	stopped chan struct{}
+
+	// Owned by run.
+	file    *os.File
+	name    string // of file
+	w       *recording.Writer
+	index   recording.Indexer
+	session string             // ID of the session being written
+	pending []recording.Record // received before the session started
+	failed  string             // ID of the session whose file couldn't be started
+}
+
+// A recorderItem is a datagram to record, or the end of the session.
+type recorderItem struct {
+	record  recording.Record
+	session f1.Session // in progress when the datagram was received
+	end     bool
+}
+
+func newRecorder(dir string, compact bool) *recorder {
+	r := &recorder{
+		dir:     dir,
+		compact: compact,
+		items:   make(chan recorderItem, RecordQueueSize),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// record queues datagram b, received at t, to be written to the file of
+// session, or held until a session starts if its ID is empty. b is copied.
+func (r *recorder) record(b []byte, t time.Time, session f1.Session) {
+	r.items <- recorderItem{
+		record:  recording.Record{Time: t, Data: append([]byte(nil), b...)},
+		session: session,
+	}
+}
+
+// end closes the file of the session once the datagrams queued so far are
+// written.
+func (r *recorder) end() {
+	r.items <- recorderItem{end: true}
+}
+
+// stop writes the datagrams queued so far and closes the session file.
+func (r *recorder) stop() {
+	close(r.quit)
+	<-r.stopped
+}
+
+func (r *recorder) run() {
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+	defer close(r.stopped)
+
+	for {
+		select {
+		case item := <-r.items:
+			if err := r.write(item); err != nil {
+				fmt.Println("Error: ", err)
+			}
+		case <-flush.C:
+			if r.w != nil {
+				r.w.Flush()
+			}
+		case <-r.quit:
+			for len(r.items) > 0 {
+				if err := r.write(<-r.items); err != nil {
+					fmt.Println("Error: ", err)
+				}
+			}
+			r.close()
+			return
+		}
+	}
+}
+
+func (r *recorder) write(item recorderItem) error {
+	switch {
+	case item.end:
+		r.close()
+		return nil
+	case item.session.ID == "":
+		if r.w == nil {
+			r.hold(item.record)
+			return nil
+		}
+	case item.session.ID == r.failed:
+		// Its file couldn't be created: dropped, rather than failing again
+		// for every datagram.
+		return nil
+	case item.session.ID != r.session || r.w == nil:
+		if err := r.rotate(item.session); err != nil {
+			if r.w == nil {
+				r.failed = item.session.ID
+			}
+			r.pending = nil
+			return err
+		}
+	}
+	return r.writeRecord(item.record)
+}
+
+func (r *recorder) writeRecord(record recording.Record) error {
+	offset := r.w.Offset()
+	if err := r.w.Write(record); err != nil {
+		return err
+	}
+	r.index.Add(offset, record)
+	return nil
+}
+
+// hold keeps a datagram received while no session is in progress, such as
+// the first packets of a session before the frame they build is complete, to
+// write at the start of the next session.
+func (r *recorder) hold(record recording.Record) {
+	r.pending = append(r.pending, record)
+	i := 0
+	for i < len(r.pending) && (record.Time.Sub(r.pending[i].Time) > StreamTimeout || len(r.pending)-i > RecordQueueSize) {
+		i++
+	}
+	r.pending = r.pending[i:]
+}
+
+// rotate closes the current session file and starts one for session, with
+// the datagrams held so far.
+func (r *recorder) rotate(session f1.Session) error {
+	r.close()
+
+	start := session.Start
+	if len(r.pending) > 0 && r.pending[0].Time.Before(start) {
+		start = r.pending[0].Time
+	}
+	name := filepath.Join(r.dir, f1.FileName(session.ID)+recording.Ext)
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	w, err := recording.NewWriter(file, recording.Header{
+		Start:   start,
+		Format:  session.Format,
+		Source:  session.Source,
+		Compact: r.compact,
+	})
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file, r.name, r.w, r.session = file, name, w, session.ID
+	r.index = recording.Indexer{}
+	for _, record := range r.pending {
+		if err := r.writeRecord(record); err != nil {
+			return err
+		}
+	}
+	r.pending = nil
+	return nil
+}
+
+// close closes the current session file, and writes its index next to it.
+func (r *recorder) close() {
+	if r.w == nil {
+		return
+	}
+	if err := r.w.Flush(); err != nil {
+		fmt.Println("Error: ", err)
+	}
+	r.file.Close()
+	if err := recording.SaveIndex(r.name, r.index.Index(r.w.Offset())); err != nil {
+		fmt.Println("Error: ", err)
+	}
+	r.file, r.w, r.session = nil, nil, ""
+}
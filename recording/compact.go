@@ -0,0 +1,228 @@
+package recording
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	// CompactMagic starts every compact session file.
+	CompactMagic = "F1TZ"
+	// BlockSize is the most records in a block of a compact session file.
+	BlockSize = 1024
+
+	// maxBlock caps the size of a block, so a corrupt one doesn't allocate
+	// gigabytes.
+	maxBlock = BlockSize * (math.MaxUint16 + 2*binary.MaxVarintLen64)
+)
+
+// A blockWriter collects the records of a block of a compact session file.
+type blockWriter struct {
+	offsets []time.Duration
+	lengths []int
+	data    []byte // of all records, one after the other
+
+	raw bytes.Buffer
+	out bytes.Buffer
+	fw  *flate.Writer
+}
+
+func newBlockWriter() *blockWriter {
+	b := &blockWriter{}
+	// BestSpeed compresses the delta encoded columns almost as well as the
+	// default level, at a fraction of the cost.
+	b.fw, _ = flate.NewWriter(&b.out, flate.BestSpeed)
+	return b
+}
+
+func (b *blockWriter) add(offset time.Duration, data []byte) {
+	b.offsets = append(b.offsets, offset)
+	b.lengths = append(b.lengths, len(data))
+	b.data = append(b.data, data...)
+}
+
+func (b *blockWriter) len() int {
+	return len(b.offsets)
+}
+
+// encode returns the compressed block, prefixed with its length, and starts
+// a new one.
+func (b *blockWriter) encode() []byte {
+	b.raw.Reset()
+	var v [binary.MaxVarintLen64]byte
+	b.raw.Write(v[:binary.PutUvarint(v[:], uint64(len(b.offsets)))])
+	var last time.Duration
+	for i, offset := range b.offsets {
+		b.raw.Write(v[:binary.PutVarint(v[:], int64(offset-last))])
+		b.raw.Write(v[:binary.PutUvarint(v[:], uint64(b.lengths[i]))])
+		last = offset
+	}
+
+	starts := blockStarts(b.lengths)
+	for _, rows := range blockColumns(b.lengths) {
+		n := b.lengths[rows[0]]
+		for c := 0; c < n; c++ {
+			prev := byte(0)
+			for _, row := range rows {
+				x := b.data[starts[row]+c]
+				b.raw.WriteByte(x ^ prev)
+				prev = x
+			}
+		}
+	}
+
+	b.out.Reset()
+	b.out.Write(make([]byte, 4))
+	b.fw.Reset(&b.out)
+	b.fw.Write(b.raw.Bytes())
+	b.fw.Close()
+	out := b.out.Bytes()
+	binary.LittleEndian.PutUint32(out, uint32(len(out)-4))
+
+	b.offsets, b.lengths, b.data = b.offsets[:0], b.lengths[:0], b.data[:0]
+	return out
+}
+
+// A blockReader decodes the blocks of a compact session file.
+type blockReader struct {
+	offsets []time.Duration
+	lengths []int
+	starts  []int
+	data    []byte
+	next    int // index of the next record to return
+
+	compressed []byte
+	raw        bytes.Buffer
+	fr         io.ReadCloser
+}
+
+// read reads the next block from r, returning its size in the file.
+func (b *blockReader) read(r io.Reader) (int64, error) {
+	var h [4]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, err
+	}
+	n := binary.LittleEndian.Uint32(h[:])
+	if n > maxBlock {
+		return 0, errors.New("recording: block too long")
+	}
+	if int(n) > cap(b.compressed) {
+		b.compressed = make([]byte, n)
+	}
+	b.compressed = b.compressed[:n]
+	if _, err := io.ReadFull(r, b.compressed); err != nil {
+		return 0, err
+	}
+
+	if b.fr == nil {
+		b.fr = flate.NewReader(bytes.NewReader(b.compressed))
+	} else {
+		b.fr.(flate.Resetter).Reset(bytes.NewReader(b.compressed), nil)
+	}
+	b.raw.Reset()
+	if _, err := b.raw.ReadFrom(io.LimitReader(b.fr, maxBlock)); err != nil {
+		return 0, errBadBlock
+	}
+	return int64(4 + n), b.decode(b.raw.Bytes())
+}
+
+var errBadBlock = errors.New("recording: corrupt block")
+
+func (b *blockReader) decode(raw []byte) error {
+	count, n := binary.Uvarint(raw)
+	if n <= 0 || count > BlockSize {
+		return errBadBlock
+	}
+	raw = raw[n:]
+
+	b.offsets, b.lengths, b.next = b.offsets[:0], b.lengths[:0], 0
+	var offset time.Duration
+	size := 0
+	for i := uint64(0); i < count; i++ {
+		delta, n := binary.Varint(raw)
+		if n <= 0 {
+			return errBadBlock
+		}
+		length, m := binary.Uvarint(raw[n:])
+		if m <= 0 || length > math.MaxUint16 {
+			return errBadBlock
+		}
+		raw = raw[n+m:]
+		offset += time.Duration(delta)
+		b.offsets = append(b.offsets, offset)
+		b.lengths = append(b.lengths, int(length))
+		size += int(length)
+	}
+	if len(raw) != size {
+		return errBadBlock
+	}
+
+	if size > cap(b.data) {
+		b.data = make([]byte, size)
+	}
+	b.data = b.data[:size]
+	b.starts = blockStarts(b.lengths)
+	for _, rows := range blockColumns(b.lengths) {
+		n := b.lengths[rows[0]]
+		for c := 0; c < n; c++ {
+			prev := byte(0)
+			for _, row := range rows {
+				prev ^= raw[0]
+				raw = raw[1:]
+				b.data[b.starts[row]+c] = prev
+			}
+		}
+	}
+	return nil
+}
+
+// reset drops the records left in the block.
+func (b *blockReader) reset() {
+	b.next = len(b.offsets)
+}
+
+// record returns the next record of the block, if any is left.
+func (b *blockReader) record() (offset time.Duration, data []byte, ok bool) {
+	if b.next >= len(b.offsets) {
+		return 0, nil, false
+	}
+	i := b.next
+	b.next++
+	return b.offsets[i], b.data[b.starts[i] : b.starts[i]+b.lengths[i]], true
+}
+
+// blockStarts returns where each record of a block starts in its data.
+func blockStarts(lengths []int) []int {
+	starts := make([]int, len(lengths))
+	n := 0
+	for i, length := range lengths {
+		starts[i] = n
+		n += length
+	}
+	return starts
+}
+
+// blockColumns groups the records of a block by length, which tells the
+// packet types of the header based formats apart, in the order the lengths
+// first appear. Each group is stored column by column, every byte XORed with
+// the same byte of the previous record of the group, so channels that change
+// little between frames compress to almost nothing.
+func blockColumns(lengths []int) [][]int {
+	var groups [][]int
+	group := map[int]int{}
+	for i, length := range lengths {
+		g, ok := group[length]
+		if !ok {
+			g = len(groups)
+			group[length] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
@@ -0,0 +1,282 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+const (
+	// IndexMagic starts every index file.
+	IndexMagic = "F1TI"
+	// IndexVersion is the version of the index format written by this
+	// package.
+	IndexVersion = 1
+	// IndexExt is appended to the name of a session file to name its index.
+	IndexExt = ".idx"
+
+	// IndexInterval is the most game time, in seconds, between two entries
+	// of an index.
+	IndexInterval = 1
+)
+
+// indexHeaderSize and indexEntrySize are the sizes of the header of an index
+// file and of each of its entries.
+const (
+	indexHeaderSize = 18
+	indexEntrySize  = 15
+)
+
+var (
+	// ErrNotIndex is returned when reading a file that is not an index or
+	// was written by a newer version of this package.
+	ErrNotIndex = errors.New("recording: not an index file")
+	// ErrStaleIndex is returned by LoadIndex when the session file changed
+	// since it was indexed.
+	ErrStaleIndex = errors.New("recording: index is out of date")
+)
+
+// An Index maps the laps, sectors and game time of a session file to the
+// offsets of its records, so a Reader can seek to them instead of reading the
+// whole file. It is kept next to the session file, in a file of its own:
+//
+//	magic    [4]byte  "F1TI"
+//	version  uint16   index format version
+//	size     int64    size of the session file indexed
+//	count    uint32   number of entries, followed by the entries
+//
+// Each entry is:
+//
+//	offset   int64    offset of the first record of a frame in the session
+//	                  file, or of the block holding it in compact files
+//	time     float32  game time of the record, seconds
+//	lap      uint16   lap of the player, counting from 1
+//	sector   uint8    sector of the player, counting from 0
+//
+// There is an entry for the first frame of every lap and sector, and at
+// least one for every IndexInterval of game time.
+type Index struct {
+	Size    int64
+	Entries []IndexEntry // in file order
+}
+
+// An IndexEntry is the offset of the first record of a frame, or of its
+// block, along with where the player was on track at the time. With the
+// header based formats, a frame takes the records since the last one, so
+// reading from there decodes the lap data along with the telemetry.
+type IndexEntry struct {
+	Offset int64
+	Time   float32
+	Lap    int
+	Sector int
+}
+
+// Lap returns the entry at the start of lap, or the first one after it if
+// lap was not recorded. ok is false if the file ends before lap.
+func (x *Index) Lap(lap int) (e IndexEntry, ok bool) {
+	for _, e := range x.Entries {
+		if e.Lap >= lap {
+			return e, true
+		}
+	}
+	return IndexEntry{}, false
+}
+
+// Time returns the last entry at or before game time t, or the first entry
+// if t is before it. ok is false if the index is empty.
+func (x *Index) Time(t float32) (e IndexEntry, ok bool) {
+	for i, e := range x.Entries {
+		if e.Time > t {
+			if i == 0 {
+				return e, true
+			}
+			return x.Entries[i-1], true
+		}
+	}
+	if len(x.Entries) == 0 {
+		return IndexEntry{}, false
+	}
+	return x.Entries[len(x.Entries)-1], true
+}
+
+// Laps returns the offsets in the file of the records from the start of lap
+// from to the end of lap to. ok is false if the file ends before from.
+func (x *Index) Laps(from, to int) (start, end int64, ok bool) {
+	e, ok := x.Lap(from)
+	if !ok {
+		return 0, 0, false
+	}
+	end = x.Size
+	if next, ok := x.Lap(to + 1); ok {
+		end = next.Offset
+	}
+	return e.Offset, end, true
+}
+
+// An Indexer builds the index of a session file from its records, as they
+// are written or read.
+type Indexer struct {
+	stream  f1.Stream
+	start   int64 // offset of the first record of the frame being decoded
+	pending bool  // whether a frame is being decoded
+	entries []IndexEntry
+}
+
+// Add decodes record r, found at offset in the file, and adds an entry for
+// the frame it completes, if any, if it starts a lap or sector, or enough
+// game time passed.
+func (x *Indexer) Add(offset int64, r Record) {
+	frame, ok, err := x.stream.Decode(r.Data)
+	if err != nil {
+		return
+	}
+	if !x.pending {
+		x.start, x.pending = offset, true
+	}
+	if !ok {
+		return
+	}
+	x.pending = false
+
+	e := IndexEntry{Offset: x.start, Time: frame.Time, Lap: frame.PlayerLap(), Sector: int(frame.Sector)}
+	if n := len(x.entries); n > 0 {
+		last := x.entries[n-1]
+		// Game time goes back on flashbacks and restarts.
+		if e.Lap == last.Lap && e.Sector == last.Sector && e.Time >= last.Time && e.Time-last.Time < IndexInterval {
+			return
+		}
+	}
+	x.entries = append(x.entries, e)
+}
+
+// Index returns the index of the records added so far, for a session file of
+// size bytes.
+func (x *Indexer) Index(size int64) *Index {
+	return &Index{Size: size, Entries: x.entries}
+}
+
+// BuildIndex indexes the session file called name.
+func BuildIndex(name string) (*Index, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	var x Indexer
+	for {
+		offset := r.Offset()
+		record, err := r.Next()
+		if err == io.EOF {
+			// Sized as the file rather than up to its last whole record,
+			// so the index of a file cut short isn't always out of date.
+			return x.Index(info.Size()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		x.Add(offset, record)
+	}
+}
+
+// LoadIndex reads the index of the session file called name. It returns
+// ErrStaleIndex if the file changed since the index was written.
+func LoadIndex(name string) (*Index, error) {
+	file, err := os.Open(name + IndexExt)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	x, err := ReadIndex(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != x.Size {
+		return nil, ErrStaleIndex
+	}
+	return x, nil
+}
+
+// SaveIndex writes x as the index of the session file called name.
+func SaveIndex(name string, x *Index) error {
+	file, err := os.Create(name + IndexExt)
+	if err != nil {
+		return err
+	}
+	if err := WriteIndex(file, x); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteIndex writes x to w.
+func WriteIndex(w io.Writer, x *Index) error {
+	if len(x.Entries) > math.MaxUint32 {
+		return errors.New("recording: index too long")
+	}
+
+	bw := bufio.NewWriter(w)
+	var b [indexHeaderSize]byte
+	copy(b[:], IndexMagic)
+	binary.LittleEndian.PutUint16(b[4:], IndexVersion)
+	binary.LittleEndian.PutUint64(b[6:], uint64(x.Size))
+	binary.LittleEndian.PutUint32(b[14:], uint32(len(x.Entries)))
+	bw.Write(b[:])
+
+	for _, e := range x.Entries {
+		var b [indexEntrySize]byte
+		binary.LittleEndian.PutUint64(b[0:], uint64(e.Offset))
+		binary.LittleEndian.PutUint32(b[8:], math.Float32bits(e.Time))
+		binary.LittleEndian.PutUint16(b[12:], uint16(e.Lap))
+		b[14] = uint8(e.Sector)
+		bw.Write(b[:])
+	}
+	return bw.Flush()
+}
+
+// ReadIndex reads an index written by WriteIndex from r.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	var b [indexHeaderSize]byte
+	if _, err := io.ReadFull(br, b[:]); err != nil {
+		return nil, ErrNotIndex
+	}
+	version := binary.LittleEndian.Uint16(b[4:])
+	if string(b[:4]) != IndexMagic || version == 0 || version > IndexVersion {
+		return nil, ErrNotIndex
+	}
+
+	x := &Index{Size: int64(binary.LittleEndian.Uint64(b[6:]))}
+	for n := binary.LittleEndian.Uint32(b[14:]); n > 0; n-- {
+		var b [indexEntrySize]byte
+		if _, err := io.ReadFull(br, b[:]); err != nil {
+			return nil, ErrNotIndex
+		}
+		x.Entries = append(x.Entries, IndexEntry{
+			Offset: int64(binary.LittleEndian.Uint64(b[0:])),
+			Time:   math.Float32frombits(binary.LittleEndian.Uint32(b[8:])),
+			Lap:    int(binary.LittleEndian.Uint16(b[12:])),
+			Sector: int(b[14]),
+		})
+	}
+	return x, nil
+}
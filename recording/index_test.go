@@ -0,0 +1,97 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+const testPlayer = 3
+
+// testPacket returns the datagram of a 2018 packet of the player at game time
+// t, p being a pointer to the packet without its header.
+func testPacket(p interface{}, h *f1.PacketHeader2018, id uint8, t float32) []byte {
+	*h = f1.PacketHeader2018{PacketFormat: 2018, PacketVersion: 1, PacketID: id, SessionUID: 1, SessionTime: t, PlayerCarIndex: testPlayer}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, p)
+	return b.Bytes()
+}
+
+// testLaps returns the records of laps of a 2018 session, of three frames of
+// lap data and car telemetry each, one per sector, ten seconds apart.
+func testLaps(laps int) []Record {
+	var records []Record
+	for lap := 1; lap <= laps; lap++ {
+		for sector := 0; sector < 3; sector++ {
+			t := float32(10 * (3*(lap-1) + sector))
+			var lapData f1.PacketLapData2018
+			lapData.LapData[testPlayer].CurrentLapNum = uint8(lap)
+			lapData.LapData[testPlayer].Sector = uint8(sector)
+			var telemetry f1.PacketCarTelemetryData2018
+			telemetry.CarTelemetryData[testPlayer].Speed = uint16(100 + lap)
+			received := testHeader.Start.Add(time.Duration(t) * time.Second)
+			records = append(records,
+				Record{Time: received, Data: testPacket(&lapData, &lapData.PacketHeader2018, f1.PacketLapData, t)},
+				Record{Time: received, Data: testPacket(&telemetry, &telemetry.PacketHeader2018, f1.PacketCarTelemetry, t)})
+		}
+	}
+	return records
+}
+
+// TestIndexSeek checks seeking to the entry of a lap decodes the frame
+// starting it, lap data included.
+func TestIndexSeek(t *testing.T) {
+	h := testHeader
+	h.Format = f1.Format{PacketFormat: 2018}
+	b := writeRecords(t, h, testLaps(3), 0)
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x Indexer
+	for {
+		offset := r.Offset()
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.Add(offset, record)
+	}
+	index := x.Index(int64(len(b)))
+	if len(index.Entries) != 9 {
+		t.Fatalf("%d entries, want one per sector of 3 laps", len(index.Entries))
+	}
+
+	e, ok := index.Lap(2)
+	if !ok || e.Lap != 2 || e.Sector != 0 || e.Time != 30 {
+		t.Fatalf("Lap(2) = %+v, %v", e, ok)
+	}
+	if err := r.SeekRecord(e.Offset); err != nil {
+		t.Fatal(err)
+	}
+	var stream f1.Stream
+	for {
+		record, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame, ok, err := stream.Decode(record.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			if frame.PlayerLap() != 2 || frame.Sector != 0 || frame.Speed != 102/3.6 {
+				t.Fatalf("first frame after seeking on lap %d, sector %g, at %g m/s, want lap 2, sector 0, at 102 km/h",
+					frame.PlayerLap(), frame.Sector, frame.Speed)
+			}
+			break
+		}
+	}
+}
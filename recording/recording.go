@@ -0,0 +1,312 @@
+// Package recording reads and writes session files, which hold the raw
+// datagrams of one telemetry session along with the time they were received.
+//
+// A session file starts with a header:
+//
+//	magic    [4]byte  "F1TR"
+//	version  uint16   file format version
+//	start    int64    receive time of the session, unix nanoseconds
+//	format   uint16   packet format of the session, see f1.Format
+//	extra    uint8    extradata level
+//	major    uint8    game major version
+//	minor    uint8    game minor version
+//	source   uint16   length of the source name, followed by the name
+//
+// followed by any number of records:
+//
+//	offset   int64    receive time, nanoseconds since start
+//	length   uint16   length of the datagram, followed by the datagram
+//
+// All integers are little endian. Records are only ever appended, so a file
+// cut short by a crash is still readable up to its last whole record.
+//
+// Compact session files start with "F1TZ" instead, and hold the records in
+// blocks of up to BlockSize:
+//
+//	length   uint32   length of the block, followed by the block
+//
+// Each block is deflate compressed, and holds:
+//
+//	count    uvarint  number of records
+//	offset   varint   receive time, nanoseconds since the previous record,
+//	                  or since start for the first record of the block
+//	length   uvarint  length of the datagram
+//
+// for each record, followed by the datagrams. Datagrams of the same length
+// are stored together, column by column: the first byte of each of them, in
+// order, then the second byte and so on. Every byte is XORed with the same
+// byte of the previous datagram of that length in the block, so the channels
+// that change little from one frame to the next compress to almost nothing.
+// Blocks are independent of each other, and written whenever the Writer is
+// flushed, so a file cut short by a crash is readable up to its last whole
+// block.
+//
+// A session file may be accompanied by an Index, to seek to a lap or game
+// time without reading the records before it.
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+const (
+	// Magic starts every session file.
+	Magic = "F1TR"
+	// Version is the version of the file format written by this package.
+	Version = 1
+	// Ext is the file name extension of session files.
+	Ext = ".f1tr"
+)
+
+// ErrNotRecording is returned when reading a file that is not a session file
+// or was written by a newer version of this package.
+var ErrNotRecording = errors.New("recording: not a session file")
+
+// A Header describes the session held by a file.
+type Header struct {
+	Version uint16
+	Start   time.Time // receive time of the first datagram
+	Format  f1.Format // format of the first datagram
+	Source  string    // name of the listener the session was received on
+	Compact bool      // whether records are compressed in blocks
+}
+
+// A Record is one datagram along with the time it was received.
+type Record struct {
+	Time time.Time
+	Data []byte
+}
+
+// headerSize and recordHeaderSize are the sizes of the file header, without
+// the source name, and of the header of every record.
+const (
+	headerSize       = 21
+	recordHeaderSize = 10
+)
+
+// A Writer appends records to a session file.
+type Writer struct {
+	w      *bufio.Writer
+	start  time.Time
+	offset int64
+	buf    [recordHeaderSize]byte
+	block  *blockWriter // nil unless compact
+}
+
+// NewWriter writes the header h to w and returns a Writer appending records
+// after it. The records are buffered until Flush is called. h.Version is
+// ignored; files are always written in the current Version.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if len(h.Source) > math.MaxUint16 {
+		return nil, errors.New("recording: source name too long")
+	}
+
+	bw := bufio.NewWriter(w)
+	var b [headerSize]byte
+	copy(b[:], Magic)
+	if h.Compact {
+		copy(b[:], CompactMagic)
+	}
+	binary.LittleEndian.PutUint16(b[4:], Version)
+	binary.LittleEndian.PutUint64(b[6:], uint64(h.Start.UnixNano()))
+	binary.LittleEndian.PutUint16(b[14:], h.Format.PacketFormat)
+	b[16] = uint8(h.Format.Extradata)
+	b[17] = h.Format.GameMajorVersion
+	b[18] = h.Format.GameMinorVersion
+	binary.LittleEndian.PutUint16(b[19:], uint16(len(h.Source)))
+	bw.Write(b[:])
+	if _, err := bw.WriteString(h.Source); err != nil {
+		return nil, err
+	}
+
+	writer := &Writer{w: bw, start: h.Start, offset: int64(headerSize + len(h.Source))}
+	if h.Compact {
+		writer.block = newBlockWriter()
+	}
+	return writer, nil
+}
+
+// Write appends r to the file.
+func (w *Writer) Write(r Record) error {
+	if len(r.Data) > math.MaxUint16 {
+		return errors.New("recording: datagram too long")
+	}
+	if w.block != nil {
+		w.block.add(r.Time.Sub(w.start), r.Data)
+		if w.block.len() == BlockSize {
+			return w.writeBlock()
+		}
+		return nil
+	}
+
+	binary.LittleEndian.PutUint64(w.buf[:], uint64(r.Time.Sub(w.start)))
+	binary.LittleEndian.PutUint16(w.buf[8:], uint16(len(r.Data)))
+	w.w.Write(w.buf[:])
+	_, err := w.w.Write(r.Data)
+	w.offset += int64(recordHeaderSize + len(r.Data))
+	return err
+}
+
+// writeBlock writes the records of the current block of a compact file.
+func (w *Writer) writeBlock() error {
+	b := w.block.encode()
+	w.offset += int64(len(b))
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Offset returns the offset in the file the next record is written at, or
+// for compact files the offset of the block it is written in.
+func (w *Writer) Offset() int64 {
+	return w.offset
+}
+
+// Flush writes any buffered records to the underlying writer. In compact
+// files, it ends the current block.
+func (w *Writer) Flush() error {
+	if w.block != nil && w.block.len() > 0 {
+		if err := w.writeBlock(); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// A Reader reads the records of a session file in order.
+type Reader struct {
+	Header Header
+
+	src    io.Reader
+	r      *bufio.Reader
+	offset int64
+	buf    []byte
+
+	// Of compact files.
+	block      *blockReader
+	blockStart int64 // offset of the current block
+}
+
+// NewReader reads the header of the session file in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	var b [headerSize]byte
+	if _, err := io.ReadFull(br, b[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrNotRecording
+		}
+		return nil, err
+	}
+	version := binary.LittleEndian.Uint16(b[4:])
+	magic := string(b[:4])
+	if magic != Magic && magic != CompactMagic || version == 0 || version > Version {
+		return nil, ErrNotRecording
+	}
+
+	source := make([]byte, binary.LittleEndian.Uint16(b[19:]))
+	if _, err := io.ReadFull(br, source); err != nil {
+		return nil, ErrNotRecording
+	}
+
+	reader := &Reader{
+		Header: Header{
+			Version: version,
+			Start:   time.Unix(0, int64(binary.LittleEndian.Uint64(b[6:]))),
+			Format: f1.Format{
+				PacketFormat:     binary.LittleEndian.Uint16(b[14:]),
+				Extradata:        int(b[16]),
+				GameMajorVersion: b[17],
+				GameMinorVersion: b[18],
+			},
+			Source:  string(source),
+			Compact: magic == CompactMagic,
+		},
+		src:    r,
+		r:      br,
+		offset: int64(headerSize + len(source)),
+	}
+	if reader.Header.Compact {
+		reader.block = &blockReader{}
+	} else {
+		reader.buf = make([]byte, math.MaxUint16)
+	}
+	return reader, nil
+}
+
+// Next returns the next record. Its Data is only valid until the next call
+// to Next. At the end of the file, including after a record cut short, Next
+// returns io.EOF.
+func (r *Reader) Next() (Record, error) {
+	if r.block != nil {
+		return r.nextCompact()
+	}
+
+	var b [recordHeaderSize]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		return Record{}, eof(err)
+	}
+	data := r.buf[:binary.LittleEndian.Uint16(b[8:])]
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Record{}, eof(err)
+	}
+	r.offset += int64(recordHeaderSize + len(data))
+
+	offset := time.Duration(binary.LittleEndian.Uint64(b[:]))
+	return Record{Time: r.Header.Start.Add(offset), Data: data}, nil
+}
+
+func (r *Reader) nextCompact() (Record, error) {
+	for {
+		if offset, data, ok := r.block.record(); ok {
+			return Record{Time: r.Header.Start.Add(offset), Data: data}, nil
+		}
+		n, err := r.block.read(r.r)
+		if err != nil {
+			r.block.reset()
+			return Record{}, eof(err)
+		}
+		r.blockStart = r.offset
+		r.offset += n
+	}
+}
+
+// Offset returns the offset in the file of the next record, or for compact
+// files the offset of the block holding it.
+func (r *Reader) Offset() int64 {
+	if r.block != nil && r.block.next < len(r.block.offsets) {
+		return r.blockStart
+	}
+	return r.offset
+}
+
+// SeekRecord moves the reader to the record at offset, as found in the Index
+// of the file, or to the block at offset for compact files. The file must be
+// an io.Seeker.
+func (r *Reader) SeekRecord(offset int64) error {
+	s, ok := r.src.(io.Seeker)
+	if !ok {
+		return errors.New("recording: file is not seekable")
+	}
+	if _, err := s.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r.r.Reset(r.src)
+	r.offset = offset
+	if r.block != nil {
+		r.block.reset()
+	}
+	return nil
+}
+
+func eof(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
@@ -0,0 +1,241 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+var testHeader = Header{
+	Start:  time.Unix(1500000000, 123456789),
+	Format: f1.Format{PacketFormat: 2023, GameMajorVersion: 1, GameMinorVersion: 4},
+	Source: "pc",
+}
+
+// testRecords returns n records of the lengths of the packets of a header
+// based format, interleaved as the games send them, with the bytes changing a
+// little from one frame to the next.
+func testRecords(n int) []Record {
+	lengths := []int{1349, 644, 1131, 45, 0, 1352, 1, 1460}
+	records := make([]Record, n)
+	for i := range records {
+		data := make([]byte, lengths[i%len(lengths)])
+		for j := range data {
+			data[j] = byte(j + i/len(lengths))
+		}
+		records[i] = Record{
+			Time: testHeader.Start.Add(time.Duration(i) * 2 * time.Millisecond),
+			Data: data,
+		}
+	}
+	// Receive times may go back, as the clock is adjusted.
+	if n > 2 {
+		records[2].Time = testHeader.Start.Add(-time.Second)
+	}
+	return records
+}
+
+// writeRecords returns a session file holding records, flushed after every
+// flush records if flush isn't 0.
+func writeRecords(t *testing.T, h Header, records []Record, flush int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+		if flush != 0 && (i+1)%flush == 0 {
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readRecords returns the records of the session file b, and the error that
+// ended them.
+func readRecords(t *testing.T, b []byte) (*Reader, []Record, error) {
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []Record
+	for {
+		record, err := r.Next()
+		if err != nil {
+			return r, records, err
+		}
+		record.Data = append([]byte{}, record.Data...)
+		records = append(records, record)
+	}
+}
+
+func checkRecords(t *testing.T, name string, got, want []Record) {
+	if len(got) != len(want) {
+		t.Fatalf("%s: read %d records, want %d", name, len(got), len(want))
+	}
+	for i := range got {
+		if !got[i].Time.Equal(want[i].Time) || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Fatalf("%s: record %d is %v with %d bytes, want %v with %d bytes",
+				name, i, got[i].Time, len(got[i].Data), want[i].Time, len(want[i].Data))
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := testRecords(2*BlockSize + 100)
+	for _, compact := range []bool{false, true} {
+		for _, flush := range []int{0, 7} {
+			h := testHeader
+			h.Compact = compact
+			r, got, err := readRecords(t, writeRecords(t, h, records, flush))
+			if err != io.EOF {
+				t.Fatalf("compact %v: Next = %v, want io.EOF", compact, err)
+			}
+			h.Version = Version
+			if !r.Header.Start.Equal(h.Start) {
+				t.Errorf("compact %v: start %v, want %v", compact, r.Header.Start, h.Start)
+			}
+			r.Header.Start = h.Start
+			if !reflect.DeepEqual(r.Header, h) {
+				t.Errorf("compact %v: header %+v, want %+v", compact, r.Header, h)
+			}
+			checkRecords(t, "round trip", got, records)
+		}
+	}
+}
+
+func TestCompactSize(t *testing.T) {
+	records := testRecords(BlockSize)
+	h := testHeader
+	raw := writeRecords(t, h, records, 0)
+	h.Compact = true
+	compact := writeRecords(t, h, records, 0)
+	if len(compact)*10 > len(raw) {
+		t.Errorf("compact file is %d bytes, raw %d", len(compact), len(raw))
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	records := testRecords(100)
+	for _, compact := range []bool{false, true} {
+		h := testHeader
+		h.Compact = compact
+		b := writeRecords(t, h, records, 10)
+		var whole int
+		var cut []byte
+		if compact {
+			// Blocks hold 10 records; cut the 8th short.
+			whole = 70
+			r, err := NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < whole; i++ {
+				if _, err := r.Next(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cut = b[:r.Offset()+10]
+		} else {
+			// Cut the 74th record short in its datagram.
+			whole = 73
+			size := headerSize + len(h.Source)
+			for _, r := range records[:whole] {
+				size += recordHeaderSize + len(r.Data)
+			}
+			cut = b[:size+recordHeaderSize+len(records[whole].Data)/2]
+		}
+
+		_, got, err := readRecords(t, cut)
+		if err != io.EOF {
+			t.Fatalf("compact %v: Next = %v, want io.EOF", compact, err)
+		}
+		checkRecords(t, "truncated", got, records[:whole])
+	}
+}
+
+func TestSeekRecord(t *testing.T) {
+	records := testRecords(100)
+	for _, compact := range []bool{false, true} {
+		h := testHeader
+		h.Compact = compact
+		b := writeRecords(t, h, records, 10)
+		r, err := NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var offset int64
+		for i := 0; i < 45; i++ {
+			if i == 40 {
+				offset = r.Offset()
+			}
+			if _, err := r.Next(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.SeekRecord(offset); err != nil {
+			t.Fatal(err)
+		}
+		record, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRecords(t, "seek", []Record{record}, records[40:41])
+	}
+}
+
+func TestNotRecording(t *testing.T) {
+	b := writeRecords(t, testHeader, testRecords(1), 0)
+	newer := append([]byte{}, b...)
+	newer[4] = Version + 1
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"short header", b[:headerSize-1]},
+		{"magic", append([]byte("F1TX"), b[4:]...)},
+		{"newer version", newer},
+	}
+	for _, test := range tests {
+		if _, err := NewReader(bytes.NewReader(test.b)); err != ErrNotRecording {
+			t.Errorf("%s: NewReader = %v, want ErrNotRecording", test.name, err)
+		}
+	}
+}
+
+func TestCorruptBlock(t *testing.T) {
+	h := testHeader
+	h.Compact = true
+	b := writeRecords(t, h, testRecords(10), 0)
+	b[len(b)-1] ^= 0xff
+	if _, _, err := readRecords(t, b); err == nil || err == io.EOF {
+		t.Fatalf("Next = %v, want an error", err)
+	}
+}
+
+func TestWriteTooLong(t *testing.T) {
+	w, err := NewWriter(ioutil.Discard, testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Record{Time: testHeader.Start, Data: make([]byte, 1<<16)}); err == nil {
+		t.Fatal("Write of a datagram over 64 KiB succeeded")
+	}
+	if _, err := NewWriter(ioutil.Discard, Header{Source: string(make([]byte, 1<<16))}); err == nil {
+		t.Fatal("NewWriter with a source name over 64 KiB succeeded")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// RelayQueueSize is how many datagrams may wait for a slow relay target
+// before further ones are dropped.
+const RelayQueueSize = 256
+
+// A relay forwards the raw datagrams received by the listeners to another
+// address, so other telemetry tools can still receive the game's stream.
+type relay struct {
+	// Accessed atomically, first in the struct to be 64-bit aligned.
+	sent    uint64
+	dropped uint64 // queue full or write failed
+	limited uint64 // over the rate limit
+
+	source  string         // listener to forward, empty for all of them
+	addr    string         // host:port to forward to
+	packets map[uint8]bool // packet ids to forward, nil for all
+	rate    float64        // datagrams per second, 0 for no limit
+
+	conn  *net.UDPConn
+	queue chan []byte
+}
+
+// relays collects the -relay flags.
+type relays []*relay
+
+func (r *relays) String() string {
+	var s []string
+	for _, relay := range *r {
+		s = append(s, relay.addr)
+	}
+	return strings.Join(s, ",")
+}
+
+// Set parses a relay as "[source=]host:port[?rate=N&packets=ID,ID...]". Only
+// datagrams received by the listener named source are forwarded, if given.
+// rate caps the datagrams sent per second, and packets only forwards the
+// header based packets with the given ids.
+func (r *relays) Set(s string) error {
+	relay := &relay{}
+	if i := strings.Index(s, "="); i >= 0 && i < strings.Index(s+"?", "?") {
+		relay.source, s = s[:i], s[i+1:]
+	}
+	relay.addr = s
+	if i := strings.Index(s, "?"); i >= 0 {
+		relay.addr = s[:i]
+		query, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return err
+		}
+		if err := relay.parseOptions(query); err != nil {
+			return err
+		}
+	}
+	if relay.addr == "" {
+		return errors.New("want [source=]host:port[?options]")
+	}
+
+	*r = append(*r, relay)
+	return nil
+}
+
+func (r *relay) parseOptions(query url.Values) error {
+	for key := range query {
+		switch value := query.Get(key); key {
+		case "rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(rate > 0) || math.IsInf(rate, 0) {
+				return fmt.Errorf("invalid rate %q", value)
+			}
+			r.rate = rate
+		case "packets":
+			r.packets = map[uint8]bool{}
+			for _, id := range strings.Split(value, ",") {
+				n, err := strconv.ParseUint(id, 10, 8)
+				if err != nil {
+					return fmt.Errorf("invalid packet id %q", id)
+				}
+				r.packets[uint8(n)] = true
+			}
+		default:
+			return fmt.Errorf("unknown relay option %q", key)
+		}
+	}
+	return nil
+}
+
+// start connects to the target and starts forwarding queued datagrams.
+func (r *relay) start() error {
+	addr, err := net.ResolveUDPAddr("udp", r.addr)
+	if err != nil {
+		return err
+	}
+	r.conn, err = net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return err
+	}
+
+	r.queue = make(chan []byte, RelayQueueSize)
+	go r.run()
+	return nil
+}
+
+func (r *relay) run() {
+	defer r.conn.Close()
+
+	limit := newLimiter(r.rate, time.Now())
+	for b := range r.queue {
+		if r.rate > 0 && !limit.allow(time.Now()) {
+			atomic.AddUint64(&r.limited, 1)
+			continue
+		}
+		if _, err := r.conn.Write(b); err != nil {
+			atomic.AddUint64(&r.dropped, 1)
+			continue
+		}
+		atomic.AddUint64(&r.sent, 1)
+	}
+}
+
+// A limiter is a token bucket, letting through rate datagrams a second on
+// average.
+type limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter starting full at time now. The bucket holds a
+// second of datagrams, and at least one so rates under one a second still
+// let some through.
+func newLimiter(rate float64, now time.Time) *limiter {
+	burst := math.Max(rate, 1)
+	return &limiter{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// allow reports whether a datagram may go at time now, taking a token if so.
+func (l *limiter) allow(now time.Time) bool {
+	l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// forward queues datagram b, received by the listener named source, if the
+// relay wants it. It never blocks; datagrams that don't fit in the queue are
+// dropped.
+func (r *relay) forward(source string, b []byte) {
+	if r.source != "" && r.source != source {
+		return
+	}
+	if r.packets != nil {
+		// Only the header based formats have packet ids.
+		if id, ok := f1.PacketID(b); ok && !r.packets[id] {
+			return
+		}
+	}
+
+	select {
+	case r.queue <- b:
+	default:
+		atomic.AddUint64(&r.dropped, 1)
+	}
+}
+
+func (r *relay) String() string {
+	return fmt.Sprintf("%s: %d sent, %d dropped, %d rate limited", r.addr,
+		atomic.LoadUint64(&r.sent), atomic.LoadUint64(&r.dropped), atomic.LoadUint64(&r.limited))
+}
+
+// forward queues datagram b on every relay. b is copied once and shared by
+// all of them.
+func (r relays) forward(source string, b []byte) {
+	if len(r) == 0 {
+		return
+	}
+	b = append([]byte(nil), b...)
+	for _, relay := range r {
+		relay.forward(source, b)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+func TestRelaysSet(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want relay
+	}{
+		{"127.0.0.1:20778", relay{addr: "127.0.0.1:20778"}},
+		{"udp=localhost:20778", relay{source: "udp", addr: "localhost:20778"}},
+		{"udp=localhost:20778?rate=0.5&packets=0,6", relay{source: "udp", addr: "localhost:20778", rate: 0.5, packets: map[uint8]bool{0: true, 6: true}}},
+		{"localhost:20778?rate=1e3", relay{addr: "localhost:20778", rate: 1000}},
+		{"localhost:20778?packets=255", relay{addr: "localhost:20778", packets: map[uint8]bool{255: true}}},
+	} {
+		var r relays
+		if err := r.Set(test.s); err != nil {
+			t.Errorf("Set(%q): %v", test.s, err)
+			continue
+		}
+		if len(r) != 1 || !reflect.DeepEqual(*r[0], test.want) {
+			t.Errorf("Set(%q) = %+v, want %+v", test.s, r[0], test.want)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"udp=",
+		"?rate=1",
+		"localhost:20778?rate=0",
+		"localhost:20778?rate=-1",
+		"localhost:20778?rate=NaN",
+		"localhost:20778?rate=nan",
+		"localhost:20778?rate=Inf",
+		"localhost:20778?rate=+Inf",
+		"localhost:20778?rate=-Inf",
+		"localhost:20778?rate=1e400",
+		"localhost:20778?rate=fast",
+		"localhost:20778?packets=256",
+		"localhost:20778?packets=1,",
+		"localhost:20778?packets=-1",
+		"localhost:20778?burst=10",
+		"localhost:20778?rate=%zz",
+	} {
+		var r relays
+		if err := r.Set(s); err == nil {
+			t.Errorf("Set(%q) succeeded: %+v", s, r[0])
+		}
+	}
+
+	// Each flag adds a relay.
+	var r relays
+	r.Set("localhost:1")
+	r.Set("localhost:2")
+	if r.String() != "localhost:1,localhost:2" {
+		t.Errorf("relays %q", r.String())
+	}
+}
+
+// allowed returns how many of the datagrams sent at the given offsets from
+// start a limiter of rate lets through.
+func allowed(rate float64, offsets ...time.Duration) int {
+	start := time.Unix(1500000000, 0)
+	l := newLimiter(rate, start)
+	n := 0
+	for _, d := range offsets {
+		if l.allow(start.Add(d)) {
+			n++
+		}
+	}
+	return n
+}
+
+// every returns n offsets, interval apart from 0.
+func every(n int, interval time.Duration) []time.Duration {
+	offsets := make([]time.Duration, n)
+	for i := range offsets {
+		offsets[i] = time.Duration(i) * interval
+	}
+	return offsets
+}
+
+func TestLimiter(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		rate    float64
+		offsets []time.Duration
+		want    int
+	}{
+		{"burst of a second", 10, every(20, 0), 10},
+		{"at the rate", 10, every(100, 100*time.Millisecond), 100},
+		{"over the rate", 10, every(101, 50*time.Millisecond), 10 + 50},
+		{"after the burst", 10, append(every(10, 0), time.Second), 11},
+		{"burst kept to a second", 10, append(every(10, 0), time.Hour, time.Hour, time.Hour), 13},
+		{"under 1/s", 0.5, every(10, 0), 1},
+		{"under 1/s, a second on", 0.5, []time.Duration{0, time.Second}, 1},
+		{"under 1/s, 2 seconds on", 0.5, []time.Duration{0, 2 * time.Second}, 2},
+		{"under 1/s, over the rate", 0.2, every(21, time.Second), 5},
+		{"under 1/s, kept to 1", 0.2, []time.Duration{time.Hour, time.Hour, time.Hour}, 1},
+		{"clock going back", 1, []time.Duration{0, -time.Hour, -time.Hour + time.Second}, 1},
+	} {
+		if got := allowed(test.rate, test.offsets...); got != test.want {
+			t.Errorf("%s: %d let through, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// testDatagram returns the 2018 datagram of packet id, p being a pointer to
+// the packet and h to its header.
+func testDatagram(p interface{}, h *f1.PacketHeader2018, id uint8) []byte {
+	*h = f1.PacketHeader2018{PacketFormat: 2018, PacketVersion: 1, PacketID: id}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, p)
+	return b.Bytes()
+}
+
+func TestRelayForward(t *testing.T) {
+	var r relays
+	if err := r.Set("udp=localhost:1?packets=6"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set("localhost:2"); err != nil {
+		t.Fatal(err)
+	}
+	filtered, all := r[0], r[1]
+	filtered.queue = make(chan []byte, 2)
+	all.queue = make(chan []byte, 2)
+
+	var telemetryPacket f1.PacketCarTelemetryData2018
+	var lapPacket f1.PacketLapData2018
+	telemetry := testDatagram(&telemetryPacket, &telemetryPacket.PacketHeader2018, f1.PacketCarTelemetry)
+	lapData := testDatagram(&lapPacket, &lapPacket.PacketHeader2018, f1.PacketLapData)
+	if id, ok := f1.PacketID(lapData); !ok || id != f1.PacketLapData {
+		t.Fatalf("PacketID = %d, %v, want %d", id, ok, f1.PacketLapData)
+	}
+	legacy := make([]byte, 1289)
+	r.forward("udp", telemetry)
+	r.forward("udp", lapData)
+	r.forward("other", telemetry)
+	r.forward("udp", legacy)
+
+	if n := len(filtered.queue); n != 2 || !bytes.Equal(<-filtered.queue, telemetry) || !bytes.Equal(<-filtered.queue, legacy) {
+		t.Errorf("filtered relay queued %d datagrams, want the telemetry and legacy ones from udp", n)
+	}
+	// The queue has room for 2, the rest are dropped.
+	if b := <-all.queue; !bytes.Equal(b, telemetry) {
+		t.Errorf("relay queued %x first, want the telemetry", b[:8])
+	}
+	if b := <-all.queue; !bytes.Equal(b, lapData) {
+		t.Errorf("relay queued %x second, want the lap data", b[:8])
+	}
+	if all.dropped != 2 || filtered.dropped != 0 {
+		t.Errorf("%v, %v", filtered, all)
+	}
+
+	// The datagrams queued are copies, as listeners reuse their buffers.
+	b := append([]byte(nil), telemetry...)
+	r.forward("udp", b)
+	b[0] = 0
+	if q := <-all.queue; q[0] != telemetry[0] {
+		t.Error("relays.forward didn't copy the datagram")
+	}
+}
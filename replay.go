@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/gizak/termui"
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// ReplaySpeeds are the replay speeds picked with the + and - keys.
+var ReplaySpeeds = []float64{0.25, 0.5, 1, 2, 4, 8, 16}
+
+// errRestart is returned by replay.play to play the file again from the
+// start, to seek back to an earlier lap.
+var errRestart = errors.New("restart replay")
+
+type replayCommand int
+
+const (
+	replayPause replayCommand = iota
+	replayFaster
+	replaySlower
+	replayStep
+	replayNextLap
+	replayPrevLap
+)
+
+// replayKeys maps the keys controlling a replay to their commands.
+var replayKeys = map[string]replayCommand{
+	"<space>": replayPause,
+	"+":       replayFaster,
+	"=":       replayFaster,
+	"-":       replaySlower,
+	"f":       replayStep,
+	"]":       replayNextLap,
+	"[":       replayPrevLap,
+}
+
+// A replay plays session files back into the dashboard, keeping the time
+// between datagrams as they were received, scaled by the replay speed.
+type replay struct {
+	files    []string
+	port     uint16 // port the datagrams of captures were sent to
+	dataChan chan<- message
+	commands chan replayCommand
+	status   atomic.Value
+
+	// Owned by run.
+	file     int      // index of the file being played
+	dec      *decoder // of the file being played
+	speed    float64  // one of ReplaySpeeds, or as set by the -speed flag
+	paused   bool
+	step     bool // play until the next frame, then stay paused
+	seekLap  int  // play without waiting until this lap, 0 if not seeking
+	lap      int  // lap of the last frame
+	last     *f1.Frame
+	finished bool
+}
+
+// replayMain runs the replay command, which shows recorded sessions on the
+// dashboard instead of live telemetry.
+func replayMain(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := flags.Float64("speed", 1, "replay `speed`, from 0.25 to 16")
+	lap := flags.Int("lap", 0, "`lap` to start the replay at")
+	port := flags.Uint("port", DefaultPort, "UDP `port` to read the telemetry of pcap and pcapng captures from, 0 for any")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s replay [flags] file...\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Files are session files or pcap and pcapng captures.")
+		flags.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "Keys: space pauses, + and - change speed, f steps a frame, [ and ] seek a lap.")
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *speed < ReplaySpeeds[0] || *speed > ReplaySpeeds[len(ReplaySpeeds)-1] {
+		log.Fatalf("replay speed %g out of range", *speed)
+	}
+	if *port > math.MaxUint16 {
+		log.Fatalf("invalid port %d", *port)
+	}
+
+	// Unbuffered, so the dashboard follows pauses and seeks right away.
+	dataChan := make(chan message)
+	p := &replay{
+		files:    flags.Args(),
+		port:     uint16(*port),
+		dataChan: dataChan,
+		commands: make(chan replayCommand, 16),
+		speed:    *speed,
+		seekLap:  *lap,
+	}
+	p.updateStatus()
+
+	ui := NewUI(dataChan, nil, nil)
+	ui.SetStatus(p.Status)
+	for key, command := range replayKeys {
+		command := command
+		termui.Handle("/sys/kbd/"+key, func(termui.Event) {
+			select {
+			case p.commands <- command:
+			default:
+				// Dropped rather than blocking the UI while the
+				// replay is busy.
+			}
+		})
+	}
+
+	go p.run()
+	ui.Start()
+}
+
+// Status describes the state of the replay.
+func (p *replay) Status() string {
+	return p.status.Load().(string)
+}
+
+func (p *replay) updateStatus() {
+	state := "playing"
+	switch {
+	case p.finished:
+		state = "finished"
+	case p.paused:
+		state = "paused"
+	}
+	p.status.Store(fmt.Sprintf("Replay %s %gx  %s (%d/%d)  lap %d",
+		state, p.speed, filepath.Base(p.files[p.current()]), p.current()+1, len(p.files), p.lap))
+}
+
+// current returns the index of the file being played, or of the last one
+// once finished.
+func (p *replay) current() int {
+	if p.file >= len(p.files) {
+		return len(p.files) - 1
+	}
+	return p.file
+}
+
+func (p *replay) run() {
+	for p.file < len(p.files) {
+		if p.dec == nil {
+			p.dec = newDecoder()
+		}
+		err := p.play(p.files[p.file])
+		if err == errRestart {
+			continue
+		}
+		if err != nil {
+			fmt.Println("Error: ", err)
+		}
+		if m, ok := p.dec.end(); ok {
+			p.dataChan <- m
+		}
+		p.dec = nil
+		p.file++
+	}
+
+	p.finished = true
+	p.updateStatus()
+	p.resend()
+	for range p.commands {
+		// Nothing left to control.
+	}
+}
+
+// play plays a single session file or capture.
+func (p *replay) play(name string) error {
+	s, err := openSessionFile(name, p.port)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// Played again from the start to seek back, but still the same session.
+	p.dec.seek()
+	var last time.Time
+	seeked := 0
+	for {
+		if p.seekLap != 0 && p.seekLap != seeked {
+			// Without an index, seeking plays the file without waiting
+			// until the lap is reached.
+			if s.seekLap(p.seekLap) {
+				p.dec.seek()
+			}
+			seeked = p.seekLap
+		}
+
+		record, err := s.Next()
+		if err == io.EOF {
+			p.seekLap = 0
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !last.IsZero() {
+			if err := p.wait(record.Time.Sub(last)); err != nil {
+				return err
+			}
+		}
+		last = record.Time
+
+		messages, err := p.dec.decode(s.source, record.Data, record.Time)
+		if err != nil {
+			continue
+		}
+		for _, m := range messages {
+			if m.event == nil {
+				p.lap = m.frame.PlayerLap()
+				if p.seekLap != 0 {
+					if p.lap < p.seekLap {
+						continue
+					}
+					p.seekLap = 0
+				}
+				p.step = false
+				frame := m.frame
+				p.last = &frame
+				p.updateStatus()
+			}
+			p.dataChan <- m
+		}
+	}
+}
+
+// wait waits for d of recorded time to pass at the replay speed, handling
+// commands meanwhile. It doesn't wait at all while seeking or stepping, but
+// still handles the commands sent.
+func (p *replay) wait(d time.Duration) error {
+	for p.seekLap == 0 && !p.step {
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		start := time.Now()
+		if !p.paused {
+			if d <= 0 {
+				return nil
+			}
+			timer = time.NewTimer(time.Duration(float64(d) / p.speed))
+			timeout = timer.C
+		}
+
+		select {
+		case <-timeout:
+			return nil
+		case command := <-p.commands:
+			if timer != nil {
+				timer.Stop()
+				d -= time.Duration(float64(time.Since(start)) * p.speed)
+			}
+			if err := p.handle(command); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case command := <-p.commands:
+			if err := p.handle(command); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *replay) handle(command replayCommand) error {
+	defer p.resend()
+	defer p.updateStatus()
+
+	switch command {
+	case replayPause:
+		p.paused = !p.paused
+	case replayFaster:
+		for _, speed := range ReplaySpeeds {
+			if speed > p.speed {
+				p.speed = speed
+				break
+			}
+		}
+	case replaySlower:
+		for i := len(ReplaySpeeds) - 1; i >= 0; i-- {
+			if ReplaySpeeds[i] < p.speed {
+				p.speed = ReplaySpeeds[i]
+				break
+			}
+		}
+	case replayStep:
+		p.step = p.paused
+	case replayNextLap:
+		p.seekLap = p.lap + 1
+	case replayPrevLap:
+		// Session files can only be read forwards, so start over.
+		p.seekLap = p.lap - 1
+		if p.seekLap < 0 {
+			p.seekLap = 0
+		}
+		return errRestart
+	}
+	return nil
+}
+
+// resend sends the last frame again, so the dashboard shows the new status.
+func (p *replay) resend() {
+	if p.last != nil {
+		p.dataChan <- message{frame: *p.last}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestReplayWaitSeeking checks the commands sent while seeking or stepping
+// are handled, rather than left to fill the channel up.
+func TestReplayWaitSeeking(t *testing.T) {
+	for _, p := range []*replay{
+		{seekLap: 3},
+		{step: true, paused: true},
+	} {
+		p.files = []string{"session.f1s"}
+		p.commands = make(chan replayCommand, 16)
+		p.speed = 1
+		for i := 0; i < cap(p.commands); i++ {
+			p.commands <- replayFaster
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- p.wait(time.Hour) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("wait waited while seeking or stepping")
+		}
+		if n := len(p.commands); n != 0 || p.speed != ReplaySpeeds[len(ReplaySpeeds)-1] {
+			t.Fatalf("%d commands left, speed %gx, want all handled", n, p.speed)
+		}
+	}
+
+	// Seeking back starts over, even while seeking.
+	p := &replay{files: []string{"session.f1s"}, commands: make(chan replayCommand, 1), seekLap: 3, lap: 2, speed: 1}
+	p.commands <- replayPrevLap
+	if err := p.wait(time.Hour); err != errRestart || p.seekLap != 1 {
+		t.Fatalf("wait = %v, seeking lap %d, want a restart to lap 1", err, p.seekLap)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/luan/f1-telemetry/capture"
+	"github.com/luan/f1-telemetry/recording"
+)
+
+// A sessionFile reads back the datagrams of a session file or a packet
+// capture.
+type sessionFile struct {
+	source  string // listener name for session files, file name for captures
+	file    *os.File
+	next    func() (recording.Record, error)
+	capture *capture.Reader   // nil for session files
+	index   *recording.Index  // nil for captures and files not indexed
+	reader  *recording.Reader // nil for captures
+}
+
+// openSessionFile opens the session file or the pcap or pcapng capture called
+// name. The datagrams of captures are those sent to port. The index of a
+// session file is loaded along with it, if it has an up to date one.
+func openSessionFile(name string, port uint16) (*sessionFile, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sessionFile{file: file}
+	r, err := recording.NewReader(file)
+	if err == nil {
+		s.source, s.next, s.reader = r.Header.Source, r.Next, r
+		s.index, _ = recording.LoadIndex(name)
+		return s, nil
+	}
+	if err == recording.ErrNotRecording {
+		if _, err = file.Seek(0, io.SeekStart); err == nil {
+			s.capture, err = capture.NewReader(file, port)
+		}
+	}
+	if err == capture.ErrNotCapture {
+		err = fmt.Errorf("%s: not a session file or packet capture", name)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.source, s.next = filepath.Base(name), s.capture.Next
+	return s, nil
+}
+
+// Next returns the next datagram. Its Data is only valid until the next call
+// to Next. At the end of the file Next returns io.EOF.
+func (s *sessionFile) Next() (recording.Record, error) {
+	return s.next()
+}
+
+// Skipped returns how many datagrams of a capture could not be read.
+func (s *sessionFile) Skipped() int {
+	if s.capture == nil {
+		return 0
+	}
+	return s.capture.Skipped
+}
+
+// seekLap moves to the start of lap, if the file is indexed. It reports
+// whether it did.
+func (s *sessionFile) seekLap(lap int) bool {
+	if s.index == nil {
+		return false
+	}
+	e, ok := s.index.Lap(lap)
+	if !ok {
+		return false
+	}
+	return s.reader.SeekRecord(e.Offset) == nil
+}
+
+// seekLaps moves to the start of lap from, if the file is indexed, and
+// returns the offset at which lap to ends, or the file if to is 0. It reports
+// whether it did.
+func (s *sessionFile) seekLaps(from, to int) (end int64, ok bool) {
+	if s.index == nil {
+		return 0, false
+	}
+	start, end, ok := s.index.Laps(from, to)
+	if to == 0 {
+		end = s.index.Size
+	}
+	if !ok || s.reader.SeekRecord(start) != nil {
+		return 0, false
+	}
+	return end, true
+}
+
+func (s *sessionFile) Close() error {
+	return s.file.Close()
+}
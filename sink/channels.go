@@ -0,0 +1,286 @@
+package sink
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// A channel is a value of the telemetry, named as in the Influx schema, e.g.
+// "fuel-in-tank" or "tyres-wear_fl".
+type channel struct {
+	name  string
+	value func(t *f1.TelemetryData) interface{}
+}
+
+// A carChannel is a value of a car in the race.
+type carChannel struct {
+	name  string
+	value func(c *f1.CarData) interface{}
+}
+
+// telemetryChannels are the values of the player's car: the fields of
+// f1.TelemetryData but the cars, with wheel arrays expanded. Lap and
+// SessionType are "laps-completed" and "session-type-number", not to be
+// mistaken for the lap the player is on and the session type name the points
+// and messages carry.
+var telemetryChannels = concat(
+	[]channel{
+		{"game-time", func(t *f1.TelemetryData) interface{} { return t.Time }},
+		{"laptime", func(t *f1.TelemetryData) interface{} { return t.Laptime }},
+		{"lapdistance", func(t *f1.TelemetryData) interface{} { return t.Lapdistance }},
+		{"totaldistance", func(t *f1.TelemetryData) interface{} { return t.Totaldistance }},
+		{"x", func(t *f1.TelemetryData) interface{} { return t.X }},
+		{"y", func(t *f1.TelemetryData) interface{} { return t.Y }},
+		{"z", func(t *f1.TelemetryData) interface{} { return t.Z }},
+		{"speed", func(t *f1.TelemetryData) interface{} { return t.Speed }},
+		{"xv", func(t *f1.TelemetryData) interface{} { return t.Xv }},
+		{"yv", func(t *f1.TelemetryData) interface{} { return t.Yv }},
+		{"zv", func(t *f1.TelemetryData) interface{} { return t.Zv }},
+		{"xr", func(t *f1.TelemetryData) interface{} { return t.Xr }},
+		{"yr", func(t *f1.TelemetryData) interface{} { return t.Yr }},
+		{"zr", func(t *f1.TelemetryData) interface{} { return t.Zr }},
+		{"xd", func(t *f1.TelemetryData) interface{} { return t.Xd }},
+		{"yd", func(t *f1.TelemetryData) interface{} { return t.Yd }},
+		{"zd", func(t *f1.TelemetryData) interface{} { return t.Zd }},
+		{"throttle", func(t *f1.TelemetryData) interface{} { return t.Throttle }},
+		{"steer", func(t *f1.TelemetryData) interface{} { return t.Steer }},
+		{"brake", func(t *f1.TelemetryData) interface{} { return t.Brake }},
+		{"clutch", func(t *f1.TelemetryData) interface{} { return t.Clutch }},
+		{"gear", func(t *f1.TelemetryData) interface{} { return t.Gear }},
+		{"gforce-lat", func(t *f1.TelemetryData) interface{} { return t.GforceLat }},
+		{"gforce-lon", func(t *f1.TelemetryData) interface{} { return t.GforceLon }},
+		{"laps-completed", func(t *f1.TelemetryData) interface{} { return t.Lap }},
+		{"enginerate", func(t *f1.TelemetryData) interface{} { return t.Enginerate }},
+		{"sli-pro-native-support", func(t *f1.TelemetryData) interface{} { return t.SliProNativeSupport }},
+		{"car-position", func(t *f1.TelemetryData) interface{} { return t.CarPosition }},
+		{"kers-level", func(t *f1.TelemetryData) interface{} { return t.KersLevel }},
+		{"kers-max-level", func(t *f1.TelemetryData) interface{} { return t.KersMaxLevel }},
+		{"drs", func(t *f1.TelemetryData) interface{} { return t.DRS }},
+		{"traction-control", func(t *f1.TelemetryData) interface{} { return t.TractionControl }},
+		{"anti-lock-brakes", func(t *f1.TelemetryData) interface{} { return t.AntiLockBrakes }},
+		{"fuel-in-tank", func(t *f1.TelemetryData) interface{} { return t.FuelInTank }},
+		{"fuel-capacity", func(t *f1.TelemetryData) interface{} { return t.FuelCapacity }},
+		{"in-pits", func(t *f1.TelemetryData) interface{} { return t.InPits }},
+		{"sector", func(t *f1.TelemetryData) interface{} { return t.Sector }},
+		{"sector1-time", func(t *f1.TelemetryData) interface{} { return t.Sector1Time }},
+		{"sector2-time", func(t *f1.TelemetryData) interface{} { return t.Sector2Time }},
+		{"team-info", func(t *f1.TelemetryData) interface{} { return t.TeamInfo }},
+		{"total-laps", func(t *f1.TelemetryData) interface{} { return t.TotalLaps }},
+		{"track-size", func(t *f1.TelemetryData) interface{} { return t.TrackSize }},
+		{"last-lap-time", func(t *f1.TelemetryData) interface{} { return t.LastLapTime }},
+		{"max-rpm", func(t *f1.TelemetryData) interface{} { return t.MaxRpm }},
+		{"idle-rpm", func(t *f1.TelemetryData) interface{} { return t.IdleRpm }},
+		{"max-gears", func(t *f1.TelemetryData) interface{} { return t.MaxGears }},
+		{"session-type-number", func(t *f1.TelemetryData) interface{} { return t.SessionType }},
+		{"drsallowed", func(t *f1.TelemetryData) interface{} { return t.Drsallowed }},
+		{"track-number", func(t *f1.TelemetryData) interface{} { return t.TrackNumber }},
+		{"vehiclefiaflags", func(t *f1.TelemetryData) interface{} { return t.Vehiclefiaflags }},
+		{"era", func(t *f1.TelemetryData) interface{} { return t.Era }},
+		{"engine-temperature", func(t *f1.TelemetryData) interface{} { return t.EngineTemperature }},
+		{"gforce-vert", func(t *f1.TelemetryData) interface{} { return t.GforceVert }},
+		{"ang-vel-x", func(t *f1.TelemetryData) interface{} { return t.AngVelX }},
+		{"ang-vel-y", func(t *f1.TelemetryData) interface{} { return t.AngVelY }},
+		{"ang-vel-z", func(t *f1.TelemetryData) interface{} { return t.AngVelZ }},
+		{"tyre-compound", func(t *f1.TelemetryData) interface{} { return t.TyreCompound }},
+		{"front-brake-bias", func(t *f1.TelemetryData) interface{} { return t.FrontBrakeBias }},
+		{"fuel-mix", func(t *f1.TelemetryData) interface{} { return t.FuelMix }},
+		{"currentlapinvalid", func(t *f1.TelemetryData) interface{} { return t.Currentlapinvalid }},
+		{"front-left-wing-damage", func(t *f1.TelemetryData) interface{} { return t.FrontLeftWingDamage }},
+		{"front-right-wing-damage", func(t *f1.TelemetryData) interface{} { return t.FrontRightWingDamage }},
+		{"rear-wing-damage", func(t *f1.TelemetryData) interface{} { return t.RearWingDamage }},
+		{"engine-damage", func(t *f1.TelemetryData) interface{} { return t.EngineDamage }},
+		{"gear-box-damage", func(t *f1.TelemetryData) interface{} { return t.GearBoxDamage }},
+		{"exhaust-damage", func(t *f1.TelemetryData) interface{} { return t.ExhaustDamage }},
+		{"pit-limiter-status", func(t *f1.TelemetryData) interface{} { return t.PitLimiterStatus }},
+		{"pit-speed-limit", func(t *f1.TelemetryData) interface{} { return t.PitSpeedLimit }},
+		{"session-time-left", func(t *f1.TelemetryData) interface{} { return t.SessionTimeLeft }},
+		{"rev-lights-percent", func(t *f1.TelemetryData) interface{} { return t.RevLightsPercent }},
+		{"is-spectating", func(t *f1.TelemetryData) interface{} { return t.IsSpectating }},
+		{"spectator-car-index", func(t *f1.TelemetryData) interface{} { return t.SpectatorCarIndex }},
+		{"num-cars", func(t *f1.TelemetryData) interface{} { return t.NumCars }},
+		{"player-car-index", func(t *f1.TelemetryData) interface{} { return t.PlayerCarIndex }},
+		{"yaw", func(t *f1.TelemetryData) interface{} { return t.Yaw }},
+		{"pitch", func(t *f1.TelemetryData) interface{} { return t.Pitch }},
+		{"roll", func(t *f1.TelemetryData) interface{} { return t.Roll }},
+		{"local-xv", func(t *f1.TelemetryData) interface{} { return t.XLocalVelocity }},
+		{"local-yv", func(t *f1.TelemetryData) interface{} { return t.YLocalVelocity }},
+		{"local-zv", func(t *f1.TelemetryData) interface{} { return t.ZLocalVelocity }},
+		{"ang-acc-x", func(t *f1.TelemetryData) interface{} { return t.AngAccX }},
+		{"ang-acc-y", func(t *f1.TelemetryData) interface{} { return t.AngAccY }},
+		{"ang-acc-z", func(t *f1.TelemetryData) interface{} { return t.AngAccZ }},
+	},
+	wheels("susp-pos", func(t *f1.TelemetryData) [4]float32 { return t.SuspPos }),
+	wheels("susp-vel", func(t *f1.TelemetryData) [4]float32 { return t.SuspVel }),
+	wheels("susp-acc", func(t *f1.TelemetryData) [4]float32 { return t.SuspAcceleration }),
+	wheels("wheel-speed", func(t *f1.TelemetryData) [4]float32 { return t.WheelSpeed }),
+	wheels("brakes-temp", func(t *f1.TelemetryData) [4]float32 { return t.BrakesTemp }),
+	wheels("tyres-pressure", func(t *f1.TelemetryData) [4]float32 { return t.TyresPressure }),
+	wheelBytes("tyres-temperature", func(t *f1.TelemetryData) [4]byte { return t.TyresTemperature }),
+	wheelBytes("tyres-wear", func(t *f1.TelemetryData) [4]byte { return t.TyresWear }),
+	wheelBytes("tyres-damage", func(t *f1.TelemetryData) [4]byte { return t.TyresDamage }),
+)
+
+// carChannels are the values of the cars in the race: the fields of
+// f1.CarData, with the world position as x, y and z.
+var carChannels = []carChannel{
+	{"x", func(c *f1.CarData) interface{} { return c.WorldPosition[0] }},
+	{"y", func(c *f1.CarData) interface{} { return c.WorldPosition[1] }},
+	{"z", func(c *f1.CarData) interface{} { return c.WorldPosition[2] }},
+	{"lastlap-time", func(c *f1.CarData) interface{} { return c.LastlapTime }},
+	{"currentlap-time", func(c *f1.CarData) interface{} { return c.CurrentlapTime }},
+	{"bestlap-time", func(c *f1.CarData) interface{} { return c.BestlapTime }},
+	{"sector1-time", func(c *f1.CarData) interface{} { return c.Sector1Time }},
+	{"sector2-time", func(c *f1.CarData) interface{} { return c.Sector2Time }},
+	{"lap-distance", func(c *f1.CarData) interface{} { return c.LapDistance }},
+	{"driver-id", func(c *f1.CarData) interface{} { return c.DriverID }},
+	{"team-id", func(c *f1.CarData) interface{} { return c.TeamID }},
+	{"car-position", func(c *f1.CarData) interface{} { return c.CarPosition }},
+	{"current-lap-num", func(c *f1.CarData) interface{} { return c.CurrentLapNum }},
+	{"tyre-compound", func(c *f1.CarData) interface{} { return c.TyreCompound }},
+	{"in-pits", func(c *f1.CarData) interface{} { return c.InPits }},
+	{"sector", func(c *f1.CarData) interface{} { return c.Sector }},
+	{"currentlapinvalid", func(c *f1.CarData) interface{} { return c.Currentlapinvalid }},
+	{"penalties", func(c *f1.CarData) interface{} { return c.Penalties }},
+}
+
+var wheelNames = [4]string{"rl", "rr", "fl", "fr"}
+
+// wheels expands an array of wheels into a channel per wheel.
+func wheels(name string, v func(t *f1.TelemetryData) [4]float32) []channel {
+	var channels []channel
+	for i, wheel := range wheelNames {
+		i := i
+		channels = append(channels, channel{name + "_" + wheel, func(t *f1.TelemetryData) interface{} { return v(t)[i] }})
+	}
+	return channels
+}
+
+func wheelBytes(name string, v func(t *f1.TelemetryData) [4]byte) []channel {
+	var channels []channel
+	for i, wheel := range wheelNames {
+		i := i
+		channels = append(channels, channel{name + "_" + wheel, func(t *f1.TelemetryData) interface{} { return v(t)[i] }})
+	}
+	return channels
+}
+
+func concat(lists ...[]channel) []channel {
+	var channels []channel
+	for _, l := range lists {
+		channels = append(channels, l...)
+	}
+	return channels
+}
+
+// selectChannels returns the channels of the player's car named, all of them
+// if none are. The name of a wheel array selects all of its wheels.
+func selectChannels(names []string) ([]channel, error) {
+	if len(names) == 0 {
+		return telemetryChannels, nil
+	}
+	var channels []channel
+	for _, name := range names {
+		n := len(channels)
+		for _, c := range telemetryChannels {
+			if selects(name, c.name) {
+				channels = append(channels, c)
+			}
+		}
+		if len(channels) == n {
+			return nil, fmt.Errorf("unknown channel %q", name)
+		}
+	}
+	return channels, nil
+}
+
+// selects reports whether name selects channel: if it is its name, or the
+// name of its wheel array.
+func selects(name, channel string) bool {
+	if channel == name {
+		return true
+	}
+	for _, wheel := range wheelNames {
+		if channel == name+"_"+wheel {
+			return true
+		}
+	}
+	return false
+}
+
+// selectCarChannels returns the channels of the cars named, all of them if
+// none are.
+func selectCarChannels(names []string) ([]carChannel, error) {
+	if len(names) == 0 {
+		return carChannels, nil
+	}
+	var channels []carChannel
+	for _, name := range names {
+		n := len(channels)
+		for _, c := range carChannels {
+			if c.name == name {
+				channels = append(channels, c)
+			}
+		}
+		if len(channels) == n {
+			return nil, fmt.Errorf("unknown car channel %q", name)
+		}
+	}
+	return channels, nil
+}
+
+// appendChannels appends the channels of frame to a JSON object, followed by
+// the cars in the race as an array of objects, if cars is true.
+func appendChannels(b []byte, frame *f1.Frame, channels []channel, carChannels []carChannel, cars bool) []byte {
+	for _, c := range channels {
+		b = appendField(b, c.name, c.value(&frame.TelemetryData))
+	}
+	if !cars {
+		return b
+	}
+
+	b = append(b, `,"cars":[`...)
+	first := true
+	for i := range frame.Cars {
+		car := &frame.Cars[i]
+		if car.CarPosition == 0 {
+			// Not racing, or no such car.
+			continue
+		}
+		if !first {
+			b = append(b, ',')
+		}
+		first = false
+		b = append(b, `{"car":`...)
+		b = strconv.AppendInt(b, int64(i), 10)
+		for _, c := range carChannels {
+			b = appendField(b, c.name, c.value(car))
+		}
+		b = append(b, '}')
+	}
+	return append(b, ']')
+}
+
+// appendField appends ,"name":v to a JSON object.
+func appendField(b []byte, name string, v interface{}) []byte {
+	b = append(b, `,"`...)
+	b = append(b, name...)
+	b = append(b, `":`...)
+	if f, ok := v.(float32); ok && (math.IsNaN(float64(f)) || math.IsInf(float64(f), 0)) {
+		return append(b, "null"...)
+	}
+	return append(b, formatValue(v)...)
+}
+
+// formatValue formats the value of a channel.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case byte:
+		return strconv.Itoa(int(v))
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// A sessionClock times frames by their game time, from the start of their
+// session, rather than by when they were received. The frames of a session
+// stay evenly spaced whatever the network did, and the frames after a
+// flashback take the place of the ones it rewound.
+type sessionClock struct {
+	zero map[string]time.Time // when the game time of each session was 0
+}
+
+// time returns the time of frame: the time its session started, plus the
+// game time since then. Frames outside of a session keep their receive time.
+func (c *sessionClock) time(frame f1.Frame) time.Time {
+	if frame.Session == "" {
+		return frame.Received
+	}
+	zero, ok := c.zero[frame.Session]
+	if !ok {
+		// The first frame of the session times the session's start.
+		zero = frame.Received.Add(-seconds(frame.Time))
+		if c.zero == nil {
+			c.zero = map[string]time.Time{}
+		}
+		c.zero[frame.Session] = zero
+	}
+	return zero.Add(seconds(frame.Time))
+}
+
+// end forgets the session with the given ID.
+func (c *sessionClock) end(id string) {
+	delete(c.zero, id)
+}
+
+func seconds(s float32) time.Duration {
+	return time.Duration(float64(s) * float64(time.Second))
+}
@@ -0,0 +1,349 @@
+package sink
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// dashboard is the page the feed sink serves at /: the terminal dashboard in
+// a browser, fed by /ws at the rate given in its query, e.g. /?rate=5. It is
+// self-contained, so it works on a pit wall without internet access.
+var dashboard = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// serveDashboard serves the dashboard, with the names of the drivers, teams
+// and tyre compounds.
+func (s *Feed) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	dashboard.Execute(w, struct {
+		Drivers, Teams, TyreCompounds map[byte]string
+	}{f1.Drivers, f1.Teams, f1.TyreCompounds})
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>F1 Telemetry</title>
+<style>
+body {
+	margin: 0;
+	padding: 8px;
+	background: #111;
+	color: #ddd;
+	font: 14px/1.4 Menlo, Consolas, "DejaVu Sans Mono", monospace;
+}
+.grid {
+	display: grid;
+	grid-template-columns: minmax(300px, 1fr) minmax(360px, 2fr);
+	gap: 8px;
+}
+@media (max-width: 720px) {
+	.grid { grid-template-columns: 1fr; }
+}
+.box {
+	border: 1px solid #ddd;
+	padding: 4px 8px;
+	overflow-x: auto;
+}
+.box h2 {
+	margin: -4px -8px 4px;
+	padding: 0 8px;
+	font-size: inherit;
+	font-weight: normal;
+	color: #fff;
+	border-bottom: 1px solid #555;
+}
+#status { color: #888; }
+#speed {
+	font-size: 48px;
+	font-weight: bold;
+	cursor: pointer;
+}
+#gear { color: #888; }
+.gauge {
+	height: 20px;
+	margin: 4px 0;
+	background: #333;
+}
+.gauge div {
+	height: 100%;
+	width: 0;
+	text-align: right;
+	color: #111;
+}
+#throttle div { background: #5f5; }
+#brake div { background: #f55; }
+table { border-collapse: collapse; }
+td, th {
+	padding: 0 8px 0 0;
+	text-align: left;
+	white-space: nowrap;
+}
+th { font-weight: normal; color: #fff; border-bottom: 1px solid #555; }
+.marker {
+	display: inline-block;
+	width: 10px;
+	height: 10px;
+	border: 1px solid #666;
+}
+.tyre { border-radius: 50%; }
+.fastest { color: #f0f; }
+.current { color: #5f5; }
+.sector1 { color: #0ff; }
+.sector2 { color: #5f5; }
+.pitting { color: #9e9e9e; }
+.inpits { color: #4e4e4e; }
+</style>
+</head>
+<body>
+<div id="status">Connecting…</div>
+<div class="grid">
+	<div>
+		<div class="box">
+			<h2>Speed</h2>
+			<div id="speed" title="Click to switch units">0 mph</div>
+			<div id="gear">N</div>
+		</div>
+		<div class="box">
+			<h2>Throttle</h2>
+			<div class="gauge" id="throttle"><div></div></div>
+			<h2>Brake</h2>
+			<div class="gauge" id="brake"><div></div></div>
+		</div>
+		<div class="box">
+			<h2>Laps</h2>
+			<table id="laps"></table>
+		</div>
+	</div>
+	<div class="box">
+		<h2>Drivers</h2>
+		<table id="drivers"></table>
+	</div>
+</div>
+<script>
+"use strict";
+
+const drivers = {{.Drivers}};
+const teams = {{.Teams}};
+const tyreCompounds = {{.TyreCompounds}};
+
+// The colours of the terminal dashboard.
+const teamColors = {
+	4: "#00ffaf", 0: "#5f5fff", 1: "#af0000", 6: "#d75fff", 7: "#0000ff",
+	2: "#000000", 8: "#005fff", 11: "#5f0000", 3: "#ffff00", 5: "#00afff",
+};
+const tyreColors = {
+	0: "#8700ff", 1: "#af0000", 2: "#ffff00", 3: "#0000ff", 4: "#000000", 5: "#00d700", 6: "#00afff",
+};
+
+const channels = ["speed", "gear", "throttle", "brake", "session-type-number", "track-size", "player-car-index"];
+const carChannels = [
+	"car-position", "driver-id", "team-id", "current-lap-num", "tyre-compound", "in-pits",
+	"lastlap-time", "currentlap-time", "bestlap-time", "sector1-time", "sector2-time",
+	"lap-distance", "sector",
+];
+
+let kph = localStorage.getItem("kph") === "true";
+let session = null;
+let laps = [];
+let frame = null;
+
+document.getElementById("speed").onclick = () => {
+	kph = !kph;
+	localStorage.setItem("kph", kph);
+	if (frame) renderSpeed(frame);
+};
+
+function connect() {
+	const params = new URLSearchParams(location.search);
+	const query = new URLSearchParams({channels: channels, "car-channels": carChannels, cars: "true"});
+	if (params.has("rate")) query.set("rate", params.get("rate"));
+	const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws?" + query);
+	ws.onmessage = (e) => receive(JSON.parse(e.data));
+	ws.onclose = () => {
+		setStatus("Disconnected, reconnecting…");
+		setTimeout(connect, 1000);
+	};
+}
+
+function receive(m) {
+	switch (m.type) {
+	case "session-start":
+		session = m;
+		laps = [];
+		setStatus(m.format + " · " + m.track + " · " + m["session-type"]);
+		break;
+	case "session-end":
+		setStatus(m.format + " · " + m.track + " · " + m["session-type"] + " · ended");
+		break;
+	case "subscribed":
+		if (!session) setStatus("Waiting for telemetry…");
+		break;
+	case "error":
+		setStatus("Error: " + m.error);
+		break;
+	case "frame":
+		frame = m;
+		render(m);
+		break;
+	}
+}
+
+function setStatus(s) {
+	document.getElementById("status").textContent = s;
+}
+
+function render(f) {
+	renderSpeed(f);
+	document.getElementById("gear").textContent = f.gear > 0 ? "Gear " + f.gear : f.gear < 0 ? "R" : "N";
+	setGauge("throttle", f.throttle);
+	setGauge("brake", f.brake);
+
+	// Formats without the cars in the race, or feeds without cars, send none.
+	const cars = f.cars || [];
+	const player = cars.find((car) => car.car === f["player-car-index"]);
+	if (player) {
+		laps = playerLap(laps, player);
+		renderLaps(laps);
+	}
+	renderDrivers(cars, f["track-size"], f["session-type-number"]);
+}
+
+function renderSpeed(f) {
+	const speed = kph ? f.speed * 3.6 : f.speed * 2.23694;
+	document.getElementById("speed").textContent = Math.trunc(speed) + (kph ? " km/h" : " mph");
+}
+
+function setGauge(id, value) {
+	const percent = Math.trunc(100 * value);
+	const bar = document.querySelector("#" + id + " div");
+	bar.style.width = percent + "%";
+	bar.textContent = percent > 10 ? percent + "%" : "";
+}
+
+// playerLap updates the sector and lap times of the player's laps, as the
+// terminal dashboard does.
+function playerLap(laps, car) {
+	const n = car["current-lap-num"];
+	if (n === 0) return laps;
+	while (laps.length < n) laps.push([0, 0, 0, 0]);
+	if (n >= 2) laps[n - 2][3] = car["lastlap-time"];
+	laps[n - 1][3] = car["currentlap-time"];
+	const s1 = car["sector1-time"], s2 = car["sector2-time"];
+	switch (car.sector) {
+	case 0:
+		if (n >= 2) {
+			laps[n - 2][0] = s1;
+			laps[n - 2][1] = s2;
+			laps[n - 2][2] = car["lastlap-time"] - s1 - s2;
+			laps[n - 1][0] = car["currentlap-time"];
+		}
+		break;
+	case 1:
+		laps[n - 1][0] = s1;
+		laps[n - 1][1] = car["currentlap-time"] - s1;
+		break;
+	case 2:
+		laps[n - 1][0] = s1;
+		laps[n - 1][1] = s2;
+		laps[n - 1][2] = car["currentlap-time"] - s1 - s2;
+		break;
+	}
+	return laps;
+}
+
+// renderLaps shows the fastest sectors and lap in purple, and the times of
+// the lap in progress in green.
+function renderLaps(laps) {
+	const inProgress = (i, j) => i === laps.length - 1 && (j === 3 || j === 2 || laps[i][j + 1] === 0);
+	const lowest = [Infinity, Infinity, Infinity, Infinity];
+	laps.forEach((lap, i) => lap.forEach((t, j) => {
+		if (!inProgress(i, j) && t > 0 && t < lowest[j]) lowest[j] = t;
+	}));
+
+	const rows = [["#", "Sector 1", "Sector 2", "Sector 3", "Lap Time"].map((h) => el("th", h))];
+	laps.forEach((lap, i) => {
+		const row = [el("td", String(i + 1))];
+		lap.forEach((t, j) => {
+			const td = el("td", t > 0 ? lapTime(t) : "");
+			if (t === lowest[j]) td.className = "fastest";
+			if (inProgress(i, j)) td.className = "current";
+			row.push(td);
+		});
+		rows.push(row);
+	});
+	setRows("laps", rows);
+}
+
+function renderDrivers(cars, trackSize, sessionType) {
+	const rows = cars
+		.filter((car) => car["car-position"] > 0)
+		.sort((a, b) => a["car-position"] - b["car-position"])
+		.map((car) => {
+			const name = el("span", drivers[car["driver-id"]] || "LUA");
+			name.className = ["", "pitting", "inpits"][car["in-pits"]] || "";
+			const shown = sessionType === 3 ? car["lastlap-time"] : car["bestlap-time"];
+			const best = el("td", lapTime(shown));
+			if (car["lastlap-time"] === car["bestlap-time"]) best.className = "fastest";
+			let progress = "";
+			if (trackSize > 0) progress = " (" + (100 * car["lap-distance"] / trackSize).toFixed(1) + "%)";
+			const current = el("td", lapTime(car["currentlap-time"]) + progress);
+			current.className = ["", "sector1", "sector2"][car.sector] || "";
+			return [
+				el("td", String(car["car-position"])),
+				el("td", marker("", teamColors[car["team-id"]], teams[car["team-id"]])),
+				el("td", name),
+				el("td", "(" + car["current-lap-num"] + ")"),
+				el("td", marker("tyre", tyreColors[car["tyre-compound"]], tyreCompounds[car["tyre-compound"]])),
+				best,
+				current,
+			];
+		});
+	setRows("drivers", rows);
+}
+
+function marker(className, color, title) {
+	const span = el("span", "");
+	span.className = "marker " + className;
+	span.style.background = color || "transparent";
+	span.title = title || "";
+	return span;
+}
+
+// lapTime formats seconds as the terminal dashboard does, e.g. "1:23.4560".
+function lapTime(t) {
+	const m = Math.trunc(t / 60);
+	t -= m * 60;
+	const s = Math.trunc(t);
+	const r = Math.trunc((t - s) * 10000);
+	return m + ":" + String(s).padStart(2, "0") + "." + String(r).padStart(4, "0");
+}
+
+function el(tag, content) {
+	const e = document.createElement(tag);
+	if (typeof content === "string") e.textContent = content;
+	else e.appendChild(content);
+	return e;
+}
+
+function setRows(id, rows) {
+	const table = document.getElementById(id);
+	table.replaceChildren(...rows.map((cells) => {
+		const tr = document.createElement("tr");
+		tr.append(...cells);
+		return tr;
+	}));
+}
+
+connect();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,311 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+func init() {
+	Register("csv", func(options json.RawMessage) (Sink, error) {
+		return NewExport("csv", options)
+	})
+	Register("jsonl", func(options json.RawMessage) (Sink, error) {
+		return NewExport("jsonl", options)
+	})
+}
+
+// ExportOptions are the options of the csv and jsonl sinks.
+type ExportOptions struct {
+	Dir string `json:"dir"` // created if missing, the working directory by default
+
+	// Channels are the channels of the player's car written, named as the
+	// fields of the Influx schema, all by default. The name of a wheel
+	// array, e.g. "tyres-wear", selects all of its wheels. CarChannels are
+	// those of the other cars, which aren't written if Cars is false.
+	Channels    []string `json:"channels"`
+	CarChannels []string `json:"car-channels"`
+	Cars        bool     `json:"cars"`
+
+	// FromLap and ToLap are the first and last laps of the player written,
+	// counting from 1, 0 for no limit.
+	FromLap int `json:"from-lap"`
+	ToLap   int `json:"to-lap"`
+
+	// Rate is how many frames are written per second of game time at most,
+	// taking the first frame of each interval, 0 for every frame.
+	Rate float64 `json:"rate"`
+}
+
+// An Export sink writes the telemetry to files in a directory, one per
+// session named after its ID, or after the source for frames outside of a
+// session. Files of the same name are overwritten.
+//
+// As CSV, a session's file has a row per frame with the receive time and a
+// column per channel, and the "-cars" file next to it a row per car in the
+// race and frame, with the game time, the index of the car and a column per
+// car channel. As JSON Lines, a session's file has an object per frame, with
+// the receive time, the channels and the cars as an array of objects.
+type Export struct {
+	// Accessed atomically, first in the struct to be 64-bit aligned.
+	written uint64 // frames
+	files   uint64 // created
+
+	format      string // "csv" or "jsonl"
+	options     ExportOptions
+	channels    []channel
+	carChannels []carChannel
+	sessions    map[string]*exportSession
+}
+
+// An exportSession holds the files of a session being written.
+type exportSession struct {
+	main, cars *exportFile // cars is nil unless writing cars as CSV
+	sampled    bool        // whether a frame was written with Rate
+	interval   float64     // of 1/Rate seconds of game time last written
+	last       float32     // game time of the last frame
+}
+
+type exportFile struct {
+	file *os.File
+	w    *bufio.Writer
+	csv  *csv.Writer // nil for JSON Lines
+}
+
+// NewExport creates a sink writing format, "csv" or "jsonl", configured by
+// options.
+func NewExport(format string, options json.RawMessage) (*Export, error) {
+	s := &Export{
+		format:   format,
+		options:  ExportOptions{Dir: ".", Cars: true},
+		sessions: map[string]*exportSession{},
+	}
+	if err := decodeOptions(options, &s.options); err != nil {
+		return nil, err
+	}
+	if s.options.FromLap < 0 || s.options.ToLap < 0 || s.options.ToLap > 0 && s.options.ToLap < s.options.FromLap {
+		return nil, fmt.Errorf("invalid lap range %d-%d", s.options.FromLap, s.options.ToLap)
+	}
+	if s.options.Rate < 0 {
+		return nil, fmt.Errorf("invalid rate %g", s.options.Rate)
+	}
+
+	var err error
+	if s.channels, err = selectChannels(s.options.Channels); err != nil {
+		return nil, err
+	}
+	if s.carChannels, err = selectCarChannels(s.options.CarChannels); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open creates the directory if missing.
+func (s *Export) Open() error {
+	return os.MkdirAll(s.options.Dir, 0755)
+}
+
+// Frame writes frame, unless the lap range or the rate leave it out.
+func (s *Export) Frame(frame f1.Frame) error {
+	if lap := frame.PlayerLap(); lap < s.options.FromLap || s.options.ToLap > 0 && lap > s.options.ToLap {
+		return nil
+	}
+
+	id := frame.Session
+	if id == "" {
+		id = frame.Source
+	}
+	session, ok := s.sessions[id]
+	if !ok {
+		var err error
+		if session, err = s.create(f1.FileName(id)); err != nil {
+			return err
+		}
+		s.sessions[id] = session
+	}
+
+	if s.options.Rate > 0 {
+		// Allowing for the game time being a float32, e.g. 10.15 being
+		// 10.149999.
+		interval := math.Floor(float64(frame.Time)*s.options.Rate + 1e-3)
+		rewound := frame.Time < session.last
+		session.last = frame.Time
+		if session.sampled && !rewound && interval <= session.interval {
+			return nil
+		}
+		session.interval = interval
+		session.sampled = true
+	}
+
+	var err error
+	if s.format == "csv" {
+		err = s.writeCSV(session, &frame)
+	} else {
+		err = s.writeJSON(session, &frame)
+	}
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.written, 1)
+	return nil
+}
+
+// create creates the files of a session, writing the CSV headers.
+func (s *Export) create(name string) (*exportSession, error) {
+	if s.format == "jsonl" {
+		main, err := s.createFile(name+".jsonl", nil)
+		return &exportSession{main: main}, err
+	}
+
+	header := []string{"received"}
+	for _, c := range s.channels {
+		header = append(header, c.name)
+	}
+	main, err := s.createFile(name+".csv", header)
+	if err != nil {
+		return nil, err
+	}
+	session := &exportSession{main: main}
+	if s.options.Cars {
+		header := []string{"game-time", "car"}
+		for _, c := range s.carChannels {
+			header = append(header, c.name)
+		}
+		if session.cars, err = s.createFile(name+"-cars.csv", header); err != nil {
+			main.file.Close()
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// createFile creates a file in the directory, as CSV with header if not nil.
+func (s *Export) createFile(name string, header []string) (*exportFile, error) {
+	file, err := os.Create(filepath.Join(s.options.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&s.files, 1)
+	f := &exportFile{file: file, w: bufio.NewWriter(file)}
+	if header != nil {
+		f.csv = csv.NewWriter(f.w)
+		f.csv.Write(header)
+	}
+	return f, nil
+}
+
+func (s *Export) writeCSV(session *exportSession, frame *f1.Frame) error {
+	record := []string{frame.Received.UTC().Format(time.RFC3339Nano)}
+	for _, c := range s.channels {
+		record = append(record, formatValue(c.value(&frame.TelemetryData)))
+	}
+	if err := session.main.csv.Write(record); err != nil {
+		return err
+	}
+	if session.cars == nil {
+		return nil
+	}
+
+	for i := range frame.Cars {
+		car := &frame.Cars[i]
+		if car.CarPosition == 0 {
+			// Not racing, or no such car.
+			continue
+		}
+		record := []string{formatValue(frame.Time), strconv.Itoa(i)}
+		for _, c := range s.carChannels {
+			record = append(record, formatValue(c.value(car)))
+		}
+		if err := session.cars.csv.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Export) writeJSON(session *exportSession, frame *f1.Frame) error {
+	b := []byte(`{"received":"`)
+	b = frame.Received.UTC().AppendFormat(b, time.RFC3339Nano)
+	b = append(b, '"')
+	b = appendChannels(b, frame, s.channels, s.carChannels, s.options.Cars)
+	b = append(b, "}\n"...)
+	_, err := session.main.w.Write(b)
+	return err
+}
+
+// Event closes the files of a session once it ends.
+func (s *Export) Event(event f1.SessionEvent) error {
+	if event.Type != f1.SessionEnd {
+		return nil
+	}
+	session, ok := s.sessions[event.Session.ID]
+	if !ok {
+		return nil
+	}
+	delete(s.sessions, event.Session.ID)
+	return session.close()
+}
+
+// Flush writes the rows buffered to the files.
+func (s *Export) Flush() error {
+	for _, session := range s.sessions {
+		if err := session.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the files of the sessions still in progress.
+func (s *Export) Close() error {
+	var err error
+	for id, session := range s.sessions {
+		if e := session.close(); e != nil && err == nil {
+			err = e
+		}
+		delete(s.sessions, id)
+	}
+	return err
+}
+
+// Status reports how many frames were written, and to how many files.
+func (s *Export) Status() string {
+	return fmt.Sprintf("%d frames written to %d files", atomic.LoadUint64(&s.written), atomic.LoadUint64(&s.files))
+}
+
+func (session *exportSession) flush() error {
+	for _, f := range []*exportFile{session.main, session.cars} {
+		if f == nil {
+			continue
+		}
+		if f.csv != nil {
+			f.csv.Flush()
+		}
+		if err := f.w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (session *exportSession) close() error {
+	err := session.flush()
+	for _, f := range []*exportFile{session.main, session.cars} {
+		if f == nil {
+			continue
+		}
+		if e := f.file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
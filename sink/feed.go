@@ -0,0 +1,643 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+const (
+	// DefaultFeedAddr is the address the feed sink serves on by default,
+	// only reachable from this computer.
+	DefaultFeedAddr = "localhost:8080"
+	// DefaultFeedRate is how many frames per second clients of the feed are
+	// sent by default, a few times less often than the game sends them.
+	DefaultFeedRate = 10
+)
+
+func init() {
+	Register("feed", func(options json.RawMessage) (Sink, error) {
+		return NewFeed(options)
+	})
+}
+
+// FeedOptions are the options of the feed sink.
+type FeedOptions struct {
+	Addr string `json:"addr"` // to serve HTTP on, DefaultFeedAddr by default
+
+	// Origins are the origins of the pages, other than the feed's own, that
+	// may connect to /ws, e.g. "http://grafana.local:3000", or "*" for any.
+	Origins []string `json:"origins"`
+
+	// Channels, CarChannels and Cars are what clients are sent of each frame
+	// unless they subscribe to other channels, as with the csv and jsonl
+	// sinks: every channel and the cars in the race by default.
+	Channels    []string `json:"channels"`
+	CarChannels []string `json:"car-channels"`
+	Cars        bool     `json:"cars"`
+
+	// Rate is how many frames per second clients are sent at most unless
+	// they ask for another rate, 0 for every frame.
+	Rate float64 `json:"rate"`
+
+	// Buffer is how many messages a client may fall behind by. Frames it has
+	// no room for are dropped, and a client with no room for an event is
+	// disconnected.
+	Buffer int `json:"buffer"`
+}
+
+// A Feed sink streams the telemetry to clients over HTTP as JSON messages,
+// through a WebSocket at /ws or as server-sent events at /events:
+//
+//	{"type":"frame","session":"…","source":"…","received":"…","lap":3,"speed":71.5,…,"cars":[{"car":0,…},…]}
+//	{"type":"lap","session":"…","source":"…","received":"…","car":4,"player":true,"driver":"…","team":"…","lap":2,"time":81.25,"position":3}
+//
+// Besides frames, sampled at the rate of each client, clients are sent every
+// event derived from them: "lap" when a car completes one, "pit-entry" and
+// "pit-exit", and "position" when a car changes position, with "from" and
+// "to". Formats without the cars in the race only have these events for the
+// player. They are also sent "session-start" and "session-end", the sessions
+// in progress once they connect, and "subscribed" with their subscription.
+// Frames and events are those of every source, told apart by "source".
+//
+// The query of the URL, e.g. /events?channels=speed,gear&cars=false&rate=2,
+// subscribes to channels, car-channels, cars and rate other than the
+// defaults. WebSocket clients may change their subscription at any time by
+// sending the same as a JSON object, e.g. {"channels":["speed"],"rate":5}.
+//
+// A dashboard fed by /ws is served at /, for browsers. Browsers are only let
+// connect to /ws from the pages of the feed, and of the origins configured.
+type Feed struct {
+	// Accessed atomically, first in the struct to be 64-bit aligned.
+	sent    uint64 // frames
+	dropped uint64 // frames
+
+	options  FeedOptions
+	defaults feedSubscription
+	mux      *http.ServeMux
+	server   *http.Server
+
+	mu       sync.Mutex
+	clients  map[*feedClient]bool
+	sessions map[string][]byte // start event of the session in progress of each source
+
+	// The last frame of each source, which events are derived against. Only
+	// used by Frame.
+	last map[string]f1.Frame
+}
+
+// A feedSubscription is what a client is sent of each frame.
+type feedSubscription struct {
+	channels    []channel
+	carChannels []carChannel
+	cars        bool
+	rate        float64 // frames per second, 0 for every frame
+}
+
+// A feedRequest asks for a subscription, leaving out what it doesn't change.
+type feedRequest struct {
+	Channels    []string `json:"channels"`
+	CarChannels []string `json:"car-channels"`
+	Cars        *bool    `json:"cars"`
+	Rate        *float64 `json:"rate"`
+}
+
+type feedClient struct {
+	send      chan []byte // messages
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Guarded by Feed.mu.
+	subscription feedSubscription
+	next         time.Time // receive time from which the next frame is sent
+}
+
+// A feedEvent is a session starting or ending.
+type feedEvent struct {
+	Type        string `json:"type"`
+	Session     string `json:"session"`
+	Source      string `json:"source"`
+	Format      string `json:"format"`
+	Track       string `json:"track"`
+	SessionType string `json:"session-type"`
+	Start       string `json:"start"`
+	End         string `json:"end,omitempty"`
+}
+
+// A feedCarEvent is derived from the frames of a car.
+type feedCarEvent struct {
+	Type     string  `json:"type"`
+	Session  string  `json:"session"`
+	Source   string  `json:"source"`
+	Received string  `json:"received"`
+	Car      int     `json:"car"`
+	Player   bool    `json:"player"`
+	Driver   string  `json:"driver,omitempty"`
+	Team     string  `json:"team,omitempty"`
+	Lap      int     `json:"lap"`
+	Time     float32 `json:"time,omitempty"`     // of a lap
+	Position int     `json:"position,omitempty"` // once completing a lap
+	From     int     `json:"from,omitempty"`     // position
+	To       int     `json:"to,omitempty"`
+}
+
+// A carState is what events are derived from, for a car in a frame.
+type carState struct {
+	lap      int
+	lastLap  float32
+	position int
+	inPits   bool
+}
+
+// NewFeed creates a feed sink configured by options.
+func NewFeed(options json.RawMessage) (*Feed, error) {
+	s := &Feed{
+		options:  FeedOptions{Addr: DefaultFeedAddr, Cars: true, Rate: DefaultFeedRate, Buffer: 64},
+		mux:      http.NewServeMux(),
+		clients:  map[*feedClient]bool{},
+		sessions: map[string][]byte{},
+		last:     map[string]f1.Frame{},
+	}
+	if err := decodeOptions(options, &s.options); err != nil {
+		return nil, err
+	}
+	if s.options.Buffer < 1 {
+		return nil, fmt.Errorf("invalid buffer %d", s.options.Buffer)
+	}
+	var err error
+	s.defaults, err = s.defaults.with(feedRequest{
+		Channels:    s.options.Channels,
+		CarChannels: s.options.CarChannels,
+		Cars:        &s.options.Cars,
+		Rate:        &s.options.Rate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mux.HandleFunc("/", s.serveDashboard)
+	s.mux.HandleFunc("/ws", s.serveWebsocket)
+	s.mux.HandleFunc("/events", s.serveEvents)
+	return s, nil
+}
+
+// with returns sub changed as r asks.
+func (sub feedSubscription) with(r feedRequest) (feedSubscription, error) {
+	var err error
+	if r.Channels != nil || sub.channels == nil {
+		if sub.channels, err = selectChannels(r.Channels); err != nil {
+			return sub, err
+		}
+	}
+	if r.CarChannels != nil || sub.carChannels == nil {
+		if sub.carChannels, err = selectCarChannels(r.CarChannels); err != nil {
+			return sub, err
+		}
+	}
+	if r.Cars != nil {
+		sub.cars = *r.Cars
+	}
+	if r.Rate != nil {
+		if rate := *r.Rate; rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return sub, fmt.Errorf("invalid rate %g", *r.Rate)
+		}
+		sub.rate = *r.Rate
+	}
+	return sub, nil
+}
+
+// queryRequest returns the subscription asked for by the query of a URL.
+func queryRequest(query url.Values) (feedRequest, error) {
+	var r feedRequest
+	if v := query.Get("channels"); v != "" {
+		r.Channels = strings.Split(v, ",")
+	}
+	if v := query.Get("car-channels"); v != "" {
+		r.CarChannels = strings.Split(v, ",")
+	}
+	if v := query.Get("cars"); v != "" {
+		cars, err := strconv.ParseBool(v)
+		if err != nil {
+			return r, fmt.Errorf("invalid cars %q", v)
+		}
+		r.Cars = &cars
+	}
+	if v := query.Get("rate"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return r, fmt.Errorf("invalid rate %q", v)
+		}
+		r.Rate = &rate
+	}
+	return r, nil
+}
+
+// Open starts serving HTTP.
+func (s *Feed) Open() error {
+	l, err := net.Listen("tcp", s.options.Addr)
+	if err != nil {
+		return err
+	}
+	s.server = &http.Server{Handler: s.mux}
+	// Serve returns once the server is closed.
+	go s.server.Serve(l)
+	return nil
+}
+
+// serveWebsocket streams the feed to a WebSocket client, which may send
+// requests to change its subscription.
+func (s *Feed) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	if !s.allowOrigin(r) {
+		// Browsers let any page open a WebSocket, unlike other requests.
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	sub, ok := s.subscribe(w, r)
+	if !ok {
+		return
+	}
+	conn := upgradeWebsocket(w, r)
+	if conn == nil {
+		return
+	}
+	defer conn.close()
+	c := s.connect(sub)
+	defer s.disconnect(c)
+
+	go func() {
+		defer c.close()
+		for {
+			b, err := conn.readMessage()
+			if err != nil {
+				return
+			}
+			var req feedRequest
+			if err := decodeOptions(b, &req); err != nil {
+				c.trySend(errorMessage(err))
+				continue
+			}
+			s.resubscribe(c, req)
+		}
+	}()
+
+	for {
+		select {
+		case b := <-c.send:
+			if err := conn.writeText(b); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// allowOrigin reports whether r comes from a page allowed to connect: one
+// served by the feed, or of a configured origin. Requests of clients other
+// than browsers have no origin, and are allowed.
+func (s *Feed) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range s.options.Origins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// serveEvents streams the feed to a client as server-sent events.
+func (s *Feed) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	sub, ok := s.subscribe(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	c := s.connect(sub)
+	defer s.disconnect(c)
+
+	for {
+		select {
+		case b := <-c.send:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// subscribe returns the subscription asked for by the query of r, replying
+// with an error if it is invalid.
+func (s *Feed) subscribe(w http.ResponseWriter, r *http.Request) (feedSubscription, bool) {
+	req, err := queryRequest(r.URL.Query())
+	if err == nil {
+		var sub feedSubscription
+		if sub, err = s.defaults.with(req); err == nil {
+			return sub, true
+		}
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+	return feedSubscription{}, false
+}
+
+// connect adds a client subscribed to sub, telling it so and about the
+// sessions in progress.
+func (s *Feed) connect(sub feedSubscription) *feedClient {
+	c := &feedClient{
+		send:         make(chan []byte, s.options.Buffer),
+		done:         make(chan struct{}),
+		subscription: sub,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c] = true
+	c.trySend(subscribedMessage(sub))
+	for _, b := range s.sessions {
+		c.trySend(b)
+	}
+	return c
+}
+
+func (s *Feed) disconnect(c *feedClient) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+	c.close()
+}
+
+// resubscribe changes the subscription of c as req asks, telling it the
+// outcome.
+func (s *Feed) resubscribe(c *feedClient, req feedRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, err := c.subscription.with(req)
+	if err != nil {
+		c.trySend(errorMessage(err))
+		return
+	}
+	c.subscription = sub
+	c.next = time.Time{}
+	c.trySend(subscribedMessage(sub))
+}
+
+// trySend queues a message for c, reporting whether it had room for it.
+func (c *feedClient) trySend(b []byte) bool {
+	select {
+	case c.send <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *feedClient) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// due reports whether a frame received at t is sent to c at the rate of its
+// subscription.
+func (c *feedClient) due(t time.Time) bool {
+	if c.subscription.rate == 0 {
+		return true
+	}
+	interval := time.Duration(float64(time.Second) / c.subscription.rate)
+	if t.Before(c.next) && c.next.Sub(t) <= interval {
+		// Unless the clock went back.
+		return false
+	}
+	// Keeping to the rate on average, unless falling behind.
+	c.next = c.next.Add(interval)
+	if !c.next.After(t) || c.next.Sub(t) > interval {
+		c.next = t.Add(interval)
+	}
+	return true
+}
+
+// Frame sends frame to the clients it is due to, and the events derived from
+// it to every client.
+func (s *Feed) Frame(frame f1.Frame) error {
+	events := s.derive(&frame)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range events {
+		s.broadcast(event)
+	}
+	for c := range s.clients {
+		if !c.due(frame.Received) {
+			continue
+		}
+		if c.trySend(frameMessage(&frame, &c.subscription)) {
+			atomic.AddUint64(&s.sent, 1)
+		} else {
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+	return nil
+}
+
+// broadcast sends an event to every client, disconnecting those with no room
+// for it, as they would miss it. s.mu is held.
+func (s *Feed) broadcast(b []byte) {
+	for c := range s.clients {
+		if !c.trySend(b) {
+			c.close()
+		}
+	}
+}
+
+// derive returns the events of the cars from the last frame of the source of
+// frame to frame.
+func (s *Feed) derive(frame *f1.Frame) [][]byte {
+	last, tracked := s.last[frame.Source]
+	s.last[frame.Source] = *frame
+	if !tracked || last.Session != frame.Session {
+		return nil
+	}
+
+	var events [][]byte
+	e := feedCarEvent{Session: frame.Session, Source: frame.Source, Received: frame.Received.UTC().Format(time.RFC3339Nano)}
+	if !frame.Format.Fields().Has(f1.FieldCars) {
+		e.Car, e.Player = int(frame.PlayerCarIndex), true
+		return appendCarEvents(events, e, playerState(&last), playerState(frame))
+	}
+	for i := range frame.Cars {
+		car, previous := &frame.Cars[i], &last.Cars[i]
+		if car.CarPosition == 0 || previous.CarPosition == 0 {
+			// Not racing, or no such car.
+			continue
+		}
+		e.Car, e.Player = i, i == int(frame.PlayerCarIndex)
+		e.Driver, e.Team = f1.Drivers[car.DriverID], f1.Teams[car.TeamID]
+		events = appendCarEvents(events, e, carDataState(previous), carDataState(car))
+	}
+	return events
+}
+
+func playerState(frame *f1.Frame) carState {
+	return carState{
+		lap:      frame.PlayerLap(),
+		lastLap:  frame.LastLapTime,
+		position: int(frame.CarPosition),
+		inPits:   frame.InPits != 0,
+	}
+}
+
+func carDataState(car *f1.CarData) carState {
+	return carState{
+		lap:      int(car.CurrentLapNum),
+		lastLap:  car.LastlapTime,
+		position: int(car.CarPosition),
+		inPits:   car.InPits != 0,
+	}
+}
+
+// appendCarEvents appends the events of a car going from previous to
+// current, filling in e.
+func appendCarEvents(events [][]byte, e feedCarEvent, previous, current carState) [][]byte {
+	add := func(e feedCarEvent) {
+		b, _ := json.Marshal(e)
+		events = append(events, b)
+	}
+	e.Lap = current.lap
+	if current.lap == previous.lap+1 && current.lastLap > 0 {
+		lap := e
+		lap.Type, lap.Lap, lap.Time, lap.Position = "lap", previous.lap, current.lastLap, current.position
+		add(lap)
+	}
+	if current.inPits != previous.inPits {
+		pit := e
+		pit.Type = "pit-exit"
+		if current.inPits {
+			pit.Type = "pit-entry"
+		}
+		add(pit)
+	}
+	if current.position != previous.position && previous.position > 0 && current.position > 0 {
+		position := e
+		position.Type, position.From, position.To = "position", previous.position, current.position
+		add(position)
+	}
+	return events
+}
+
+// Event sends a session starting or ending to every client.
+func (s *Feed) Event(event f1.SessionEvent) error {
+	session := event.Session
+	e := feedEvent{
+		Type:        "session-" + event.Type.String(),
+		Session:     session.ID,
+		Source:      session.Source,
+		Format:      session.Format.String(),
+		Track:       f1.TrackName(session.TrackNumber),
+		SessionType: f1.SessionTypeName(session.SessionType),
+		Start:       session.Start.UTC().Format(time.RFC3339Nano),
+	}
+	if event.Type == f1.SessionEnd {
+		e.End = session.End.UTC().Format(time.RFC3339Nano)
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event.Type == f1.SessionStart {
+		s.sessions[session.Source] = b
+	} else {
+		delete(s.sessions, session.Source)
+	}
+	s.broadcast(b)
+	return nil
+}
+
+// Flush does nothing, as messages are sent as they come.
+func (s *Feed) Flush() error {
+	return nil
+}
+
+// Close stops serving HTTP and disconnects the clients.
+func (s *Feed) Close() error {
+	err := s.server.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.close()
+	}
+	return err
+}
+
+// Status reports how many clients are connected, and how many frames were
+// sent to them and dropped.
+func (s *Feed) Status() string {
+	s.mu.Lock()
+	clients := len(s.clients)
+	s.mu.Unlock()
+	return fmt.Sprintf("%d clients, %d frames sent, %d dropped", clients, atomic.LoadUint64(&s.sent), atomic.LoadUint64(&s.dropped))
+}
+
+// frameMessage encodes the channels of frame sub subscribes to.
+func frameMessage(frame *f1.Frame, sub *feedSubscription) []byte {
+	session, _ := json.Marshal(frame.Session)
+	source, _ := json.Marshal(frame.Source)
+	b := append([]byte(`{"type":"frame","session":`), session...)
+	b = append(b, `,"source":`...)
+	b = append(b, source...)
+	b = append(b, `,"received":"`...)
+	b = frame.Received.UTC().AppendFormat(b, time.RFC3339Nano)
+	b = append(b, `","lap":`...)
+	b = strconv.AppendInt(b, int64(frame.PlayerLap()), 10)
+	b = appendChannels(b, frame, sub.channels, sub.carChannels, sub.cars)
+	return append(b, '}')
+}
+
+func subscribedMessage(sub feedSubscription) []byte {
+	m := struct {
+		Type        string   `json:"type"`
+		Channels    []string `json:"channels"`
+		CarChannels []string `json:"car-channels"`
+		Cars        bool     `json:"cars"`
+		Rate        float64  `json:"rate"`
+	}{Type: "subscribed", Channels: []string{}, CarChannels: []string{}, Cars: sub.cars, Rate: sub.rate}
+	for _, c := range sub.channels {
+		m.Channels = append(m.Channels, c.name)
+	}
+	for _, c := range sub.carChannels {
+		m.CarChannels = append(m.CarChannels, c.name)
+	}
+	b, _ := json.Marshal(m)
+	return b
+}
+
+func errorMessage(err error) []byte {
+	b, _ := json.Marshal(struct {
+		Type  string `json:"type"`
+		Error string `json:"error"`
+	}{"error", err.Error()})
+	return b
+}
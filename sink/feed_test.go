@@ -0,0 +1,128 @@
+package sink
+
+import (
+	"encoding/json"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// messages returns the messages sent to c so far, decoded.
+func messages(t *testing.T, c *feedClient) []map[string]interface{} {
+	var ms []map[string]interface{}
+	for {
+		select {
+		case b := <-c.send:
+			var m map[string]interface{}
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			ms = append(ms, m)
+		default:
+			return ms
+		}
+	}
+}
+
+func TestFeedRate(t *testing.T) {
+	for _, rate := range []string{"NaN", "nan", "Inf", "+Inf", "-Inf", "1e400", "fast"} {
+		if _, err := queryRequest(url.Values{"rate": {rate}}); err == nil {
+			t.Errorf("queryRequest(rate=%s) succeeded", rate)
+		}
+	}
+	for _, rate := range []float64{-1, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := (feedSubscription{}).with(feedRequest{Rate: &rate}); err == nil {
+			t.Errorf("with(rate %g) succeeded", rate)
+		}
+	}
+
+	r, err := queryRequest(url.Values{"rate": {"2.5"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := (feedSubscription{}).with(r)
+	if err != nil || sub.rate != 2.5 {
+		t.Fatalf("with(rate=2.5) = %g, %v", sub.rate, err)
+	}
+	if _, err := NewFeed(json.RawMessage(`{"rate": -2}`)); err == nil {
+		t.Fatal("NewFeed with rate -2 succeeded")
+	}
+}
+
+// TestFeedSources checks the frames and sessions of two sources are kept
+// apart: events are derived from the frames of the same source only.
+func TestFeedSources(t *testing.T) {
+	s, err := NewFeed(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := s.defaults.with(feedRequest{Channels: []string{"speed"}, Rate: new(float64)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1500000000, 0)
+	sessions := map[string]f1.Session{
+		"a": {ID: "a-1", Source: "a", Start: start},
+		"b": {ID: "b-1", Source: "b", Start: start},
+	}
+	for _, source := range []string{"a", "b"} {
+		s.Event(f1.SessionEvent{Type: f1.SessionStart, Session: sessions[source]})
+	}
+	// Both sessions are in progress for clients connecting now.
+	c := s.connect(sub)
+	ms := messages(t, c)
+	if len(ms) != 3 || ms[0]["type"] != "subscribed" || ms[1]["type"] != "session-start" || ms[2]["type"] != "session-start" ||
+		ms[1]["source"] == ms[2]["source"] {
+		t.Fatalf("messages on connecting %v, want both sessions", ms)
+	}
+
+	frame := func(source string, lap int) f1.Frame {
+		frame := f1.Frame{Source: source, Session: sessions[source].ID, Received: start.Add(time.Duration(lap) * time.Minute)}
+		frame.Lap = float32(lap)
+		frame.LastLapTime = 80 + float32(lap)
+		if source == "b" {
+			frame.Lap += 10
+		}
+		return frame
+	}
+	// Interleaved, each source completes a lap.
+	for _, f := range []f1.Frame{frame("a", 0), frame("b", 0), frame("a", 1), frame("b", 1)} {
+		if err := s.Frame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var laps []string
+	frames := map[string]int{}
+	for _, m := range messages(t, c) {
+		switch m["type"] {
+		case "lap":
+			laps = append(laps, m["source"].(string))
+			if m["session"] != sessions[m["source"].(string)].ID {
+				t.Errorf("lap %v of the session of another source", m)
+			}
+		case "frame":
+			frames[m["source"].(string)]++
+		default:
+			t.Errorf("unexpected message %v", m)
+		}
+	}
+	if len(laps) != 2 || laps[0] != "a" || laps[1] != "b" {
+		t.Errorf("laps of sources %q, want a then b", laps)
+	}
+	if frames["a"] != 2 || frames["b"] != 2 {
+		t.Errorf("frames of sources %v, want 2 of each", frames)
+	}
+
+	// Ending the session of a source leaves the other in progress.
+	end := sessions["a"]
+	end.End = start.Add(time.Hour)
+	s.Event(f1.SessionEvent{Type: f1.SessionEnd, Session: end})
+	ms = messages(t, s.connect(sub))
+	if len(ms) != 2 || ms[1]["type"] != "session-start" || ms[1]["source"] != "b" {
+		t.Fatalf("messages on connecting %v, want the session of b", ms)
+	}
+}
@@ -0,0 +1,524 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	client "github.com/influxdata/influxdb/client/v2"
+	"github.com/luan/f1-telemetry/f1"
+)
+
+const (
+	// DefaultInfluxAddr is the address of InfluxDB by default.
+	DefaultInfluxAddr = "http://localhost:8086"
+	// DefaultInfluxDatabase is the database written to by default.
+	DefaultInfluxDatabase = "f1telemetry"
+	// DefaultInfluxBatch is how many points are written at once by default,
+	// unless the sink is flushed before.
+	DefaultInfluxBatch = 5000
+	// DefaultInfluxPending is how many points may wait to be written while
+	// InfluxDB is unreachable by default, about five minutes of a race.
+	DefaultInfluxPending = 1000000
+	// DefaultInfluxTimeout is how long a write may take by default.
+	DefaultInfluxTimeout = 5 * time.Second
+	// DefaultInfluxSpoolSize is how many bytes of line protocol the spool
+	// holds by default, a few hours of racing.
+	DefaultInfluxSpoolSize = 1 << 30
+
+	// InfluxBackoff is how long to wait before retrying a failed write. It
+	// doubles with every failure in a row, up to InfluxMaxBackoff.
+	InfluxBackoff    = 500 * time.Millisecond
+	InfluxMaxBackoff = time.Minute
+
+	// influxPrecision is the precision of the times of the points.
+	influxPrecision = "us"
+)
+
+func init() {
+	Register("influx", NewInflux)
+}
+
+// InfluxOptions configures an Influx sink.
+type InfluxOptions struct {
+	Addr string `json:"addr"`
+	// Version is the write API to use: 1 for InfluxDB 1.x, 2 for 2.x and
+	// 3.x, which also takes 1.x writes.
+	Version int `json:"version"`
+
+	// Written to with version 1, with basic auth if Username is set.
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retention-policy"` // default if empty
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+
+	// Written to with version 2. Bucket defaults to Database.
+	Org    string `json:"org"`
+	Bucket string `json:"bucket"`
+	Token  string `json:"token"`
+
+	// Create creates the database and retention policy, or the bucket, if
+	// missing, keeping the data for Retention, forever if 0. Creating a
+	// bucket takes the Org to create it in.
+	Create    bool     `json:"create"`
+	Retention Duration `json:"retention"`
+
+	Batch   int      `json:"batch"`   // most points written at once
+	Pending int      `json:"pending"` // most points waiting to be written
+	Timeout Duration `json:"timeout"` // of a write
+	// Spool is a directory to keep the points that couldn't be written in,
+	// if set. SpoolSize caps its size in bytes.
+	Spool     string `json:"spool"`
+	SpoolSize int64  `json:"spool-size"`
+}
+
+// An Influx sink writes the telemetry of the player, the cars and the
+// session events to InfluxDB, as the points "telemetry", "car" and "session".
+//
+// Points are timed by the game time of their frame, from the start of their
+// session, so a session's points are evenly spaced and a flashback writes
+// over the points it rewound. They are tagged with:
+//
+//	source        listener or file the frame came from
+//	session       ID of the session, see f1.Session
+//	track         name of the track, e.g. "Monza", or its number if unknown
+//	session-type  unknown, practice, qualifying or race
+//
+// "telemetry" points are the player's car, tagged driver=self and with the
+// lap the player is on, counting from 1. Their fields are those of
+// f1.TelemetryData but the cars, in lower case with dashes, e.g.
+// "fuel-in-tank"; "game-time" is the game time, as InfluxDB rejects fields
+// called "time", "laps-completed" is Lap, the laps completed, and
+// "session-type-number" is the number of the session type tagged. Wheel
+// arrays are expanded into a field per wheel, suffixed _rl, _rr, _fl and _fr,
+// e.g. "tyres-wear_fl". The header based formats add the f1.Conditions:
+// weather, track-temperature, air-temperature and safety-car-status.
+//
+// "car" points are the cars in the race, tagged with the driver and team
+// IDs, the index of the car as "car", and the lap the car is on. Their fields
+// are those of f1.CarData, with the world position as x, y and z, and the
+// name of the driver as "name" if sent.
+//
+// "session" points mark the start and end of a session, with the fields
+// event, "start" or "end", format, track-number, era and total-laps.
+//
+// Points are written in batches, when a batch is full or the sink is flushed.
+// Failed writes are retried with exponential back-off, keeping the batches
+// that failed, and the points added meanwhile, up to the pending option;
+// beyond that the oldest ones are dropped.
+//
+// The database or bucket is created, with the create option, when the sink is
+// opened, or before the next write if InfluxDB is unreachable then, or if a
+// write finds it missing. Failing to create it doesn't hold the writes back:
+// the user may be allowed to write to it but not to create it.
+//
+// With the spool option, they are written to the spool instead, as line
+// protocol, and the spool is drained first once InfluxDB is back, so the
+// points are written in order. Points left in the spool when the sink is
+// closed are written the next time it is opened. Beyond the spool size the
+// oldest points are dropped.
+type Influx struct {
+	// Accessed atomically, first in the struct to be 64-bit aligned.
+	written uint64 // points
+	failed  uint64 // writes
+	dropped uint64 // points
+	retry   int64  // unix nanoseconds of the next attempt, 0 if not failing
+	spooled int64  // points in spool
+	spoolB  int64  // bytes in spool
+
+	options InfluxOptions
+	http    http.Client
+	created bool   // whether the database or bucket was created, or tried to
+	spool   *spool // nil if not spooling
+	clock   sessionClock
+	batch   client.BatchPoints   // being filled, nil if empty
+	queue   []client.BatchPoints // waiting to be written, oldest first
+	queued  int                  // points in queue
+	backoff time.Duration
+
+	mu  sync.Mutex // guards err, read by Status
+	err error      // of the last failure
+}
+
+// NewInflux creates an Influx sink from its options.
+func NewInflux(options json.RawMessage) (Sink, error) {
+	s := &Influx{options: InfluxOptions{
+		Addr:     DefaultInfluxAddr,
+		Version:  1,
+		Database: DefaultInfluxDatabase,
+		Create:   true,
+		Batch:    DefaultInfluxBatch,
+		Pending:  DefaultInfluxPending,
+		Timeout:  Duration(DefaultInfluxTimeout),
+
+		SpoolSize: DefaultInfluxSpoolSize,
+	}}
+	if err := decodeOptions(options, &s.options); err != nil {
+		return nil, err
+	}
+	if s.options.Batch < 1 || s.options.Pending < s.options.Batch {
+		return nil, errors.New("want 0 < batch <= pending")
+	}
+	switch s.options.Version {
+	case 1:
+	case 2:
+		if s.options.Bucket == "" {
+			s.options.Bucket = s.options.Database
+		}
+		if s.options.Create && s.options.Org == "" {
+			return nil, errors.New("want an org to create the bucket in, or create false")
+		}
+	default:
+		return nil, fmt.Errorf("unknown version %d, want 1 or 2", s.options.Version)
+	}
+	return s, nil
+}
+
+// Open opens the spool, if any, and creates the database or bucket if
+// missing. Failing to isn't an error: it is tried again before the first
+// write, whose Flush returns the error.
+func (s *Influx) Open() error {
+	s.http.Timeout = time.Duration(s.options.Timeout)
+	if s.options.Spool != "" {
+		var err error
+		s.spool, err = openSpool(s.options.Spool, s.options.SpoolSize)
+		if err != nil {
+			return err
+		}
+		s.updateSpool()
+	}
+
+	s.created = !s.options.Create
+	if !s.created {
+		if err := s.create(); err != nil {
+			s.setErr(err)
+		} else {
+			s.created = true
+		}
+	}
+	return nil
+}
+
+// Frame adds the points of frame to the batch written by Flush: one for the
+// player and one for each car.
+func (s *Influx) Frame(frame f1.Frame) error {
+	data := frame.TelemetryData
+	t := s.clock.time(frame)
+
+	tags := s.tags(frame)
+	tags["driver"] = "self"
+	tags["lap"] = strconv.Itoa(frame.PlayerLap())
+	fields := make(map[string]interface{}, len(telemetryChannels)+4)
+	for _, c := range telemetryChannels {
+		fields[c.name] = c.value(&data)
+	}
+	if frame.Format.Fields().Has(f1.FieldConditions) {
+		fields["weather"] = frame.Weather
+		fields["track-temperature"] = frame.TrackTemperature
+		fields["air-temperature"] = frame.AirTemperature
+		fields["safety-car-status"] = frame.SafetyCarStatus
+	}
+
+	if err := s.add("telemetry", tags, fields, t); err != nil {
+		return err
+	}
+
+	for i, car := range data.Cars {
+		if car.CarPosition == 0 {
+			// Not racing, or no such car.
+			continue
+		}
+		tags := s.tags(frame)
+		tags["driver"] = strconv.Itoa(int(car.DriverID))
+		tags["team"] = strconv.Itoa(int(car.TeamID))
+		tags["car"] = strconv.Itoa(i)
+		tags["lap"] = strconv.Itoa(int(car.CurrentLapNum))
+		fields := make(map[string]interface{}, len(carChannels))
+		for _, c := range carChannels {
+			fields[c.name] = c.value(&car)
+		}
+		if frame.Names[i] != "" {
+			fields["name"] = frame.Names[i]
+		}
+
+		if err := s.add("car", tags, fields, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tags returns the tags shared by the points of frame.
+func (s *Influx) tags(frame f1.Frame) map[string]string {
+	return map[string]string{
+		"source":       frame.Source,
+		"session":      frame.Session,
+		"track":        f1.TrackName(frame.TrackNumber),
+		"session-type": f1.SessionTypeName(frame.SessionType),
+	}
+}
+
+// Event adds a point marking the start or end of a session.
+func (s *Influx) Event(event f1.SessionEvent) error {
+	session := event.Session
+	t := session.Start
+	if event.Type == f1.SessionEnd {
+		t = session.End
+	}
+	tags := map[string]string{
+		"source":       session.Source,
+		"session":      session.ID,
+		"track":        f1.TrackName(session.TrackNumber),
+		"session-type": f1.SessionTypeName(session.SessionType),
+	}
+	if event.Type == f1.SessionEnd {
+		s.clock.end(session.ID)
+	}
+	fields := map[string]interface{}{
+		"event":        event.Type.String(),
+		"format":       session.Format.String(),
+		"track-number": session.TrackNumber,
+		"era":          session.Era,
+		"total-laps":   session.TotalLaps,
+	}
+	pt, err := client.NewPoint("session", tags, fields, t)
+	if err != nil {
+		return err
+	}
+	return s.addPoint(pt)
+}
+
+func (s *Influx) add(name string, tags map[string]string, fields map[string]interface{}, t time.Time) error {
+	pt, err := client.NewPoint(name, tags, fields, t)
+	if err != nil {
+		return err
+	}
+	return s.addPoint(pt)
+}
+
+func (s *Influx) addPoint(pt *client.Point) error {
+	if s.batch == nil {
+		bp, err := client.NewBatchPoints(client.BatchPointsConfig{
+			Database:  s.options.Database,
+			Precision: influxPrecision,
+		})
+		if err != nil {
+			return err
+		}
+		s.batch = bp
+	}
+	s.batch.AddPoint(pt)
+	if len(s.batch.Points()) >= s.options.Batch {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush writes the points added so far, unless backing off after a failed
+// write, in which case they go to the spool, if any. Failed writes are
+// counted in Status rather than returned; failing to create the database or
+// bucket is returned, the points being written regardless.
+func (s *Influx) Flush() error {
+	s.enqueue()
+	if retry := atomic.LoadInt64(&s.retry); retry != 0 && time.Now().UnixNano() < retry {
+		s.spill()
+		return nil
+	}
+	return s.write()
+}
+
+// enqueue queues the batch being filled, dropping the oldest points queued
+// if there are too many.
+func (s *Influx) enqueue() {
+	if s.batch == nil {
+		return
+	}
+	s.queue = append(s.queue, s.batch)
+	s.queued += len(s.batch.Points())
+	s.batch = nil
+
+	for s.queued > s.options.Pending {
+		n := len(s.queue[0].Points())
+		atomic.AddUint64(&s.dropped, uint64(n))
+		s.shift(n)
+	}
+}
+
+// shift removes the oldest batch queued, of n points.
+func (s *Influx) shift(n int) {
+	s.queued -= n
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+}
+
+// write creates the database or bucket if needed, and writes the spool, if
+// any, then the queued batches, oldest first, stopping at the first failure
+// to back off. Batches InfluxDB rejects are dropped. It returns the error
+// creating the database or bucket, if any.
+func (s *Influx) write() error {
+	var err error
+	if !s.created {
+		// Tried once, and again if a write finds it missing.
+		s.created = true
+		if err = s.create(); err != nil {
+			s.setErr(err)
+		}
+	}
+	if s.spool != nil && !s.drain() {
+		s.spill()
+		return err
+	}
+	for len(s.queue) > 0 {
+		bp := s.queue[0]
+		n := len(bp.Points())
+		switch werr := s.writeLines(encode(bp)); {
+		case werr == errRejected:
+			s.setErr(werr)
+			atomic.AddUint64(&s.dropped, uint64(n))
+		case werr != nil:
+			s.fail(werr)
+			s.spill()
+			return err
+		default:
+			atomic.AddUint64(&s.written, uint64(n))
+		}
+		s.shift(n)
+	}
+	s.backoff = 0
+	atomic.StoreInt64(&s.retry, 0)
+	return err
+}
+
+// fail counts a failed write, and backs off before the next one. The
+// database or bucket is created again first if the write found it missing.
+func (s *Influx) fail(err error) {
+	atomic.AddUint64(&s.failed, 1)
+	s.setErr(err)
+	if _, ok := err.(missingError); ok && s.options.Create {
+		s.created = false
+	}
+	s.backoff *= 2
+	if s.backoff < InfluxBackoff {
+		s.backoff = InfluxBackoff
+	}
+	if s.backoff > InfluxMaxBackoff {
+		s.backoff = InfluxMaxBackoff
+	}
+	atomic.StoreInt64(&s.retry, time.Now().Add(s.backoff).UnixNano())
+}
+
+// spill moves the queued batches to the spool, if any.
+func (s *Influx) spill() {
+	if s.spool == nil {
+		return
+	}
+	defer s.updateSpool()
+
+	for len(s.queue) > 0 {
+		bp := s.queue[0]
+		n := len(bp.Points())
+		evicted, err := s.spool.push(encode(bp), n)
+		atomic.AddUint64(&s.dropped, uint64(evicted))
+		if err != nil {
+			// Keep the batch in memory, and try again later.
+			s.setErr(err)
+			return
+		}
+		s.shift(n)
+	}
+}
+
+// drain writes the spool, oldest segment first. It reports whether the spool
+// was emptied.
+func (s *Influx) drain() bool {
+	defer s.updateSpool()
+
+	for !s.spool.empty() {
+		lines, n, err := s.spool.peek()
+		if err == nil {
+			err = s.writeLines(lines)
+			if err != nil && err != errRejected {
+				s.fail(err)
+				return false
+			}
+		}
+		if err != nil {
+			// Unreadable, or never going to be written.
+			s.setErr(err)
+			atomic.AddUint64(&s.dropped, uint64(n))
+		} else {
+			atomic.AddUint64(&s.written, uint64(n))
+		}
+		if err := s.spool.pop(); err != nil {
+			s.setErr(err)
+			return false
+		}
+	}
+	return true
+}
+
+// encode returns the points of bp in line protocol.
+func encode(bp client.BatchPoints) []byte {
+	var lines bytes.Buffer
+	for _, pt := range bp.Points() {
+		lines.WriteString(pt.PrecisionString(bp.Precision()))
+		lines.WriteByte('\n')
+	}
+	return lines.Bytes()
+}
+
+func (s *Influx) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (s *Influx) lastErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Influx) updateSpool() {
+	atomic.StoreInt64(&s.spooled, int64(s.spool.points))
+	atomic.StoreInt64(&s.spoolB, s.spool.size)
+}
+
+// Close makes a last attempt at writing the points left, back-off or not,
+// spooling what can't be written.
+func (s *Influx) Close() error {
+	s.enqueue()
+	err := s.write()
+	if s.queued > 0 {
+		atomic.AddUint64(&s.dropped, uint64(s.queued))
+		return fmt.Errorf("%d points not written: %v", s.queued, s.lastErr())
+	}
+	return err
+}
+
+// Status counts the points written and dropped, and the failed writes, with
+// the last error.
+func (s *Influx) Status() string {
+	status := fmt.Sprintf("%d written, %d dropped, %d failed",
+		atomic.LoadUint64(&s.written), atomic.LoadUint64(&s.dropped), atomic.LoadUint64(&s.failed))
+	if retry := atomic.LoadInt64(&s.retry); retry != 0 {
+		wait := time.Until(time.Unix(0, retry))
+		if wait < 0 {
+			wait = 0
+		}
+		status += fmt.Sprintf(", retry in %.1fs", wait.Seconds())
+	}
+	if spooled := atomic.LoadInt64(&s.spooled); spooled > 0 {
+		status += fmt.Sprintf(", %d spooled (%.1f MiB)", spooled, float64(atomic.LoadInt64(&s.spoolB))/(1<<20))
+	}
+	if err := s.lastErr(); err != nil {
+		status += fmt.Sprintf(", last error: %v", err)
+	}
+	return status
+}
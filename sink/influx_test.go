@@ -0,0 +1,489 @@
+package sink
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// A fakeInflux is an InfluxDB server recording the requests it receives and
+// the lines written to it. It has the 1.x query API and the 2.x buckets API,
+// unless v3 is set.
+type fakeInflux struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int // of every request, as usual if 0
+	v3       bool
+	missing  bool            // whether writes to databases not created fail
+	readOnly bool            // whether creating databases and buckets is forbidden
+	dbs      map[string]bool // databases created
+	requests []fakeRequest
+	lines    []string        // written, in order
+	queries  []string        // InfluxQL statements run, in order
+	policies map[string]bool // retention policies created
+	buckets  []fakeBucket    // created, with the ID of their org
+}
+
+type fakeBucket struct {
+	OrgID          string `json:"orgID"`
+	Name           string `json:"name"`
+	RetentionRules []struct {
+		Type         string `json:"type"`
+		EverySeconds int64  `json:"everySeconds"`
+	} `json:"retentionRules"`
+}
+
+type fakeRequest struct {
+	Method, Path string
+	Query        url.Values
+	Header       http.Header
+	Body         string
+}
+
+func newFakeInflux() *fakeInflux {
+	f := &fakeInflux{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeInflux) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, fakeRequest{r.Method, r.URL.Path, r.URL.Query(), r.Header, string(body)})
+
+	if f.status != 0 && f.status/100 != 2 {
+		w.WriteHeader(f.status)
+		w.Write([]byte(`{"error":"unavailable"}`))
+		return
+	}
+	switch {
+	case r.URL.Path == "/write" && f.missing && !f.dbs[r.URL.Query().Get("db")]:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"database not found: \"` + r.URL.Query().Get("db") + `\""}`))
+	case r.URL.Path == "/write" || r.URL.Path == "/api/v2/write":
+		f.lines = append(f.lines, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/query" && r.Method == "POST":
+		form, _ := url.ParseQuery(string(body))
+		q := form.Get("q")
+		f.queries = append(f.queries, q)
+		if f.readOnly {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"requires admin privilege"}`))
+			return
+		}
+		if strings.HasPrefix(q, "CREATE DATABASE ") {
+			if f.dbs == nil {
+				f.dbs = map[string]bool{}
+			}
+			f.dbs[strings.Trim(strings.Fields(q)[2], `"`)] = true
+		}
+		if strings.HasPrefix(q, "CREATE RETENTION POLICY ") {
+			name := strings.Fields(q)[3]
+			if f.policies[name] {
+				w.Write([]byte(`{"results":[{"statement_id":0,"error":"retention policy already exists"}]}`))
+				return
+			}
+			if f.policies == nil {
+				f.policies = map[string]bool{}
+			}
+			f.policies[name] = true
+		}
+		w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+	case f.v3 && strings.HasPrefix(r.URL.Path, "/api/v2/") && r.URL.Path != "/api/v2/write":
+		http.NotFound(w, r)
+	case r.URL.Path == "/api/v2/buckets" && r.Method == "GET":
+		var found []fakeBucket
+		for _, b := range f.buckets {
+			if b.Name == r.URL.Query().Get("name") {
+				found = append(found, b)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"buckets": found})
+	case r.URL.Path == "/api/v2/buckets" && r.Method == "POST":
+		var b fakeBucket
+		if err := json.Unmarshal(body, &b); err != nil || b.OrgID != "0123" {
+			http.Error(w, `{"message":"bad bucket"}`, http.StatusBadRequest)
+			return
+		}
+		f.buckets = append(f.buckets, b)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	case r.URL.Path == "/api/v2/orgs" && r.Method == "GET":
+		if org := r.URL.Query().Get("org"); org != "team" {
+			w.Write([]byte(`{"orgs":[]}`))
+			return
+		}
+		w.Write([]byte(`{"orgs":[{"id":"0123","name":"team"}]}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeInflux) setStatus(status int) {
+	f.mu.Lock()
+	f.status = status
+	f.mu.Unlock()
+}
+
+// writes returns the number of write requests received.
+func (f *fakeInflux) writes() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if strings.HasSuffix(r.Path, "/write") {
+			n++
+		}
+	}
+	return n
+}
+
+// written returns the game time, in seconds, of the points written, in
+// order.
+func (f *fakeInflux) written() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var times []int
+	for _, line := range f.lines {
+		us, _ := strconv.ParseInt(line[strings.LastIndexByte(line, ' ')+1:], 10, 64)
+		times = append(times, int(time.Unix(0, us*1000).Sub(testStart)/time.Second))
+	}
+	return times
+}
+
+var testStart = time.Unix(1500000000, 0)
+
+// testFrame returns a frame of the session "s1" at game time t seconds, with
+// the player alone on track unless cars are racing too.
+func testFrame(t int, cars int) f1.Frame {
+	frame := f1.Frame{Source: "test", Session: "s1", Received: testStart.Add(time.Duration(t) * time.Second)}
+	frame.Time = float32(t)
+	for i := 0; i < cars; i++ {
+		frame.Cars[i].CarPosition = uint8(i + 1)
+	}
+	return frame
+}
+
+func newTestInflux(t *testing.T, options string) *Influx {
+	s, err := NewInflux(json.RawMessage(options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	return s.(*Influx)
+}
+
+// elapse ends the back-off of s.
+func elapse(s *Influx) {
+	if atomic.LoadInt64(&s.retry) != 0 {
+		atomic.StoreInt64(&s.retry, time.Now().Add(-time.Millisecond).UnixNano())
+	}
+}
+
+func addFrames(t *testing.T, s *Influx, from, to int) {
+	for i := from; i < to; i++ {
+		if err := s.Frame(testFrame(i, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkWritten(t *testing.T, f *fakeInflux, want ...int) {
+	got := f.written()
+	if len(got) != len(want) {
+		t.Fatalf("written points at %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("written points at %v, want %v", got, want)
+		}
+	}
+}
+
+func checkStatus(t *testing.T, s *Influx, want string) {
+	if status := s.Status(); !strings.HasPrefix(status, want) {
+		t.Fatalf("Status = %q, want %q", status, want)
+	}
+}
+
+func TestInfluxBatch(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false, "batch": 3}`)
+
+	addFrames(t, s, 0, 2)
+	if n := f.writes(); n != 0 {
+		t.Fatalf("%d writes before the batch is full", n)
+	}
+	addFrames(t, s, 2, 4)
+	if n := f.writes(); n != 1 {
+		t.Fatalf("%d writes of a full batch, want 1", n)
+	}
+	checkWritten(t, f, 0, 1, 2)
+
+	// The player and two cars fill the next batch.
+	if err := s.Frame(testFrame(4, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.writes(); n != 2 {
+		t.Fatalf("%d writes, want 2", n)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkWritten(t, f, 0, 1, 2, 3, 4, 4, 4)
+	checkStatus(t, s, "7 written, 0 dropped, 0 failed")
+	if n := f.writes(); n != 3 {
+		t.Fatalf("%d writes, want 3", n)
+	}
+	if err := s.Flush(); err != nil || f.writes() != 3 {
+		t.Fatalf("Flush of no points = %v, with %d writes, want 3", err, f.writes())
+	}
+}
+
+func TestInfluxRetry(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	f.setStatus(http.StatusServiceUnavailable)
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false}`)
+
+	addFrames(t, s, 0, 1)
+	s.Flush()
+	if f.writes() != 1 || s.backoff != InfluxBackoff {
+		t.Fatalf("%d writes, back-off %v, want 1 and %v", f.writes(), s.backoff, InfluxBackoff)
+	}
+	checkStatus(t, s, "0 written, 0 dropped, 1 failed, retry in")
+
+	// Not retried while backing off.
+	addFrames(t, s, 1, 2)
+	s.Flush()
+	if f.writes() != 1 {
+		t.Fatalf("%d writes while backing off, want 1", f.writes())
+	}
+
+	// The back-off doubles with every failure, up to InfluxMaxBackoff.
+	want := InfluxBackoff
+	for i := 0; i < 10; i++ {
+		elapse(s)
+		s.Flush()
+		want *= 2
+		if want > InfluxMaxBackoff {
+			want = InfluxMaxBackoff
+		}
+		if s.backoff != want {
+			t.Fatalf("back-off %v after %d failures, want %v", s.backoff, i+2, want)
+		}
+	}
+	checkStatus(t, s, "0 written, 0 dropped, 11 failed")
+
+	f.setStatus(0)
+	addFrames(t, s, 2, 3)
+	elapse(s)
+	s.Flush()
+	checkWritten(t, f, 0, 1, 2)
+	if s.Status() != "3 written, 0 dropped, 11 failed, last error: influx: 503 Service Unavailable: unavailable" || s.backoff != 0 {
+		t.Fatalf("Status = %q with back-off %v after a write, want no retry", s.Status(), s.backoff)
+	}
+}
+
+func TestInfluxPending(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	f.setStatus(http.StatusInternalServerError)
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false, "batch": 1, "pending": 2}`)
+
+	addFrames(t, s, 0, 5)
+	checkStatus(t, s, "0 written, 3 dropped, 1 failed")
+
+	f.setStatus(0)
+	elapse(s)
+	s.Flush()
+	checkWritten(t, f, 3, 4)
+	checkStatus(t, s, "2 written, 3 dropped, 1 failed")
+}
+
+func TestInfluxRejected(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	f.setStatus(http.StatusBadRequest)
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false}`)
+
+	addFrames(t, s, 0, 2)
+	s.Flush()
+	// Dropped rather than retried.
+	if s.Status() != "0 written, 2 dropped, 0 failed, last error: "+errRejected.Error() || s.err != errRejected {
+		t.Fatalf("Status = %q, error %v after a rejected write", s.Status(), s.err)
+	}
+	f.setStatus(0)
+	addFrames(t, s, 2, 3)
+	s.Flush()
+	checkWritten(t, f, 2)
+}
+
+func TestInfluxSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := newFakeInflux()
+	defer f.Close()
+	f.setStatus(http.StatusServiceUnavailable)
+	options := `{"addr": "` + f.URL + `", "create": false, "spool": "` + dir + `"}`
+	s := newTestInflux(t, options)
+
+	addFrames(t, s, 0, 2)
+	s.Flush()
+	addFrames(t, s, 2, 3)
+	s.Flush() // backing off
+	if s.queued != 0 {
+		t.Fatalf("%d points left in memory, want all spooled", s.queued)
+	}
+	checkStatus(t, s, "0 written, 0 dropped, 1 failed, retry in")
+	if !strings.Contains(s.Status(), ", 3 spooled") {
+		t.Fatalf("Status = %q, want 3 spooled", s.Status())
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	segments, _ := ioutil.ReadDir(dir)
+	if len(segments) != 2 {
+		t.Fatalf("%d segments in the spool, want 2", len(segments))
+	}
+
+	// The spool is written first the next time.
+	f.setStatus(0)
+	s = newTestInflux(t, options)
+	if !strings.Contains(s.Status(), ", 3 spooled") {
+		t.Fatalf("Status = %q after opening, want 3 spooled", s.Status())
+	}
+	addFrames(t, s, 3, 4)
+	s.Flush()
+	checkWritten(t, f, 0, 1, 2, 3)
+	if s.Status() != "4 written, 0 dropped, 0 failed" {
+		t.Fatalf("Status = %q, want the spool empty", s.Status())
+	}
+	if segments, _ := ioutil.ReadDir(dir); len(segments) != 0 {
+		t.Fatalf("%d segments left in the spool", len(segments))
+	}
+}
+
+func TestInfluxSpoolSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := newFakeInflux()
+	defer f.Close()
+	f.setStatus(http.StatusServiceUnavailable)
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false, "spool": "`+dir+`", "spool-size": 8000}`)
+
+	// Each batch takes about 1800 bytes, so the oldest are evicted.
+	for i := 0; i < 10; i++ {
+		addFrames(t, s, i, i+1)
+		s.Flush()
+	}
+	spooled := int(atomic.LoadInt64(&s.spooled))
+	if spooled == 0 || spooled == 10 || int(atomic.LoadUint64(&s.dropped))+spooled != 10 {
+		t.Fatalf("Status = %q, want the oldest points dropped", s.Status())
+	}
+	if size := atomic.LoadInt64(&s.spoolB); size > 8000 {
+		t.Fatalf("spool of %d bytes, want at most 8000", size)
+	}
+
+	f.setStatus(0)
+	elapse(s)
+	s.Flush()
+	got := f.written()
+	if len(got) != spooled || got[len(got)-1] != 9 {
+		t.Fatalf("written points at %v, want the last %d", got, spooled)
+	}
+}
+
+// TestInfluxPointKeys checks no field of a point is named as one of its tags.
+func TestInfluxPointKeys(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false}`)
+	if err := s.Frame(testFrame(0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	s.Flush()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.lines) != 3 {
+		t.Fatalf("%d points written, want 3", len(f.lines))
+	}
+	for _, line := range f.lines {
+		parts := strings.Split(line, " ")
+		tags := map[string]bool{}
+		for _, tag := range strings.Split(parts[0], ",")[1:] {
+			tags[tag[:strings.IndexByte(tag, '=')]] = true
+		}
+		if !tags["lap"] || !tags["session-type"] {
+			t.Fatalf("point %s, want lap and session-type tags", parts[0])
+		}
+		for _, field := range strings.Split(parts[1], ",") {
+			if key := field[:strings.IndexByte(field, '=')]; tags[key] {
+				t.Errorf("%s is both a tag and a field of %s", key, parts[0])
+			}
+		}
+	}
+}
+
+// TestInfluxConditions checks the conditions and names sent by the header
+// based formats are written.
+func TestInfluxConditions(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false}`)
+	frame := testFrame(0, 1)
+	if err := s.Frame(frame); err != nil {
+		t.Fatal(err)
+	}
+	frame = testFrame(1, 1)
+	frame.Format = f1.Format{PacketFormat: 2018}
+	frame.Weather, frame.TrackTemperature, frame.SafetyCarStatus = 3, 32, 2
+	frame.Names[0] = "Luan"
+	if err := s.Frame(frame); err != nil {
+		t.Fatal(err)
+	}
+	s.Flush()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.lines) != 4 {
+		t.Fatalf("%d points written, want 4", len(f.lines))
+	}
+	if strings.Contains(f.lines[0], "weather=") || strings.Contains(f.lines[1], "name=") {
+		t.Errorf("conditions written for a format without them: %s", f.lines[:2])
+	}
+	for _, want := range []string{"weather=3i", "track-temperature=32i", "safety-car-status=2i"} {
+		if !strings.Contains(f.lines[2], want) {
+			t.Errorf("telemetry point %s, want %s", f.lines[2], want)
+		}
+	}
+	if !strings.Contains(f.lines[3], `name="Luan"`) {
+		t.Errorf("car point %s, want the name of the driver", f.lines[3])
+	}
+}
@@ -0,0 +1,225 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errRejected = errors.New("points rejected by InfluxDB")
+
+// missingError is the error of a write to a database or bucket that doesn't
+// exist.
+type missingError struct{ error }
+
+// writeLines writes points in line protocol with the write API of the
+// configured version. It returns errRejected if InfluxDB can't parse them,
+// and a missingError if the database or bucket doesn't exist.
+func (s *Influx) writeLines(lines []byte) error {
+	path := "/write"
+	query := url.Values{"db": {s.options.Database}, "precision": {influxPrecision}}
+	if s.options.RetentionPolicy != "" {
+		query.Set("rp", s.options.RetentionPolicy)
+	}
+	if s.options.Version == 2 {
+		path = "/api/v2/write"
+		query = url.Values{"bucket": {s.options.Bucket}, "precision": {influxPrecision}}
+		if s.options.Org != "" {
+			query.Set("org", s.options.Org)
+		}
+	}
+
+	resp, err := s.request("POST", path+"?"+query.Encode(), "text/plain; charset=utf-8", bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2:
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	case resp.StatusCode == http.StatusBadRequest:
+		return errRejected
+	case resp.StatusCode == http.StatusNotFound:
+		return missingError{responseError(resp)}
+	}
+	return responseError(resp)
+}
+
+// create creates the database and retention policy, or the bucket, if
+// missing.
+func (s *Influx) create() error {
+	if s.options.Version == 2 {
+		return s.createBucket()
+	}
+
+	if err := s.query("CREATE DATABASE " + quoteIdent(s.options.Database)); err != nil {
+		return err
+	}
+	if s.options.RetentionPolicy == "" {
+		return nil
+	}
+	duration := "INF"
+	if s.options.Retention > 0 {
+		duration = fmt.Sprintf("%ds", time.Duration(s.options.Retention)/time.Second)
+	}
+	err := s.query(fmt.Sprintf("CREATE RETENTION POLICY %s ON %s DURATION %s REPLICATION 1",
+		quoteIdent(s.options.RetentionPolicy), quoteIdent(s.options.Database), duration))
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		// Left as it is if changed since.
+		return nil
+	}
+	return err
+}
+
+// query runs an InfluxQL statement with the 1.x query API.
+func (s *Influx) query(q string) error {
+	form := url.Values{"q": {q}}
+	resp, err := s.request("POST", "/query", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	var result struct {
+		Error   string `json:"error"`
+		Results []struct {
+			Error string `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	for _, r := range result.Results {
+		if r.Error != "" {
+			return fmt.Errorf("influx: %s", r.Error)
+		}
+	}
+	if result.Error != "" {
+		return fmt.Errorf("influx: %s", result.Error)
+	}
+	return nil
+}
+
+// createBucket creates the bucket in the org with the 2.x API if missing.
+// InfluxDB 3.x has no such API, and creates databases as they are written to.
+func (s *Influx) createBucket() error {
+	var buckets struct {
+		Buckets []struct{} `json:"buckets"`
+	}
+	query := url.Values{"name": {s.options.Bucket}, "org": {s.options.Org}}
+	switch status, err := s.getJSON("/api/v2/buckets?"+query.Encode(), &buckets); {
+	case status == http.StatusNotFound:
+		return nil
+	case err != nil:
+		return err
+	case len(buckets.Buckets) > 0:
+		return nil
+	}
+
+	var orgs struct {
+		Orgs []struct {
+			ID string `json:"id"`
+		} `json:"orgs"`
+	}
+	if _, err := s.getJSON("/api/v2/orgs?"+url.Values{"org": {s.options.Org}}.Encode(), &orgs); err != nil {
+		return err
+	}
+	if len(orgs.Orgs) == 0 {
+		return fmt.Errorf("influx: no org %q", s.options.Org)
+	}
+
+	type rule struct {
+		Type         string `json:"type"`
+		EverySeconds int64  `json:"everySeconds"`
+	}
+	bucket := struct {
+		OrgID          string `json:"orgID"`
+		Name           string `json:"name"`
+		RetentionRules []rule `json:"retentionRules"`
+	}{OrgID: orgs.Orgs[0].ID, Name: s.options.Bucket, RetentionRules: []rule{}}
+	if s.options.Retention > 0 {
+		every := int64(time.Duration(s.options.Retention) / time.Second)
+		bucket.RetentionRules = append(bucket.RetentionRules, rule{Type: "expire", EverySeconds: every})
+	}
+	b, err := json.Marshal(bucket)
+	if err != nil {
+		return err
+	}
+	resp, err := s.request("POST", "/api/v2/buckets", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusUnprocessableEntity {
+		// 422 if created meanwhile.
+		return responseError(resp)
+	}
+	return nil
+}
+
+// getJSON gets path and decodes the response into v if successful, returning
+// the status code.
+func (s *Influx) getJSON(path string, v interface{}) (int, error) {
+	resp, err := s.request("GET", path, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, responseError(resp)
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// request sends a request to InfluxDB, authenticated with the token with
+// version 2, or the user name and password with version 1.
+func (s *Influx) request(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.options.Addr+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case s.options.Version == 2 && s.options.Token != "":
+		req.Header.Set("Authorization", "Token "+s.options.Token)
+	case s.options.Version == 1 && s.options.Username != "":
+		req.SetBasicAuth(s.options.Username, s.options.Password)
+	}
+	return s.http.Do(req)
+}
+
+// responseError describes an unexpected response, with the message InfluxDB
+// sent, if any.
+func responseError(resp *http.Response) error {
+	var body struct {
+		Error   string `json:"error"`   // 1.x
+		Message string `json:"message"` // 2.x
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	json.Unmarshal(b, &body)
+	if body.Message != "" {
+		body.Error = body.Message
+	}
+	if body.Error != "" {
+		return fmt.Errorf("influx: %s: %s", resp.Status, body.Error)
+	}
+	return fmt.Errorf("influx: %s", resp.Status)
+}
+
+// quoteIdent quotes an InfluxQL identifier.
+func quoteIdent(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
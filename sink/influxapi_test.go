@@ -0,0 +1,286 @@
+package sink
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// request returns the last request to path the server received.
+func (f *fakeInflux) request(t *testing.T, path string) fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		if f.requests[i].Path == path {
+			return f.requests[i]
+		}
+	}
+	t.Fatalf("no request to %s", path)
+	return fakeRequest{}
+}
+
+func (f *fakeInflux) paths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var paths []string
+	for _, r := range f.requests {
+		paths = append(paths, r.Method+" "+r.Path)
+	}
+	return paths
+}
+
+func TestInfluxWriteV1(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "create": false, "database": "f1", "retention-policy": "week",
+		"username": "luan", "password": "pw"}`)
+	addFrames(t, s, 0, 2)
+	s.Flush()
+
+	r := f.request(t, "/write")
+	if r.Method != "POST" || r.Query.Get("db") != "f1" || r.Query.Get("rp") != "week" || r.Query.Get("precision") != "us" {
+		t.Errorf("%s /write?%s, want POST to db f1, rp week, in us", r.Method, r.Query.Encode())
+	}
+	if user, password, ok := (&http.Request{Header: r.Header}).BasicAuth(); !ok || user != "luan" || password != "pw" {
+		t.Errorf("basic auth %q, %q, want luan, pw", user, password)
+	}
+	if !strings.HasPrefix(r.Body, "telemetry,") || strings.Count(r.Body, "\n") != 2 {
+		t.Errorf("body %q, want 2 telemetry points", r.Body)
+	}
+
+	// Without a retention policy, the default one is written to.
+	s = newTestInflux(t, `{"addr": "`+f.URL+`", "create": false}`)
+	addFrames(t, s, 0, 1)
+	s.Flush()
+	r = f.request(t, "/write")
+	if _, ok := r.Query["rp"]; ok || r.Query.Get("db") != DefaultInfluxDatabase {
+		t.Errorf("/write?%s, want db %s without rp", r.Query.Encode(), DefaultInfluxDatabase)
+	}
+	if r.Header.Get("Authorization") != "" {
+		t.Errorf("Authorization %q without a user name", r.Header.Get("Authorization"))
+	}
+}
+
+func TestInfluxWriteV2(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "version": 2, "create": false, "org": "team", "bucket": "laps",
+		"token": "secret", "database": "f1", "username": "luan"}`)
+	addFrames(t, s, 0, 1)
+	s.Flush()
+
+	r := f.request(t, "/api/v2/write")
+	want := map[string][]string{"org": {"team"}, "bucket": {"laps"}, "precision": {"us"}}
+	if r.Method != "POST" || !reflect.DeepEqual(map[string][]string(r.Query), want) {
+		t.Errorf("%s /api/v2/write?%s, want POST with %v", r.Method, r.Query.Encode(), want)
+	}
+	if auth := r.Header.Get("Authorization"); auth != "Token secret" {
+		t.Errorf("Authorization %q, want %q", auth, "Token secret")
+	}
+	checkWritten(t, f, 0)
+
+	// The bucket defaults to the database.
+	s = newTestInflux(t, `{"addr": "`+f.URL+`", "version": 2, "create": false, "database": "f1"}`)
+	addFrames(t, s, 0, 1)
+	s.Flush()
+	r = f.request(t, "/api/v2/write")
+	if r.Query.Get("bucket") != "f1" || r.Header.Get("Authorization") != "" {
+		t.Errorf("/api/v2/write?%s, Authorization %q, want bucket f1 without a token", r.Query.Encode(), r.Header.Get("Authorization"))
+	}
+}
+
+func TestInfluxCreateV1(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	options := `{"addr": "` + f.URL + `", "database": "f1", "retention-policy": "week", "retention": "168h"}`
+	s := newTestInflux(t, options)
+	want := []string{
+		`CREATE DATABASE "f1"`,
+		`CREATE RETENTION POLICY "week" ON "f1" DURATION 604800s REPLICATION 1`,
+	}
+	if !reflect.DeepEqual(f.queries, want) {
+		t.Fatalf("queries %q, want %q", f.queries, want)
+	}
+	if !s.created || s.err != nil {
+		t.Fatalf("created %v, error %v", s.created, s.err)
+	}
+
+	// The retention policy existing already is fine.
+	s = newTestInflux(t, options)
+	if !s.created || s.err != nil {
+		t.Fatalf("created %v, error %v with the retention policy existing", s.created, s.err)
+	}
+
+	// Without a retention policy, only the database is created.
+	f.queries = nil
+	newTestInflux(t, `{"addr": "`+f.URL+`"}`)
+	if want := []string{`CREATE DATABASE "f1telemetry"`}; !reflect.DeepEqual(f.queries, want) {
+		t.Fatalf("queries %q, want %q", f.queries, want)
+	}
+}
+
+func TestInfluxCreateLater(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	f.setStatus(http.StatusServiceUnavailable)
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "database": "f1"}`)
+	if s.created || s.err == nil {
+		t.Fatalf("created %v, error %v with InfluxDB down", s.created, s.err)
+	}
+
+	// Created before the first write once InfluxDB is back.
+	f.setStatus(0)
+	f.requests = nil
+	addFrames(t, s, 0, 1)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if paths := f.paths(); !reflect.DeepEqual(paths, []string{"POST /query", "POST /write"}) {
+		t.Fatalf("requests %q, want the database created, then written to", paths)
+	}
+	checkWritten(t, f, 0)
+}
+
+// TestInfluxCreateForbidden checks a user allowed to write to the database
+// but not to create it still writes to it.
+func TestInfluxCreateForbidden(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	f.readOnly = true
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "database": "f1"}`)
+	if s.created || s.err == nil {
+		t.Fatalf("created %v, error %v without the rights to", s.created, s.err)
+	}
+
+	// Tried again before the first write, which goes ahead regardless.
+	f.requests = nil
+	addFrames(t, s, 0, 1)
+	if err := s.Flush(); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Flush = %v, want the database not created", err)
+	}
+	if paths := f.paths(); !reflect.DeepEqual(paths, []string{"POST /query", "POST /write"}) {
+		t.Fatalf("requests %q, want a create, then a write", paths)
+	}
+	checkWritten(t, f, 0)
+	checkStatus(t, s, "1 written, 0 dropped, 0 failed, last error: influx: 403 Forbidden: requires admin privilege")
+
+	// Not tried again while the writes succeed.
+	f.requests = nil
+	addFrames(t, s, 1, 2)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if paths := f.paths(); !reflect.DeepEqual(paths, []string{"POST /write"}) {
+		t.Fatalf("requests %q, want only a write", paths)
+	}
+}
+
+// TestInfluxCreateMissing checks a database gone since the sink was opened is
+// created again.
+func TestInfluxCreateMissing(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	f.missing = true
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "database": "f1"}`)
+	if !s.created || s.err != nil {
+		t.Fatalf("created %v, error %v", s.created, s.err)
+	}
+
+	f.mu.Lock()
+	f.dbs = nil
+	f.mu.Unlock()
+	addFrames(t, s, 0, 1)
+	s.Flush()
+	checkStatus(t, s, "0 written, 0 dropped, 1 failed, retry in")
+	if _, ok := s.err.(missingError); !ok || s.created {
+		t.Fatalf("created %v, error %v after writing to a missing database", s.created, s.err)
+	}
+
+	f.requests = nil
+	elapse(s)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if paths := f.paths(); !reflect.DeepEqual(paths, []string{"POST /query", "POST /write"}) {
+		t.Fatalf("requests %q, want the database created again, then written to", paths)
+	}
+	checkWritten(t, f, 0)
+
+	// Without the create option, the writes fail until it is back.
+	f.mu.Lock()
+	f.dbs = nil
+	f.mu.Unlock()
+	s = newTestInflux(t, `{"addr": "`+f.URL+`", "database": "f1", "create": false}`)
+	addFrames(t, s, 0, 1)
+	s.Flush()
+	elapse(s)
+	f.requests = nil
+	s.Flush()
+	if paths := f.paths(); !reflect.DeepEqual(paths, []string{"POST /write"}) || !s.created {
+		t.Fatalf("requests %q, want only a write", paths)
+	}
+}
+
+func TestInfluxCreateV2(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	options := `{"addr": "` + f.URL + `", "version": 2, "org": "team", "bucket": "laps", "token": "secret", "retention": "720h"}`
+	s := newTestInflux(t, options)
+	if !s.created || s.err != nil {
+		t.Fatalf("created %v, error %v", s.created, s.err)
+	}
+	want := []string{"GET /api/v2/buckets", "GET /api/v2/orgs", "POST /api/v2/buckets"}
+	if paths := f.paths(); !reflect.DeepEqual(paths, want) {
+		t.Fatalf("requests %q, want %q", paths, want)
+	}
+	if len(f.buckets) != 1 {
+		t.Fatalf("buckets %+v, want one", f.buckets)
+	}
+	b := f.buckets[0]
+	if b.Name != "laps" || len(b.RetentionRules) != 1 || b.RetentionRules[0].Type != "expire" || b.RetentionRules[0].EverySeconds != 720*3600 {
+		t.Errorf("bucket %+v, want laps expiring after 720h", b)
+	}
+	r := f.request(t, "/api/v2/buckets")
+	if r.Header.Get("Authorization") != "Token secret" || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("bucket created with headers %v", r.Header)
+	}
+
+	// Left alone once it exists.
+	f.requests = nil
+	newTestInflux(t, options)
+	if paths := f.paths(); !reflect.DeepEqual(paths, []string{"GET /api/v2/buckets"}) {
+		t.Fatalf("requests %q with the bucket existing, want only a lookup", paths)
+	}
+
+	// An unknown org is an error, reported in Status until created.
+	s = newTestInflux(t, `{"addr": "`+f.URL+`", "version": 2, "org": "other", "bucket": "laps2"}`)
+	if s.created || s.err == nil || !strings.Contains(s.err.Error(), `no org "other"`) {
+		t.Fatalf("created %v, error %v with an unknown org", s.created, s.err)
+	}
+	if !strings.Contains(s.Status(), `last error: influx: no org "other"`) {
+		t.Fatalf("Status = %q, want the unknown org", s.Status())
+	}
+
+	// Creating a bucket takes an org.
+	if _, err := NewInflux(json.RawMessage(`{"version": 2, "bucket": "laps"}`)); err == nil {
+		t.Fatal("NewInflux creating a bucket without an org succeeded")
+	}
+}
+
+func TestInfluxCreateV3(t *testing.T) {
+	f := newFakeInflux()
+	defer f.Close()
+	f.v3 = true
+	s := newTestInflux(t, `{"addr": "`+f.URL+`", "version": 2, "org": "team", "bucket": "laps", "token": "secret"}`)
+	// InfluxDB 3.x has no buckets API, and creates the database on write.
+	if !s.created || s.err != nil {
+		t.Fatalf("created %v, error %v", s.created, s.err)
+	}
+	addFrames(t, s, 0, 1)
+	s.Flush()
+	if paths := f.paths(); !reflect.DeepEqual(paths, []string{"GET /api/v2/buckets", "POST /api/v2/write"}) {
+		t.Fatalf("requests %q, want a lookup and a write", paths)
+	}
+}
@@ -0,0 +1,313 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+	"github.com/luan/f1-telemetry/motec"
+)
+
+// DefaultMotecRate is how many samples per second the channels of MoTeC logs
+// have by default, about as often as the game sends telemetry.
+const DefaultMotecRate = 60
+
+func init() {
+	Register("motec", func(options json.RawMessage) (Sink, error) {
+		return NewMotec(options)
+	})
+}
+
+// MotecOptions are the options of the motec sink.
+type MotecOptions struct {
+	Dir string `json:"dir"` // created if missing, the working directory by default
+
+	// FromLap and ToLap are the first and last laps of the player written,
+	// counting from 1, 0 for no limit.
+	FromLap int `json:"from-lap"`
+	ToLap   int `json:"to-lap"`
+
+	Rate   int    `json:"rate"`   // samples per second
+	Driver string `json:"driver"` // name of the driver, the player's driver by default
+}
+
+// A Motec sink writes the telemetry of the player's car as MoTeC i2 logs, one
+// .ld file per session named after its ID, and an .ldx file next to it with
+// the laps. A session is kept in memory until it ends, or the sink is closed,
+// and then written.
+//
+// The frames are sampled at a fixed rate by their game time, each sample
+// holding the last frame before it, and a flashback rewinds the samples to
+// where it went back to.
+type Motec struct {
+	written uint64 // logs, accessed atomically
+
+	options  MotecOptions
+	sessions map[string]*motecSession
+}
+
+type motecSession struct {
+	name  string   // of the files
+	first f1.Frame // describing the log
+	start float32  // game time of the first sample
+	// Samples of each of motecChannels, and the values of the last frame,
+	// which the samples from its time on hold.
+	samples [][]float32
+	held    []float32
+	laps    []motec.Lap
+}
+
+// A motecChannel is a channel of MoTeC logs, scaled to the units i2 expects.
+type motecChannel struct {
+	name, short, unit string
+	value             func(t *f1.TelemetryData) float32
+}
+
+var motecChannels = []motecChannel{
+	{"Ground Speed", "Speed", "km/h", func(t *f1.TelemetryData) float32 { return t.Speed * 3.6 }},
+	{"Throttle Pos", "Throttle", "%", func(t *f1.TelemetryData) float32 { return t.Throttle * 100 }},
+	{"Brake Pos", "Brake", "%", func(t *f1.TelemetryData) float32 { return t.Brake * 100 }},
+	{"Clutch Pos", "Clutch", "%", func(t *f1.TelemetryData) float32 { return t.Clutch * 100 }},
+	{"Steering Pos", "Steer", "%", func(t *f1.TelemetryData) float32 { return t.Steer * 100 }},
+	{"Gear", "Gear", "", func(t *f1.TelemetryData) float32 { return t.Gear }},
+	{"Engine RPM", "RPM", "rpm", func(t *f1.TelemetryData) float32 { return t.Enginerate }},
+	{"G Force Lat", "G Lat", "G", func(t *f1.TelemetryData) float32 { return t.GforceLat }},
+	{"G Force Long", "G Long", "G", func(t *f1.TelemetryData) float32 { return t.GforceLon }},
+	{"G Force Vert", "G Vert", "G", func(t *f1.TelemetryData) float32 { return t.GforceVert }},
+	{"Lap Distance", "Lap Dist", "m", func(t *f1.TelemetryData) float32 { return t.Lapdistance }},
+	{"Lap Time", "Lap Time", "s", func(t *f1.TelemetryData) float32 { return t.Laptime }},
+	{"Lap Number", "Lap", "", func(t *f1.TelemetryData) float32 { return t.Lap + 1 }},
+	{"DRS", "DRS", "", func(t *f1.TelemetryData) float32 { return t.DRS }},
+	{"Fuel Level", "Fuel", "kg", func(t *f1.TelemetryData) float32 { return t.FuelInTank }},
+	{"Engine Temp", "Eng Temp", "C", func(t *f1.TelemetryData) float32 { return t.EngineTemperature }},
+	{"Brake Bias", "Bias", "%", func(t *f1.TelemetryData) float32 { return float32(t.FrontBrakeBias) }},
+	{"Pos X", "X", "m", func(t *f1.TelemetryData) float32 { return t.X }},
+	{"Pos Y", "Y", "m", func(t *f1.TelemetryData) float32 { return t.Y }},
+	{"Pos Z", "Z", "m", func(t *f1.TelemetryData) float32 { return t.Z }},
+}
+
+// motecWheels are channels with a value per wheel, named after the wheel.
+var motecWheels = []struct {
+	name, short, unit string
+	value             func(t *f1.TelemetryData, wheel int) float32
+}{
+	{"Wheel Speed", "WSpd", "km/h", func(t *f1.TelemetryData, i int) float32 { return t.WheelSpeed[i] * 3.6 }},
+	{"Susp Pos", "Susp", "mm", func(t *f1.TelemetryData, i int) float32 { return t.SuspPos[i] }},
+	{"Susp Vel", "SuspV", "mm/s", func(t *f1.TelemetryData, i int) float32 { return t.SuspVel[i] }},
+	{"Brake Temp", "BTemp", "C", func(t *f1.TelemetryData, i int) float32 { return t.BrakesTemp[i] }},
+	{"Tyre Temp", "TTemp", "C", func(t *f1.TelemetryData, i int) float32 { return float32(t.TyresTemperature[i]) }},
+	{"Tyre Pres", "TPres", "psi", func(t *f1.TelemetryData, i int) float32 { return t.TyresPressure[i] }},
+	{"Tyre Wear", "TWear", "%", func(t *f1.TelemetryData, i int) float32 { return float32(t.TyresWear[i]) }},
+}
+
+func init() {
+	for _, w := range motecWheels {
+		for i, wheel := range wheelNames {
+			w, i, wheel := w, i, strings.ToUpper(wheel)
+			motecChannels = append(motecChannels, motecChannel{
+				w.name + " " + wheel, w.short + " " + wheel, w.unit,
+				func(t *f1.TelemetryData) float32 { return w.value(t, i) },
+			})
+		}
+	}
+}
+
+// NewMotec creates a motec sink configured by options.
+func NewMotec(options json.RawMessage) (*Motec, error) {
+	s := &Motec{
+		options:  MotecOptions{Dir: ".", Rate: DefaultMotecRate},
+		sessions: map[string]*motecSession{},
+	}
+	if err := decodeOptions(options, &s.options); err != nil {
+		return nil, err
+	}
+	if s.options.FromLap < 0 || s.options.ToLap < 0 || s.options.ToLap > 0 && s.options.ToLap < s.options.FromLap {
+		return nil, fmt.Errorf("invalid lap range %d-%d", s.options.FromLap, s.options.ToLap)
+	}
+	if s.options.Rate < 1 || s.options.Rate > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid rate %d", s.options.Rate)
+	}
+	return s, nil
+}
+
+// Open creates the directory if missing.
+func (s *Motec) Open() error {
+	return os.MkdirAll(s.options.Dir, 0755)
+}
+
+// Frame samples frame, unless the lap range leaves it out.
+func (s *Motec) Frame(frame f1.Frame) error {
+	lap := frame.PlayerLap()
+	if lap < s.options.FromLap || s.options.ToLap > 0 && lap > s.options.ToLap {
+		return nil
+	}
+
+	id := frame.Session
+	if id == "" {
+		id = frame.Source
+	}
+	session, ok := s.sessions[id]
+	if !ok {
+		session = &motecSession{
+			name:    f1.FileName(id),
+			first:   frame,
+			start:   frame.Time,
+			samples: make([][]float32, len(motecChannels)),
+			held:    make([]float32, len(motecChannels)),
+		}
+		s.sessions[id] = session
+	}
+	session.add(frame, s.options.Rate)
+	return nil
+}
+
+// add samples frame: the samples up to its time hold the last frame, and the
+// ones from its time on will hold frame.
+func (session *motecSession) add(frame f1.Frame, rate int) {
+	// Allowing for the game time being a float32.
+	n := int(math.Ceil(float64(frame.Time-session.start)*float64(rate) - 1e-3))
+	if n < 0 {
+		n = 0
+	}
+	if n < len(session.samples[0]) {
+		// A flashback.
+		for i := range session.samples {
+			session.samples[i] = session.samples[i][:n]
+		}
+		for len(session.laps) > 0 && session.laps[len(session.laps)-1].Start >= sampleTime(n, rate) {
+			session.laps = session.laps[:len(session.laps)-1]
+		}
+		if len(session.laps) > 0 {
+			session.laps[len(session.laps)-1].Time = 0
+		}
+	}
+	for i, samples := range session.samples {
+		for len(samples) < n {
+			samples = append(samples, session.held[i])
+		}
+		session.samples[i] = samples
+	}
+	for i, c := range motecChannels {
+		session.held[i] = c.value(&frame.TelemetryData)
+	}
+
+	// Laps start Laptime before the first frame on them.
+	start := seconds(frame.Time - session.start - frame.Laptime)
+	switch {
+	case len(session.laps) == 0:
+		if start < 0 {
+			// Started with the log, part way through the lap.
+			start = 0
+		}
+		session.laps = append(session.laps, motec.Lap{Number: frame.PlayerLap(), Start: start})
+	case frame.PlayerLap() > session.laps[len(session.laps)-1].Number:
+		previous := &session.laps[len(session.laps)-1]
+		if previous.Start > 0 || session.first.Laptime < 1 {
+			// Completed in the log.
+			previous.Time = seconds(frame.LastLapTime)
+			if previous.Time <= 0 {
+				previous.Time = start - previous.Start
+			}
+		}
+		session.laps = append(session.laps, motec.Lap{Number: frame.PlayerLap(), Start: start})
+	}
+}
+
+func sampleTime(n, rate int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(rate)
+}
+
+// Event writes the log of a session once it ends.
+func (s *Motec) Event(event f1.SessionEvent) error {
+	if event.Type != f1.SessionEnd {
+		return nil
+	}
+	session, ok := s.sessions[event.Session.ID]
+	if !ok {
+		return nil
+	}
+	delete(s.sessions, event.Session.ID)
+	return s.write(session)
+}
+
+// Flush does nothing, as logs are written whole.
+func (s *Motec) Flush() error {
+	return nil
+}
+
+// Close writes the logs of the sessions still in progress.
+func (s *Motec) Close() error {
+	var err error
+	for id, session := range s.sessions {
+		if e := s.write(session); e != nil && err == nil {
+			err = e
+		}
+		delete(s.sessions, id)
+	}
+	return err
+}
+
+// Status reports how many logs were written.
+func (s *Motec) Status() string {
+	return fmt.Sprintf("%d logs written", atomic.LoadUint64(&s.written))
+}
+
+// write writes the .ld and .ldx files of a session.
+func (s *Motec) write(session *motecSession) error {
+	frame := session.first
+	log := motec.Log{
+		Start:   frame.Received,
+		Driver:  s.options.Driver,
+		Venue:   f1.TrackName(frame.TrackNumber),
+		Event:   f1.SessionTypeName(frame.SessionType),
+		Session: session.name,
+		Comment: frame.Format.String(),
+	}
+	if i := int(frame.PlayerCarIndex); i < len(frame.Cars) && frame.Format.Fields().Has(f1.FieldCars) {
+		if log.Driver == "" {
+			log.Driver = f1.Drivers[frame.Cars[i].DriverID]
+		}
+		log.Vehicle = f1.Teams[frame.Cars[i].TeamID]
+	}
+	for i, c := range motecChannels {
+		// The last frame holds for one more sample.
+		data := append(session.samples[i], session.held[i])
+		log.Channels = append(log.Channels, motec.Channel{
+			Name: c.name, Short: c.short, Unit: c.unit, Freq: s.options.Rate, Data: data,
+		})
+	}
+
+	name := filepath.Join(s.options.Dir, session.name)
+	if err := writeFile(name+".ld", func(f *os.File) error {
+		_, err := log.WriteTo(f)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := writeFile(name+".ldx", func(f *os.File) error {
+		return motec.WriteLDX(f, session.laps)
+	}); err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.written, 1)
+	return nil
+}
+
+// writeFile creates the file called name and writes it with write.
+func writeFile(name string, write func(f *os.File) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+// Package sink defines the outputs telemetry is written to, such as InfluxDB,
+// and a registry to create them by type from their configuration.
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// A Sink writes telemetry somewhere. Its methods are called from a single
+// goroutine: Open first, then Frame and Event in the order the frames and
+// events happened, with Flush in between every flush interval, and Close
+// last.
+type Sink interface {
+	// Open connects to or creates the output, before any frame is written.
+	Open() error
+	// Frame writes a frame, or buffers it until Flush.
+	Frame(frame f1.Frame) error
+	// Event writes a session starting or ending, or buffers it until Flush.
+	Event(event f1.SessionEvent) error
+	// Flush writes what was buffered.
+	Flush() error
+	// Close flushes the sink and releases the output.
+	Close() error
+}
+
+// A StatusReporter is a Sink reporting how it is doing, such as how many
+// writes failed, for the dashboard. Status may be called from any goroutine.
+type StatusReporter interface {
+	Status() string
+}
+
+// A Duration is a time.Duration given as a string in configurations, such as
+// "500ms" or "1m30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// A Config configures a sink.
+type Config struct {
+	Type string `json:"type"`
+	// Name tells sinks of the same type apart, and defaults to the type.
+	Name string `json:"name,omitempty"`
+	// Options are specific to the type of sink.
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// A Factory creates a sink from its options, which may be empty.
+type Factory func(options json.RawMessage) (Sink, error)
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Factory{}
+)
+
+// Register makes a type of sink available to New. It panics if the type is
+// registered twice.
+func Register(typ string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[typ]; ok {
+		panic("sink: Register called twice for " + typ)
+	}
+	registry[typ] = factory
+}
+
+// Types returns the registered types of sink, sorted.
+func Types() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var types []string
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates the sink configured by c. It isn't opened yet.
+func New(c Config) (Sink, error) {
+	mu.Lock()
+	factory, ok := registry[c.Type]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("sink: unknown type %q, want one of %s", c.Type, strings.Join(Types(), ", "))
+	}
+	s, err := factory(c.Options)
+	if err != nil {
+		name := c.Name
+		if name == "" {
+			name = c.Type
+		}
+		return nil, fmt.Errorf("sink %s: %v", name, err)
+	}
+	return s, nil
+}
+
+// decodeOptions decodes the options of a sink into v, which holds the
+// defaults. Unknown options are an error, so typos don't go unnoticed.
+func decodeOptions(options json.RawMessage, v interface{}) error {
+	if len(options) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
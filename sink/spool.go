@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// spoolExt ends the names of spool segments; segments being written end in
+// ".tmp" until they are complete.
+const spoolExt = ".lp"
+
+// A spool is a queue of batches of points in line protocol kept on disk,
+// one file, or segment, per batch, so they survive outages and restarts.
+// Segments are named after their sequence number and number of points, e.g.
+// "000000000000002a-5000.lp". When the segments take more than max bytes,
+// the oldest ones are evicted.
+type spool struct {
+	dir      string
+	max      int64
+	segments []segment // oldest first
+	size     int64     // of segments, in bytes
+	points   int       // in segments
+	next     uint64    // sequence number of the next segment
+}
+
+type segment struct {
+	name   string
+	points int
+	size   int64
+}
+
+// openSpool opens the spool in dir, creating dir if needed, with the segments
+// left by a previous run.
+func openSpool(dir string, max int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &spool{dir: dir, max: max}
+	for _, fi := range files {
+		name := fi.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// Cut short by a crash.
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		var seq uint64
+		var points int
+		if _, err := fmt.Sscanf(name, "%x-%d"+spoolExt, &seq, &points); err != nil {
+			continue
+		}
+		s.segments = append(s.segments, segment{name: name, points: points, size: fi.Size()})
+		s.size += fi.Size()
+		s.points += points
+		if seq >= s.next {
+			s.next = seq + 1
+		}
+	}
+	// The sequence numbers are fixed width, so they sort by name.
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].name < s.segments[j].name })
+	return s, nil
+}
+
+// push adds a segment holding lines, the line protocol of points points, and
+// evicts the oldest segments if the spool is over its size. It returns the
+// number of points evicted.
+func (s *spool) push(lines []byte, points int) (int, error) {
+	name := fmt.Sprintf("%016x-%d%s", s.next, points, spoolExt)
+	path := filepath.Join(s.dir, name)
+	if err := ioutil.WriteFile(path+".tmp", lines, 0644); err != nil {
+		os.Remove(path + ".tmp")
+		return 0, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return 0, err
+	}
+	s.next++
+	s.segments = append(s.segments, segment{name: name, points: points, size: int64(len(lines))})
+	s.size += int64(len(lines))
+	s.points += points
+
+	evicted := 0
+	for s.size > s.max && len(s.segments) > 0 {
+		evicted += s.segments[0].points
+		if err := s.pop(); err != nil {
+			return evicted, err
+		}
+	}
+	return evicted, nil
+}
+
+// peek returns the lines and number of points of the oldest segment.
+func (s *spool) peek() ([]byte, int, error) {
+	lines, err := ioutil.ReadFile(filepath.Join(s.dir, s.segments[0].name))
+	return lines, s.segments[0].points, err
+}
+
+// pop removes the oldest segment.
+func (s *spool) pop() error {
+	seg := s.segments[0]
+	s.segments = s.segments[1:]
+	s.size -= seg.size
+	s.points -= seg.points
+	return os.Remove(filepath.Join(s.dir, seg.name))
+}
+
+func (s *spool) empty() bool {
+	return len(s.segments) == 0
+}
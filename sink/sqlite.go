@@ -0,0 +1,389 @@
+package sink
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// DefaultArchivePath is the SQLite database the sqlite sink writes to by
+// default.
+const DefaultArchivePath = "f1telemetry.db"
+
+// ArchiveTimeFormat is how the times of sessions are stored, in UTC, so they
+// sort as text.
+const ArchiveTimeFormat = "2006-01-02T15:04:05.000Z"
+
+func init() {
+	Register("sqlite", func(options json.RawMessage) (Sink, error) {
+		return NewArchive(options)
+	})
+}
+
+// ArchiveOptions are the options of the sqlite sink.
+type ArchiveOptions struct {
+	Path string `json:"path"` // of the database, created if missing
+}
+
+// migrations create and update the schema of archives, in order. The
+// user_version of an archive is how many of them were applied to it. Applied
+// migrations must not change, add one instead.
+var migrations = []string{
+	`CREATE TABLE sessions (
+		id           TEXT PRIMARY KEY,
+		source       TEXT NOT NULL,
+		format       TEXT NOT NULL,
+		session_uid  INTEGER NOT NULL,
+		start_time   TEXT NOT NULL,
+		end_time     TEXT,
+		track_number INTEGER NOT NULL,
+		track        TEXT NOT NULL,
+		session_type TEXT NOT NULL,
+		era          INTEGER NOT NULL,
+		total_laps   INTEGER NOT NULL
+	);
+	CREATE TABLE laps (
+		session_id    TEXT NOT NULL REFERENCES sessions (id),
+		lap           INTEGER NOT NULL,
+		time          REAL NOT NULL,
+		sector1       REAL,
+		sector2       REAL,
+		sector3       REAL,
+		valid         INTEGER NOT NULL,
+		compound      TEXT NOT NULL,
+		fuel_start    REAL NOT NULL,
+		fuel_end      REAL NOT NULL,
+		wear_start_rl INTEGER NOT NULL,
+		wear_start_rr INTEGER NOT NULL,
+		wear_start_fl INTEGER NOT NULL,
+		wear_start_fr INTEGER NOT NULL,
+		wear_end_rl   INTEGER NOT NULL,
+		wear_end_rr   INTEGER NOT NULL,
+		wear_end_fl   INTEGER NOT NULL,
+		wear_end_fr   INTEGER NOT NULL,
+		PRIMARY KEY (session_id, lap)
+	);
+	CREATE TABLE car_laps (
+		session_id TEXT NOT NULL REFERENCES sessions (id),
+		car        INTEGER NOT NULL,
+		lap        INTEGER NOT NULL,
+		driver_id  INTEGER NOT NULL,
+		driver     TEXT NOT NULL,
+		team_id    INTEGER NOT NULL,
+		team       TEXT NOT NULL,
+		time       REAL NOT NULL,
+		sector1    REAL,
+		sector2    REAL,
+		sector3    REAL,
+		position   INTEGER NOT NULL,
+		compound   TEXT NOT NULL,
+		PRIMARY KEY (session_id, car, lap)
+	);
+	CREATE INDEX sessions_track ON sessions (track, session_type);
+	CREATE INDEX laps_time ON laps (time);`,
+}
+
+// OpenArchive opens the SQLite archive at path, creating it if missing, and
+// migrates it to the current schema.
+func OpenArchive(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return db, nil
+}
+
+// migrate applies the migrations an archive is missing, each in a
+// transaction of its own.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("archive schema version %d is newer than this program's, %d", version, len(migrations))
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", i+1, err)
+		}
+		// PRAGMA takes no parameters.
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An Archive sink stores sessions in a SQLite database, with a summary of
+// each lap of the player: its time and sector splits, whether it was valid,
+// the compound, and the fuel and tyre wear at its start and end, and the lap
+// results of every car in the race. The lap times are those of the game, in
+// seconds.
+//
+// Only the player's laps seen from their start are stored. A lap completed
+// again after a flashback replaces the one stored before.
+//
+// The SQLite driver, github.com/mattn/go-sqlite3, is written in C: building
+// with the sink takes cgo, and a C compiler for the target, so
+// CGO_ENABLED=1 and CC must be set when cross compiling.
+type Archive struct {
+	// Accessed atomically, first in the struct to be 64-bit aligned.
+	sessions uint64
+	laps     uint64
+	carLaps  uint64
+
+	options ArchiveOptions
+	db      *sql.DB
+	tx      *sql.Tx // written to until the next Flush, nil if not begun
+	active  map[string]*archiveSession
+}
+
+type archiveSession struct {
+	laps map[int]*archiveLap // of the player, by number
+	lap  *archiveLap         // in progress
+	last f1.Frame
+	cars [20]f1.CarData // in the last frame
+
+	// The track and session type stored. The header based formats send them
+	// every so often, so they may be 0 in the start event.
+	track, sessionType float32
+}
+
+// An archiveLap holds the state of the player's car at the start of a lap.
+type archiveLap struct {
+	number   int
+	partial  bool // if not seen from its start
+	compound byte
+	fuel     float32
+	wear     [4]byte
+}
+
+// NewArchive creates a sqlite sink configured by options.
+func NewArchive(options json.RawMessage) (*Archive, error) {
+	s := &Archive{
+		options: ArchiveOptions{Path: DefaultArchivePath},
+		active:  map[string]*archiveSession{},
+	}
+	if err := decodeOptions(options, &s.options); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open opens the database, migrating it to the current schema.
+func (s *Archive) Open() error {
+	db, err := OpenArchive(s.options.Path)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+// Frame stores the laps completed as of frame.
+func (s *Archive) Frame(frame f1.Frame) error {
+	session, ok := s.active[frame.Session]
+	if !ok {
+		// Outside of a session, or one started before the sink.
+		return nil
+	}
+	if frame.TrackNumber != session.track || frame.SessionType != session.sessionType {
+		if err := s.updateSession(frame, session); err != nil {
+			return err
+		}
+	}
+
+	number := frame.PlayerLap()
+	if session.lap == nil || number != session.lap.number {
+		if session.lap != nil && number == session.lap.number+1 && !session.lap.partial {
+			if err := s.storeLap(frame, session); err != nil {
+				return err
+			}
+		}
+		lap, ok := session.laps[number]
+		if !ok || session.lap == nil || number > session.lap.number {
+			// A new lap, rather than one gone back to by a flashback.
+			lap = &archiveLap{
+				number:   number,
+				partial:  frame.Laptime >= 1,
+				compound: frame.TyreCompound,
+				fuel:     frame.FuelInTank,
+				wear:     frame.TyresWear,
+			}
+			session.laps[number] = lap
+		}
+		session.lap = lap
+	}
+
+	if frame.Format.Fields().Has(f1.FieldCars) {
+		for i, car := range frame.Cars {
+			previous := session.cars[i]
+			if car.CarPosition > 0 && previous.CurrentLapNum > 0 && car.CurrentLapNum == previous.CurrentLapNum+1 && car.LastlapTime > 0 {
+				if err := s.storeCarLap(frame.Session, i, previous, car); err != nil {
+					return err
+				}
+			}
+		}
+		session.cars = frame.Cars
+	}
+	session.last = frame
+	return nil
+}
+
+// storeLap stores the lap of session completed with frame, the first frame
+// on the next lap.
+func (s *Archive) storeLap(frame f1.Frame, session *archiveSession) error {
+	if frame.LastLapTime <= 0 {
+		return nil
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	lap, last := session.lap, session.last
+	s1, s2, s3 := splits(frame.LastLapTime, last.Sector1Time, last.Sector2Time)
+	_, err = tx.Exec(`INSERT OR REPLACE INTO laps VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		frame.Session, lap.number, frame.LastLapTime, s1, s2, s3, last.Currentlapinvalid == 0,
+		f1.TyreCompoundName(lap.compound), lap.fuel, last.FuelInTank,
+		lap.wear[0], lap.wear[1], lap.wear[2], lap.wear[3],
+		last.TyresWear[0], last.TyresWear[1], last.TyresWear[2], last.TyresWear[3])
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.laps, 1)
+	return nil
+}
+
+// updateSession stores the session data of frame, which came after the start
+// event of session.
+func (s *Archive) updateSession(frame f1.Frame, session *archiveSession) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE sessions SET track_number = ?, track = ?, session_type = ?, era = ?, total_laps = ? WHERE id = ?`,
+		frame.TrackNumber, f1.TrackName(frame.TrackNumber), f1.SessionTypeName(frame.SessionType),
+		frame.Era, frame.TotalLaps, frame.Session)
+	if err != nil {
+		return err
+	}
+	session.track, session.sessionType = frame.TrackNumber, frame.SessionType
+	return nil
+}
+
+// storeCarLap stores the lap of car i completed between previous and car.
+func (s *Archive) storeCarLap(id string, i int, previous, car f1.CarData) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	s1, s2, s3 := splits(car.LastlapTime, previous.Sector1Time, previous.Sector2Time)
+	_, err = tx.Exec(`INSERT OR REPLACE INTO car_laps VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, i, previous.CurrentLapNum, car.DriverID, f1.Drivers[car.DriverID], car.TeamID, f1.Teams[car.TeamID],
+		car.LastlapTime, s1, s2, s3, car.CarPosition, f1.TyreCompoundName(previous.TyreCompound))
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.carLaps, 1)
+	return nil
+}
+
+// splits returns the sector times of a lap, nil if unknown.
+func splits(lap, sector1, sector2 float32) (s1, s2, s3 interface{}) {
+	if sector1 <= 0 || sector2 <= 0 || sector1+sector2 >= lap {
+		return nil, nil, nil
+	}
+	return sector1, sector2, lap - sector1 - sector2
+}
+
+// Event stores a session starting, or its end.
+func (s *Archive) Event(event f1.SessionEvent) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	session := event.Session
+	if event.Type == f1.SessionEnd {
+		delete(s.active, session.ID)
+		_, err := tx.Exec(`UPDATE sessions SET end_time = ? WHERE id = ?`, archiveTime(session.End), session.ID)
+		return err
+	}
+
+	s.active[session.ID] = &archiveSession{
+		laps:        map[int]*archiveLap{},
+		track:       session.TrackNumber,
+		sessionType: session.SessionType,
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO sessions VALUES (?, ?, ?, ?, ?, NULL, ?, ?, ?, ?, ?)`,
+		session.ID, session.Source, session.Format.String(), int64(session.SessionUID), archiveTime(session.Start),
+		session.TrackNumber, f1.TrackName(session.TrackNumber), f1.SessionTypeName(session.SessionType),
+		session.Era, session.TotalLaps)
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.sessions, 1)
+	return nil
+}
+
+func archiveTime(t time.Time) string {
+	return t.UTC().Format(ArchiveTimeFormat)
+}
+
+// begin begins the transaction written to until the next Flush, if not
+// begun yet.
+func (s *Archive) begin() (*sql.Tx, error) {
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		s.tx = tx
+	}
+	return s.tx, nil
+}
+
+// Flush commits what was stored since the last Flush.
+func (s *Archive) Flush() error {
+	if s.tx == nil {
+		return nil
+	}
+	err := s.tx.Commit()
+	s.tx = nil
+	return err
+}
+
+// Close commits what was stored and closes the database. Sessions still in
+// progress are left without an end time.
+func (s *Archive) Close() error {
+	err := s.Flush()
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Status reports how many sessions and laps were stored.
+func (s *Archive) Status() string {
+	return fmt.Sprintf("%d sessions, %d laps, %d car laps stored",
+		atomic.LoadUint64(&s.sessions), atomic.LoadUint64(&s.laps), atomic.LoadUint64(&s.carLaps))
+}
@@ -0,0 +1,347 @@
+package sink
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// newTestArchive opens an archive in a temporary directory, removed by the
+// function returned, which closes the archive first.
+func newTestArchive(t *testing.T) (*Archive, func()) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, _ := json.Marshal(ArchiveOptions{Path: filepath.Join(dir, "archive.db")})
+	s, err := NewArchive(options)
+	if err == nil {
+		err = s.Open()
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// TestArchiveSessionData checks the session data sent after the start event,
+// as the header based formats do, is stored.
+func TestArchiveSessionData(t *testing.T) {
+	s, done := newTestArchive(t)
+	defer done()
+
+	start := time.Unix(1500000000, 0)
+	session := f1.Session{ID: "s1", Source: "test", Start: start}
+	if err := s.Event(f1.SessionEvent{Type: f1.SessionStart, Session: session}); err != nil {
+		t.Fatal(err)
+	}
+	frame := f1.Frame{Source: "test", Session: "s1", Received: start}
+	if err := s.Frame(frame); err != nil {
+		t.Fatal(err)
+	}
+	frame.TrackNumber, frame.SessionType, frame.TotalLaps = 7, 3, 52
+	for i := 0; i < 2; i++ {
+		if err := s.Frame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var track, sessionType string
+	var totalLaps int
+	err := s.db.QueryRow(`SELECT track, session_type, total_laps FROM sessions WHERE id = 's1'`).Scan(&track, &sessionType, &totalLaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track != f1.TrackName(7) || sessionType != f1.SessionTypeName(3) || totalLaps != 52 {
+		t.Fatalf("session on %s, %s of %d laps, want %s, %s of 52", track, sessionType, totalLaps, f1.TrackName(7), f1.SessionTypeName(3))
+	}
+}
+
+// archiveFrame returns a 2018 frame of the session "s1" of the player, in
+// car 0, lap seconds into lap. Car 1 is on the same lap, in the sector the
+// player is in, having done the last one in 81.25.
+func archiveFrame(lap int, laptime float32, sector int) f1.Frame {
+	frame := f1.Frame{Format: f1.Format{PacketFormat: 2018}, Source: "test", Session: "s1", Received: testStart}
+	frame.Time = float32(lap)*100 + laptime
+	frame.Laptime = laptime
+	frame.Sector = float32(sector)
+	frame.TyreCompound = 2
+	frame.Cars[0] = f1.CarData{CarPosition: 1, CurrentLapNum: uint8(lap)}
+	car := f1.CarData{CarPosition: 2, CurrentLapNum: uint8(lap), DriverID: 9, TeamID: 4, TyreCompound: 3}
+	if lap > 1 {
+		car.LastlapTime = 81.25
+	}
+	if sector >= 1 {
+		car.Sector1Time = 25.5
+	}
+	if sector >= 2 {
+		car.Sector2Time = 28.5
+	}
+	frame.Cars[1] = car
+	return frame
+}
+
+// archiveLapRow is a row of the laps table.
+type archiveLapRow struct {
+	Lap                int
+	Time               float64
+	S1, S2, S3         sql.NullFloat64
+	Valid              bool
+	Compound           string
+	FuelStart, FuelEnd float64
+	WearStart, WearEnd [4]int
+}
+
+func archiveLaps(t *testing.T, db *sql.DB) []archiveLapRow {
+	rows, err := db.Query(`SELECT lap, time, sector1, sector2, sector3, valid, compound, fuel_start, fuel_end,
+		wear_start_rl, wear_start_rr, wear_start_fl, wear_start_fr, wear_end_rl, wear_end_rr, wear_end_fl, wear_end_fr
+		FROM laps WHERE session_id = 's1' ORDER BY lap`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var laps []archiveLapRow
+	for rows.Next() {
+		var l archiveLapRow
+		err := rows.Scan(&l.Lap, &l.Time, &l.S1, &l.S2, &l.S3, &l.Valid, &l.Compound, &l.FuelStart, &l.FuelEnd,
+			&l.WearStart[0], &l.WearStart[1], &l.WearStart[2], &l.WearStart[3],
+			&l.WearEnd[0], &l.WearEnd[1], &l.WearEnd[2], &l.WearEnd[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		laps = append(laps, l)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return laps
+}
+
+func split(t float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: t, Valid: true}
+}
+
+// TestArchiveLaps drives laps across their boundaries: one joined halfway,
+// one valid, and one invalid then driven again after a flashback.
+func TestArchiveLaps(t *testing.T) {
+	s, done := newTestArchive(t)
+	defer done()
+	session := f1.Session{ID: "s1", Source: "test", Start: testStart, Format: f1.Format{PacketFormat: 2018}}
+	if err := s.Event(f1.SessionEvent{Type: f1.SessionStart, Session: session}); err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []f1.Frame
+	add := func(lap int, laptime float32, sector int, change func(f *f1.Frame)) {
+		frame := archiveFrame(lap, laptime, sector)
+		if change != nil {
+			change(&frame)
+		}
+		frames = append(frames, frame)
+	}
+	// Lap 1, joined halfway through.
+	add(1, 40, 1, nil)
+	add(1, 79, 2, nil)
+	// Lap 2, valid.
+	add(2, 0.1, 0, func(f *f1.Frame) {
+		f.LastLapTime = 80
+		f.FuelInTank, f.TyresWear = 10, [4]byte{1, 2, 3, 4}
+	})
+	add(2, 40, 1, func(f *f1.Frame) { f.Sector1Time = 25 })
+	add(2, 79, 2, func(f *f1.Frame) {
+		f.Sector1Time, f.Sector2Time = 25, 28
+		f.FuelInTank, f.TyresWear = 9, [4]byte{5, 6, 7, 8}
+	})
+	// Lap 3, invalidated.
+	add(3, 0.1, 0, func(f *f1.Frame) {
+		f.LastLapTime = 80.5
+		f.FuelInTank, f.TyresWear = 8.9, [4]byte{5, 6, 7, 8}
+	})
+	add(3, 79, 2, func(f *f1.Frame) {
+		f.Sector1Time, f.Sector2Time = 26, 27
+		f.Currentlapinvalid = 1
+		f.FuelInTank, f.TyresWear = 8, [4]byte{9, 10, 11, 12}
+	})
+	add(4, 0.1, 0, func(f *f1.Frame) { f.LastLapTime = 82 })
+	// A flashback back to lap 3, driven again validly.
+	add(3, 60, 2, func(f *f1.Frame) { f.Sector1Time, f.Sector2Time = 26, 27 })
+	add(3, 79, 2, func(f *f1.Frame) {
+		f.Sector1Time, f.Sector2Time = 26, 27
+		f.FuelInTank, f.TyresWear = 8.1, [4]byte{9, 9, 9, 9}
+	})
+	add(4, 0.1, 0, func(f *f1.Frame) { f.LastLapTime = 81 })
+	run := func(frames []f1.Frame) []archiveLapRow {
+		for _, frame := range frames {
+			if err := s.Frame(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		laps := archiveLaps(t, s.db)
+		for i := range laps {
+			// Stored as float32.
+			laps[i].FuelStart = math.Round(laps[i].FuelStart*10) / 10
+			laps[i].FuelEnd = math.Round(laps[i].FuelEnd*10) / 10
+		}
+		return laps
+	}
+	check := func(got, want []archiveLapRow) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("laps %+v, want %+v", got, want)
+		}
+	}
+
+	lap2 := archiveLapRow{Lap: 2, Time: 80.5, S1: split(25), S2: split(28), S3: split(27.5), Valid: true, Compound: "soft",
+		FuelStart: 10, FuelEnd: 9, WearStart: [4]int{1, 2, 3, 4}, WearEnd: [4]int{5, 6, 7, 8}}
+	lap3 := archiveLapRow{Lap: 3, Time: 82, S1: split(26), S2: split(27), S3: split(29), Compound: "soft",
+		FuelStart: 8.9, FuelEnd: 8, WearStart: [4]int{5, 6, 7, 8}, WearEnd: [4]int{9, 10, 11, 12}}
+	check(run(frames[:8]), []archiveLapRow{lap2, lap3})
+
+	// Driven again, lap 3 keeps the state it started with.
+	lap3.Time, lap3.S3, lap3.Valid, lap3.FuelEnd, lap3.WearEnd = 81, split(28), true, 8.1, [4]int{9, 9, 9, 9}
+	check(run(frames[8:]), []archiveLapRow{lap2, lap3})
+	checkArchiveStatus(t, s, "1 sessions, 3 laps, 4 car laps stored")
+}
+
+// TestArchiveCarLaps checks the laps of the other cars are stored from the
+// car data, each with the sectors it was last seen with.
+func TestArchiveCarLaps(t *testing.T) {
+	s, done := newTestArchive(t)
+	defer done()
+	session := f1.Session{ID: "s1", Source: "test", Start: testStart, Format: f1.Format{PacketFormat: 2018}}
+	if err := s.Event(f1.SessionEvent{Type: f1.SessionStart, Session: session}); err != nil {
+		t.Fatal(err)
+	}
+	for lap := 1; lap <= 3; lap++ {
+		for sector := 0; sector < 3; sector++ {
+			if err := s.Frame(archiveFrame(lap, float32(30*sector), sector)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// A car that isn't racing.
+	frame := archiveFrame(4, 0, 0)
+	frame.Cars[2] = f1.CarData{CurrentLapNum: 4, LastlapTime: 90}
+	if err := s.Frame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.db.Query(`SELECT car, lap, driver_id, driver, team_id, team, time, sector1, sector2, sector3, position, compound
+		FROM car_laps WHERE session_id = 's1' ORDER BY car, lap`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var laps []string
+	for rows.Next() {
+		var car, lap, driverID, teamID, position int
+		var driver, team, compound string
+		var time float64
+		var s1, s2, s3 sql.NullFloat64
+		if err := rows.Scan(&car, &lap, &driverID, &driver, &teamID, &team, &time, &s1, &s2, &s3, &position, &compound); err != nil {
+			t.Fatal(err)
+		}
+		laps = append(laps, fmt.Sprintf("%d %d %d %s %d %s %g %g %g %g %d %s",
+			car, lap, driverID, driver, teamID, team, time, s1.Float64, s2.Float64, s3.Float64, position, compound))
+	}
+	lap := func(n int) string {
+		return fmt.Sprintf("1 %d 9 %s 4 %s 81.25 25.5 28.5 27.25 2 medium", n, f1.Drivers[9], f1.Teams[4])
+	}
+	// The player's car has no last lap time, so only car 1 completes laps.
+	if want := []string{lap(1), lap(2), lap(3)}; !reflect.DeepEqual(laps, want) {
+		t.Fatalf("car laps %q, want %q", laps, want)
+	}
+	checkArchiveStatus(t, s, "1 sessions, 0 laps, 3 car laps stored")
+}
+
+func checkArchiveStatus(t *testing.T, s *Archive, want string) {
+	if status := s.Status(); status != want {
+		t.Fatalf("Status = %q, want %q", status, want)
+	}
+}
+
+func TestArchiveMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "archive.db")
+	version := func(db *sql.DB) int {
+		var v int
+		if err := db.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	db, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := version(db); v != len(migrations) {
+		t.Fatalf("user_version %d, want %d", v, len(migrations))
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, source, format, session_uid, start_time, track_number, track, session_type, era, total_laps)
+		VALUES ('s1', 'test', 'F1 2018', 1, '', 0, '', '', 2017, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Migrations added since are applied to the archive, keeping its data.
+	defer func(m []string) { migrations = m }(migrations)
+	migrations = append(migrations[:len(migrations):len(migrations)], `ALTER TABLE sessions ADD COLUMN notes TEXT`)
+	if db, err = OpenArchive(path); err != nil {
+		t.Fatal(err)
+	}
+	var id string
+	var notes sql.NullString
+	if err := db.QueryRow(`SELECT id, notes FROM sessions`).Scan(&id, &notes); err != nil || id != "s1" {
+		t.Fatalf("session %q, %v after migrating", id, err)
+	}
+	if v := version(db); v != len(migrations) {
+		t.Fatalf("user_version %d, want %d", v, len(migrations))
+	}
+
+	// A migration failing is rolled back, user_version included.
+	migrations = append(migrations, `CREATE TABLE laps (lap INTEGER)`)
+	if err := migrate(db); err == nil || !strings.Contains(err.Error(), "migration 3") {
+		t.Fatalf("migrate = %v, want migration 3 failing", err)
+	}
+	if v := version(db); v != 2 {
+		t.Fatalf("user_version %d after a failed migration, want 2", v)
+	}
+	db.Close()
+
+	// Archives written by a newer version are left alone.
+	migrations = migrations[:1]
+	if db, err := OpenArchive(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		if db != nil {
+			db.Close()
+		}
+		t.Fatalf("OpenArchive of a newer archive = %v, want an error", err)
+	}
+}
@@ -0,0 +1,210 @@
+package sink
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server side of the WebSocket protocol, RFC 6455, as much as the feed
+// needs: text messages out, and small messages, pings and closes in.
+
+// websocketGUID is appended to the key of a handshake to accept it.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	websocketContinuation = 0x0
+	websocketText         = 0x1
+	websocketBinary       = 0x2
+	websocketClose        = 0x8
+	websocketPing         = 0x9
+	websocketPong         = 0xa
+)
+
+const (
+	// websocketMaxMessage limits the size of the messages clients send,
+	// subscriptions being small.
+	websocketMaxMessage = 64 << 10
+	// websocketWriteTimeout is how long a client may take to receive a
+	// message before it is disconnected.
+	websocketWriteTimeout = 10 * time.Second
+)
+
+var errWebsocketMessageSize = errors.New("websocket: message too big")
+
+// A websocketConn is a WebSocket connection accepted by upgradeWebsocket. One
+// goroutine may read from it while others write.
+type websocketConn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	mu        sync.Mutex // held while writing a frame
+	closeOnce sync.Once
+}
+
+// upgradeWebsocket accepts the WebSocket handshake of r. If r isn't one, it
+// replies with an error and returns nil.
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) *websocketConn {
+	if r.Method != "GET" || !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket handshake expected", http.StatusBadRequest)
+		return nil
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket unsupported", http.StatusInternalServerError)
+		return nil
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " +
+		base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil
+	}
+	return &websocketConn{conn: conn, r: rw.Reader}
+}
+
+// headerHas reports whether the comma separated list of header name has
+// token, ignoring case.
+func headerHas(header http.Header, name, token string) bool {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeText sends a text message.
+func (c *websocketConn) writeText(b []byte) error {
+	return c.writeFrame(websocketText, b)
+}
+
+// writeFrame sends a whole message in a single frame, unmasked as servers do.
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	b := make([]byte, 0, len(payload)+10)
+	b = append(b, 0x80|opcode) // final frame
+	switch n := len(payload); {
+	case n < 126:
+		b = append(b, byte(n))
+	case n <= 0xffff:
+		b = append(b, 126, byte(n>>8), byte(n))
+	default:
+		b = append(b, 127)
+		b = b[:len(b)+8]
+		binary.BigEndian.PutUint64(b[len(b)-8:], uint64(n))
+	}
+	b = append(b, payload...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// readMessage returns the next data message the client sends, answering
+// pings along the way. It returns io.EOF once the client closes the
+// connection.
+func (c *websocketConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(c.r, h[:]); err != nil {
+			return nil, err
+		}
+		final, opcode := h[0]&0x80 != 0, h[0]&0x0f
+		if h[1]&0x80 == 0 {
+			return nil, errors.New("websocket: unmasked client frame")
+		}
+		n := uint64(h[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(c.r, b[:]); err != nil {
+				return nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(c.r, b[:]); err != nil {
+				return nil, err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if n > websocketMaxMessage || uint64(len(message))+n > websocketMaxMessage {
+			c.closeWith(1009) // message too big
+			return nil, errWebsocketMessageSize
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return nil, err
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case websocketPing:
+			if err := c.writeFrame(websocketPong, payload); err != nil {
+				return nil, err
+			}
+		case websocketPong:
+		case websocketClose:
+			c.close()
+			return nil, io.EOF
+		case websocketText, websocketBinary, websocketContinuation:
+			message = append(message, payload...)
+			if final {
+				return message, nil
+			}
+		default:
+			c.closeWith(1002) // protocol error
+			return nil, errors.New("websocket: unknown opcode")
+		}
+	}
+}
+
+// close closes the connection normally.
+func (c *websocketConn) close() {
+	c.closeWith(1000)
+}
+
+// closeWith sends a close frame with status code, if it can, and closes the
+// connection.
+func (c *websocketConn) closeWith(code uint16) {
+	c.closeOnce.Do(func() {
+		c.writeFrame(websocketClose, []byte{byte(code >> 8), byte(code)})
+		c.conn.Close()
+	})
+}
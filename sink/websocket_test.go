@@ -0,0 +1,463 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luan/f1-telemetry/f1"
+)
+
+// writeClientFrame writes a frame as clients do, masked.
+func writeClientFrame(w io.Writer, final bool, opcode byte, payload []byte) error {
+	b := []byte{opcode, 0x80}
+	if final {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b[1] |= byte(n)
+	case n <= 0xffff:
+		b[1] |= 126
+		b = append(b, byte(n>>8), byte(n))
+	default:
+		b[1] |= 127
+		b = append(b, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(b[len(b)-8:], uint64(n))
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	b = append(b, mask...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// readServerFrame reads a frame as clients do, checking it is a whole
+// unmasked message.
+func readServerFrame(r io.Reader) (byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	if h[0]&0x80 == 0 || h[1]&0x80 != 0 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	n := uint64(h[1])
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	payload := make([]byte, n)
+	_, err := io.ReadFull(r, payload)
+	return h[0] & 0x0f, payload, err
+}
+
+// dialWebsocket connects to the WebSocket at path of a test server, with
+// the Origin header origin if not empty, returning the response to the
+// handshake and, if accepted, the connection.
+func dialWebsocket(t *testing.T, server *httptest.Server, path, origin string) (*http.Response, net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	req, _ := http.NewRequest("GET", server.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return resp, nil, nil
+	}
+	return resp, conn, r
+}
+
+func TestWebsocketHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn := upgradeWebsocket(w, r); conn != nil {
+			conn.close()
+		}
+	}))
+	defer server.Close()
+
+	// The example of RFC 6455.
+	resp, conn, _ := dialWebsocket(t, server, "/", "")
+	if conn == nil {
+		t.Fatalf("handshake answered %s", resp.Status)
+	}
+	conn.Close()
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept %q", accept)
+	}
+	if !headerHas(resp.Header, "Connection", "upgrade") || !headerHas(resp.Header, "Upgrade", "websocket") {
+		t.Errorf("handshake response headers %v", resp.Header)
+	}
+
+	for _, test := range []struct {
+		method string
+		header map[string]string
+		status int
+	}{
+		{"GET", nil, http.StatusBadRequest},
+		{"POST", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"}, http.StatusBadRequest},
+		{"GET", map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"GET", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest(test.method, server.URL, nil)
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %v answered %s, want %d", test.method, test.header, resp.Status, test.status)
+		}
+	}
+}
+
+// pipeWebsocket returns the server side of a connection over a pipe, and the
+// client side.
+func pipeWebsocket() (*websocketConn, net.Conn) {
+	server, client := net.Pipe()
+	return &websocketConn{conn: server, r: bufio.NewReader(server)}, client
+}
+
+func TestWebsocketFrames(t *testing.T) {
+	conn, client := pipeWebsocket()
+	defer client.Close()
+
+	// Each length encoding, both ways.
+	for _, n := range []int{0, 125, 126, 0xffff, 0x10000} {
+		message := bytes.Repeat([]byte{'a' + byte(n%26)}, n)
+		errc := make(chan error, 1)
+		go func() { errc <- conn.writeText(message) }()
+		opcode, payload, err := readServerFrame(client)
+		if err != nil || <-errc != nil {
+			t.Fatalf("reading a message of %d bytes: %v", n, err)
+		}
+		if opcode != websocketText || !bytes.Equal(payload, message) {
+			t.Fatalf("sent %d bytes, received opcode %d, %d bytes", n, opcode, len(payload))
+		}
+		if n > websocketMaxMessage {
+			continue
+		}
+		go func() { errc <- writeClientFrame(client, true, websocketText, message) }()
+		got, err := conn.readMessage()
+		if err != nil || <-errc != nil {
+			t.Fatalf("reading a message of %d bytes: %v", n, err)
+		}
+		if !bytes.Equal(got, message) {
+			t.Fatalf("sent %d bytes, received %d", n, len(got))
+		}
+	}
+
+	// A fragmented message, with a ping in between answered.
+	pong := make(chan []byte, 1)
+	go func() {
+		writeClientFrame(client, false, websocketText, []byte(`{"rate"`))
+		writeClientFrame(client, true, websocketPing, []byte("ping"))
+		if opcode, payload, err := readServerFrame(client); err == nil && opcode == websocketPong {
+			pong <- payload
+		}
+		close(pong)
+		writeClientFrame(client, false, websocketContinuation, []byte(`:5`))
+		writeClientFrame(client, true, websocketContinuation, []byte(`}`))
+	}()
+	message, err := conn.readMessage()
+	if err != nil || string(message) != `{"rate":5}` {
+		t.Fatalf("readMessage = %q, %v", message, err)
+	}
+	if p := <-pong; string(p) != "ping" {
+		t.Fatalf("ping answered with %q", p)
+	}
+
+	// Closing, answered with a close.
+	closed := make(chan []byte, 1)
+	go func() {
+		writeClientFrame(client, true, websocketClose, []byte{0x03, 0xe8})
+		_, payload, _ := readServerFrame(client)
+		closed <- payload
+	}()
+	if _, err := conn.readMessage(); err != io.EOF {
+		t.Fatalf("readMessage after a close = %v, want EOF", err)
+	}
+	if code := <-closed; !bytes.Equal(code, []byte{0x03, 0xe8}) {
+		t.Fatalf("close answered with %x, want 1000", code)
+	}
+}
+
+func TestWebsocketBadFrames(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		frame []byte
+		code  uint16
+	}{
+		{"too big", []byte{0x81, 0xff, 0, 0, 0, 0, 0, 0x10, 0, 0}, 1009},
+		{"too big once continued", []byte{0x01, 0x80 | 126, 0xff, 0xff, 0, 0, 0, 0}, 1009},
+		{"unknown opcode", []byte{0x83, 0x80, 0, 0, 0, 0}, 1002},
+		{"unmasked", []byte{0x81, 0x00}, 0},
+	} {
+		conn, client := pipeWebsocket()
+		frame := test.frame
+		if test.name == "too big once continued" {
+			// A first fragment of 0xffff bytes.
+			frame = append(frame, make([]byte, 0xffff)...)
+			frame = append(frame, 0x80, 0x80|126, 0x00, 0x02, 0, 0, 0, 0)
+		}
+		closed := make(chan uint16, 1)
+		// Written on its own, as what follows the frame may be left unread.
+		go client.Write(frame)
+		go func() {
+			if opcode, payload, err := readServerFrame(client); err == nil && opcode == websocketClose && len(payload) == 2 {
+				closed <- binary.BigEndian.Uint16(payload)
+			}
+			close(closed)
+		}()
+		if _, err := conn.readMessage(); err == nil {
+			t.Errorf("%s: readMessage succeeded", test.name)
+		}
+		if test.code == 0 {
+			conn.conn.Close()
+		}
+		if code := <-closed; code != test.code {
+			t.Errorf("%s: closed with %d, want %d", test.name, code, test.code)
+		}
+		client.Close()
+	}
+}
+
+// TestFeedWebsocket runs a client of the feed over a WebSocket.
+func TestFeedWebsocket(t *testing.T) {
+	s, err := NewFeed(json.RawMessage(`{"rate": 0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s.mux)
+	defer server.Close()
+
+	resp, conn, r := dialWebsocket(t, server, "/ws?channels=speed&cars=false", "")
+	if conn == nil {
+		t.Fatalf("handshake answered %s", resp.Status)
+	}
+	defer conn.Close()
+	read := func() map[string]interface{} {
+		t.Helper()
+		opcode, b, err := readServerFrame(r)
+		if err != nil || opcode != websocketText {
+			t.Fatalf("reading a message: opcode %d, %v", opcode, err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		return m
+	}
+	if m := read(); m["type"] != "subscribed" || m["rate"] != 0.0 || m["cars"] != false {
+		t.Fatalf("first message %v, want the subscription of the query", m)
+	}
+
+	if err := writeClientFrame(conn, true, websocketText, []byte(`{"channels":["gear"],"rate":5}`)); err != nil {
+		t.Fatal(err)
+	}
+	m := read()
+	if channels, _ := m["channels"].([]interface{}); m["type"] != "subscribed" || m["rate"] != 5.0 ||
+		len(channels) != 1 || channels[0] != "gear" {
+		t.Fatalf("message %v, want the new subscription", m)
+	}
+	writeClientFrame(conn, true, websocketText, []byte(`{"rate":-1}`))
+	if m := read(); m["type"] != "error" {
+		t.Fatalf("message %v, want an error", m)
+	}
+
+	frame := f1.Frame{Source: "test", Session: "s1", Received: time.Unix(1500000000, 0)}
+	frame.Gear = 4
+	if err := s.Frame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if m := read(); m["type"] != "frame" || m["gear"] != 4.0 || m["speed"] != nil {
+		t.Fatalf("message %v, want a frame of the gear", m)
+	}
+	if status := s.Status(); status != "1 clients, 1 frames sent, 0 dropped" {
+		t.Errorf("Status = %q", status)
+	}
+}
+
+func TestFeedWebsocketOrigin(t *testing.T) {
+	s, err := NewFeed(json.RawMessage(`{"origins": ["http://grafana.local:3000/"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s.mux)
+	defer server.Close()
+
+	for _, test := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{server.URL, true},
+		{strings.ToUpper(server.URL), true},
+		{"http://grafana.local:3000", true},
+		{"http://evil.example", false},
+		{"http://grafana.local:3001", false},
+		{"null", false},
+	} {
+		resp, conn, _ := dialWebsocket(t, server, "/ws", test.origin)
+		if conn != nil {
+			conn.Close()
+		}
+		if ok := conn != nil; ok != test.ok {
+			t.Errorf("origin %q answered %s", test.origin, resp.Status)
+		} else if !ok && resp.StatusCode != http.StatusForbidden {
+			t.Errorf("origin %q answered %s, want 403", test.origin, resp.Status)
+		}
+	}
+
+	s, err = NewFeed(json.RawMessage(`{"origins": ["*"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	if !s.allowOrigin(r) {
+		t.Error("origin not allowed with *")
+	}
+}
+
+// derived returns the events derived from frames, decoded.
+func derived(t *testing.T, s *Feed, frames ...f1.Frame) []feedCarEvent {
+	var events []feedCarEvent
+	for i := range frames {
+		for _, b := range s.derive(&frames[i]) {
+			var e feedCarEvent
+			if err := json.Unmarshal(b, &e); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func checkEvents(t *testing.T, got, want []feedCarEvent) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("events %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("event %+v, want %+v", got[i], want[i])
+		}
+	}
+}
+
+// TestFeedEvents checks the lap, pit and position events derived from the
+// cars in the race.
+func TestFeedEvents(t *testing.T) {
+	s, err := NewFeed(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := time.Unix(1500000000, 0)
+	frame := func(n int) f1.Frame {
+		frame := f1.Frame{Format: f1.Format{PacketFormat: 2018}, Source: "test", Session: "s1", Received: received.Add(time.Duration(n) * time.Second)}
+		frame.PlayerCarIndex = 1
+		frame.Cars[0] = f1.CarData{CarPosition: 1, CurrentLapNum: 1, DriverID: 9, TeamID: 4}
+		frame.Cars[1] = f1.CarData{CarPosition: 2, CurrentLapNum: 1, DriverID: 7, TeamID: 1}
+		return frame
+	}
+	// Car 1 completes a lap and takes the lead as car 0 pits, then car 0
+	// leaves the pits. Car 2 isn't racing.
+	frames := []f1.Frame{frame(0), frame(1), frame(2), frame(3)}
+	frames[1].Cars[1] = f1.CarData{CarPosition: 1, CurrentLapNum: 2, LastlapTime: 81.5, DriverID: 7, TeamID: 1}
+	frames[1].Cars[0].CarPosition, frames[1].Cars[0].InPits = 2, 1
+	frames[2].Cars[1], frames[3].Cars[1] = frames[1].Cars[1], frames[1].Cars[1]
+	frames[2].Cars[0] = frames[1].Cars[0]
+	frames[3].Cars[0].CarPosition = 2
+	for i := range frames {
+		frames[i].Cars[2] = f1.CarData{CurrentLapNum: uint8(i), LastlapTime: 90}
+	}
+
+	at := func(n int) string { return received.Add(time.Duration(n) * time.Second).UTC().Format(time.RFC3339Nano) }
+	car0 := feedCarEvent{Session: "s1", Source: "test", Car: 0, Driver: f1.Drivers[9], Team: f1.Teams[4], Lap: 1}
+	car1 := feedCarEvent{Session: "s1", Source: "test", Car: 1, Player: true, Driver: f1.Drivers[7], Team: f1.Teams[1], Lap: 2}
+	pitEntry, position0 := car0, car0
+	pitEntry.Type, pitEntry.Received = "pit-entry", at(1)
+	position0.Type, position0.Received, position0.From, position0.To = "position", at(1), 1, 2
+	lap, position1 := car1, car1
+	lap.Type, lap.Received, lap.Lap, lap.Time, lap.Position = "lap", at(1), 1, 81.5, 1
+	position1.Type, position1.Received, position1.From, position1.To = "position", at(1), 2, 1
+	pitExit := car0
+	pitExit.Type, pitExit.Received = "pit-exit", at(3)
+	checkEvents(t, derived(t, s, frames...), []feedCarEvent{pitEntry, position0, lap, position1, pitExit})
+
+	// A new session starts over.
+	next := frame(4)
+	next.Session = "s2"
+	next.Cars[0].CarPosition = 2
+	checkEvents(t, derived(t, s, next), nil)
+}
+
+// TestFeedPlayerEvents checks the events of the player are derived from the
+// formats without the cars in the race.
+func TestFeedPlayerEvents(t *testing.T) {
+	s, err := NewFeed(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := time.Unix(1500000000, 0)
+	frame := func(n int, lap, position, inPits float32) f1.Frame {
+		frame := f1.Frame{Source: "test", Session: "s1", Received: received.Add(time.Duration(n) * time.Second)}
+		frame.Lap, frame.CarPosition, frame.InPits = lap, position, inPits
+		frame.LastLapTime = 80 + lap
+		return frame
+	}
+	frames := []f1.Frame{frame(0, 0, 3, 0), frame(1, 1, 3, 1), frame(2, 1, 2, 0)}
+	if frames[1].PlayerLap() != frames[0].PlayerLap()+1 {
+		t.Fatalf("player on lap %d then %d", frames[0].PlayerLap(), frames[1].PlayerLap())
+	}
+
+	at := func(n int) string { return received.Add(time.Duration(n) * time.Second).UTC().Format(time.RFC3339Nano) }
+	e := feedCarEvent{Session: "s1", Source: "test", Player: true, Lap: frames[1].PlayerLap()}
+	lap, pitEntry, pitExit, position := e, e, e, e
+	lap.Type, lap.Received, lap.Lap, lap.Time, lap.Position = "lap", at(1), frames[0].PlayerLap(), 81, 3
+	pitEntry.Type, pitEntry.Received = "pit-entry", at(1)
+	pitExit.Type, pitExit.Received = "pit-exit", at(2)
+	position.Type, position.Received, position.From, position.To = "position", at(2), 3, 2
+	checkEvents(t, derived(t, s, frames...), []feedCarEvent{lap, pitEntry, pitExit, position})
+}
@@ -50,12 +50,14 @@ const (
 )
 
 type UI struct {
-	dataChan  <-chan f1.TelemetryData
+	dataChan  <-chan message
 	speedUnit atomic.Value
 
 	components []termui.Bufferer
 
 	logoPar     *termui.Par
+	formatPar   *termui.Par
+	statusPar   *termui.Par
 	speedPar    *termui.Par
 	throttle    *termui.Gauge
 	brake       *termui.Gauge
@@ -64,10 +66,28 @@ type UI struct {
 
 	carPar *termui.Par
 
-	playerLaps [][4]float32
+	relays   relays
+	relayPar *termui.Par
+
+	subscribers   []*subscriber
+	subscriberPar *termui.Par
+
+	status func() string // shown next to the format, if set
+
+	fields     f1.Fields
+	playerLaps map[string][][4]float32    // keyed by source
+	sessions   map[string]f1.SessionEvent // last session event, keyed by source
+
+	source      string        // source shown
+	format      f1.Format     // of the source shown
+	conditions  f1.Conditions // of the source shown
+	sources     []string      // in the order they were first seen
+	sourceIndex int32         // index of the source to show, changed by the tab key
 }
 
-func NewUI(dataChan <-chan f1.TelemetryData) *UI {
+// NewUI creates a dashboard showing the frames received from dataChan, and
+// the state of relays and of the subscribers of the bus, if any.
+func NewUI(dataChan <-chan message, relays relays, subscribers []*subscriber) *UI {
 	err := termui.Init()
 	if err != nil {
 		log.Fatal(err)
@@ -77,6 +97,8 @@ func NewUI(dataChan <-chan f1.TelemetryData) *UI {
 		dataChan: dataChan,
 
 		logoPar:     termui.NewPar(""),
+		formatPar:   termui.NewPar("Waiting for telemetry"),
+		statusPar:   termui.NewPar(""),
 		speedPar:    termui.NewPar(""),
 		brake:       termui.NewGauge(),
 		throttle:    termui.NewGauge(),
@@ -85,13 +107,20 @@ func NewUI(dataChan <-chan f1.TelemetryData) *UI {
 
 		carPar: termui.NewPar(""),
 
-		playerLaps: [][4]float32{},
+		relays:   relays,
+		relayPar: termui.NewPar(""),
+
+		subscribers:   subscribers,
+		subscriberPar: termui.NewPar(""),
+
+		playerLaps: map[string][][4]float32{},
+		sessions:   map[string]f1.SessionEvent{},
 	}
 
 	ui.initColors()
 
 	ui.speedUnit.Store(KPH)
-	ui.components = []termui.Bufferer{ui.logoPar, ui.speedPar, ui.brake, ui.throttle, ui.driverTable, ui.lapsTable, ui.carPar}
+	ui.layout(f1.AllFields)
 
 	ui.logoPar.Height = 7
 	ui.logoPar.Width = 100
@@ -105,6 +134,18 @@ func NewUI(dataChan <-chan f1.TelemetryData) *UI {
     '.,(_)______(_).>  / ___/  / /                             |_|_____|_|
     ~~~~~~~~~~~~~~~~~~/_/~~~~~/_/~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~`
 
+	ui.formatPar.Height = 1
+	ui.formatPar.Width = 90
+	ui.formatPar.X = 0
+	ui.formatPar.Y = 0
+	ui.formatPar.Border = false
+
+	ui.statusPar.Height = 1
+	ui.statusPar.Width = 65
+	ui.statusPar.X = 95
+	ui.statusPar.Y = 0
+	ui.statusPar.Border = false
+
 	ui.speedPar.Height = 6
 	ui.speedPar.Width = 60
 	ui.speedPar.X = 0
@@ -141,6 +182,29 @@ func NewUI(dataChan <-chan f1.TelemetryData) *UI {
 	ui.carPar.Border = false
 	ui.carPar.Text = strings.Join(car, "\n")
 
+	ui.relayPar.Width = 60
+	ui.relayPar.Height = 2 + len(ui.relays)
+	ui.relayPar.X = 95
+	ui.relayPar.Y = 31
+	ui.relayPar.BorderLabel = "Relays"
+	ui.relayPar.BorderFg = termui.ColorWhite
+
+	ui.subscriberPar.Width = 80
+	ui.subscriberPar.Height = 2
+	for _, s := range ui.subscribers {
+		ui.subscriberPar.Height++
+		if s.status != nil {
+			ui.subscriberPar.Height++
+		}
+	}
+	ui.subscriberPar.X = 95
+	ui.subscriberPar.Y = 31
+	if len(ui.relays) > 0 {
+		ui.subscriberPar.Y += ui.relayPar.Height
+	}
+	ui.subscriberPar.BorderLabel = "Subscribers"
+	ui.subscriberPar.BorderFg = termui.ColorWhite
+
 	ui.lapsTable.Width = 60
 	ui.lapsTable.Height = 22
 	ui.lapsTable.X = 0
@@ -184,6 +248,10 @@ func (ui *UI) setupEvents() {
 		termui.StopLoop()
 	})
 
+	termui.Handle("/sys/kbd/<tab>", func(termui.Event) {
+		atomic.AddInt32(&ui.sourceIndex, 1)
+	})
+
 	termui.Handle("/sys/kbd/s", func(termui.Event) {
 		if ui.speedUnit.Load().(SpeedUnit) == MPH {
 			ui.speedUnit.Store(KPH)
@@ -193,6 +261,34 @@ func (ui *UI) setupEvents() {
 	})
 }
 
+// layout shows the components the game sends data for. Games using the
+// shorter extradata levels have no car array, so there are no laps or drivers
+// to show.
+func (ui *UI) layout(fields f1.Fields) {
+	ui.fields = fields
+	ui.components = []termui.Bufferer{ui.logoPar, ui.formatPar, ui.statusPar, ui.speedPar}
+	if fields.Has(f1.FieldInputs) {
+		ui.components = append(ui.components, ui.brake, ui.throttle)
+	}
+	if fields.Has(f1.FieldCars) {
+		ui.components = append(ui.components, ui.driverTable, ui.lapsTable)
+	}
+	if len(ui.relays) > 0 {
+		ui.components = append(ui.components, ui.relayPar)
+	}
+	if len(ui.subscribers) > 0 {
+		ui.components = append(ui.components, ui.subscriberPar)
+	}
+	ui.components = append(ui.components, ui.carPar)
+	termui.Clear()
+}
+
+// SetStatus sets a function returning a line of status, such as the state of
+// a replay, shown with every frame. It must be called before Start.
+func (ui *UI) SetStatus(status func() string) {
+	ui.status = status
+}
+
 func (ui *UI) render() {
 	termui.Render(ui.components...)
 }
@@ -200,71 +296,8 @@ func (ui *UI) render() {
 func (ui *UI) Start() {
 	defer termui.Close()
 
-	ui.processTelemetry(f1.TelemetryData{
-		Speed:          40,
-		Throttle:       1,
-		Brake:          1,
-		PlayerCarIndex: 0,
-		Cars: [20]f1.CarData{
-			{
-				CurrentLapNum: 1,
-				LastlapTime:   0,
-				Sector:        0,
-				Sector1Time:   0,
-				Sector2Time:   0,
-			},
-		},
-	})
-
-	ui.processTelemetry(f1.TelemetryData{
-		Speed:          40,
-		Throttle:       1,
-		Brake:          1,
-		PlayerCarIndex: 0,
-		Cars: [20]f1.CarData{
-			{
-				CurrentLapNum: 2,
-				LastlapTime:   75.1234,
-				Sector:        0,
-				Sector1Time:   30,
-				Sector2Time:   30,
-			},
-		},
-	})
-
-	ui.processTelemetry(f1.TelemetryData{
-		Speed:          40,
-		Throttle:       1,
-		Brake:          1,
-		PlayerCarIndex: 0,
-		Cars: [20]f1.CarData{
-			{
-				CurrentLapNum: 3,
-				LastlapTime:   72.4333,
-				Sector:        0,
-				Sector1Time:   28,
-				Sector2Time:   33,
-			},
-		},
-	})
-
-	ui.processTelemetry(f1.TelemetryData{
-		Speed:          40,
-		Throttle:       1,
-		Brake:          1,
-		PlayerCarIndex: 0,
-		Cars: [20]f1.CarData{
-			{
-				CurrentLapNum:  3,
-				LastlapTime:    72.4333,
-				Sector:         2,
-				Sector1Time:    29,
-				Sector2Time:    33,
-				CurrentlapTime: 70,
-			},
-		},
-	})
-
+	// Render the empty dashboard until the first frame arrives.
+	ui.processTelemetry(f1.TelemetryData{})
 	ui.render()
 
 	wg := sync.WaitGroup{}
@@ -282,9 +315,16 @@ func (ui *UI) Start() {
 
 		for {
 			select {
-			case telemetry := <-ui.dataChan:
-				ui.processTelemetry(telemetry)
-				ui.render()
+			case m := <-ui.dataChan:
+				render := false
+				if m.event != nil {
+					render = ui.processSessionEvent(*m.event)
+				} else {
+					render = ui.processFrame(m.frame)
+				}
+				if render {
+					ui.render()
+				}
 			case <-signal:
 				return
 			}
@@ -294,6 +334,86 @@ func (ui *UI) Start() {
 	wg.Wait()
 }
 
+// processFrame updates the lap times of the source of frame, and renders it
+// if it is the source shown.
+func (ui *UI) processFrame(frame f1.Frame) bool {
+	if _, ok := ui.playerLaps[frame.Source]; !ok {
+		ui.sources = append(ui.sources, frame.Source)
+		ui.playerLaps[frame.Source] = [][4]float32{}
+	}
+	ui.selectSource()
+	if frame.Source != ui.source {
+		ui.playerLaps[frame.Source] = processPlayerLap(ui.playerLaps[frame.Source], frame.TelemetryData)
+		return false
+	}
+
+	ui.format = frame.Format
+	ui.conditions = frame.Conditions
+	ui.renderFormat()
+	if ui.status != nil {
+		ui.statusPar.Text = ui.status()
+	}
+	if fields := frame.Format.Fields(); fields != ui.fields {
+		ui.layout(fields)
+	}
+	ui.processTelemetry(frame.TelemetryData)
+	return true
+}
+
+// processSessionEvent starts the lap times of the source of event over when
+// a session starts. It reports whether the source is shown.
+func (ui *UI) processSessionEvent(event f1.SessionEvent) bool {
+	source := event.Session.Source
+	ui.sessions[source] = event
+	if _, ok := ui.playerLaps[source]; ok && event.Type == f1.SessionStart {
+		ui.playerLaps[source] = [][4]float32{}
+	}
+	if source != ui.source {
+		return false
+	}
+
+	ui.renderFormat()
+	return true
+}
+
+// renderFormat describes the source shown, its format and session.
+func (ui *UI) renderFormat() {
+	session := ""
+	if event, ok := ui.sessions[ui.source]; ok {
+		session = "Session since " + event.Session.Start.Format("15:04:05")
+		if event.Type == f1.SessionEnd {
+			session = "Session ended " + event.Session.End.Format("15:04:05")
+		}
+	}
+	if ui.format.Fields().Has(f1.FieldConditions) {
+		c := ui.conditions
+		session += fmt.Sprintf("  Weather: %s, track %d°C, air %d°C", f1.WeatherName(c.Weather), c.TrackTemperature, c.AirTemperature)
+		if c.SafetyCarStatus != 0 {
+			session += ", safety car: " + f1.SafetyCarName(c.SafetyCarStatus)
+		}
+	}
+	ui.formatPar.Text = fmt.Sprintf("Source: %s (%d/%d, tab to switch)  Format: %s  %s",
+		ui.source, ui.indexOf(ui.source)+1, len(ui.sources), ui.format, session)
+}
+
+// selectSource switches to the source picked with the tab key.
+func (ui *UI) selectSource() {
+	i := int(atomic.LoadInt32(&ui.sourceIndex)) % len(ui.sources)
+	if ui.sources[i] != ui.source {
+		ui.source = ui.sources[i]
+		termui.Clear()
+	}
+}
+
+func (ui *UI) indexOf(source string) int {
+	for i, s := range ui.sources {
+		if s == source {
+			return i
+		}
+	}
+	return -1
+}
+
 func (ui *UI) processTelemetry(telemetry f1.TelemetryData) {
 	ui.speedPar.Text = ui.renderSpeed(telemetry.Speed)
 	ui.brake.Percent = int(100 * telemetry.Brake)
@@ -301,11 +421,14 @@ func (ui *UI) processTelemetry(telemetry f1.TelemetryData) {
 
 	sortedCars := sortCars(telemetry.Cars)
 
-	ui.processPlayerLap(telemetry)
-	ui.renderPlayerLaps()
+	ui.playerLaps[ui.source] = processPlayerLap(ui.playerLaps[ui.source], telemetry)
+	ui.renderPlayerLaps(ui.playerLaps[ui.source])
 
 	ui.renderCar(telemetry)
 
+	ui.renderRelays()
+	ui.renderSubscribers()
+
 	ui.renderCars(sortedCars, telemetry.TrackSize, byte(telemetry.SessionType))
 }
 
@@ -316,8 +439,30 @@ func (ui *UI) renderCar(telemetry f1.TelemetryData) {
 	ui.carPar.Text = strings.Join(carCopy, "\n")
 }
 
-func (ui *UI) renderPlayerLaps() {
-	ui.lapsTable.Rows = make([][]string, 2+len(ui.playerLaps))
+func (ui *UI) renderRelays() {
+	var lines []string
+	for _, r := range ui.relays {
+		lines = append(lines, r.String())
+	}
+	ui.relayPar.Text = strings.Join(lines, "\n")
+}
+
+func (ui *UI) renderSubscribers() {
+	var lines []string
+	for _, s := range ui.subscribers {
+		lines = append(lines, s.String())
+		if s.status != nil {
+			lines = append(lines, "  "+s.status())
+		}
+	}
+	ui.subscriberPar.Text = strings.Join(lines, "\n")
+}
+
+func (ui *UI) renderPlayerLaps(playerLaps [][4]float32) {
+	ui.lapsTable.Rows = make([][]string, 2+len(playerLaps))
+	// The table sizes its colors to its rows when first rendered.
+	ui.lapsTable.FgColors = nil
+	ui.lapsTable.BgColors = nil
 
 	ui.lapsTable.Rows[0] = []string{
 		"#",
@@ -342,9 +487,9 @@ func (ui *UI) renderPlayerLaps() {
 		float32(math.MaxFloat32),
 	}
 
-	for i, lap := range ui.playerLaps {
+	for i, lap := range playerLaps {
 		for j := range lap {
-			if i == len(ui.playerLaps)-1 &&
+			if i == len(playerLaps)-1 &&
 				(j == 3 || j == 2 || lap[j+1] == 0) {
 				continue
 			}
@@ -354,7 +499,7 @@ func (ui *UI) renderPlayerLaps() {
 		}
 	}
 
-	for i, lap := range ui.playerLaps {
+	for i, lap := range playerLaps {
 		s := []string{fmt.Sprintf("%2d", i+1), "", "", "", ""}
 		for j := range lap {
 			if lap[j] > 0 {
@@ -363,7 +508,7 @@ func (ui *UI) renderPlayerLaps() {
 			if lap[j] == lowest[j] {
 				s[j+1] = fmt.Sprintf("[%s](fg-magenta)", s[j+1])
 			}
-			if i == len(ui.playerLaps)-1 &&
+			if i == len(playerLaps)-1 &&
 				(j == 3 || j == 2 || lap[j+1] == 0) {
 				s[j+1] = fmt.Sprintf("[%s](fg-green)", s[j+1])
 			}
@@ -372,36 +517,43 @@ func (ui *UI) renderPlayerLaps() {
 	}
 }
 
-func (ui *UI) processPlayerLap(telemetry f1.TelemetryData) {
+func processPlayerLap(playerLaps [][4]float32, telemetry f1.TelemetryData) [][4]float32 {
+	if int(telemetry.PlayerCarIndex) >= len(telemetry.Cars) {
+		return playerLaps
+	}
 	playerCar := telemetry.Cars[telemetry.PlayerCarIndex]
-	for int(playerCar.CurrentLapNum) > len(ui.playerLaps) {
-		ui.playerLaps = append(ui.playerLaps, [4]float32{0, 0, 0, 0})
+	if playerCar.CurrentLapNum == 0 {
+		return playerLaps
+	}
+	for int(playerCar.CurrentLapNum) > len(playerLaps) {
+		playerLaps = append(playerLaps, [4]float32{0, 0, 0, 0})
 	}
 	if playerCar.CurrentLapNum >= 2 {
-		ui.playerLaps[playerCar.CurrentLapNum-2][3] = playerCar.LastlapTime
+		playerLaps[playerCar.CurrentLapNum-2][3] = playerCar.LastlapTime
 	}
-	ui.playerLaps[playerCar.CurrentLapNum-1][3] = playerCar.CurrentlapTime
+	playerLaps[playerCar.CurrentLapNum-1][3] = playerCar.CurrentlapTime
 	switch playerCar.Sector {
 	case 0:
 		if playerCar.CurrentLapNum >= 2 {
-			ui.playerLaps[playerCar.CurrentLapNum-2][0] = playerCar.Sector1Time
-			ui.playerLaps[playerCar.CurrentLapNum-2][1] = playerCar.Sector2Time
-			ui.playerLaps[playerCar.CurrentLapNum-2][2] = playerCar.LastlapTime - playerCar.Sector1Time - playerCar.Sector2Time
+			playerLaps[playerCar.CurrentLapNum-2][0] = playerCar.Sector1Time
+			playerLaps[playerCar.CurrentLapNum-2][1] = playerCar.Sector2Time
+			playerLaps[playerCar.CurrentLapNum-2][2] = playerCar.LastlapTime - playerCar.Sector1Time - playerCar.Sector2Time
 
-			ui.playerLaps[playerCar.CurrentLapNum-1][0] = playerCar.CurrentlapTime
+			playerLaps[playerCar.CurrentLapNum-1][0] = playerCar.CurrentlapTime
 		}
 	case 1:
 		if playerCar.CurrentLapNum >= 1 {
-			ui.playerLaps[playerCar.CurrentLapNum-1][0] = playerCar.Sector1Time
-			ui.playerLaps[playerCar.CurrentLapNum-1][1] = playerCar.CurrentlapTime - playerCar.Sector1Time
+			playerLaps[playerCar.CurrentLapNum-1][0] = playerCar.Sector1Time
+			playerLaps[playerCar.CurrentLapNum-1][1] = playerCar.CurrentlapTime - playerCar.Sector1Time
 		}
 	case 2:
 		if playerCar.CurrentLapNum >= 1 {
-			ui.playerLaps[playerCar.CurrentLapNum-1][0] = playerCar.Sector1Time
-			ui.playerLaps[playerCar.CurrentLapNum-1][1] = playerCar.Sector2Time
-			ui.playerLaps[playerCar.CurrentLapNum-1][2] = playerCar.CurrentlapTime - playerCar.Sector1Time - playerCar.Sector2Time
+			playerLaps[playerCar.CurrentLapNum-1][0] = playerCar.Sector1Time
+			playerLaps[playerCar.CurrentLapNum-1][1] = playerCar.Sector2Time
+			playerLaps[playerCar.CurrentLapNum-1][2] = playerCar.CurrentlapTime - playerCar.Sector1Time - playerCar.Sector2Time
 		}
 	}
+	return playerLaps
 }
 
 func (ui *UI) renderCars(sortedCars []f1.CarData, trackSize float32, sessionType byte) {